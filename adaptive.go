@@ -0,0 +1,68 @@
+package ratelim
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// An Adapter adapts the rate.Limiter of a key according to the responses received for that key. Each time the
+// Classifier reports a round trip as OutcomeThrottled, the limit is multiplied by Backoff; each time it reports
+// OutcomeSuccess, the limit is increased by Recovery until it is restored to the key's configured limit.
+//
+// Limiters whose configured limit is rate.Inf are never adapted, since there is no finite rate to back off from.
+type Adapter struct {
+	// Classifier classifies each round trip; if nil, StatusClassifier is used.
+	Classifier ResponseClassifier
+	// Backoff is the factor by which the limit is multiplied on each throttled response; if not within (0, 1), 0.5
+	// is used.
+	Backoff float64
+	// Recovery is the amount by which the limit is increased on each successful response; if not positive, one tenth
+	// of the configured limit is used.
+	Recovery rate.Limit
+	// MinLimit is the lowest limit the Adapter will back off to.
+	MinLimit rate.Limit
+}
+
+// Classify classifies the result of a round trip using the Adapter's Classifier.
+func (a *Adapter) Classify(resp *http.Response, err error) Outcome {
+	if a.Classifier == nil {
+		return StatusClassifier(resp, err)
+	}
+	return a.Classifier(resp, err)
+}
+
+// Adapt adjusts the limit of limiter, whose configured (i.e. maximum) limit is max, according to outcome.
+func (a *Adapter) Adapt(limiter *rate.Limiter, max rate.Limit, outcome Outcome) {
+	if max == rate.Inf {
+		return
+	}
+	current := limiter.Limit()
+	switch outcome {
+	case OutcomeThrottled:
+		backoff := a.Backoff
+		if backoff <= 0 || backoff >= 1 {
+			backoff = 0.5
+		}
+		limit := current * rate.Limit(backoff)
+		if limit < a.MinLimit {
+			limit = a.MinLimit
+		}
+		if limit != current {
+			limiter.SetLimit(limit)
+		}
+	case OutcomeSuccess:
+		if current >= max {
+			return
+		}
+		recovery := a.Recovery
+		if recovery <= 0 {
+			recovery = max / 10
+		}
+		limit := current + recovery
+		if limit > max {
+			limit = max
+		}
+		limiter.SetLimit(limit)
+	}
+}
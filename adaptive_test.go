@@ -0,0 +1,41 @@
+package ratelim
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestAdapter_Adapt(t *testing.T) {
+	a := &Adapter{MinLimit: 2}
+	limiter := rate.NewLimiter(10, 1)
+	steps := []struct {
+		outcome Outcome
+		want    rate.Limit
+	}{
+		{OutcomeThrottled, 5},
+		{OutcomeThrottled, 2.5},
+		{OutcomeThrottled, 2},
+		{OutcomeError, 2},
+		{OutcomeSuccess, 3},
+		{OutcomeSuccess, 4},
+	}
+	for i, step := range steps {
+		a.Adapt(limiter, 10, step.outcome)
+		if got := limiter.Limit(); got != step.want {
+			t.Fatalf("step %d: limit after %v = %v, want %v", i, step.outcome, got, step.want)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		a.Adapt(limiter, 10, OutcomeSuccess)
+	}
+	if got := limiter.Limit(); got != 10 {
+		t.Errorf("limit not restored: got %v, want %v", got, 10)
+	}
+
+	inf := rate.NewLimiter(rate.Inf, 0)
+	a.Adapt(inf, rate.Inf, OutcomeThrottled)
+	if got := inf.Limit(); got != rate.Inf {
+		t.Errorf("infinite limit adapted: got %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package ratelim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// The actions recorded in an AuditRecord.
+const (
+	// AuditPass is recorded for a request permitted without waiting, or with a wait below AuditLog.WaitThreshold.
+	AuditPass = "pass"
+	// AuditWait is recorded for a request permitted after waiting.
+	AuditWait = "wait"
+	// AuditReject is recorded for a request which failed before being permitted, e.g. because too many requests were
+	// waiting, its context was done or its quota was exceeded.
+	AuditReject = "reject"
+)
+
+// An AuditRecord is a line of an AuditLog: the decision taken for a request by a PerKeyRoundTripper.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Key    string    `json:"key"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Action string    `json:"action"`
+	// WaitMillis is how long the request waited to be permitted, in milliseconds.
+	WaitMillis float64 `json:"wait_ms"`
+	// Limit and Burst are those of the key's rate.Limiter when the decision was recorded. An infinite Limit, i.e.
+	// rate.Inf or more, is encoded as the string "inf", since JSON has no infinite numbers.
+	Limit float64 `json:"limit"`
+	Burst int     `json:"burst"`
+	// Error is the reason a request was rejected.
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes r as a JSON object, with an infinite Limit encoded as "inf".
+func (r AuditRecord) MarshalJSON() ([]byte, error) {
+	type record AuditRecord
+	return json.Marshal(
+		struct {
+			record
+			Limit auditLimit `json:"limit"`
+		}{record(r), auditLimit(r.Limit)},
+	)
+}
+
+// UnmarshalJSON decodes a JSON object encoded by MarshalJSON, in which Limit is either a number or "inf".
+func (r *AuditRecord) UnmarshalJSON(data []byte) error {
+	type record AuditRecord
+	v := struct {
+		*record
+		Limit auditLimit `json:"limit"`
+	}{record: (*record)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Limit = float64(v.Limit)
+	return nil
+}
+
+// An auditLimit is the Limit of an AuditRecord, encoded as a JSON number or, if infinite, as "inf", which is decoded
+// as rate.Inf.
+type auditLimit float64
+
+func (l auditLimit) MarshalJSON() ([]byte, error) {
+	if l >= auditLimit(rate.Inf) {
+		return []byte(`"inf"`), nil
+	}
+	return json.Marshal(float64(l))
+}
+
+func (l *auditLimit) UnmarshalJSON(data []byte) error {
+	if string(data) == `"inf"` {
+		*l = auditLimit(rate.Inf)
+		return nil
+	}
+	return json.Unmarshal(data, (*float64)(l))
+}
+
+// An AuditLog writes an AuditRecord for every decision taken by a PerKeyRoundTripper as a line of JSON, for audits and
+// the offline analysis of throttling behavior.
+type AuditLog struct {
+	// WaitThreshold is the wait below which a request is recorded as passing rather than waiting; if not positive,
+	// 1ms is used.
+	WaitThreshold time.Duration
+	// OnError, if non-nil, is called with the errors writing records, which are otherwise dropped.
+	OnError func(err error)
+	mux     sync.Mutex
+	w       io.Writer
+}
+
+// NewAuditLog returns an AuditLog writing to w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// Record writes r as a line of JSON.
+func (a *AuditLog) Record(r AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	_, err = a.w.Write(append(line, '\n'))
+	return err
+}
+
+func (a *AuditLog) waitThreshold() time.Duration {
+	if a.WaitThreshold > 0 {
+		return a.WaitThreshold
+	}
+	return time.Millisecond
+}
+
+// audit records the decision taken for req, a request for key: whether it was permitted, after waiting for wait, or
+// rejected with err.
+func (t *PerKeyRoundTripper[K]) audit(key K, req *http.Request, permitted bool, wait time.Duration, err error) {
+	cfg := t.LimiterConfig(key)
+	r := AuditRecord{
+		Time:       time.Now(),
+		Key:        fmt.Sprint(key),
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Action:     AuditPass,
+		WaitMillis: float64(wait) / float64(time.Millisecond),
+		Limit:      float64(cfg.Limit),
+		Burst:      cfg.Burst,
+	}
+	if limiter, ok := t.limiters.Load(key); ok {
+		r.Limit, r.Burst = float64(limiter.Limit()), limiter.Burst()
+	}
+	switch {
+	case !permitted:
+		r.Action = AuditReject
+		if err != nil {
+			r.Error = err.Error()
+		}
+	case wait >= t.Audit.waitThreshold():
+		r.Action = AuditWait
+	}
+	if err := t.Audit.Record(r); err != nil && t.Audit.OnError != nil {
+		t.Audit.OnError(err)
+	}
+}
@@ -0,0 +1,90 @@
+package ratelim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyRoundTripper_Audit(t *testing.T) {
+	var buf bytes.Buffer
+	rt := PerOriginRoundTripper(20, 1, &flakyTransport{})
+	rt.Audit = NewAuditLog(&buf)
+	send := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/a", nil)
+		if resp, err := rt.RoundTrip(req); err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+	send(context.Background())
+	send(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	send(ctx)
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	wantActions := []string{AuditPass, AuditWait, AuditReject}
+	if len(records) != len(wantActions) {
+		t.Fatalf("got %d records, want %d: %s", len(records), len(wantActions), buf.String())
+	}
+	for i, r := range records {
+		if r.Action != wantActions[i] {
+			t.Errorf("record %d: Action = %q, want %q", i, r.Action, wantActions[i])
+		}
+		if r.Key != "https://example.com" || r.Method != http.MethodGet || r.URL != "https://example.com/a" {
+			t.Errorf("record %d: Key, Method, URL = %q, %q, %q", i, r.Key, r.Method, r.URL)
+		}
+		if r.Limit != 20 || r.Burst != 1 {
+			t.Errorf("record %d: Limit, Burst = %v, %d, want 20, 1", i, r.Limit, r.Burst)
+		}
+	}
+	if records[1].WaitMillis <= 1 {
+		t.Errorf("waiting record: WaitMillis = %v, want > 1", records[1].WaitMillis)
+	}
+	if records[2].Error == "" {
+		t.Error("rejected record has no Error")
+	}
+}
+
+func TestPerKeyRoundTripper_AuditInfiniteLimit(t *testing.T) {
+	var buf bytes.Buffer
+	rt := PerOriginRoundTripper(rate.Inf, 0, &flakyTransport{})
+	rt.Audit = NewAuditLog(&buf)
+	rt.Audit.OnError = func(err error) {
+		t.Errorf("recording failed: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	// a limit beyond rate.Inf, which encoding/json cannot encode as a number
+	if err := rt.Audit.Record(AuditRecord{Key: "b", Limit: math.Inf(1)}); err != nil {
+		t.Fatalf("Record() with an infinite limit error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"limit":"inf"`) {
+		t.Errorf("record %s does not encode the limit as \"inf\"", buf.String())
+	}
+	records, err := ReadAuditLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Limit != float64(rate.Inf) || records[1].Limit != float64(rate.Inf) {
+		t.Errorf("ReadAuditLog() = %+v, want records with infinite limits", records)
+	}
+}
@@ -0,0 +1,113 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A Plan holds the dispatch times planned for a batch of requests by a Scheduler. The tokens for all the requests are
+// reserved when the plan is made, so each request may be sent at its planned time without waiting.
+type Plan struct {
+	// Times are the planned dispatch times of the requests, in order.
+	Times        []time.Time
+	reservations []*rate.Reservation
+}
+
+// Cancel returns the tokens reserved for requests whose planned time has not yet come to the limiter.
+func (p *Plan) Cancel() {
+	for _, r := range p.reservations {
+		r.Cancel()
+	}
+}
+
+// A Scheduler plans and executes batches of requests through a PerKeyRoundTripper, so that bulk jobs can plan their
+// work up front rather than have every request block in RoundTrip at once.
+type Scheduler[K comparable] struct {
+	Transport *PerKeyRoundTripper[K]
+}
+
+// NewScheduler returns a new Scheduler for requests sent through t.
+func NewScheduler[K comparable](t *PerKeyRoundTripper[K]) *Scheduler[K] {
+	return &Scheduler[K]{Transport: t}
+}
+
+// Plan reserves tokens from the limiter of key for n requests and returns their dispatch times, spread evenly between
+// the times the first and the last tokens are available rather than bunched up at the start by the limiter's burst.
+// Spreading the times never plans a request before its token is available.
+func (s *Scheduler[K]) Plan(key K, n int) (*Plan, error) {
+	limiter := s.Transport.limiter(key)
+	now := time.Now()
+	p := &Plan{
+		Times:        make([]time.Time, n),
+		reservations: make([]*rate.Reservation, 0, n),
+	}
+	for i := 0; i < n; i++ {
+		r := limiter.ReserveN(now, 1)
+		if !r.OK() {
+			p.Cancel()
+			return nil, fmt.Errorf("ratelim: cannot plan %d requests for key %v: limiter permits no requests", n, key)
+		}
+		p.reservations = append(p.reservations, r)
+	}
+	if n == 0 {
+		return p, nil
+	}
+	// the tokens of a bucket are available at once up to its burst, then at a constant interval, so times spread
+	// evenly from the first token to the last are never before their tokens
+	first, last := p.reservations[0].DelayFrom(now), p.reservations[n-1].DelayFrom(now)
+	for i := range p.Times {
+		if n == 1 {
+			p.Times[i] = now.Add(first)
+			continue
+		}
+		p.Times[i] = now.Add(first + (last-first)*time.Duration(i)/time.Duration(n-1))
+	}
+	return p, nil
+}
+
+// A BatchResult holds the result of a request executed by a Scheduler.
+type BatchResult struct {
+	Response *http.Response
+	Err      error
+}
+
+// Execute plans the dispatch of reqs, which must all map to key, and sends each through the Scheduler's Transport at
+// its planned time. It returns the results in the order of reqs once all requests have completed; if ctx is done
+// first, the requests not yet sent fail with ctx.Err() and their tokens are returned to the limiter.
+func (s *Scheduler[K]) Execute(ctx context.Context, key K, reqs []*http.Request) ([]BatchResult, error) {
+	for i, req := range reqs {
+		if k := s.Transport.Key(req); k != key {
+			return nil, fmt.Errorf("ratelim: request %d has key %v, not %v", i, k, key)
+		}
+	}
+	plan, err := s.Plan(key, len(reqs))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(reqs))
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			timer := time.NewTimer(time.Until(plan.Times[i]))
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				plan.reservations[i].Cancel()
+				results[i].Err = ctx.Err()
+				return
+			case <-timer.C:
+			}
+			req := reqs[i].WithContext(WithReservation(reqs[i].Context(), plan.reservations[i]))
+			results[i].Response, results[i].Err = s.Transport.RoundTrip(req)
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
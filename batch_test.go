@@ -0,0 +1,94 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Plan(t *testing.T) {
+	transport := PerOriginRoundTripper(10, 3, nil)
+	s := NewScheduler(transport)
+	start := time.Now()
+	plan, err := s.Plan("k", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 tokens from the burst and 5 more at 100ms intervals: the last is available after 500ms
+	last := plan.Times[len(plan.Times)-1].Sub(start)
+	if last < 490*time.Millisecond || last > 510*time.Millisecond {
+		t.Errorf("last request planned after %v, want 500ms", last)
+	}
+	for i := 1; i < len(plan.Times); i++ {
+		gap := plan.Times[i].Sub(plan.Times[i-1])
+		if gap < 70*time.Millisecond || gap > 73*time.Millisecond {
+			t.Errorf("gap between requests %d and %d = %v, want ~71ms", i-1, i, gap)
+		}
+	}
+	plan.Cancel()
+}
+
+func TestScheduler_PlanEmptyBucket(t *testing.T) {
+	transport := PerOriginRoundTripper(10, 3, nil)
+	transport.limiter("k").AllowN(time.Now(), 3)
+	s := NewScheduler(transport)
+	plan, err := s.Plan("k", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plan.Cancel()
+	// the tokens are available after 100, 200, 300 and 400ms
+	for i, r := range plan.reservations {
+		if token := time.Now().Add(r.Delay()); plan.Times[i].Before(token.Add(-time.Millisecond)) {
+			t.Errorf("request %d planned %v before its token", i, token.Sub(plan.Times[i]))
+		}
+	}
+	if first := time.Until(plan.Times[0]); first < 90*time.Millisecond {
+		t.Errorf("first request planned in %v, want ~100ms", first)
+	}
+}
+
+func TestScheduler_Execute(t *testing.T) {
+	var mux sync.Mutex
+	var arrivals []time.Time
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				mux.Lock()
+				defer mux.Unlock()
+				arrivals = append(arrivals, time.Now())
+			},
+		),
+	)
+	defer ts.Close()
+	transport := PerOriginRoundTripper(20, 1, nil)
+	reqs := make([]*http.Request, 5)
+	for i := range reqs {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%d", ts.URL, i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i] = req
+	}
+	start := time.Now()
+	results, err := NewScheduler(transport).Execute(context.Background(), transport.Key(reqs[0]), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("request %d: %v", i, result.Err)
+		}
+		_ = result.Response.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("batch executed in %v, want ~200ms", elapsed)
+	}
+	if len(arrivals) != len(reqs) {
+		t.Errorf("server received %d requests, want %d", len(arrivals), len(reqs))
+	}
+}
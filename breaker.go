@@ -0,0 +1,275 @@
+package ratelim
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A BreakerState is the state of the circuit of a key of a Breaker.
+type BreakerState int32
+
+const (
+	// BreakerClosed permits all requests; it is the initial state.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all requests until the Breaker's OpenDuration has elapsed.
+	BreakerOpen
+	// BreakerHalfOpen permits a limited number of probe requests, whose outcomes close or reopen the circuit.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int32(s))
+}
+
+// A BreakerOpenError is returned for a request rejected by a Breaker.
+type BreakerOpenError struct {
+	Key   any
+	State BreakerState
+	// Until is when the circuit becomes half-open, or the zero time.Time if it is half-open already and the request
+	// was not selected as a probe.
+	Until time.Time
+}
+
+func (e *BreakerOpenError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("ratelim: circuit for key %v is %s", e.Key, e.State)
+	}
+	return fmt.Sprintf("ratelim: circuit for key %v is %s until %s", e.Key, e.State, e.Until.Format(time.RFC3339))
+}
+
+// A BreakerTransition is a change of the state of the circuit of a key.
+type BreakerTransition[K comparable] struct {
+	Key      K
+	From, To BreakerState
+	At       time.Time
+}
+
+// HalfOpenConfig configures how a Breaker probes whether the upstream of a key has recovered once its OpenDuration
+// has elapsed.
+type HalfOpenConfig struct {
+	// MaxProbes is the maximum number of probe requests in flight at once; if not positive, 1 is used. Further
+	// requests are rejected until a probe completes.
+	MaxProbes int
+	// SuccessThreshold is the number of successful probes which close the circuit; if not positive, 1 is used. Any
+	// failed probe reopens it.
+	SuccessThreshold int
+	// SelectProbe, if non-nil, selects the requests which may probe, e.g. only idempotent or health check requests;
+	// the others are rejected while the circuit is half-open.
+	SelectProbe func(req *http.Request) bool
+}
+
+// A Breaker is a circuit breaker per key: after FailureThreshold consecutive failed requests for a key, its circuit
+// opens and its requests are rejected with a *BreakerOpenError for OpenDuration, sparing an upstream which is down the
+// load of requests bound to fail. The circuit then becomes half-open, permitting probe requests as configured by
+// HalfOpen, until enough probes succeed to close it or one fails and reopens it.
+type Breaker[K comparable] struct {
+	// FailureThreshold is the number of consecutive failed requests which open the circuit; if not positive, 5 is
+	// used.
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before becoming half-open; if not positive, 30 seconds is used.
+	OpenDuration time.Duration
+	HalfOpen     HalfOpenConfig
+	// Classifier classifies each round trip, of which OutcomeError is a failure; if nil, StatusClassifier is used.
+	Classifier ResponseClassifier
+	// OnStateChange, if non-nil, is called with each transition of the circuit of a key, e.g. to alert when a circuit
+	// opens. It is called synchronously, by the request causing the transition.
+	OnStateChange func(transition BreakerTransition[K])
+	states        *syncmap.SyncMap[K, *breakerState]
+	now           func() time.Time
+}
+
+type breakerState struct {
+	mux        sync.Mutex
+	state      BreakerState
+	generation uint64 // incremented on each transition, so that outcomes from a previous state are ignored
+	failures   int
+	openedAt   time.Time
+	probes     int
+	probesOK   int
+	changes    []breakerChange // transitions not yet notified
+}
+
+type breakerChange struct {
+	from, to BreakerState
+}
+
+// NewBreaker returns a new Breaker with default parameters.
+func NewBreaker[K comparable]() *Breaker[K] {
+	return &Breaker[K]{
+		states: syncmap.New[K, *breakerState](),
+		now:    time.Now,
+	}
+}
+
+// A BreakerPermit is returned by Breaker.Allow for a permitted request. Its outcome must be reported by Done once it
+// completes, or Cancel if it is not sent.
+type BreakerPermit[K comparable] struct {
+	breaker    *Breaker[K]
+	key        K
+	state      *breakerState
+	generation uint64
+	probe      bool
+	once       sync.Once
+}
+
+// State returns the state of the circuit of key.
+func (b *Breaker[K]) State(key K) BreakerState {
+	s, ok := b.states.Load(key)
+	if !ok {
+		return BreakerClosed
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == BreakerOpen && !b.now().Before(s.openedAt.Add(b.openDuration())) {
+		return BreakerHalfOpen
+	}
+	return s.state
+}
+
+// Classify classifies the result of a round trip using the Breaker's Classifier.
+func (b *Breaker[K]) Classify(resp *http.Response, err error) Outcome {
+	if b.Classifier == nil {
+		return StatusClassifier(resp, err)
+	}
+	return b.Classifier(resp, err)
+}
+
+func (b *Breaker[K]) openDuration() time.Duration {
+	return orDefault(b.OpenDuration, 30*time.Second, b.OpenDuration > 0)
+}
+
+// Allow returns a permit for req, a request for key, or a *BreakerOpenError if the circuit of key rejects it.
+func (b *Breaker[K]) Allow(key K, req *http.Request) (*BreakerPermit[K], error) {
+	s := loadOrCompute[K](b.states, key, newValue[breakerState])
+	s.mux.Lock()
+	now := b.now()
+	permit, err := b.allow(s, key, req, now)
+	changes := s.takeChanges()
+	s.mux.Unlock()
+	b.notify(key, changes, now)
+	return permit, err
+}
+
+// allow implements Allow; s.mux must be held.
+func (b *Breaker[K]) allow(s *breakerState, key K, req *http.Request, now time.Time) (*BreakerPermit[K], error) {
+	if s.state == BreakerOpen {
+		if until := s.openedAt.Add(b.openDuration()); now.Before(until) {
+			return nil, &BreakerOpenError{Key: key, State: BreakerOpen, Until: until}
+		}
+		s.transition(BreakerHalfOpen)
+	}
+	permit := &BreakerPermit[K]{breaker: b, key: key, state: s, generation: s.generation}
+	if s.state == BreakerHalfOpen {
+		selected := b.HalfOpen.SelectProbe == nil || b.HalfOpen.SelectProbe(req)
+		if !selected || s.probes >= orDefault(b.HalfOpen.MaxProbes, 1, b.HalfOpen.MaxProbes > 0) {
+			return nil, &BreakerOpenError{Key: key, State: BreakerHalfOpen}
+		}
+		s.probes++
+		permit.probe = true
+	}
+	return permit, nil
+}
+
+// Done reports the outcome of the permitted request, which failed if failed is true. Only the first call to Done or
+// Cancel has an effect.
+func (p *BreakerPermit[K]) Done(failed bool) {
+	p.once.Do(
+		func() {
+			p.breaker.observe(p, failed, true)
+		},
+	)
+}
+
+// Cancel reports that the permitted request was not sent, releasing its probe slot if it was a probe. Only the first
+// call to Done or Cancel has an effect.
+func (p *BreakerPermit[K]) Cancel() {
+	p.once.Do(
+		func() {
+			p.breaker.observe(p, false, false)
+		},
+	)
+}
+
+// observe records the outcome of the request of p, if it was sent.
+func (b *Breaker[K]) observe(p *BreakerPermit[K], failed, sent bool) {
+	s := p.state
+	s.mux.Lock()
+	now := b.now()
+	if p.probe && p.generation == s.generation {
+		s.probes--
+	}
+	if sent && p.generation == s.generation {
+		switch {
+		case s.state == BreakerClosed && failed:
+			if s.failures++; s.failures >= orDefault(b.FailureThreshold, 5, b.FailureThreshold > 0) {
+				s.openedAt = now
+				s.transition(BreakerOpen)
+			}
+		case s.state == BreakerClosed:
+			s.failures = 0
+		case s.state == BreakerHalfOpen && failed:
+			s.openedAt = now
+			s.transition(BreakerOpen)
+		case s.state == BreakerHalfOpen:
+			threshold := b.HalfOpen.SuccessThreshold
+			if s.probesOK++; s.probesOK >= orDefault(threshold, 1, threshold > 0) {
+				s.transition(BreakerClosed)
+			}
+		}
+	}
+	changes := s.takeChanges()
+	s.mux.Unlock()
+	b.notify(p.key, changes, now)
+}
+
+// transition moves s to state, resetting its counters; s.mux must be held.
+func (s *breakerState) transition(state BreakerState) {
+	s.changes = append(s.changes, breakerChange{from: s.state, to: state})
+	s.state = state
+	s.generation++
+	s.failures, s.probes, s.probesOK = 0, 0, 0
+}
+
+// takeChanges returns and clears the transitions of s not yet notified; s.mux must be held.
+func (s *breakerState) takeChanges() []breakerChange {
+	changes := s.changes
+	s.changes = nil
+	return changes
+}
+
+// notify calls OnStateChange with the transitions of the circuit of key, without holding its lock.
+func (b *Breaker[K]) notify(key K, changes []breakerChange, now time.Time) {
+	if b.OnStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		b.OnStateChange(BreakerTransition[K]{Key: key, From: c.from, To: c.to, At: now})
+	}
+}
+
+// Reset closes the circuit of key.
+func (b *Breaker[K]) Reset(key K) {
+	s, ok := b.states.Load(key)
+	if !ok {
+		return
+	}
+	s.mux.Lock()
+	if s.state != BreakerClosed {
+		s.transition(BreakerClosed)
+	}
+	changes := s.takeChanges()
+	s.mux.Unlock()
+	b.notify(key, changes, b.now())
+}
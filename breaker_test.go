@@ -0,0 +1,109 @@
+package ratelim
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker[string]()
+	b.now = func() time.Time {
+		return now
+	}
+	b.FailureThreshold = 2
+	b.OpenDuration = time.Minute
+	b.HalfOpen = HalfOpenConfig{
+		MaxProbes:        1,
+		SuccessThreshold: 2,
+		SelectProbe: func(req *http.Request) bool {
+			return req.Method == http.MethodGet
+		},
+	}
+	var transitions []string
+	b.OnStateChange = func(tr BreakerTransition[string]) {
+		transitions = append(transitions, tr.From.String()+">"+tr.To.String())
+	}
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com/", nil)
+	allow := func(req *http.Request) *BreakerPermit[string] {
+		t.Helper()
+		p, err := b.Allow("a", req)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		return p
+	}
+	reject := func(req *http.Request, want BreakerState) {
+		t.Helper()
+		var open *BreakerOpenError
+		if _, err := b.Allow("a", req); !errors.As(err, &open) || open.State != want {
+			t.Fatalf("Allow() error = %v, want a *BreakerOpenError in state %v", err, want)
+		}
+	}
+
+	// a success resets the count of consecutive failures
+	allow(get).Done(true)
+	allow(get).Done(false)
+	allow(get).Done(true)
+	if state := b.State("a"); state != BreakerClosed {
+		t.Fatalf("State() = %v, want %v", state, BreakerClosed)
+	}
+	allow(get).Done(true)
+	reject(get, BreakerOpen)
+
+	now = now.Add(time.Minute)
+	if state := b.State("a"); state != BreakerHalfOpen {
+		t.Fatalf("State() = %v after OpenDuration, want %v", state, BreakerHalfOpen)
+	}
+	reject(post, BreakerHalfOpen)
+	probe := allow(get)
+	reject(get, BreakerHalfOpen)
+	probe.Cancel()
+	probe.Done(true) // ignored after Cancel
+	allow(get).Done(false)
+	allow(get).Done(true)
+	reject(get, BreakerOpen)
+
+	now = now.Add(time.Minute)
+	allow(get).Done(false)
+	allow(get).Done(false)
+	if state := b.State("a"); state != BreakerClosed {
+		t.Fatalf("State() = %v after successful probes, want %v", state, BreakerClosed)
+	}
+	want := []string{
+		"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed",
+	}
+	if !reflect.DeepEqual(transitions, want) {
+		t.Errorf("transitions = %q, want %q", transitions, want)
+	}
+	if state := b.State("b"); state != BreakerClosed {
+		t.Errorf("State() of another key = %v, want %v", state, BreakerClosed)
+	}
+}
+
+func TestPerKeyRoundTripper_Breaker(t *testing.T) {
+	transport := &flakyTransport{failures: 3}
+	rt := PerOriginRoundTripper(1000, 10, transport)
+	rt.Breaker = NewBreaker[string]()
+	rt.Breaker.FailureThreshold = 3
+	for range 3 {
+		if _, err := rt.RoundTrip(mustNewRequest(t)); err == nil {
+			t.Fatal("RoundTrip() succeeded, want the transport's error")
+		}
+	}
+	var open *BreakerOpenError
+	if _, err := rt.RoundTrip(mustNewRequest(t)); !errors.As(err, &open) {
+		t.Fatalf("RoundTrip() error = %v, want a *BreakerOpenError", err)
+	}
+	if transport.requests != 3 {
+		t.Errorf("transport sent %d requests, want 3", transport.requests)
+	}
+	rt.Breaker.Reset("https://example.com")
+	if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+		t.Errorf("RoundTrip() after Reset error = %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// A BudgetExhaustedError is returned for a request whose context's budget, set by WithBudget, has been spent.
+type BudgetExhaustedError struct {
+	Budget int
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	return fmt.Sprintf("ratelim: request budget of %d exhausted", e.Budget)
+}
+
+type budget struct {
+	n         int
+	remaining atomic.Int64
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a copy of ctx which permits at most n requests to be sent through a PerKeyRoundTripper, whatever
+// their key, e.g. to bound the requests issued by one user-triggered operation. Each request sent, including each
+// retry, spends one request, while requests rejected or canceled before being sent spend none; once the budget is
+// spent, requests fail with a *BudgetExhaustedError. The budget is shared by all
+// requests using ctx or a context derived from it, unless a new budget is set.
+func WithBudget(ctx context.Context, n int) context.Context {
+	b := &budget{n: n}
+	b.remaining.Store(int64(n))
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetRemaining returns the number of requests left in the budget of ctx, and false if it has none.
+func BudgetRemaining(ctx context.Context) (remaining int, ok bool) {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return 0, false
+	}
+	return max(int(b.remaining.Load()), 0), true
+}
+
+// checkBudget fails if the budget of ctx, if any, has been spent, without spending it.
+func checkBudget(ctx context.Context) error {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if ok && b.remaining.Load() <= 0 {
+		return &BudgetExhaustedError{Budget: b.n}
+	}
+	return nil
+}
+
+// refundBudget gives back a request spent from the budget of ctx, if any, for a request which was not sent after all.
+func refundBudget(ctx context.Context) {
+	if b, ok := ctx.Value(budgetKey{}).(*budget); ok {
+		b.remaining.Add(1)
+	}
+}
+
+// spendBudget spends one request of the budget of ctx, if any, failing if it has been spent. It is called just before
+// a request is sent, so that requests rejected by other limits spend none.
+func spendBudget(ctx context.Context) error {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return nil
+	}
+	if b.remaining.Add(-1) < 0 {
+		b.remaining.Add(1)
+		return &BudgetExhaustedError{Budget: b.n}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithBudget(t *testing.T) {
+	transport := &flakyTransport{failures: 1}
+	rt := PerOriginRoundTripper(1000, 10, transport)
+	rt.Retry = &RetryPolicy{MaxRetries: 5}
+	ctx := WithBudget(context.Background(), 3)
+	get := func() error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/", nil)
+		_, err := rt.RoundTrip(req)
+		return err
+	}
+	// the first request fails once and is retried, spending 2 of the budget
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, ok := BudgetRemaining(ctx); !ok || remaining != 1 {
+		t.Errorf("BudgetRemaining() = %d, %t, want 1, true", remaining, ok)
+	}
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	var exhausted *BudgetExhaustedError
+	if err := get(); !errors.As(err, &exhausted) || exhausted.Budget != 3 {
+		t.Errorf("RoundTrip() error = %v, want *BudgetExhaustedError", err)
+	}
+	if transport.requests != 3 {
+		t.Errorf("transport sent %d requests, want 3", transport.requests)
+	}
+	if _, ok := BudgetRemaining(context.Background()); ok {
+		t.Error("BudgetRemaining() of a context without budget reports ok")
+	}
+}
+
+func TestWithBudget_notSent(t *testing.T) {
+	transport := &flakyTransport{}
+	rt := PerOriginRoundTripper(1000, 10, transport)
+	key := "https://example.com"
+	rt.SetLimiterConfig(key, LimiterConfig{Limit: 1000, Burst: 10, MaxWaiters: 1})
+	loadOrCompute[string](rt.waiters, key, newValue[atomic.Int64]).Add(1)
+	ctx := WithBudget(context.Background(), 1)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, key+"/", nil)
+	var tooMany *TooManyWaitersError
+	if _, err := rt.RoundTrip(req); !errors.As(err, &tooMany) {
+		t.Fatalf("RoundTrip() error = %v, want a *TooManyWaitersError", err)
+	}
+	if remaining, _ := BudgetRemaining(ctx); remaining != 1 {
+		t.Errorf("BudgetRemaining() = %d after a rejected request, want 1", remaining)
+	}
+	rt.waiters.Delete(key)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := BudgetRemaining(ctx); remaining != 0 || transport.requests != 1 {
+		t.Errorf("BudgetRemaining() = %d after %d requests sent, want 0 after 1", remaining, transport.requests)
+	}
+}
+
+func TestWithBudget_retryNotSent(t *testing.T) {
+	transport := &flakyTransport{failures: 1}
+	rt := PerOriginRoundTripper(1, 1, transport)
+	rt.Retry = &RetryPolicy{MaxRetries: 1}
+	ctx, cancel := context.WithTimeout(WithBudget(context.Background(), 2), 50*time.Millisecond)
+	defer cancel()
+	// the retry of the failed request cannot be permitted by the limiter before the deadline
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/", nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() succeeded without the retry")
+	}
+	if remaining, _ := BudgetRemaining(ctx); remaining != 1 || transport.requests != 1 {
+		t.Errorf("BudgetRemaining() = %d after %d requests sent, want 1 after 1", remaining, transport.requests)
+	}
+}
@@ -0,0 +1,187 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A ByteBudgetExceededError is returned for a request whose key has received its ByteBudget for the current period.
+type ByteBudgetExceededError struct {
+	Key   string
+	Limit int64
+	// Reset is the end of the period, when the budget is replenished.
+	Reset time.Time
+}
+
+func (e *ByteBudgetExceededError) Error() string {
+	return fmt.Sprintf("ratelim: budget of %d response bytes for key %s exceeded until %v", e.Limit, e.Key, e.Reset)
+}
+
+// A ByteBudget limits the response body bytes received for each key to Limit per calendar Period, for APIs metered by
+// the data transferred and backends billed by bandwidth. Since the size of a response is only known once it has been
+// read, the budget is checked before each request is sent, and the last response of a period may exceed it.
+type ByteBudget struct {
+	Limit  int64
+	Period QuotaPeriod
+	// Location is the time zone of the calendar; if nil, UTC is used.
+	Location *time.Location
+	// SlowAt, if within (0, 1), is the fraction of the Limit beyond which the requests of a key are spaced out so that,
+	// at the average size of its responses so far in the period, the rest of its budget lasts until the period ends,
+	// rather than being exhausted early.
+	SlowAt float64
+	// now returns the current time; it is replaced in tests.
+	now       func() time.Time
+	usageOnce sync.Once
+	usage     *syncmap.SyncMap[string, *byteUsage]
+}
+
+// byteUsage is the usage of a ByteBudget by a key in a period.
+type byteUsage struct {
+	mux      sync.Mutex
+	period   time.Time
+	bytes    int64
+	requests int64
+	// next is the earliest time at which the next request may be sent while the key is slowed
+	next time.Time
+}
+
+// NewByteBudget returns a ByteBudget of limit bytes per period.
+func NewByteBudget(limit int64, period QuotaPeriod) *ByteBudget {
+	return &ByteBudget{Limit: limit, Period: period}
+}
+
+// current returns the current time and the start of the current period.
+func (b *ByteBudget) current() (now, period time.Time) {
+	now = time.Now()
+	if b.now != nil {
+		now = b.now()
+	}
+	loc := b.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	return now, b.Period.Start(now)
+}
+
+// keyUsage returns the usage of key, locked and reset if it is of an earlier period than period.
+func (b *ByteBudget) keyUsage(key string, period time.Time) *byteUsage {
+	b.usageOnce.Do(
+		func() {
+			b.usage = syncmap.New[string, *byteUsage]()
+		},
+	)
+	u := loadOrCompute[string](b.usage, key, newValue[byteUsage])
+	u.mux.Lock()
+	if !u.period.Equal(period) {
+		u.period, u.bytes, u.requests, u.next = period, 0, 0, time.Time{}
+	}
+	return u
+}
+
+// Used returns the response body bytes received for key in the current period.
+func (b *ByteBudget) Used(key string) int64 {
+	_, period := b.current()
+	u := b.keyUsage(key, period)
+	defer u.mux.Unlock()
+	return u.bytes
+}
+
+// Remaining returns the response body bytes left in the budget of key for the current period.
+func (b *ByteBudget) Remaining(key string) int64 {
+	return max(b.Limit-b.Used(key), 0)
+}
+
+// Add records n response body bytes received for key.
+func (b *ByteBudget) Add(key string, n int64) {
+	_, period := b.current()
+	u := b.keyUsage(key, period)
+	defer u.mux.Unlock()
+	u.bytes += n
+}
+
+// Wait blocks until a request for key may be sent, which is at once unless the key has used more than SlowAt of its
+// budget, or ctx is done. It fails with a *ByteBudgetExceededError if the budget is used up.
+func (b *ByteBudget) Wait(ctx context.Context, key string) error {
+	delay, err := b.reserve(key)
+	if err != nil || delay <= 0 {
+		return err
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// check fails with a *ByteBudgetExceededError if the budget of key for the current period is used up.
+func (b *ByteBudget) check(key string) error {
+	_, period := b.current()
+	u := b.keyUsage(key, period)
+	defer u.mux.Unlock()
+	if u.bytes >= b.Limit {
+		return &ByteBudgetExceededError{Key: key, Limit: b.Limit, Reset: b.Period.End(period)}
+	}
+	return nil
+}
+
+// reserve counts a request for key, and returns how long it must wait to keep to the pace set by SlowAt.
+func (b *ByteBudget) reserve(key string) (time.Duration, error) {
+	now, period := b.current()
+	u := b.keyUsage(key, period)
+	defer u.mux.Unlock()
+	end := b.Period.End(period)
+	if u.bytes >= b.Limit {
+		return 0, &ByteBudgetExceededError{Key: key, Limit: b.Limit, Reset: end}
+	}
+	u.requests++
+	if b.SlowAt <= 0 || b.SlowAt >= 1 || float64(u.bytes) < b.SlowAt*float64(b.Limit) {
+		return 0, nil
+	}
+	// space the requests left at the average size of the responses to those before this one over the rest of the period
+	average := float64(u.bytes) / float64(max(u.requests-1, 1))
+	requests := max(float64(b.Limit-u.bytes)/average, 1)
+	interval := time.Duration(float64(end.Sub(now)) / requests)
+	at := now
+	if u.next.After(at) {
+		at = u.next
+	}
+	u.next = at.Add(interval)
+	return at.Sub(now), nil
+}
+
+// countBytes wraps the body of resp, if any, to add the bytes read from it to the budget of key.
+func (b *ByteBudget) countBytes(key string, resp *http.Response) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &countingBody{
+		ReadCloser: resp.Body,
+		add: func(n int64) {
+			b.Add(key, n)
+		},
+	}
+}
+
+// A countingBody calls add with the number of bytes of each read.
+type countingBody struct {
+	io.ReadCloser
+	add func(n int64)
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,80 @@
+package ratelim
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestByteBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewByteBudget(1000, QuotaDaily)
+	b.SlowAt = 0.5
+	b.now = func() time.Time {
+		return now
+	}
+	for range 3 {
+		if delay, err := b.reserve("a"); delay != 0 || err != nil {
+			t.Fatalf("reserve() = %v, %v below SlowAt; want 0, nil", delay, err)
+		}
+	}
+	b.Add("a", 600)
+	steps := []time.Duration{
+		// 400 bytes left at 200 per response: 2 requests in the 12 hours left
+		0,
+		// 400 bytes left at 150 per response, after the previous request
+		6 * time.Hour,
+	}
+	for i, want := range steps {
+		if delay, err := b.reserve("a"); delay != want || err != nil {
+			t.Errorf("step %d: reserve() = %v, %v; want %v, nil", i, delay, err, want)
+		}
+	}
+	b.Add("a", 400)
+	var exceeded *ByteBudgetExceededError
+	if _, err := b.reserve("a"); !errors.As(err, &exceeded) {
+		t.Fatalf("reserve() = %v, want a *ByteBudgetExceededError", err)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !exceeded.Reset.Equal(want) {
+		t.Errorf("Reset = %v, want %v", exceeded.Reset, want)
+	}
+	if remaining := b.Remaining("b"); remaining != 1000 {
+		t.Errorf("Remaining(b) = %d, want 1000", remaining)
+	}
+
+	now = now.Add(12 * time.Hour)
+	if used := b.Used("a"); used != 0 {
+		t.Errorf("Used(a) on the next day = %d, want 0", used)
+	}
+}
+
+// bodyTransport responds to every request with a 200 response with the given body.
+type bodyTransport string
+
+func (b bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := io.NopCloser(strings.NewReader(string(b)))
+	return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+}
+
+func TestPerKeyRoundTripper_ByteBudget(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 10, bodyTransport("0123456789"))
+	rt.ByteBudget = NewByteBudget(15, QuotaHourly)
+	for range 2 {
+		resp, err := rt.RoundTrip(mustNewRequest(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	if used := rt.ByteBudget.Used("https://example.com"); used != 20 {
+		t.Errorf("Used() = %d, want 20", used)
+	}
+	var exceeded *ByteBudgetExceededError
+	if _, err := rt.RoundTrip(mustNewRequest(t)); !errors.As(err, &exceeded) {
+		t.Errorf("RoundTrip() beyond the budget = %v, want a *ByteBudgetExceededError", err)
+	}
+}
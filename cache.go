@@ -0,0 +1,317 @@
+package ratelim
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A CachedResponse is a response stored by a CachingRoundTripper.
+type CachedResponse struct {
+	StatusCode int
+	Proto      string
+	Header     http.Header
+	Body       []byte
+	// RequestTime and ResponseTime are the times at which the request was sent and the response received.
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// Vary holds the values of the request headers named by the response's Vary header.
+	Vary map[string]string
+}
+
+// A Cache stores the responses of a CachingRoundTripper. Implementations must be safe for concurrent use; they may be
+// backed by external storage.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+// DefaultMemoryCacheSize is the number of responses held by a MemoryCache returned by NewMemoryCache.
+const DefaultMemoryCacheSize = 1024
+
+// A MemoryCache is a Cache holding responses in memory, up to a fixed number of them, evicting the least recently used
+// response to make room for another.
+type MemoryCache struct {
+	m *syncmap.LRUMap[string, *CachedResponse]
+}
+
+// NewMemoryCache returns a new, empty MemoryCache holding at most DefaultMemoryCacheSize responses.
+func NewMemoryCache() *MemoryCache {
+	return NewMemoryCacheSize(DefaultMemoryCacheSize)
+}
+
+// NewMemoryCacheSize returns a new, empty MemoryCache holding at most size responses, which must be positive.
+func NewMemoryCacheSize(size int) *MemoryCache {
+	return &MemoryCache{m: syncmap.NewLRU[string, *CachedResponse](size, nil)}
+}
+
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	return c.m.Load(key)
+}
+
+func (c *MemoryCache) Set(key string, resp *CachedResponse) {
+	c.m.Store(key, resp)
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.m.Delete(key)
+}
+
+// A CachingRoundTripper is a private HTTP cache loosely following RFC 9111. Fresh cached responses to GET requests are
+// served without sending a request at all, and stale responses with a validator (ETag or Last-Modified) are
+// revalidated with a conditional request, whose 304 (Not Modified) response is typically not counted against a
+// server's quota. Placed in front of a PerKeyRoundTripper, it is often the cheapest way to stay under a rate limit.
+//
+// Only responses with status 200, 203, 300, 301, 404 or 410 and an explicit freshness lifetime (from the max-age
+// Cache-Control directive or the Expires header) or a validator are stored; responses with the no-store directive
+// never are. Since the cache is shared by every caller of the transport, responses to requests carrying credentials
+// (an Authorization, Proxy-Authorization or Cookie header) are neither stored nor served from the cache unless they
+// are explicitly public, as for a shared cache (RFC 9111 section 3.5).
+type CachingRoundTripper struct {
+	http.RoundTripper
+	Cache Cache
+	// MaxBodySize, if positive, is the size above which response bodies are not stored.
+	MaxBodySize int64
+}
+
+// NewCachingRoundTripper creates a new CachingRoundTripper storing responses in cache (or a new MemoryCache, if nil)
+// and sending requests through roundTripper; if nil, a new *http.Transport is created with defaults based on
+// http.DefaultTransport.
+func NewCachingRoundTripper(cache Cache, roundTripper http.RoundTripper) *CachingRoundTripper {
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
+	if roundTripper == nil {
+		roundTripper = defaultTransport()
+	}
+	return &CachingRoundTripper{
+		RoundTripper: roundTripper,
+		Cache:        cache,
+	}
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+// parseCacheControl parses a Cache-Control header into a map from lower-cased directive to (unquoted) value.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// lifetime returns the freshness lifetime of the response.
+func (c *CachedResponse) lifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(c.Header)["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if expires := c.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(c.Header.Get("Date"))
+		if err != nil {
+			date = c.ResponseTime
+		}
+		return t.Sub(date)
+	}
+	return 0
+}
+
+// age returns the current age of the response (RFC 9111 section 4.2.3).
+func (c *CachedResponse) age(now time.Time) time.Duration {
+	apparent := time.Duration(0)
+	if date, err := http.ParseTime(c.Header.Get("Date")); err == nil && c.ResponseTime.After(date) {
+		apparent = c.ResponseTime.Sub(date)
+	}
+	if seconds, err := strconv.Atoi(c.Header.Get("Age")); err == nil {
+		corrected := time.Duration(seconds)*time.Second + c.ResponseTime.Sub(c.RequestTime)
+		if corrected > apparent {
+			apparent = corrected
+		}
+	}
+	return apparent + now.Sub(c.ResponseTime)
+}
+
+func (c *CachedResponse) fresh(now time.Time) bool {
+	if _, noCache := parseCacheControl(c.Header)["no-cache"]; noCache {
+		return false
+	}
+	return c.lifetime() > c.age(now)
+}
+
+func (c *CachedResponse) hasValidator() bool {
+	return c.Header.Get("ETag") != "" || c.Header.Get("Last-Modified") != ""
+}
+
+// public reports whether the response may be shared with requests carrying credentials, by its public directive.
+func (c *CachedResponse) public() bool {
+	_, public := parseCacheControl(c.Header)["public"]
+	return public
+}
+
+func (c *CachedResponse) matches(req *http.Request) bool {
+	if hasCredentials(req) && !c.public() {
+		return false
+	}
+	for name, value := range c.Vary {
+		if name == "*" || req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// response returns a new *http.Response for req from the cached response.
+func (c *CachedResponse) response(req *http.Request, now time.Time) *http.Response {
+	header := c.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(c.age(now).Seconds())))
+	major, minor, ok := http.ParseHTTPVersion(c.Proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(c.StatusCode) + " " + http.StatusText(c.StatusCode),
+		StatusCode:    c.StatusCode,
+		Proto:         c.Proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+func (t *CachingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if req.Method != http.MethodHead && req.Method != http.MethodOptions && req.Method != http.MethodTrace {
+			// unsafe methods invalidate the stored response for the target URI
+			t.Cache.Delete(cacheKey(req))
+		}
+		return t.RoundTripper.RoundTrip(req)
+	}
+	if _, noStore := parseCacheControl(req.Header)["no-store"]; noStore {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	cached, ok := t.Cache.Get(key)
+	if ok && !cached.matches(req) {
+		cached, ok = nil, false
+	}
+	_, noCache := parseCacheControl(req.Header)["no-cache"]
+	if ok && !noCache && cached.fresh(time.Now()) {
+		return cached.response(req, time.Now()), nil
+	}
+
+	outReq := req
+	if ok && cached.hasValidator() &&
+		req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		outReq = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			outReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	requestTime := time.Now()
+	resp, err := t.RoundTripper.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+	if outReq != req && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		updated := *cached
+		updated.Header = cached.Header.Clone()
+		for name, values := range resp.Header {
+			updated.Header[name] = values
+		}
+		updated.RequestTime, updated.ResponseTime = requestTime, responseTime
+		t.Cache.Set(key, &updated)
+		return updated.response(req, responseTime), nil
+	}
+	return t.store(key, req, resp, requestTime, responseTime), nil
+}
+
+// store stores resp in the cache if it is cacheable, returning a response to pass to the caller in its place.
+func (t *CachingRoundTripper) store(
+	key string,
+	req *http.Request,
+	resp *http.Response,
+	requestTime, responseTime time.Time,
+) *http.Response {
+	if !cacheableStatus[resp.StatusCode] {
+		return resp
+	}
+	if _, noStore := parseCacheControl(resp.Header)["no-store"]; noStore {
+		return resp
+	}
+	cached := &CachedResponse{
+		StatusCode:   resp.StatusCode,
+		Proto:        resp.Proto,
+		Header:       resp.Header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	if cached.lifetime() <= 0 && !cached.hasValidator() || hasCredentials(req) && !cached.public() {
+		return resp
+	}
+	if t.MaxBodySize > 0 && resp.ContentLength > t.MaxBodySize {
+		return resp
+	}
+	reader := io.Reader(resp.Body)
+	if t.MaxBodySize > 0 {
+		reader = io.LimitReader(resp.Body, t.MaxBodySize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil || t.MaxBodySize > 0 && int64(len(body)) > t.MaxBodySize {
+		// pass on what was read followed by the rest (or the read error)
+		resp.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	cached.Body = body
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				if cached.Vary == nil {
+					cached.Vary = make(map[string]string)
+				}
+				cached.Vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+			}
+		}
+	}
+	t.Cache.Set(key, cached)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp
+}
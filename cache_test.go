@@ -0,0 +1,156 @@
+package ratelim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCachingRoundTripper(t *testing.T) {
+	var hits, notModified atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				switch r.URL.Path {
+				case "/fresh":
+					w.Header().Set("Cache-Control", "max-age=60")
+				case "/etag":
+					w.Header().Set("Cache-Control", "no-cache")
+					w.Header().Set("ETag", `"v1"`)
+					if r.Header.Get("If-None-Match") == `"v1"` {
+						notModified.Add(1)
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				case "/no-store":
+					w.Header().Set("Cache-Control", "no-store, max-age=60")
+				}
+				_, _ = io.WriteString(w, "body of "+r.URL.Path)
+			},
+		),
+	)
+	defer ts.Close()
+	client := ts.Client()
+	client.Transport = NewCachingRoundTripper(nil, client.Transport)
+	get := func(path string) {
+		t.Helper()
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "body of "+path {
+			t.Errorf("GET %s: body = %q", path, body)
+		}
+	}
+	tests := []struct {
+		path        string
+		wantHits    int32
+		wantNotMods int32
+	}{
+		{"/fresh", 1, 0},
+		{"/etag", 1, 1},
+		{"/no-store", 2, 0},
+		{"/uncacheable", 2, 0},
+	}
+	for _, tt := range tests {
+		hits.Store(0)
+		notModified.Store(0)
+		for i := 0; i < 2; i++ {
+			get(tt.path)
+		}
+		if hits.Load() != tt.wantHits+tt.wantNotMods || notModified.Load() != tt.wantNotMods {
+			t.Errorf(
+				"GET %s twice: %d requests (%d not modified), want %d (%d)",
+				tt.path,
+				hits.Load(),
+				notModified.Load(),
+				tt.wantHits+tt.wantNotMods,
+				tt.wantNotMods,
+			)
+		}
+	}
+}
+
+func TestCachingRoundTripper_credentials(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				cacheControl := "max-age=60"
+				if r.URL.Path == "/public" {
+					cacheControl = "public, max-age=60"
+				}
+				w.Header().Set("Cache-Control", cacheControl)
+				_, _ = io.WriteString(w, "body for "+r.Header.Get("Authorization"))
+			},
+		),
+	)
+	defer ts.Close()
+	client := &http.Client{Transport: NewCachingRoundTripper(nil, ts.Client().Transport)}
+	get := func(path, user string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if user != "" {
+			req.Header.Set("Authorization", user)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		users    []string
+		wantHits int32
+	}{
+		// each user gets their own response, and none is stored for the others
+		{"private", "/private", []string{"alice", "bob", "alice", ""}, 4},
+		// a response cached for anonymous requests is not served to a user
+		{"anonymous first", "/anonymous", []string{"", "alice", ""}, 2},
+		{"public", "/public", []string{"alice", "bob"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				hits.Store(0)
+				for _, user := range tt.users {
+					body := get(tt.path, user)
+					if tt.path != "/public" && body != "body for "+user {
+						t.Errorf("%q got %q", user, body)
+					}
+				}
+				if n := hits.Load(); n != tt.wantHits {
+					t.Errorf("server received %d requests, want %d", n, tt.wantHits)
+				}
+			},
+		)
+	}
+}
+
+func TestMemoryCache_size(t *testing.T) {
+	c := NewMemoryCacheSize(2)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(key, &CachedResponse{})
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("least recently used response not evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("response %q evicted", key)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ratelim
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// An Outcome is the classification of the result of a round trip, as reported by a ResponseClassifier.
+type Outcome int
+
+const (
+	// OutcomeSuccess indicates the request was handled by the server without any sign of throttling or failure.
+	OutcomeSuccess Outcome = iota
+	// OutcomeThrottled indicates the server rejected the request because the client is sending too many requests.
+	OutcomeThrottled
+	// OutcomeError indicates the request failed for some reason other than throttling.
+	OutcomeError
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeThrottled:
+		return "throttled"
+	case OutcomeError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// A ResponseClassifier classifies the response and error returned by a round trip. Classifiers which inspect the
+// response body must leave it readable from the beginning for the caller.
+type ResponseClassifier func(resp *http.Response, err error) Outcome
+
+// StatusClassifier classifies a round trip by its status code alone: 429 (Too Many Requests) is OutcomeThrottled,
+// while a non-nil error or a 5xx status is OutcomeError.
+func StatusClassifier(resp *http.Response, err error) Outcome {
+	if err != nil || resp == nil {
+		return OutcomeError
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return OutcomeThrottled
+	}
+	if resp.StatusCode >= 500 {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// awsThrottlingCodes are the error codes used by AWS (and S3-compatible) APIs to signal throttling.
+var awsThrottlingCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestThrottledException",
+	"TooManyRequestsException",
+	"ProvisionedThroughputExceededException",
+	"TransactionInProgressException",
+	"RequestLimitExceeded",
+	"BandwidthLimitExceeded",
+	"LimitExceededException",
+	"RequestThrottled",
+	"SlowDown",
+	"PriorRequestNotComplete",
+	"EC2ThrottledException",
+}
+
+// maxPeekBytes is the maximum number of bytes of a response body read by classifiers which inspect the body.
+const maxPeekBytes = 64 << 10
+
+// AWSClassifier classifies a round trip like StatusClassifier, but also recognizes the throttling error codes used by
+// AWS-style APIs, which are often returned with a 400 or 503 status code rather than 429. The error code is looked
+// up in the X-Amzn-ErrorType header and, failing that, in the first 64KiB of the response body, which covers both
+// the JSON ("__type" or "code") and XML (<Code>) error formats.
+func AWSClassifier(resp *http.Response, err error) Outcome {
+	outcome := StatusClassifier(resp, err)
+	if err != nil || resp == nil || outcome == OutcomeThrottled {
+		return outcome
+	}
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusServiceUnavailable {
+		return outcome
+	}
+	if errorType := resp.Header.Get("X-Amzn-ErrorType"); errorType != "" {
+		// the header value may be qualified, e.g. "ThrottlingException:http://internal.amazon.com/coral/..."
+		code, _, _ := strings.Cut(errorType, ":")
+		if isAWSThrottlingCode(code) {
+			return OutcomeThrottled
+		}
+	}
+	body, peekErr := peekBody(resp, maxPeekBytes)
+	if peekErr != nil {
+		return outcome
+	}
+	for _, code := range awsThrottlingCodes {
+		for _, pattern := range []string{
+			`"` + code + `"`,
+			`#` + code + `"`,
+			`<Code>` + code + `</Code>`,
+		} {
+			if bytes.Contains(body, []byte(pattern)) {
+				return OutcomeThrottled
+			}
+		}
+	}
+	return outcome
+}
+
+func isAWSThrottlingCode(code string) bool {
+	// JSON protocols may prefix the code with a namespace, e.g. "com.amazonaws.dynamodb.v20120810#ThrottlingException"
+	if i := strings.LastIndexByte(code, '#'); i >= 0 {
+		code = code[i+1:]
+	}
+	for _, c := range awsThrottlingCodes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// peekBody reads up to n bytes from the body of resp, then replaces the body with one which yields the same content
+// from the beginning.
+func peekBody(resp *http.Response, n int64) ([]byte, error) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	peeked, err := io.ReadAll(io.LimitReader(resp.Body, n))
+	resp.Body = &peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(peeked), resp.Body),
+		Closer: resp.Body,
+	}
+	return peeked, err
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,107 @@
+package ratelim
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestAWSClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want Outcome
+	}{
+		{
+			name: "ok",
+			resp: newResponse(http.StatusOK, nil, `{"__type":"ThrottlingException"}`),
+			want: OutcomeSuccess,
+		},
+		{
+			name: "transport error",
+			err:  errors.New("connection reset"),
+			want: OutcomeError,
+		},
+		{
+			name: "too many requests",
+			resp: newResponse(http.StatusTooManyRequests, nil, ""),
+			want: OutcomeThrottled,
+		},
+		{
+			name: "json throttling exception",
+			resp: newResponse(
+				http.StatusBadRequest,
+				nil,
+				`{"__type":"com.amazonaws.dynamodb.v20120810#ThrottlingException","message":"Rate exceeded"}`,
+			),
+			want: OutcomeThrottled,
+		},
+		{
+			name: "xml slow down",
+			resp: newResponse(
+				http.StatusServiceUnavailable,
+				nil,
+				`<?xml version="1.0" encoding="UTF-8"?><Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`,
+			),
+			want: OutcomeThrottled,
+		},
+		{
+			name: "error type header",
+			resp: newResponse(
+				http.StatusBadRequest,
+				http.Header{"X-Amzn-Errortype": []string{"TooManyRequestsException:http://internal.amazon.com/"}},
+				"",
+			),
+			want: OutcomeThrottled,
+		},
+		{
+			name: "validation error",
+			resp: newResponse(http.StatusBadRequest, nil, `{"__type":"ValidationException"}`),
+			want: OutcomeSuccess,
+		},
+		{
+			name: "unavailable",
+			resp: newResponse(http.StatusServiceUnavailable, nil, "down for maintenance"),
+			want: OutcomeError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var before string
+				if tt.resp != nil {
+					b, _ := io.ReadAll(tt.resp.Body)
+					before = string(b)
+					tt.resp.Body = io.NopCloser(strings.NewReader(before))
+				}
+				if got := AWSClassifier(tt.resp, tt.err); got != tt.want {
+					t.Errorf("AWSClassifier() = %v, want %v", got, tt.want)
+				}
+				if tt.resp == nil {
+					return
+				}
+				after, err := io.ReadAll(tt.resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(after) != before {
+					t.Errorf("body not preserved: got %q, want %q", after, before)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim"
+	"golang.org/x/time/rate"
+)
+
+// loadConfig holds the flags of the load command.
+type loadConfig struct {
+	url         string
+	method      string
+	limit       float64
+	burst       int
+	pattern     string
+	offered     float64
+	requests    int
+	duration    time.Duration
+	concurrency int
+	timeout     time.Duration
+}
+
+func parseLoadFlags(args []string, out io.Writer) (loadConfig, error) {
+	var cfg loadConfig
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&cfg.method, "method", http.MethodGet, "HTTP method of the requests")
+	fs.Float64Var(&cfg.limit, "limit", 10, "rate limit of the target's origin, in requests per second")
+	fs.IntVar(&cfg.burst, "burst", 1, "burst size of the target's origin")
+	fs.StringVar(
+		&cfg.pattern, "pattern", "burst",
+		"request pattern: burst offers all requests at once, steady offers them evenly at -rate",
+	)
+	fs.Float64Var(&cfg.offered, "rate", 0, "rate at which requests are offered by the steady pattern, per second")
+	fs.IntVar(&cfg.requests, "n", 100, "number of requests to send")
+	fs.DurationVar(&cfg.duration, "duration", 0, "if positive, stop offering requests after this long")
+	fs.IntVar(&cfg.concurrency, "c", 10, "maximum number of requests in flight")
+	fs.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "timeout of each request, including its wait")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: ratelim load [flags] URL")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return cfg, errUsage
+	}
+	cfg.url = fs.Arg(0)
+	switch {
+	case cfg.pattern != "burst" && cfg.pattern != "steady":
+		return cfg, fmt.Errorf("unknown pattern %q", cfg.pattern)
+	case cfg.pattern == "steady" && cfg.offered <= 0:
+		return cfg, fmt.Errorf("the steady pattern needs a positive -rate")
+	case cfg.requests <= 0 || cfg.concurrency <= 0:
+		return cfg, fmt.Errorf("-n and -c must be positive")
+	}
+	return cfg, nil
+}
+
+// loadResult summarizes the responses to the requests sent by the load command.
+type loadResult struct {
+	mu       sync.Mutex
+	statuses map[int]int
+	errors   map[string]int
+}
+
+func (r *loadResult) record(resp *http.Response, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[err.Error()]++
+		return
+	}
+	r.statuses[resp.StatusCode]++
+}
+
+// runLoad sends requests to a target through a PerOriginRoundTripper, and reports the rate achieved, the time the
+// requests waited for the limiter and the statuses of their responses.
+func runLoad(args []string, out io.Writer) error {
+	cfg, err := parseLoadFlags(args, out)
+	if err != nil {
+		return err
+	}
+	rt := ratelim.PerOriginRoundTripper(rate.Limit(cfg.limit), cfg.burst, nil)
+	defer rt.CloseIdleConnections()
+	client := &http.Client{Transport: rt, Timeout: cfg.timeout}
+	result := &loadResult{statuses: make(map[int]int), errors: make(map[string]int)}
+
+	ctx := context.Background()
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				result.record(send(client, cfg))
+			}
+		}()
+	}
+	start := time.Now()
+	offer(ctx, cfg, jobs)
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var stats ratelim.Stats
+	for _, s := range rt.AllStats() {
+		stats = s
+	}
+	return writeLoadReport(out, elapsed, stats, result)
+}
+
+// offer sends cfg.requests jobs following cfg.pattern, or as many as it can until ctx is done.
+func offer(ctx context.Context, cfg loadConfig, jobs chan<- struct{}) {
+	var tick <-chan time.Time
+	if cfg.pattern == "steady" {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.offered))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := 0; i < cfg.requests; i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func send(client *http.Client, cfg loadConfig) (*http.Response, error) {
+	req, err := http.NewRequest(cfg.method, cfg.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+func writeLoadReport(out io.Writer, elapsed time.Duration, stats ratelim.Stats, result *loadResult) error {
+	var b strings.Builder
+	fmt.Fprintf(
+		&b, "requests: %d in %v (%.2f req/s)\n", stats.Requests, elapsed.Round(time.Millisecond),
+		float64(stats.Requests)/elapsed.Seconds(),
+	)
+	fmt.Fprintf(
+		&b, "wait: mean %v, p50 %s, p90 %s, p99 %s\n", stats.Wait.Mean().Round(time.Millisecond),
+		quantile(stats.Wait, 0.5), quantile(stats.Wait, 0.9), quantile(stats.Wait, 0.99),
+	)
+	codes := slices.Sorted(maps.Keys(result.statuses))
+	statuses := make([]string, 0, len(codes))
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d: %d", code, result.statuses[code]))
+	}
+	fmt.Fprintf(&b, "statuses: %s\n", strings.Join(statuses, ", "))
+	fmt.Fprintf(&b, "429s: %d\n", result.statuses[http.StatusTooManyRequests])
+	for _, msg := range slices.Sorted(maps.Keys(result.errors)) {
+		fmt.Fprintf(&b, "error: %s (%d)\n", msg, result.errors[msg])
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// quantile formats the upper bound of the q-quantile of h.
+func quantile(h ratelim.Histogram, q float64) string {
+	d := h.Quantile(q)
+	if d < 0 {
+		return fmt.Sprintf(">%v", h.Bounds[len(h.Bounds)-1])
+	}
+	return fmt.Sprintf("<=%v", d)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunLoad(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1)%3 == 0 {
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	var out strings.Builder
+	start := time.Now()
+	if err := run([]string{"load", "-limit", "50", "-burst", "1", "-n", "6", "-c", "3", server.URL}, &out); err != nil {
+		t.Fatal(err)
+	}
+	// the first request is sent at once and the other five 20ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("load took %v, want at least 100ms", elapsed)
+	}
+	report := out.String()
+	for _, want := range []string{"requests: 6 in ", "statuses: 200: 4, 429: 2\n", "429s: 2\n", "wait: mean "} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestParseLoadFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "defaults", args: []string{"http://example.com"}},
+		{name: "steady", args: []string{"-pattern", "steady", "-rate", "5", "http://example.com"}},
+		{name: "steady without rate", args: []string{"-pattern", "steady", "http://example.com"}, wantErr: true},
+		{name: "unknown pattern", args: []string{"-pattern", "ramp", "http://example.com"}, wantErr: true},
+		{name: "no requests", args: []string{"-n", "0", "http://example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := parseLoadFlags(tt.args, &strings.Builder{})
+				if (err != nil) != tt.wantErr {
+					t.Errorf("parseLoadFlags(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
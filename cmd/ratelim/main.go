@@ -0,0 +1,51 @@
+// Command ratelim sends rate limited requests to an HTTP target, to verify limit configs against it or to find its
+// rate limit, and replays audit logs to tune limits against recorded traffic.
+//
+// Usage:
+//
+//	ratelim load [flags] URL
+//	ratelim probe [flags] URL
+//	ratelim replay [flags] FILE
+//
+// The load command sends requests at a configurable pattern and reports the rate achieved, while the probe command
+// searches for the rate at which a target starts throttling requests and suggests a config for it. The replay command
+// simulates alternative limits against the requests of an audit log, as written by a ratelim.AuditLog.
+//
+// Run a command with -h for its flags.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var errUsage = errors.New("usage: ratelim load|probe [flags] URL | ratelim replay [flags] FILE")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "ratelim:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+// run runs the command named by args[0] with the rest of args, writing its report to out.
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "load":
+		return runLoad(args[1:], out)
+	case "probe":
+		return runProbe(args[1:], out)
+	case "replay":
+		return runReplay(args[1:], out)
+	default:
+		return fmt.Errorf("unknown command %q; %w", args[0], errUsage)
+	}
+}
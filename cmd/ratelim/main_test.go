@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"flood", "http://example.com"}},
+		{name: "missing URL", args: []string{"load"}},
+		{name: "missing file", args: []string{"replay"}},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if err := run(tt.args, io.Discard); !errors.Is(err, errUsage) {
+					t.Errorf("run(%q) = %v, want %v", tt.args, err, errUsage)
+				}
+			},
+		)
+	}
+}
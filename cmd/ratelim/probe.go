@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim"
+	"golang.org/x/time/rate"
+)
+
+func parseProbeFlags(args []string, out io.Writer) (*ratelim.LimitProbe, error) {
+	var (
+		method           string
+		minimum, maximum float64
+		tolerance        float64
+	)
+	p := &ratelim.LimitProbe{}
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&method, "method", http.MethodGet, "HTTP method of the requests")
+	fs.Float64Var(&minimum, "min", 1, "lowest rate tried, in requests per second")
+	fs.Float64Var(&maximum, "max", 100, "highest rate tried, in requests per second")
+	fs.Float64Var(&tolerance, "tolerance", 0, "precision of the search, in requests per second (default min/10)")
+	fs.DurationVar(&p.Step, "step", 5*time.Second, "how long each rate is tried")
+	fs.DurationVar(&p.Cooldown, "cooldown", 10*time.Second, "pause after a throttled step")
+	fs.Float64Var(&p.Margin, "margin", 0.1, "fraction by which the suggested limit is below the limit found")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: ratelim probe [flags] URL")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errUsage
+	}
+	target := fs.Arg(0)
+	p.Min, p.Max, p.Tolerance = rate.Limit(minimum), rate.Limit(maximum), rate.Limit(tolerance)
+	p.NewRequest = func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, method, target, nil)
+	}
+	return p, nil
+}
+
+// runProbe searches for the rate limit of a target with a LimitProbe, and reports each step and the suggested config.
+func runProbe(args []string, out io.Writer) error {
+	p, err := parseProbeFlags(args, out)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := p.Run(ctx)
+	if err != nil {
+		return err
+	}
+	return writeProbeReport(out, result)
+}
+
+func writeProbeReport(out io.Writer, result ratelim.ProbeResult) error {
+	var b strings.Builder
+	for _, step := range result.Steps {
+		fmt.Fprintf(
+			&b, "rate: %.2f req/s\trequests: %d\tthrottled: %d\terrors: %d\n",
+			float64(step.Rate), step.Requests, step.Throttled, step.Errors,
+		)
+	}
+	cfg, err := json.Marshal(result.Config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "limit: %.2f req/s\n", float64(result.Limit))
+	fmt.Fprintf(&b, "suggested config: %s\n", cfg)
+	fmt.Fprintf(&b, "load flags: -limit %.2f -burst %d\n", float64(result.Config.Limit), result.Config.Burst)
+	_, err = io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRunProbe(t *testing.T) {
+	limiter := rate.NewLimiter(30, 2)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if !limiter.Allow() {
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	var out strings.Builder
+	args := []string{"probe", "-min", "10", "-max", "80", "-tolerance", "10", "-step", "300ms", "-cooldown", "50ms"}
+	if err := run(append(args, server.URL), &out); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	for _, want := range []string{"rate: 10.00 req/s\t", "limit: ", `suggested config: {"Limit":`, "load flags: -limit "} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim"
+	"golang.org/x/time/rate"
+)
+
+// replayConfig holds the flags of the replay command.
+type replayConfig struct {
+	path   string
+	config string
+	keys   bool
+	replay ratelim.Replay
+}
+
+func parseReplayFlags(args []string, out io.Writer) (replayConfig, error) {
+	var (
+		cfg   replayConfig
+		limit float64
+	)
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Float64Var(&limit, "limit", 10, "rate limit of each key, in requests per second")
+	fs.IntVar(&cfg.replay.Config.Default.Burst, "burst", 1, "burst size of each key")
+	fs.StringVar(
+		&cfg.config, "config", "",
+		"file of a transport config in JSON, with default and rules, replacing -limit and -burst",
+	)
+	fs.DurationVar(&cfg.replay.MaxWait, "max-wait", 0, "if positive, reject requests which would wait longer")
+	fs.BoolVar(&cfg.keys, "keys", false, "report the requests of each key")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: ratelim replay [flags] FILE")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return cfg, errUsage
+	}
+	cfg.path = fs.Arg(0)
+	cfg.replay.Config.Default.Limit = rate.Limit(limit)
+	return cfg, nil
+}
+
+// runReplay replays an audit log, or stdin if its path is "-", with alternative limits, and reports the decisions
+// recorded in the log and those which the limits would have taken.
+func runReplay(args []string, out io.Writer) error {
+	cfg, err := parseReplayFlags(args, out)
+	if err != nil {
+		return err
+	}
+	if cfg.config != "" {
+		if cfg.replay.Config, err = readTransportConfig(cfg.config); err != nil {
+			return err
+		}
+	}
+	in := os.Stdin
+	if cfg.path != "-" {
+		if in, err = os.Open(cfg.path); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	records, err := ratelim.ReadAuditLog(in)
+	if err != nil {
+		return err
+	}
+	return writeReplayReport(out, ratelim.SummarizeAudit(records), cfg.replay.Run(records), cfg.keys)
+}
+
+func readTransportConfig(path string) (ratelim.TransportConfig, error) {
+	var cfg ratelim.TransportConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func writeReplayReport(out io.Writer, recorded, replayed ratelim.ReplayResult, keys bool) error {
+	var b strings.Builder
+	writeCounts := func(label string, c ratelim.ReplayCounts) {
+		var mean time.Duration
+		if permitted := c.Passed + c.Waited; permitted > 0 {
+			mean = c.Wait / time.Duration(permitted)
+		}
+		fmt.Fprintf(
+			&b, "%s\trequests: %d\tpassed: %d\twaited: %d\trejected: %d\tmean wait: %v\tmax wait: %v\n",
+			label, c.Requests, c.Passed, c.Waited, c.Rejected, mean, c.MaxWait,
+		)
+	}
+	writeCounts("recorded", recorded.ReplayCounts)
+	writeCounts("replayed", replayed.ReplayCounts)
+	if keys {
+		for _, key := range slices.Sorted(maps.Keys(replayed.Keys)) {
+			fmt.Fprintf(&b, "\nkey: %s\n", key)
+			writeCounts("recorded", recorded.Keys[key])
+			writeCounts("replayed", replayed.Keys[key])
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
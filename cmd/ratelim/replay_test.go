@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReplay(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "audit.log")
+	var lines []string
+	for range 4 {
+		lines = append(lines, `{"time":"2024-01-01T00:00:00Z","key":"https://example.com","action":"pass"}`)
+	}
+	if err := os.WriteFile(log, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.json")
+	rules := `{
+		"default": {"Limit": 100, "Burst": 10},
+		"rules": [{"Pattern": "https://example.com", "Config": {"Limit": 1, "Burst": 1}}]
+	}`
+	if err := os.WriteFile(config, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "flags",
+			args: []string{"-limit", "1", "-burst", "2", "-max-wait", "1s", "-keys"},
+			want: []string{
+				"recorded\trequests: 4\tpassed: 4\twaited: 0\trejected: 0\t",
+				"replayed\trequests: 4\tpassed: 2\twaited: 1\trejected: 1\tmean wait: 333.333333ms\tmax wait: 1s\n",
+				"key: https://example.com\n",
+			},
+		},
+		{
+			name: "config",
+			args: []string{"-config", config},
+			want: []string{"replayed\trequests: 4\tpassed: 1\twaited: 3\trejected: 0\tmean wait: 1.5s\tmax wait: 3s\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var out strings.Builder
+				if err := run(append(append([]string{"replay"}, tt.args...), log), &out); err != nil {
+					t.Fatal(err)
+				}
+				for _, want := range tt.want {
+					if !strings.Contains(out.String(), want) {
+						t.Errorf("report does not contain %q:\n%s", want, out.String())
+					}
+				}
+			},
+		)
+	}
+}
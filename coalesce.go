@@ -0,0 +1,139 @@
+package ratelim
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A CoalescingRoundTripper deduplicates identical concurrent GET and HEAD requests: while a request is in flight, any
+// other request with the same key waits for it to complete and receives a copy of its response, rather than being
+// sent itself. Placed in front of a PerKeyRoundTripper, it saves the tokens the duplicate requests would have used.
+//
+// Responses are buffered in memory in order to be shared. Requests are keyed by method, URL and content negotiation
+// headers (Accept, Accept-Encoding and Accept-Language) by default, so requests carrying credentials, in an
+// Authorization, Proxy-Authorization or Cookie header, are never coalesced unless Key is set, lest the response for
+// one user be handed to another; a Key coalescing them must distinguish their credentials. Range requests, whose
+// partial responses are specific to the range requested, are never coalesced. If the context of the request actually
+// sent is canceled, all the requests sharing it fail.
+type CoalescingRoundTripper struct {
+	http.RoundTripper
+	// Key, if non-nil, returns the key of a request, which is shared by the requests to coalesce; if nil, requests
+	// are keyed by method, URL and content negotiation headers, and those carrying credentials are not coalesced.
+	Key func(*http.Request) string
+
+	mux   sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+	dups int
+}
+
+// NewCoalescingRoundTripper creates a new CoalescingRoundTripper sending requests through roundTripper; if nil, a new
+// *http.Transport is created with defaults based on http.DefaultTransport.
+func NewCoalescingRoundTripper(roundTripper http.RoundTripper) *CoalescingRoundTripper {
+	if roundTripper == nil {
+		roundTripper = defaultTransport()
+	}
+	return &CoalescingRoundTripper{
+		RoundTripper: roundTripper,
+		calls:        make(map[string]*coalescedCall),
+	}
+}
+
+func (t *CoalescingRoundTripper) key(req *http.Request) string {
+	if t.Key != nil {
+		return t.Key(req)
+	}
+	var b strings.Builder
+	b.WriteString(req.Method + " " + req.URL.String())
+	for _, name := range negotiationHeaders {
+		for _, v := range req.Header.Values(name) {
+			b.WriteString("\n" + name + ": " + v)
+		}
+	}
+	return b.String()
+}
+
+// negotiationHeaders are the request headers selecting the representation of a response, whose requests only share
+// responses with requests with the same values under the default key.
+var negotiationHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// credentialHeaders are the request headers identifying a user, whose requests are not coalesced with others' by the
+// default key, nor served responses cached for others.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// coalescable reports whether req may share the response of another request.
+func (t *CoalescingRoundTripper) coalescable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead ||
+		req.Body != nil && req.Body != http.NoBody || req.Header.Get("Range") != "" {
+		return false
+	}
+	return t.Key != nil || !hasCredentials(req)
+}
+
+// hasCredentials reports whether req carries any of the credentialHeaders.
+func hasCredentials(req *http.Request) bool {
+	for _, name := range credentialHeaders {
+		if _, ok := req.Header[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *CoalescingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.coalescable(req) {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	key := t.key(req)
+	t.mux.Lock()
+	if c, ok := t.calls[key]; ok {
+		c.dups++
+		t.mux.Unlock()
+		select {
+		case <-c.done:
+			return c.response(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	c := &coalescedCall{done: make(chan struct{})}
+	t.calls[key] = c
+	t.mux.Unlock()
+
+	c.resp, c.err = t.RoundTripper.RoundTrip(req)
+	t.mux.Lock()
+	delete(t.calls, key)
+	dups := c.dups
+	t.mux.Unlock()
+	if dups == 0 {
+		close(c.done)
+		return c.resp, c.err
+	}
+	if c.err == nil {
+		c.body, c.err = io.ReadAll(c.resp.Body)
+		_ = c.resp.Body.Close()
+	}
+	close(c.done)
+	return c.response(req)
+}
+
+// response returns a copy of the call's response for req.
+func (c *coalescedCall) response(req *http.Request) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	resp := *c.resp
+	resp.Header = c.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(c.body))
+	resp.Request = req
+	return &resp, nil
+}
@@ -0,0 +1,170 @@
+package ratelim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescingRoundTripper(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				_, _ = io.WriteString(w, r.URL.Path)
+			},
+		),
+	)
+	defer ts.Close()
+	client := ts.Client()
+	client.Transport = NewCoalescingRoundTripper(client.Transport)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(ts.URL + "/shared")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil || string(body) != "/shared" {
+				t.Errorf("body = %q, %v", body, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server received %d requests, want 1", n)
+	}
+}
+
+func TestCoalescingRoundTripper_credentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      func(*http.Request) string
+		header   string
+		wantHits int32
+	}{
+		{"authorization", nil, "Authorization", 4},
+		{"cookie", nil, "Cookie", 4},
+		{
+			name: "custom key",
+			key: func(req *http.Request) string {
+				return req.URL.String() + " " + req.Header.Get("Authorization")
+			},
+			header:   "Authorization",
+			wantHits: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var hits atomic.Int32
+				ts := httptest.NewServer(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							hits.Add(1)
+							time.Sleep(100 * time.Millisecond)
+							_, _ = io.WriteString(w, r.Header.Get(tt.header))
+						},
+					),
+				)
+				defer ts.Close()
+				coalescing := NewCoalescingRoundTripper(ts.Client().Transport)
+				coalescing.Key = tt.key
+				client := &http.Client{Transport: coalescing}
+				var wg sync.WaitGroup
+				// two users each send two identical requests at once
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						user := "user-" + string(rune('a'+i%2))
+						req, _ := http.NewRequest(http.MethodGet, ts.URL+"/private", nil)
+						req.Header.Set(tt.header, user)
+						resp, err := client.Do(req)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						defer resp.Body.Close()
+						if body, _ := io.ReadAll(resp.Body); string(body) != user {
+							t.Errorf("%s got the response for %q", user, body)
+						}
+					}()
+				}
+				wg.Wait()
+				if n := hits.Load(); n != tt.wantHits {
+					t.Errorf("server received %d requests, want %d", n, tt.wantHits)
+				}
+			},
+		)
+	}
+}
+
+func TestCoalescingRoundTripper_representations(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		values   [2]string
+		wantHits int32
+	}{
+		{"ranges", "Range", [2]string{"bytes=0-99", "bytes=100-199"}, 4},
+		// requests for the same range are not coalesced either
+		{"same range", "Range", [2]string{"bytes=0-99", "bytes=0-99"}, 4},
+		{"media types", "Accept", [2]string{"application/json", "text/html"}, 2},
+		{"encodings", "Accept-Encoding", [2]string{"gzip", "identity"}, 2},
+		{"languages", "Accept-Language", [2]string{"en", "fr"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var hits atomic.Int32
+				ts := httptest.NewServer(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							hits.Add(1)
+							time.Sleep(100 * time.Millisecond)
+							_, _ = io.WriteString(w, r.Header.Get(tt.header))
+						},
+					),
+				)
+				defer ts.Close()
+				client := &http.Client{Transport: NewCoalescingRoundTripper(ts.Client().Transport)}
+				var wg sync.WaitGroup
+				// each value is requested twice at once
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						value := tt.values[i%2]
+						req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+						req.Header.Set(tt.header, value)
+						resp, err := client.Do(req)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						defer resp.Body.Close()
+						if body, _ := io.ReadAll(resp.Body); string(body) != value {
+							t.Errorf("request for %q got the response for %q", value, body)
+						}
+					}()
+				}
+				wg.Wait()
+				if n := hits.Load(); n != tt.wantHits {
+					t.Errorf("server received %d requests, want %d", n, tt.wantHits)
+				}
+			},
+		)
+	}
+}
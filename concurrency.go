@@ -0,0 +1,112 @@
+package ratelim
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/milo-minderbinder/ratelim/semaphore"
+)
+
+// acquireSlots acquires the slots bounding the concurrency of a request for key, of the given weight: slots of the
+// key's MaxStreams, if stream is true, and of its MaxHTTP2Streams, if its origin is known to speak HTTP/2. It returns
+// a func releasing the slots acquired, or nil if none were.
+func (t *PerKeyRoundTripper[K]) acquireSlots(
+	ctx context.Context,
+	key K,
+	cfg LimiterConfig,
+	stream bool,
+	weight int64,
+) (release func(), err error) {
+	var releases []func()
+	releaseAll := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	acquire := func(sem *semaphore.Keyed[K], size int) error {
+		n := min(weight, int64(size))
+		if err := sem.Acquire(ctx, key, n); err != nil {
+			return err
+		}
+		releases = append(
+			releases, func() {
+				sem.Release(key, n)
+			},
+		)
+		return nil
+	}
+	if stream {
+		if err := acquire(t.streams, cfg.MaxStreams); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxHTTP2Streams > 0 && t.usesHTTP2(key) {
+		if err := acquire(t.http2Streams, cfg.MaxHTTP2Streams); err != nil {
+			releaseAll()
+			return nil, err
+		}
+	}
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	return releaseAll, nil
+}
+
+// slotWeight returns the weight of a request of the given cost against the concurrency limits of its key: its cost
+// rounded up if WeightedConcurrency is set, and 1 otherwise.
+func (t *PerKeyRoundTripper[K]) slotWeight(cost float64) int64 {
+	if !t.WeightedConcurrency {
+		return 1
+	}
+	return max(int64(math.Ceil(cost-costEpsilon)), 1)
+}
+
+// HTTP2Streams returns the weight of the requests for key in flight under its MaxHTTP2Streams, which is their number
+// unless WeightedConcurrency is set.
+func (t *PerKeyRoundTripper[K]) HTTP2Streams(key K) int {
+	return int(t.http2Streams.Held(key))
+}
+
+// usesHTTP2 reports whether the responses for key were last received over HTTP/2.
+func (t *PerKeyRoundTripper[K]) usesHTTP2(key K) bool {
+	http2, _ := t.http2.Load(key)
+	return http2
+}
+
+// learnProtocol records whether resp, a response for key, was received over HTTP/2, if the key's config sets
+// MaxHTTP2Streams.
+func (t *PerKeyRoundTripper[K]) learnProtocol(key K, cfg LimiterConfig, resp *http.Response) {
+	if cfg.MaxHTTP2Streams <= 0 || resp == nil {
+		return
+	}
+	if http2 := resp.ProtoMajor == 2; http2 != t.usesHTTP2(key) {
+		t.http2.Store(key, http2)
+	}
+}
+
+// releaseOnClose calls release, if non-nil, once the body of resp is closed, or at once if there is no response.
+func releaseOnClose(resp *http.Response, release func()) {
+	if release == nil {
+		return
+	}
+	if resp == nil {
+		release()
+		return
+	}
+	resp.Body = &onCloseBody{ReadCloser: resp.Body, done: release}
+}
+
+// An onCloseBody calls done once it is first closed.
+type onCloseBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *onCloseBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,122 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// protoTransport responds to every request with an empty 200 response of the given HTTP major version.
+type protoTransport struct {
+	major int
+}
+
+func (p protoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, ProtoMajor: p.major, Body: http.NoBody, Request: req}, nil
+}
+
+func TestPerKeyRoundTripper_MaxHTTP2Streams(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    int
+		wantHeld int
+	}{
+		{name: "HTTP/2", major: 2, wantHeld: 2},
+		{name: "HTTP/1.1", major: 1, wantHeld: 0},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rt := PerOriginRoundTripper(1000, 10, protoTransport{major: tt.major})
+				rt.SetDefaultLimiterConfig(LimiterConfig{Limit: 1000, Burst: 10, MaxHTTP2Streams: 2})
+				send := func() *http.Response {
+					req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+					resp, err := rt.RoundTrip(req)
+					if err != nil {
+						t.Error(err)
+					}
+					return resp
+				}
+				// the protocol of the origin is learned from its first response
+				_ = send().Body.Close()
+				open := []*http.Response{send(), send()}
+				if held := rt.HTTP2Streams("https://example.com"); held != tt.wantHeld {
+					t.Errorf("HTTP2Streams() = %d, want %d", held, tt.wantHeld)
+				}
+				sent := make(chan *http.Response)
+				go func() {
+					sent <- send()
+				}()
+				select {
+				case resp := <-sent:
+					if tt.major == 2 {
+						t.Error("third request sent while 2 were in flight")
+					}
+					_ = resp.Body.Close()
+				case <-time.After(50 * time.Millisecond):
+					if tt.major != 2 {
+						t.Fatal("HTTP/1.1 request waited for an HTTP/2 stream")
+					}
+					_ = open[0].Body.Close()
+					_ = (<-sent).Body.Close()
+				}
+				for _, resp := range open {
+					_ = resp.Body.Close()
+				}
+				if held := rt.HTTP2Streams("https://example.com"); held != 0 {
+					t.Errorf("HTTP2Streams() = %d after all responses were closed, want 0", held)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_WeightedConcurrency(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 100, protoTransport{major: 2})
+	rt.SetDefaultLimiterConfig(LimiterConfig{Limit: 1000, Burst: 100, MaxHTTP2Streams: 3})
+	rt.WeightedConcurrency = true
+	send := func(cost float64) *http.Response {
+		req, _ := http.NewRequestWithContext(
+			WithCost(context.Background(), cost), http.MethodGet, "https://example.com/", nil,
+		)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Error(err)
+		}
+		return resp
+	}
+	_ = send(1).Body.Close()
+	tests := []struct {
+		name     string
+		cost     float64
+		wantHeld int
+	}{
+		{name: "fractional", cost: 0.5, wantHeld: 1},
+		{name: "rounded up", cost: 1.5, wantHeld: 2},
+		{name: "capped at size", cost: 10, wantHeld: 3},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				resp := send(tt.cost)
+				if held := rt.HTTP2Streams("https://example.com"); held != tt.wantHeld {
+					t.Errorf("HTTP2Streams() = %d, want %d", held, tt.wantHeld)
+				}
+				_ = resp.Body.Close()
+			},
+		)
+	}
+	heavy := send(2)
+	sent := make(chan *http.Response)
+	go func() {
+		sent <- send(2)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("request of weight 2 sent while 2 of 3 slots were held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_ = heavy.Body.Close()
+	_ = (<-sent).Body.Close()
+}
@@ -0,0 +1,91 @@
+package ratelim
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A LimiterConfig holds the rate limiting parameters of a key.
+type LimiterConfig struct {
+	// Limit and Burst are the parameters of the key's rate.Limiter.
+	Limit rate.Limit
+	Burst int
+	// Ordered, if true, makes requests for the key acquire tokens in the order in which they began waiting, which
+	// rate.Limiter alone does not guarantee.
+	Ordered bool
+	// MaxWaiters, if positive, is the maximum number of requests which may be waiting for the key at once; further
+	// requests fail immediately with a *TooManyWaitersError.
+	MaxWaiters int
+	// SoftLimit, if positive, is a rate above which requests for the key are reported, but not delayed, as measured
+	// by a separate rate.Limiter with a burst of SoftBurst (or Burst, if SoftBurst is not positive). Requests are
+	// only ever delayed to enforce the (hard) Limit.
+	SoftLimit rate.Limit
+	SoftBurst int
+	// MaxRelease, if positive, is the maximum number of tokens released to waiting requests per ReleaseInterval (or
+	// per 100ms, if ReleaseInterval is not positive), however many tokens the key's bucket holds. This smooths the
+	// spike of requests released when a limiter with a large Burst has been idle long enough to fill its bucket.
+	MaxRelease      int
+	ReleaseInterval time.Duration
+	// Timeout, if positive, bounds the time taken by each request for the key, from when it is permitted by the
+	// key's rate.Limiter until its response body is closed, like http.Client.Timeout; time spent waiting for the
+	// limiter does not count against it.
+	Timeout time.Duration
+	// MaxStreams, if positive, is the maximum number of streaming requests, as identified by the Streaming func of a
+	// PerKeyRoundTripper, which may be open for the key at once; further streaming requests wait for one to be closed.
+	// Streaming requests are then not rate limited by the key's rate.Limiter. Changes apply once no stream is open or
+	// waiting for the key.
+	MaxStreams int
+	// MaxHTTP2Streams, if positive, is the maximum number of requests for the key which may be in flight at once, from
+	// when they are sent until their response body is closed, once responses show that the key's origin speaks
+	// HTTP/2; further requests wait for one to complete. This keeps the many streams multiplexed over a single HTTP/2
+	// connection, which the transport bounds only by the limit negotiated with the server, from monopolizing it.
+	// Changes apply once no request is in flight or waiting for the key.
+	MaxHTTP2Streams int
+	// SpikeArrest, if positive, is a rate, in requests per second, which requests for the key may not exceed even
+	// momentarily, as enforced by the spike arrest policies of API gateways: time is divided into sub-intervals of
+	// SpikeArrestInterval, or of 1/SpikeArrest seconds if it is not positive, each of which permits SpikeArrest times
+	// its length in requests, rounded up, and further requests in the sub-interval fail with a *SpikeArrestError
+	// without waiting, whatever tokens the key's rate.Limiter holds. This protects an upstream from bursts on the
+	// scale of milliseconds which the Limit and Burst would permit.
+	SpikeArrest         rate.Limit
+	SpikeArrestInterval time.Duration
+	// Serialize, if true, makes requests for the key wait for their turn before being sent, one at a time and in the
+	// order in which they arrived, from before they wait for the key's rate.Limiter until their response body is
+	// closed, for upstreams which are not safe under any concurrency, such as some legacy SOAP services. Requests for
+	// the key are then never hedged.
+	Serialize bool
+}
+
+// NewLimiter returns a new rate.Limiter with the config's Limit and Burst.
+func (c LimiterConfig) NewLimiter() *rate.Limiter {
+	return rate.NewLimiter(c.Limit, c.Burst)
+}
+
+// apply updates the limit and burst of limiter to match the config.
+func (c LimiterConfig) apply(limiter *rate.Limiter) {
+	if limiter.Limit() != c.Limit {
+		limiter.SetLimit(c.Limit)
+	}
+	if limiter.Burst() != c.Burst {
+		limiter.SetBurst(c.Burst)
+	}
+}
+
+// softConfig returns the config of the rate.Limiter enforcing the config's SoftLimit.
+func (c LimiterConfig) softConfig() LimiterConfig {
+	burst := c.SoftBurst
+	if burst <= 0 {
+		burst = c.Burst
+	}
+	return LimiterConfig{Limit: c.SoftLimit, Burst: burst}
+}
+
+// smoothingConfig returns the config of the rate.Limiter enforcing the config's MaxRelease.
+func (c LimiterConfig) smoothingConfig() LimiterConfig {
+	interval := c.ReleaseInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	return LimiterConfig{Limit: rate.Every(interval / time.Duration(c.MaxRelease)), Burst: c.MaxRelease}
+}
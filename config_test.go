@@ -0,0 +1,23 @@
+package ratelim
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_MaxRelease(t *testing.T) {
+	transport := PerOriginRoundTripper(1, 1, nil)
+	transport.SetLimiterConfig("k", LimiterConfig{Limit: 1, Burst: 100, MaxRelease: 5, ReleaseInterval: 50 * time.Millisecond})
+	limiter := transport.limiter("k")
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		if err := transport.wait(context.Background(), "k", transport.LimiterConfig("k"), limiter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 5 tokens are released immediately, then one every 10ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("15 requests released in %v, want ~100ms", elapsed)
+	}
+}
@@ -0,0 +1,84 @@
+package ratelim
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// A ConfigSource is a source of dynamic config, such as a file or a key in a KV store, which can be watched for
+// changes to apply them to running transports.
+type ConfigSource interface {
+	// Watch calls apply with the current contents of the config, failing if it cannot, then again with the new
+	// contents each time they change, until ctx is done.
+	Watch(ctx context.Context, apply func(r io.Reader) error) error
+}
+
+// A ConfigFile applies the limits read from a file, and reapplies them whenever its contents change, so that limits can
+// be tuned without restarts. It suits a Kubernetes ConfigMap mounted as a volume: the kubelet updates the mounted file
+// by swapping a symbolic link, which a ConfigFile notices since it rereads the file by path every Interval.
+type ConfigFile struct {
+	// Path is the path of the file.
+	Path string
+	// Interval is how often the file is reread; if not positive, 10s is used.
+	Interval time.Duration
+	// Apply applies the contents of the file, e.g. Manager.LoadConfig, or LoadTransportConfig for a single transport.
+	Apply func(r io.Reader) error
+	// OnError, if non-nil, is called with the errors reading or applying the file after it was first applied, which
+	// leave the limits last applied in place.
+	OnError func(err error)
+}
+
+var _ ConfigSource = (*ConfigFile)(nil)
+
+// Run applies the file, failing if it cannot, then reapplies it whenever its contents change, until ctx is done.
+func (f *ConfigFile) Run(ctx context.Context) error {
+	return f.Watch(ctx, f.Apply)
+}
+
+// Watch implements ConfigSource, applying the file with apply rather than Apply.
+func (f *ConfigFile) Watch(ctx context.Context, apply func(r io.Reader) error) error {
+	applied, err := f.load(apply, nil)
+	if err != nil {
+		return err
+	}
+	interval := f.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		data, err := f.load(apply, applied)
+		if err != nil {
+			if f.OnError != nil {
+				f.OnError(err)
+			}
+			continue
+		}
+		applied = data
+	}
+}
+
+// load reads the file and applies it unless its contents are those last applied, and returns its contents.
+func (f *ConfigFile) load(apply func(r io.Reader) error, applied []byte) ([]byte, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return applied, fmt.Errorf("ratelim: reading config file: %w", err)
+	}
+	if applied != nil && bytes.Equal(data, applied) {
+		return applied, nil
+	}
+	if err := apply(bytes.NewReader(data)); err != nil {
+		return applied, fmt.Errorf("ratelim: applying config file %s: %w", f.Path, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,96 @@
+package ratelim
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigMap writes data as the file name of a ConfigMap volume mounted at dir, as the kubelet does: into a new
+// directory, which the ..data link is then atomically swapped to.
+func writeConfigMap(t *testing.T, dir, version, name, data string) {
+	t.Helper()
+	versioned := filepath.Join(dir, "..data_"+version)
+	if err := os.Mkdir(versioned, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(versioned, name), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(versioned), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, name)); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigFile_Run(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMap(t, dir, "1", "limits.json", `{"default": {"Limit": 5, "Burst": 1}}`)
+	rt := PerOriginRoundTripper(1, 1, nil)
+	errs := make(chan error, 10)
+	f := &ConfigFile{
+		Path:     filepath.Join(dir, "limits.json"),
+		Interval: 10 * time.Millisecond,
+		Apply: func(r io.Reader) error {
+			return LoadTransportConfig(r, rt)
+		},
+		OnError: func(err error) {
+			errs <- err
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- f.Run(ctx)
+	}()
+	waitFor := func(limit float64) {
+		t.Helper()
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if float64(rt.DefaultLimiterConfig().Limit) == limit {
+				return
+			}
+		}
+		t.Fatalf("default limit = %v, want %v", rt.DefaultLimiterConfig().Limit, limit)
+	}
+	waitFor(5)
+
+	writeConfigMap(t, dir, "2", "limits.json", `{"default": {"Limit": 7, "Burst": 2}}`)
+	waitFor(7)
+	writeConfigMap(t, dir, "3", "limits.json", `{"default": `)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("OnError called with a nil error")
+		}
+	case <-time.After(time.Second):
+		t.Error("OnError not called for an invalid config")
+	}
+	if got := rt.DefaultLimiterConfig().Limit; got != 7 {
+		t.Errorf("default limit = %v after an invalid config, want 7 kept", got)
+	}
+	cancel()
+	<-done
+}
+
+func TestConfigFile_RunMissing(t *testing.T) {
+	f := &ConfigFile{
+		Path: filepath.Join(t.TempDir(), "missing.json"),
+		Apply: func(io.Reader) error {
+			return nil
+		},
+	}
+	if err := f.Run(context.Background()); err == nil {
+		t.Error("Run() succeeded with a missing file")
+	}
+}
@@ -0,0 +1,143 @@
+// Package consul provides a ratelim.ConfigSource reading limits from the Consul KV store, for deployments which
+// centralize their operational settings in Consul. It uses Consul's HTTP API directly, without its client library.
+package consul
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+// A KV watches a key of the Consul KV store with blocking queries, so that changes to its value reach running
+// transports as soon as Consul reports them.
+type KV struct {
+	// Address is the base URL of the Consul HTTP API; if empty, http://127.0.0.1:8500 is used.
+	Address string
+	// Key is the KV key holding the config, e.g. Manager.LoadConfig's JSON.
+	Key string
+	// Token, if set, is the ACL token sent with each query.
+	Token string
+	// Client sends the queries; if nil, http.DefaultClient is used. Its Timeout, if any, must exceed WaitTime.
+	Client *http.Client
+	// WaitTime is how long each blocking query waits for a change; if not positive, 5m is used.
+	WaitTime time.Duration
+	// RetryInterval is how long to wait after a failed query before retrying; if not positive, 5s is used.
+	RetryInterval time.Duration
+	// OnError, if non-nil, is called with the errors querying or applying the key after it was first applied, which
+	// leave the limits last applied in place.
+	OnError func(err error)
+}
+
+var _ ratelim.ConfigSource = (*KV)(nil)
+
+// Watch implements ratelim.ConfigSource.
+func (kv *KV) Watch(ctx context.Context, apply func(r io.Reader) error) error {
+	value, index, err := kv.get(ctx, 0)
+	if err != nil {
+		return err
+	}
+	if err := apply(bytes.NewReader(value)); err != nil {
+		return fmt.Errorf("consul: applying %s: %w", kv.Key, err)
+	}
+	applied := value
+	for {
+		value, next, err := kv.get(ctx, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil && !bytes.Equal(value, applied) {
+			if err = apply(bytes.NewReader(value)); err != nil {
+				err = fmt.Errorf("consul: applying %s: %w", kv.Key, err)
+			} else {
+				applied = value
+			}
+		}
+		if err != nil {
+			if kv.OnError != nil {
+				kv.OnError(err)
+			}
+			if err := sleep(ctx, kv.retryInterval()); err != nil {
+				return err
+			}
+			continue
+		}
+		// a lower index means the KV store was reset, and the next query must start over
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// get returns the value of the key and its index, blocking until the index exceeds index if it is positive.
+func (kv *KV) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	address := kv.Address
+	if address == "" {
+		address = "http://127.0.0.1:8500"
+	}
+	wait := kv.WaitTime
+	if wait <= 0 {
+		wait = 5 * time.Minute
+	}
+	query := url.Values{"raw": {""}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+	}
+	u := strings.TrimSuffix(address, "/") + "/v1/kv/" + strings.TrimPrefix(kv.Key, "/") + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	if kv.Token != "" {
+		req.Header.Set("X-Consul-Token", kv.Token)
+	}
+	client := kv.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: querying %s: %w", kv.Key, err)
+	}
+	defer resp.Body.Close()
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: reading %s: %w", kv.Key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul: querying %s: %s", kv.Key, resp.Status)
+	}
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: invalid X-Consul-Index for %s: %w", kv.Key, err)
+	}
+	return value, next, nil
+}
+
+func (kv *KV) retryInterval() time.Duration {
+	if kv.RetryInterval > 0 {
+		return kv.RetryInterval
+	}
+	return 5 * time.Second
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
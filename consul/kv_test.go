@@ -0,0 +1,113 @@
+package consul
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul serves a single KV key with blocking queries, as Consul does.
+type fakeConsul struct {
+	mux     sync.Mutex
+	value   string
+	index   uint64
+	changed chan struct{}
+}
+
+func (c *fakeConsul) set(value string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.value = value
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/kv/ratelim/limits" || r.Header.Get("X-Consul-Token") != "secret" {
+		http.NotFound(w, r)
+		return
+	}
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	c.mux.Lock()
+	if index >= c.index {
+		changed := c.changed
+		c.mux.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		c.mux.Lock()
+	}
+	value, current := c.value, c.index
+	c.mux.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(current, 10))
+	_, _ = io.WriteString(w, value)
+}
+
+func TestKV_Watch(t *testing.T) {
+	consul := &fakeConsul{value: "v1", index: 1, changed: make(chan struct{})}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+	kv := &KV{Address: server.URL, Key: "ratelim/limits", Token: "secret", RetryInterval: 10 * time.Millisecond}
+
+	applied := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- kv.Watch(
+			ctx, func(r io.Reader) error {
+				data, _ := io.ReadAll(r)
+				applied <- string(data)
+				return nil
+			},
+		)
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case v := <-applied:
+			return v
+		case <-time.After(time.Second):
+			t.Fatal("no value applied")
+			return ""
+		}
+	}
+	if got := next(); got != "v1" {
+		t.Errorf("applied %q, want v1", got)
+	}
+	consul.set("v2")
+	if got := next(); got != "v2" {
+		t.Errorf("applied %q, want v2", got)
+	}
+	// a change of index without a change of value is not applied again
+	consul.set("v2")
+	consul.set("v3")
+	if got := next(); got != "v3" {
+		t.Errorf("applied %q, want v3", got)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Watch() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestKV_WatchMissing(t *testing.T) {
+	server := httptest.NewServer(&fakeConsul{changed: make(chan struct{})})
+	defer server.Close()
+	kv := &KV{Address: server.URL, Key: "missing", Token: "secret"}
+	err := kv.Watch(
+		context.Background(), func(io.Reader) error {
+			return nil
+		},
+	)
+	if err == nil {
+		t.Error("Watch() succeeded for a missing key")
+	}
+}
@@ -0,0 +1,204 @@
+package ratelim
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// HeaderCost returns a function suitable for PerKeyRoundTripper.ResponseCost which reads the cost of a request from
+// the first of the named response headers holding a finite, non-negative number, such as X-Request-Cost or
+// X-GraphQL-Cost. Fractional costs are rounded up, and costs beyond maxCost are reduced to it, since they are set by
+// the server; if no header holds such a number, the cost is 1.
+func HeaderCost(names ...string) func(resp *http.Response) int {
+	return func(resp *http.Response) int {
+		for _, name := range names {
+			v := strings.TrimSpace(resp.Header.Get(name))
+			if v == "" {
+				continue
+			}
+			if cost, err := strconv.ParseFloat(v, 64); err == nil && validCost(cost) {
+				return int(math.Ceil(min(cost, maxCost)))
+			}
+		}
+		return 1
+	}
+}
+
+// maxCost bounds the cost of a single request, so that any cost converts to a whole number of tokens.
+const maxCost = math.MaxInt32
+
+// validCost reports whether cost is a finite, non-negative number.
+func validCost(cost float64) bool {
+	return cost >= 0 && !math.IsInf(cost, 1)
+}
+
+// A CostEstimator determines the cost in tokens of the requests sent through a PerKeyRoundTripper in two steps, so
+// that APIs whose cost is only known from the response, such as GraphQL APIs reporting the cost of each query, can be
+// charged a provisional cost before each request is sent, and the actual cost once its response is received.
+type CostEstimator interface {
+	// EstimateCost returns the cost of req charged before it is sent, which may be fractional.
+	EstimateCost(req *http.Request) float64
+	// SettleCost returns the actual cost of req once its response resp is received, and whether it is known. If so,
+	// any cost beyond the estimate is charged to the limiter of its key, and any estimate beyond the cost is credited
+	// to the key's later requests.
+	SettleCost(req *http.Request, resp *http.Response) (cost float64, ok bool)
+}
+
+// CostFuncs implements CostEstimator with functions, either of which may be nil: without Estimate, each request is
+// estimated to cost 1 token, and without Settle, the estimate stands.
+type CostFuncs struct {
+	Estimate func(req *http.Request) float64
+	Settle   func(req *http.Request, resp *http.Response) (cost float64, ok bool)
+}
+
+func (f CostFuncs) EstimateCost(req *http.Request) float64 {
+	if f.Estimate == nil {
+		return 1
+	}
+	return f.Estimate(req)
+}
+
+func (f CostFuncs) SettleCost(req *http.Request, resp *http.Response) (cost float64, ok bool) {
+	if f.Settle == nil {
+		return 0, false
+	}
+	return f.Settle(req, resp)
+}
+
+// maxChargeBursts bounds a charge to that many times the limiter's burst, so that a cost reported by a server cannot
+// put a key in debt for an unbounded time, nor keep charge reserving for an unbounded time.
+const maxChargeBursts = 64
+
+// charge consumes n tokens from limiter without waiting, so that subsequent reservations are delayed accordingly.
+// Since a single reservation may not exceed the limiter's burst, larger charges are split into several reservations,
+// and charges beyond maxChargeBursts times the burst are reduced to it.
+func charge(limiter *rate.Limiter, n int) {
+	now := time.Now()
+	n = min(n, maxChargeBursts*max(limiter.Burst(), 1))
+	for n > 0 {
+		chunk := n
+		if burst := limiter.Burst(); chunk > burst {
+			chunk = burst
+		}
+		if chunk <= 0 || !limiter.ReserveN(now, chunk).OK() {
+			return
+		}
+		n -= chunk
+	}
+}
+
+type costKey struct{}
+
+// WithCost returns a copy of ctx carrying the cost in tokens of the requests using it, which may be fractional,
+// overriding the RequestCost of a PerKeyRoundTripper.
+func WithCost(ctx context.Context, cost float64) context.Context {
+	return context.WithValue(ctx, costKey{}, cost)
+}
+
+// CostFromContext returns the cost set on ctx by WithCost, if any.
+func CostFromContext(ctx context.Context) (cost float64, ok bool) {
+	cost, ok = ctx.Value(costKey{}).(float64)
+	return cost, ok
+}
+
+// costEpsilon absorbs the rounding errors of fractional costs, so that e.g. ten costs of 0.1 take a single token.
+const costEpsilon = 1e-9
+
+// fractionalCost carries the fractions of the tokens taken for a key over to its later requests, so that fractional
+// costs can be charged to a rate.Limiter, which only deals in whole tokens.
+type fractionalCost struct {
+	mux    sync.Mutex
+	credit float64
+}
+
+// tokens returns the number of whole tokens to take for a cost, spending any credit left over by previous costs
+// first and keeping the fraction of the last token not spent as credit.
+func (c *fractionalCost) tokens(cost float64) int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if cost <= c.credit+costEpsilon {
+		c.credit = max(c.credit-cost, 0)
+		return 0
+	}
+	n := math.Ceil(cost - c.credit - costEpsilon)
+	c.credit += n - cost
+	return int(n)
+}
+
+// refund credits cost to later costs, keeping the credit within limit, so that an overestimated cost is not lost, but
+// neither lets a burst beyond the limiter's through.
+func (c *fractionalCost) refund(cost, limit float64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.credit = max(min(c.credit+cost, limit), c.credit)
+}
+
+func (t *PerKeyRoundTripper[K]) fractionalCost(key K) *fractionalCost {
+	return loadOrCompute[K](t.costs, key, newValue[fractionalCost])
+}
+
+// requestTokens returns the cost of req, set by WithCost, CostEstimator or RequestCost, and the whole number of tokens
+// to take for it from the limiter of key. A cost which is not a number or is infinite counts as 1 token, and one beyond
+// maxCost as maxCost.
+func (t *PerKeyRoundTripper[K]) requestTokens(req *http.Request, key K) (cost float64, tokens int) {
+	cost, ok := CostFromContext(req.Context())
+	if !ok {
+		switch {
+		case t.CostEstimator != nil:
+			cost = t.CostEstimator.EstimateCost(req)
+		case t.RequestCost != nil:
+			cost = t.RequestCost(req)
+		default:
+			return 1, 1
+		}
+	}
+	if cost <= 0 {
+		return 0, 0
+	}
+	if !validCost(cost) {
+		return 1, 1
+	}
+	cost = min(cost, maxCost)
+	return cost, t.fractionalCost(key).tokens(cost)
+}
+
+// settleCost settles the cost of req, which was charged estimate before being sent, once its response resp is
+// received: the actual cost, as returned by CostEstimator or ResponseCost, is charged to limiter, the limiter of key,
+// beyond the estimate, and credited to the key's later requests below it. ResponseCost only ever charges more.
+func (t *PerKeyRoundTripper[K]) settleCost(
+	key K,
+	limiter *rate.Limiter,
+	req *http.Request,
+	resp *http.Response,
+	estimate float64,
+) {
+	if resp == nil {
+		return
+	}
+	var cost float64
+	switch {
+	case t.CostEstimator != nil:
+		settled, ok := t.CostEstimator.SettleCost(req, resp)
+		if !ok || math.IsNaN(settled) {
+			return
+		}
+		cost = min(max(settled, 0), maxCost)
+	case t.ResponseCost != nil:
+		cost = max(float64(t.ResponseCost(resp)), estimate)
+	default:
+		return
+	}
+	switch diff := cost - estimate; {
+	case diff > costEpsilon:
+		charge(limiter, t.fractionalCost(key).tokens(diff))
+	case diff < -costEpsilon:
+		t.fractionalCost(key).refund(-diff, float64(limiter.Burst()))
+	}
+}
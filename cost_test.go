@@ -0,0 +1,160 @@
+package ratelim
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestHeaderCost(t *testing.T) {
+	cost := HeaderCost("X-Request-Cost", "X-GraphQL-Cost")
+	tests := []struct {
+		header http.Header
+		want   int
+	}{
+		{http.Header{}, 1},
+		{http.Header{"X-Request-Cost": []string{"5"}}, 5},
+		{http.Header{"X-Graphql-Cost": []string{"2.5"}}, 3},
+		{http.Header{"X-Request-Cost": []string{"n/a"}, "X-Graphql-Cost": []string{"0"}}, 0},
+		{http.Header{"X-Request-Cost": []string{"NaN"}}, 1},
+		{http.Header{"X-Request-Cost": []string{"+Inf"}}, 1},
+		{http.Header{"X-Request-Cost": []string{"-3"}}, 1},
+		{http.Header{"X-Request-Cost": []string{"1e12"}}, maxCost},
+	}
+	for _, tt := range tests {
+		if got := cost(&http.Response{Header: tt.header}); got != tt.want {
+			t.Errorf("cost(%v) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCharge(t *testing.T) {
+	limiter := rate.NewLimiter(10, 2)
+	charge(limiter, 5)
+	// 2 tokens available from the burst, so 3 are owed and the next token is available after 0.4s
+	delay := limiter.Reserve().Delay()
+	if delay < 350*time.Millisecond || delay > 400*time.Millisecond {
+		t.Errorf("delay after charge = %v, want ~400ms", delay)
+	}
+
+	// a charge far beyond the burst is bounded, rather than reserving for ever
+	limiter = rate.NewLimiter(1000, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		charge(limiter, 1e12)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("charge(1e12) did not return")
+	}
+	delay = limiter.Reserve().Delay()
+	if want := maxChargeBursts * time.Millisecond; delay < want-10*time.Millisecond || delay > want {
+		t.Errorf("delay after charge = %v, want ~%v", delay, want)
+	}
+}
+
+func TestFractionalCost_tokens(t *testing.T) {
+	var c fractionalCost
+	var got []int
+	for _, cost := range []float64{0.5, 0.5, 0.5, 0.1, 0.1, 0.1, 0.1, 0.1, 2.5, 1} {
+		got = append(got, c.tokens(cost))
+	}
+	// 0.5 + 0.5 take one token; 0.5 and the five 0.1 take another; 2.5 takes 3 leaving 0.5, so 1 takes one more
+	want := []int{1, 0, 1, 0, 0, 0, 0, 0, 3, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("tokens = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPerKeyRoundTripper_RequestCost(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	rt := PerOriginRoundTripper(20, 1, nil)
+	rt.RequestCost = func(req *http.Request) float64 {
+		if req.Method == http.MethodHead {
+			return 0.5
+		}
+		return 1
+	}
+	send := func(ctx context.Context, method string) {
+		req, _ := http.NewRequestWithContext(ctx, method, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	// four HEAD requests take two tokens, and two free requests none, so only the second token is waited for
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		send(context.Background(), http.MethodHead)
+	}
+	send(WithCost(context.Background(), 0), http.MethodGet)
+	send(WithCost(context.Background(), 0), http.MethodGet)
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > 90*time.Millisecond {
+		t.Errorf("requests took %v, want about 50ms", elapsed)
+	}
+}
+
+func TestPerKeyRoundTripper_CostEstimator(t *testing.T) {
+	tests := []struct {
+		name       string
+		costs      []string
+		wantTokens float64
+	}{
+		{
+			name:       "no cost reported",
+			costs:      []string{"", ""},
+			wantTokens: 8,
+		},
+		{
+			name:       "cost beyond the estimate",
+			costs:      []string{"3", "3"},
+			wantTokens: 4,
+		},
+		{
+			name: "free request credited",
+			// the second request is charged the credit of the first
+			costs:      []string{"0", "1"},
+			wantTokens: 9,
+		},
+	}
+	settle := func(req *http.Request, resp *http.Response) (float64, bool) {
+		cost, err := strconv.ParseFloat(resp.Header.Get("X-Cost"), 64)
+		return cost, err == nil
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rt := PerOriginRoundTripper(rate.Every(time.Hour), 10, nil)
+				rt.CostEstimator = CostFuncs{Settle: settle}
+				for _, cost := range tt.costs {
+					rt.RoundTripper = headerTransport{"X-Cost": {cost}}
+					if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if tokens := rt.Limiter(mustNewRequest(t)).Tokens(); math.Abs(tokens-tt.wantTokens) > 0.01 {
+					t.Errorf("limiter has %v tokens, want %v", tokens, tt.wantTokens)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,200 @@
+package ratelim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Cron is a parsed cron expression, which matches the minutes at which its fields all match.
+type Cron struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	lastDom bool
+	// anyDom and anyDow record unrestricted day fields: as in cron(8), when both are restricted a day matches if
+	// either matches.
+	anyDom, anyDow bool
+}
+
+// maxCronSearchDays bounds the search for a matching day, which covers every expression able to match at all (such as
+// one matching only the 29th of February).
+const maxCronSearchDays = 5 * 366
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseCron parses a standard five-field cron expression ("minute hour day-of-month month day-of-week"). Fields may
+// be "*", numbers, ranges ("1-5"), steps ("*/15", "0-30/10") and comma-separated lists of these; months and days of
+// the week may also be given by their three-letter English names, and day 7 of the week is Sunday, as is day 0. The
+// day-of-month field may also be "L", matching the last day of the month.
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("ratelim: cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+	c := &Cron{expr: expr}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("ratelim: cron expression %q: minute: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("ratelim: cron expression %q: hour: %w", expr, err)
+	}
+	if fields[2] == "L" {
+		c.lastDom = true
+	} else if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("ratelim: cron expression %q: day of month: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("ratelim: cron expression %q: month: %w", expr, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("ratelim: cron expression %q: day of week: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.anyDom = fields[2] == "*"
+	c.anyDow = fields[4] == "*"
+	return c, nil
+}
+
+// MustParseCron is like ParseCron but panics if expr cannot be parsed.
+func MustParseCron(expr string) *Cron {
+	c, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseCronValue(first, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseCronValue(last, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	if c.lastDom {
+		domMatch = t.AddDate(0, 0, 1).Day() == 1
+	}
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dowMatch
+	case c.anyDow:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first time after t matched by c, or the zero time.Time if there is none.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for days := 0; days < maxCronSearchDays; days++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+		if !c.matchesDay(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if c.minute&(1<<m) == 0 {
+					continue
+				}
+				if next := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location()); !next.Before(t) {
+					return next
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Prev returns the last time at or before t matched by c, or the zero time.Time if there is none.
+func (c *Cron) Prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	for days := 0; days < maxCronSearchDays; days++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location())
+		if !c.matchesDay(day) {
+			continue
+		}
+		for h := 23; h >= 0; h-- {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := 59; m >= 0; m-- {
+				if c.minute&(1<<m) == 0 {
+					continue
+				}
+				if prev := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location()); !prev.After(t) {
+					return prev
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
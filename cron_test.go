@@ -0,0 +1,92 @@
+package ratelim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		t    time.Time
+		next time.Time
+		prev time.Time
+	}{
+		{"*/15 * * * *", at(1, 1, 10, 7), at(1, 1, 10, 15), at(1, 1, 10, 0)},
+		{"0 9 * * mon-fri", at(1, 5, 12, 0), at(1, 8, 9, 0), at(1, 5, 9, 0)},
+		{"0 0 * * 7", at(1, 3, 0, 0), at(1, 7, 0, 0), at(12, 31, 0, 0).AddDate(-1, 0, 0)},
+		{"30 18 L * *", at(2, 10, 0, 0), at(2, 29, 18, 30), at(1, 31, 18, 30)},
+		{"0 0 29 feb *", at(3, 1, 0, 0), at(2, 29, 0, 0).AddDate(4, 0, 0), at(2, 29, 0, 0)},
+		{"0 12 1 * mon", at(1, 2, 0, 0), at(1, 8, 12, 0), at(1, 1, 12, 0)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Next(tt.t); !got.Equal(tt.next) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.t, got, tt.next)
+		}
+		if got := c.Prev(tt.t); !got.Equal(tt.prev) {
+			t.Errorf("%q.Prev(%v) = %v, want %v", tt.expr, tt.t, got, tt.prev)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestProfileScheduler(t *testing.T) {
+	s, err := NewProfileScheduler[string](
+		map[string]LimiterConfig{
+			"day":    {Limit: 1, Burst: 1},
+			"night":  {Limit: 10, Burst: 10},
+			"freeze": {Limit: 0, Burst: 0},
+		},
+		[]ProfileRule{
+			{Pattern: "https://*.example.com", Cron: "0 9 * * *", Profile: "day"},
+			{Pattern: "https://*.example.com", Cron: "0 18 * * *", Profile: "night"},
+			{Pattern: "https://*.example.com", Cron: "0 0 L * *", Profile: "freeze"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.update(time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local))
+	if profile, _ := s.ActiveProfile("https://api.example.com"); profile != "day" {
+		t.Errorf("active profile = %q, want %q", profile, "day")
+	}
+	s.update(time.Date(2024, 1, 31, 3, 0, 0, 0, time.Local))
+	if cfg, _ := s.LimiterConfig("https://api.example.com"); cfg.Limit != 0 {
+		t.Errorf("freeze not active: %+v", cfg)
+	}
+	if _, ok := s.LimiterConfig("https://example.org"); ok {
+		t.Error("unmatched key has a profile")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"https://*.example.com", "https://api.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"GET *", "GET https://x", true},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "acb", false},
+		{"a*a", "a", false},
+		{"exact", "exact", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ratelim
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A KeyStatus is a snapshot of the state of a key of a PerKeyRoundTripper.
+type KeyStatus struct {
+	Key   string
+	Limit rate.Limit
+	Burst int
+	// Tokens is the number of tokens available in the key's limiter, which is negative while requests hold
+	// reservations beyond them.
+	Tokens float64
+	// Waiting is the number of requests waiting to be permitted.
+	Waiting int
+	Stats   Stats
+	// RecentWait is the distribution of the time requests waited in the last window of the key's Stats, if
+	// StatsRotation is set and a window has ended, and otherwise since its Stats were last reset.
+	RecentWait Histogram
+}
+
+// Waiting returns the number of requests for key waiting to be permitted.
+func (t *PerKeyRoundTripper[K]) Waiting(key K) int {
+	if waiters, ok := t.waiters.Load(key); ok {
+		return int(waiters.Load())
+	}
+	return 0
+}
+
+// KeyStatuses returns the KeyStatus of every key with a limiter, sorted by key.
+func (t *PerKeyRoundTripper[K]) KeyStatuses() []KeyStatus {
+	now := time.Now()
+	var statuses []KeyStatus
+	t.limiters.Range(
+		func(key K, limiter *rate.Limiter) bool {
+			status := KeyStatus{
+				Key:     fmt.Sprint(key),
+				Limit:   limiter.Limit(),
+				Burst:   limiter.Burst(),
+				Tokens:  limiter.TokensAt(now),
+				Waiting: t.Waiting(key),
+				Stats:   t.Stats(key),
+			}
+			status.RecentWait = status.Stats.Wait
+			if windows := t.StatsWindows(key); len(windows) > 0 {
+				status.RecentWait = windows[len(windows)-1].Wait
+			}
+			statuses = append(statuses, status)
+			return true
+		},
+	)
+	slices.SortFunc(
+		statuses, func(a, b KeyStatus) int {
+			return strings.Compare(a.Key, b.Key)
+		},
+	)
+	return statuses
+}
+
+// DebugHandler returns an http.Handler serving an HTML page of the KeyStatuses of the transport, which refreshes
+// itself every 2 seconds, or every refresh seconds if set in the query, for a quick look at its limiters during an
+// incident without a metrics stack. It is typically mounted at /debug/ratelim.
+func (t *PerKeyRoundTripper[K]) DebugHandler() http.Handler {
+	return debugHandler(
+		func() []debugTransport {
+			return []debugTransport{{Name: t.name(), Keys: t.KeyStatuses()}}
+		},
+	)
+}
+
+// DebugHandler returns an http.Handler serving an HTML page of the KeyStatuses of every registered transport, as
+// PerKeyRoundTripper.DebugHandler does.
+func (m *Manager) DebugHandler() http.Handler {
+	return debugHandler(
+		func() []debugTransport {
+			var all []debugTransport
+			m.each(
+				func(name string, t *PerKeyRoundTripper[string]) {
+					all = append(all, debugTransport{Name: name, Keys: t.KeyStatuses()})
+				},
+			)
+			return all
+		},
+	)
+}
+
+type debugTransport struct {
+	Name string
+	Keys []KeyStatus
+}
+
+var debugTemplate = template.Must(
+	template.New("debug").Funcs(
+		template.FuncMap{
+			"limit": func(l rate.Limit) string {
+				if l == rate.Inf {
+					return "unlimited"
+				}
+				return strconv.FormatFloat(float64(l), 'f', 2, 64)
+			},
+			"tokens": func(tokens float64) string {
+				return strconv.FormatFloat(tokens, 'f', 2, 64)
+			},
+		},
+	).Parse(
+		`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>ratelim</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.waiting { background: #fff3cd; }
+</style>
+</head>
+<body>
+<p>Updated {{.Now.Format "2006-01-02 15:04:05 MST"}}, refreshing every {{.Refresh}}s.</p>
+{{range .Transports}}
+<h2>{{with .Name}}{{.}}{{else}}ratelim{{end}}</h2>
+<table>
+<tr>
+<th>key</th><th>limit (req/s)</th><th>burst</th><th>tokens</th><th>waiting</th><th>requests</th>
+<th>recent mean wait</th><th>recent p99 wait</th><th>p99 wait</th>
+</tr>
+{{range .Keys}}
+<tr{{if .Waiting}} class="waiting"{{end}}>
+<td>{{.Key}}</td><td>{{limit .Limit}}</td><td>{{.Burst}}</td><td>{{tokens .Tokens}}</td><td>{{.Waiting}}</td>
+<td>{{.Stats.Requests}}</td><td>{{.RecentWait.Mean}}</td><td>{{.RecentWait.Quantile 0.99}}</td>
+<td>{{.Stats.Wait.Quantile 0.99}}</td>
+</tr>
+{{else}}
+<tr><td colspan="9">no keys</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`,
+	),
+)
+
+func debugHandler(transports func() []debugTransport) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			refresh := 2
+			if n, err := strconv.Atoi(r.URL.Query().Get("refresh")); err == nil && n > 0 {
+				refresh = n
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = debugTemplate.Execute(
+				w, struct {
+					Now        time.Time
+					Refresh    int
+					Transports []debugTransport
+				}{time.Now(), refresh, transports()},
+			)
+		},
+	)
+}
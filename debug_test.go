@@ -0,0 +1,88 @@
+package ratelim
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_KeyStatuses(t *testing.T) {
+	rt := PerOriginRoundTripper(10, 1, &flakyTransport{})
+	for _, target := range []string{"https://b.example.com/", "https://a.example.com/", "https://a.example.com/"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://a.example.com/", nil)
+		_, _ = rt.RoundTrip(req)
+	}()
+	for rt.Waiting("https://a.example.com") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	statuses := rt.KeyStatuses()
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+	a, b := statuses[0], statuses[1]
+	if a.Key != "https://a.example.com" || b.Key != "https://b.example.com" {
+		t.Errorf("keys = %q, %q, want sorted origins", a.Key, b.Key)
+	}
+	if a.Limit != 10 || a.Burst != 1 || a.Waiting != 1 || a.Stats.Requests != 2 || a.Tokens >= 0 ||
+		a.RecentWait.Count != 2 {
+		t.Errorf("status of a = %+v", a)
+	}
+	if b.Waiting != 0 || b.Stats.Requests != 1 {
+		t.Errorf("status of b = %+v", b)
+	}
+	cancel()
+	<-done
+	if n := rt.Waiting("https://a.example.com"); n != 0 {
+		t.Errorf("Waiting() = %d after the request was canceled, want 0", n)
+	}
+}
+
+func TestManager_DebugHandler(t *testing.T) {
+	m := NewManager()
+	rt := PerOriginRoundTripper(10, 1, &flakyTransport{})
+	if err := m.Register("api", rt); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("idle", PerOriginRoundTripper(10, 1, &flakyTransport{})); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(m.DebugHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?refresh=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	for _, want := range []string{
+		`<meta http-equiv="refresh" content="5">`,
+		"<h2>api</h2>", "<h2>idle</h2>", "<td>https://example.com</td><td>10.00</td><td>1</td>",
+		"no keys",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("page does not contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ratelim
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A Discovery configures how a PerKeyRoundTripper discovers the rate limits of a key before sending it any other
+// requests. The first request for each key is preceded by a probe request to the same URL, and the quota policies
+// advertised in the probe's response headers (see ParseRateLimitPolicy) are used to seed the key's LimiterConfig. If
+// the probe's response advertises no policies, the Fallback config is used instead. If the probe fails, e.g. with a
+// network error or a timeout, the key's config is left unchanged and discovery is tried again with its next request.
+type Discovery struct {
+	// Method is the method of the probe request; if empty, http.MethodHead is used.
+	Method string
+	// Timeout limits the duration of the probe request, which is not canceled with the request it precedes; if zero,
+	// 10 seconds is used.
+	Timeout time.Duration
+	// Fallback is the config used when no limits could be discovered; if its Limit is zero, 1 request per second
+	// with a burst of 1 is used.
+	Fallback LimiterConfig
+}
+
+func (d *Discovery) fallback() LimiterConfig {
+	if d.Fallback.Limit == 0 {
+		return LimiterConfig{Limit: 1, Burst: 1}
+	}
+	return d.Fallback
+}
+
+// LimiterConfigForPolicies returns the config for the most restrictive of the given policies, with a burst of one
+// second's worth of requests, or false if policies is empty.
+func LimiterConfigForPolicies(policies []QuotaPolicy) (LimiterConfig, bool) {
+	if len(policies) == 0 {
+		return LimiterConfig{}, false
+	}
+	limit := rate.Inf
+	for _, p := range policies {
+		if l := p.Limit(); l < limit {
+			limit = l
+		}
+	}
+	burst := int(math.Ceil(float64(limit)))
+	if burst < 1 {
+		burst = 1
+	}
+	return LimiterConfig{Limit: limit, Burst: burst}, true
+}
+
+// Discover sends a probe request for the key of req, as configured by the Discovery field (or a zero Discovery, if
+// nil), and sets the key's LimiterConfig to the discovered limits, or to the fallback config if the probe's response
+// advertises none. The probe is sent directly through the underlying http.RoundTripper, bypassing the rate limiter,
+// with a context which keeps the values of the context of req but is not canceled with it. If the probe fails, the
+// key's config is left unchanged and the error is returned.
+func (t *PerKeyRoundTripper[K]) Discover(req *http.Request) (LimiterConfig, error) {
+	return t.discoverKey(req, t.Key(req))
+}
+
+// discoverKey runs Discover for key, the key of req.
+func (t *PerKeyRoundTripper[K]) discoverKey(req *http.Request, key K) (LimiterConfig, error) {
+	d := t.Discovery
+	if d == nil {
+		d = &Discovery{}
+	}
+	cfg, err := t.probe(d, req, key)
+	if err != nil {
+		return LimiterConfig{}, err
+	}
+	t.SetLimiterConfig(key, cfg)
+	return cfg, nil
+}
+
+func (t *PerKeyRoundTripper[K]) probe(d *Discovery, req *http.Request, key K) (LimiterConfig, error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	method := d.Method
+	if method == "" {
+		method = http.MethodHead
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	defer cancel()
+	probe, err := http.NewRequestWithContext(ctx, method, req.URL.String(), nil)
+	if err != nil {
+		return LimiterConfig{}, err
+	}
+	probe.Header = req.Header.Clone()
+	probe.Host = req.Host
+	resp, err := t.transport(key).RoundTrip(probe)
+	if err != nil {
+		return LimiterConfig{}, err
+	}
+	_ = resp.Body.Close()
+	if cfg, ok := LimiterConfigForPolicies(ParseRateLimitPolicy(resp.Header)); ok {
+		return cfg, nil
+	}
+	return d.fallback(), nil
+}
+
+// A discoveryState records whether the limits of a key have been discovered.
+type discoveryState struct {
+	mux  sync.Mutex
+	done bool
+}
+
+// discover runs Discover for key, the key of req, unless its limits have already been discovered, blocking until any
+// discovery in progress for the key has completed. A failed discovery is tried again with the key's next request.
+func (t *PerKeyRoundTripper[K]) discover(req *http.Request, key K) {
+	state := loadOrCompute[K](t.discovered, key, newValue[discoveryState])
+	state.mux.Lock()
+	defer state.mux.Unlock()
+	if state.done {
+		return
+	}
+	if _, err := t.discoverKey(req, key); err != nil {
+		if t.Logger != nil {
+			t.Logger.Printf("%T - key: %v\tdiscovery failed: %v", t, key, err)
+		}
+		return
+	}
+	state.done = true
+}
@@ -0,0 +1,124 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []QuotaPolicy
+	}{
+		{
+			name:   "numeric",
+			header: http.Header{"Ratelimit-Policy": []string{"10;w=1, 1000;w=3600"}},
+			want:   []QuotaPolicy{{10, time.Second}, {1000, time.Hour}},
+		},
+		{
+			name:   "named",
+			header: http.Header{"Ratelimit-Policy": []string{`"burst";q=100;w=60,"daily";q=1000;w=86400`}},
+			want:   []QuotaPolicy{{100, time.Minute}, {1000, 24 * time.Hour}},
+		},
+		{
+			name:   "limit parameters",
+			header: http.Header{"X-Ratelimit-Limit": []string{"100, 100;w=60"}},
+			want:   []QuotaPolicy{{100, time.Minute}},
+		},
+		{
+			name:   "no window",
+			header: http.Header{"X-Ratelimit-Limit": []string{"100"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := ParseRateLimitPolicy(tt.header)
+				if len(got) != len(tt.want) {
+					t.Fatalf("ParseRateLimitPolicy() = %v, want %v", got, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("ParseRateLimitPolicy()[%d] = %v, want %v", i, got[i], tt.want[i])
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_Discovery(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					probes.Add(1)
+					w.Header().Set("RateLimit-Policy", "20;w=1, 600;w=60")
+				}
+			},
+		),
+	)
+	defer ts.Close()
+	transport := PerOriginRoundTripper(rate.Inf, 0, nil)
+	transport.Discovery = &Discovery{}
+	client := ts.Client()
+	client.Transport = transport
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if n := probes.Load(); n != 1 {
+		t.Errorf("sent %d probes, want 1", n)
+	}
+	want := LimiterConfig{Limit: 10, Burst: 10}
+	if got := transport.LimiterConfig(Origin(mustParseURL(t, ts.URL))); got != want {
+		t.Errorf("discovered %+v, want %+v", got, want)
+	}
+	// the probe is not canceled with the request it precedes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if got, err := transport.Discover(req); err != nil || got != want {
+		t.Errorf("Discover() with a canceled request = %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestPerKeyRoundTripper_DiscoveryFailed(t *testing.T) {
+	transport := &flakyTransport{failures: 1}
+	rt := NewPerKeyRoundTripper(rate.Inf, 0, TargetOrigin, transport)
+	rt.Discovery = &Discovery{Fallback: LimiterConfig{Limit: 5, Burst: 5}}
+	key := "http://example.com"
+	steps := []struct {
+		wantRequests int
+		want         LimiterConfig
+	}{
+		// the failed probe leaves the config unchanged
+		{2, LimiterConfig{Limit: rate.Inf}},
+		// the probe is sent again, and its response advertises no policies
+		{4, LimiterConfig{Limit: 5, Burst: 5}},
+		{5, LimiterConfig{Limit: 5, Burst: 5}},
+	}
+	for i, step := range steps {
+		req, _ := http.NewRequest(http.MethodGet, key, nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if transport.requests != step.wantRequests {
+			t.Errorf("step %d: sent %d requests, want %d", i, transport.requests, step.wantRequests)
+		}
+		if got := rt.LimiterConfig(key); got != step.want {
+			t.Errorf("step %d: LimiterConfig() = %+v, want %+v", i, got, step.want)
+		}
+	}
+}
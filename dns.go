@@ -0,0 +1,115 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// A HostResolver looks up the addresses of a host. It is implemented by *net.Resolver.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// A DNSLimiter rate limits the DNS lookups made when dialing, per zone, so that a client connecting to many hosts is
+// not throttled by its resolvers before any HTTP limit applies. Use its DialContext as the DialContext of an
+// http.Transport.
+type DNSLimiter struct {
+	// Resolver looks up host addresses; if nil, net.DefaultResolver is used.
+	Resolver HostResolver
+	// Zone returns the zone whose limiter a lookup of host waits for; if nil, DefaultDNSZone is used.
+	Zone     func(host string) string
+	limit    rate.Limit
+	burst    int
+	limiters *Map[string]
+}
+
+// NewDNSLimiter returns a DNSLimiter which allows limit lookups per second, with the given burst, in each zone.
+func NewDNSLimiter(limit rate.Limit, burst int) *DNSLimiter {
+	return &DNSLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: NewMap[string](),
+	}
+}
+
+// DefaultDNSZone returns the last two labels of host, e.g. example.com for a.b.example.com. It approximates the
+// registrable domain, whose name servers are typically shared by all of its hosts, without a public suffix list.
+func DefaultDNSZone(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	i := strings.LastIndexByte(host, '.')
+	if i < 0 {
+		return host
+	}
+	if j := strings.LastIndexByte(host[:i], '.'); j >= 0 {
+		return host[j+1:]
+	}
+	return host
+}
+
+// Limiter returns the rate.Limiter for the lookups in zone.
+func (d *DNSLimiter) Limiter(zone string) *rate.Limiter {
+	if limiter, ok := d.limiters.Load(zone); ok {
+		return limiter
+	}
+	return loadOrCompute(
+		d.limiters, zone, func() *rate.Limiter {
+			return rate.NewLimiter(d.limit, d.burst)
+		},
+	)
+}
+
+// LookupHost waits for the limiter of host's zone and then looks up its addresses. IP addresses are returned without
+// a lookup or waiting.
+func (d *DNSLimiter) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{host}, nil
+	}
+	zone := DefaultDNSZone
+	if d.Zone != nil {
+		zone = d.Zone
+	}
+	if err := d.Limiter(zone(host)).Wait(ctx); err != nil {
+		return nil, err
+	}
+	var resolver HostResolver = net.DefaultResolver
+	if d.Resolver != nil {
+		resolver = d.Resolver
+	}
+	return resolver.LookupHost(ctx, host)
+}
+
+// DialContext returns a function for http.Transport.DialContext which resolves the host of each address with
+// LookupHost, and then dials the resolved addresses with dialer in turn until one connects. If dialer is nil, a zero
+// net.Dialer is used.
+func (d *DNSLimiter) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := d.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, a := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
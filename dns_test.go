@@ -0,0 +1,67 @@
+package ratelim
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lookups[host]++
+	return []string{"127.0.0.1"}, nil
+}
+
+func TestDefaultDNSZone(t *testing.T) {
+	for host, want := range map[string]string{
+		"a.b.Example.com.": "example.com",
+		"example.com":      "example.com",
+		"localhost":        "localhost",
+	} {
+		if got := DefaultDNSZone(host); got != want {
+			t.Errorf("DefaultDNSZone(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestDNSLimiter(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(mustParseURL(t, server.URL).Host)
+
+	resolver := &fakeResolver{lookups: make(map[string]int)}
+	d := NewDNSLimiter(10, 1)
+	d.Resolver = resolver
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext(nil), DisableKeepAlives: true}}
+	start := time.Now()
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.org"} {
+		resp, err := client.Get((&url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	// the second lookup in example.com waits for a token, but the lookup in example.org does not
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > 180*time.Millisecond {
+		t.Errorf("lookups took %v, want about 100ms", elapsed)
+	}
+	if len(resolver.lookups) != 3 {
+		t.Errorf("lookups = %v, want one per host", resolver.lookups)
+	}
+	if addrs, _ := d.LookupHost(context.Background(), "::1"); len(addrs) != 1 || len(resolver.lookups) != 3 {
+		t.Errorf("LookupHost() of an IP address = %v, looked up %v", addrs, resolver.lookups)
+	}
+}
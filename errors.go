@@ -0,0 +1,20 @@
+package ratelim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A TooManyWaitersError is returned for a request which was rejected because the maximum number of requests were
+// already waiting for its key.
+type TooManyWaitersError struct {
+	Key        any
+	MaxWaiters int
+}
+
+func (e *TooManyWaitersError) Error() string {
+	return fmt.Sprintf("ratelim: too many requests waiting for key %v (max %d)", e.Key, e.MaxWaiters)
+}
+
+// ErrRejected is returned for requests rejected because the transport is in ModeReject.
+var ErrRejected = errors.New("ratelim: all requests are rejected")
@@ -0,0 +1,37 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_MaxWaiters(t *testing.T) {
+	transport := PerOriginRoundTripper(10, 1, nil)
+	transport.SetLimiterConfig("k", LimiterConfig{Limit: 10, Burst: 1, MaxWaiters: 2})
+	limiter := transport.limiter("k")
+	limiter.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := transport.wait(ctx, "k", transport.LimiterConfig("k"), limiter); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	var tooMany *TooManyWaitersError
+	if err := transport.wait(ctx, "k", transport.LimiterConfig("k"), limiter); !errors.As(err, &tooMany) {
+		t.Fatalf("wait() = %v, want *TooManyWaitersError", err)
+	}
+	wg.Wait()
+	if err := transport.wait(ctx, "k", transport.LimiterConfig("k"), limiter); err != nil {
+		t.Fatalf("wait() after queue drained = %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SampledTrace reports whether req carries a W3C Trace Context traceparent header with the sampled flag set, e.g. to
+// be used as the Exempt func of a PerKeyRoundTripper so that requests traced while reproducing an issue are not
+// delayed (ref: https://www.w3.org/TR/trace-context/#traceparent-header). Tracing libraries which inject the header
+// after the PerKeyRoundTripper has sent the request are better served by an Exempt func checking the span of the
+// request's context.
+func SampledTrace(req *http.Request) bool {
+	parts := strings.Split(req.Header.Get("Traceparent"), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	return err == nil && flags&0x01 != 0
+}
+
+// BaggageMember returns a func reporting whether a request carries a W3C baggage header with a member named key, e.g.
+// to be used as the Exempt func of a PerKeyRoundTripper for requests marked "debug=true" by an engineer
+// (ref: https://www.w3.org/TR/baggage/#baggage-http-header-format). If value is not empty, the member must also have
+// that value.
+func BaggageMember(key, value string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		for _, header := range req.Header.Values("Baggage") {
+			for _, member := range strings.Split(header, ",") {
+				member, _, _ = strings.Cut(member, ";")
+				k, v, ok := strings.Cut(member, "=")
+				if !ok || strings.TrimSpace(k) != key {
+					continue
+				}
+				if value == "" {
+					return true
+				}
+				if v, err := url.PathUnescape(strings.TrimSpace(v)); err == nil && v == value {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// exempt reports whether req is exempt from limiting by the transport's Exempt func.
+func (t *PerKeyRoundTripper[K]) exempt(req *http.Request) bool {
+	return t.Exempt != nil && t.Exempt(req)
+}
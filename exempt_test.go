@@ -0,0 +1,92 @@
+package ratelim
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSampledTrace(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		want        bool
+	}{
+		{name: "sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: true},
+		{name: "other flags", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", want: true},
+		{name: "not sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "invalid version", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "invalid flags", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz"},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+				if tt.traceparent != "" {
+					req.Header.Set("traceparent", tt.traceparent)
+				}
+				if got := SampledTrace(req); got != tt.want {
+					t.Errorf("SampledTrace() = %t, want %t", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestBaggageMember(t *testing.T) {
+	tests := []struct {
+		name       string
+		key, value string
+		baggage    []string
+		want       bool
+	}{
+		{name: "any value", key: "debug", baggage: []string{"userId=alice, debug=1"}, want: true},
+		{name: "value", key: "debug", value: "on call", baggage: []string{"debug=on%20call;ttl=60"}, want: true},
+		{name: "second header", key: "debug", baggage: []string{"userId=alice", "debug=true"}, want: true},
+		{name: "other value", key: "debug", value: "true", baggage: []string{"debug=false"}},
+		{name: "other key", key: "debug", baggage: []string{"debugger=true"}},
+		{name: "missing", key: "debug"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+				for _, baggage := range tt.baggage {
+					req.Header.Add("baggage", baggage)
+				}
+				if got := BaggageMember(tt.key, tt.value)(req); got != tt.want {
+					t.Errorf("BaggageMember(%q, %q)() = %t, want %t", tt.key, tt.value, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_Exempt(t *testing.T) {
+	transport := &flakyTransport{}
+	rt := PerOriginRoundTripper(10, 1, transport)
+	rt.Exempt = BaggageMember("debug", "")
+	send := func(debug bool) time.Duration {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		if debug {
+			req.Header.Set("baggage", "debug=1")
+		}
+		start := time.Now()
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	send(false)
+	if wait := send(true); wait > 50*time.Millisecond {
+		t.Errorf("exempt request waited %v", wait)
+	}
+	// the exempt request was charged, so the next one waits for 2 tokens
+	if wait := send(false); wait < 150*time.Millisecond {
+		t.Errorf("request after exempt request waited %v, want at least 150ms", wait)
+	}
+	if transport.requests != 3 {
+		t.Errorf("transport sent %d requests, want 3", transport.requests)
+	}
+}
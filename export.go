@@ -0,0 +1,71 @@
+package ratelim
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// exportedLimits is the JSON form of the limits of a PerKeyRoundTripper.
+type exportedLimits[K comparable] struct {
+	Default LimiterConfig            `json:"default"`
+	Keys    []exportedLimit[K]       `json:"keys,omitempty"`
+	Tags    map[string]LimiterConfig `json:"tags,omitempty"`
+}
+
+type exportedLimit[K comparable] struct {
+	Key    K             `json:"key"`
+	Config LimiterConfig `json:"config"`
+}
+
+// ExportLimits returns, in JSON, the default config, the config set for each key with SetLimiterConfig and the limit
+// of each tag, to be applied to another transport with ImportLimits. The state of the limiters, such as the tokens
+// available, is not exported, nor is the LimiterConfigFunc.
+func (t *PerKeyRoundTripper[K]) ExportLimits() ([]byte, error) {
+	limits := exportedLimits[K]{Default: t.DefaultLimiterConfig(), Tags: maps.Collect(t.tagConfigs.All())}
+	for key, cfg := range t.configs.All() {
+		limits.Keys = append(limits.Keys, exportedLimit[K]{Key: key, Config: cfg})
+	}
+	slices.SortFunc(
+		limits.Keys, func(a, b exportedLimit[K]) int {
+			return strings.Compare(fmt.Sprint(a.Key), fmt.Sprint(b.Key))
+		},
+	)
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return nil, fmt.Errorf("ratelim: exporting limits: %w", err)
+	}
+	return data, nil
+}
+
+// ImportLimits applies limits exported by ExportLimits: the default config, key configs and tag limits are replaced by
+// those in data, and existing limiters are reconfigured. Nothing is changed if data cannot be decoded.
+func (t *PerKeyRoundTripper[K]) ImportLimits(data []byte) error {
+	var limits exportedLimits[K]
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return fmt.Errorf("ratelim: importing limits: %w", err)
+	}
+	t.SetDefaultLimiterConfig(limits.Default)
+	imported := make(map[K]bool, len(limits.Keys))
+	for _, l := range limits.Keys {
+		imported[l.Key] = true
+		t.configs.Store(l.Key, l.Config)
+	}
+	t.configs.DeleteFunc(
+		func(key K, _ LimiterConfig) bool {
+			return !imported[key]
+		},
+	)
+	for _, tag := range t.tagConfigs.Keys() {
+		if _, ok := limits.Tags[tag]; !ok {
+			t.DeleteTagLimit(tag)
+		}
+	}
+	for tag, cfg := range limits.Tags {
+		t.SetTagLimit(tag, cfg)
+	}
+	t.Reconfigure()
+	return nil
+}
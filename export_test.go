@@ -0,0 +1,63 @@
+package ratelim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyRoundTripper_ExportLimits(t *testing.T) {
+	src := PerOriginRoundTripper(5, 1, nil)
+	src.SetLimiterConfig("https://a.example.com", LimiterConfig{Limit: 2, Burst: 4, Timeout: time.Second})
+	src.SetLimiterConfig("https://b.example.com", LimiterConfig{Limit: rate.Inf, Burst: 1, Ordered: true})
+	src.SetTagLimit("backfill", LimiterConfig{Limit: 1, Burst: 1})
+	data, err := src.ExportLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := PerOriginRoundTripper(100, 10, nil)
+	dst.SetLimiterConfig("https://c.example.com", LimiterConfig{Limit: 7, Burst: 7})
+	dst.SetTagLimit("interactive", LimiterConfig{Limit: 50, Burst: 5})
+	limiter := dst.limiter("https://a.example.com")
+	if err := dst.ImportLimits(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := dst.DefaultLimiterConfig(), src.DefaultLimiterConfig(); got != want {
+		t.Errorf("DefaultLimiterConfig() = %+v, want %+v", got, want)
+	}
+	for _, key := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"} {
+		if got, want := dst.LimiterConfig(key), src.LimiterConfig(key); got != want {
+			t.Errorf("LimiterConfig(%s) = %+v, want %+v", key, got, want)
+		}
+	}
+	if limiter.Limit() != 2 || limiter.Burst() != 4 {
+		t.Errorf("existing limiter has limit %v and burst %d, want 2 and 4", limiter.Limit(), limiter.Burst())
+	}
+	if _, ok := dst.TagLimiter("interactive"); ok {
+		t.Error("tag limit not in the export was kept")
+	}
+	if l, ok := dst.TagLimiter("backfill"); !ok || l.Limit() != 1 {
+		t.Errorf("TagLimiter(backfill) = %v, %v, want a limiter with limit 1", l, ok)
+	}
+	exported, err := dst.ExportLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported, data) {
+		t.Errorf("re-exported limits = %s, want %s", exported, data)
+	}
+}
+
+func TestPerKeyRoundTripper_ImportLimitsInvalid(t *testing.T) {
+	rt := PerOriginRoundTripper(5, 1, nil)
+	if err := rt.ImportLimits([]byte(`{"default": 1}`)); err == nil {
+		t.Error("ImportLimits() succeeded, want an error")
+	}
+	if got := rt.DefaultLimiterConfig(); got.Limit != 5 || got.Burst != 1 {
+		t.Errorf("DefaultLimiterConfig() = %+v after a failed import, want it unchanged", got)
+	}
+}
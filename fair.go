@@ -0,0 +1,105 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// A FairLimiter shares a single rate.Limiter among many keys, granting its tokens to waiting requests by weighted fair
+// queueing so that no key can starve the others: while several keys have requests waiting, each receives a share of
+// the limiter's rate proportional to its weight, however many requests it has queued.
+//
+// Each request is tagged with a virtual finish time, which is the later of the current virtual time and the finish
+// time of the key's previous request, plus the inverse of the key's weight; tokens are granted in order of finish
+// time (start-time fair queueing).
+type FairLimiter[K comparable] struct {
+	limiter *rate.Limiter
+	// Weight, if non-nil, returns the weight of a key; weights which are not positive are treated as 1. If nil, all
+	// keys have weight 1.
+	Weight func(key K) float64
+
+	mux     sync.Mutex
+	virtual float64
+	finish  map[K]float64
+	grants  int
+	sched   waitQueue
+}
+
+// NewFairLimiter returns a new FairLimiter sharing limiter.
+func NewFairLimiter[K comparable](limiter *rate.Limiter) *FairLimiter[K] {
+	return &FairLimiter[K]{
+		limiter: limiter,
+		finish:  make(map[K]float64),
+	}
+}
+
+// Limiter returns the shared rate.Limiter.
+func (f *FairLimiter[K]) Limiter() *rate.Limiter {
+	return f.limiter
+}
+
+func (f *FairLimiter[K]) weight(key K) float64 {
+	if f.Weight == nil {
+		return 1
+	}
+	if w := f.Weight(key); w > 0 {
+		return w
+	}
+	return 1
+}
+
+// Wait blocks until the shared limiter grants a token to a request for key, or ctx is done.
+func (f *FairLimiter[K]) Wait(ctx context.Context, key K) error {
+	weight := f.weight(key)
+	f.mux.Lock()
+	start := f.virtual
+	last, tagged := f.finish[key]
+	if tagged && last > start {
+		start = last
+	}
+	finish := start + 1/weight
+	f.finish[key] = finish
+	f.mux.Unlock()
+
+	if err := f.sched.Wait(ctx, f.limiter, finish); err != nil {
+		f.untag(key, last, tagged, finish, 1/weight)
+		return err
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if start > f.virtual {
+		f.virtual = start
+	}
+	f.grants++
+	if f.grants%1024 == 0 {
+		// forget keys which have been idle long enough that their finish time no longer affects their next tag
+		for k, last := range f.finish {
+			if last <= f.virtual {
+				delete(f.finish, k)
+			}
+		}
+	}
+	return nil
+}
+
+// untag rolls back the finish time of key after a request tagged with finish fails to wait, so that the key's later
+// requests are not queued behind a request which was never granted a token. If no other request for the key has been
+// tagged since, the key's previous finish time is restored; otherwise, the key's finish time is moved back by the
+// request's cost.
+func (f *FairLimiter[K]) untag(key K, last float64, tagged bool, finish, cost float64) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	current, ok := f.finish[key]
+	switch {
+	case !ok:
+	case current == finish && tagged:
+		f.finish[key] = last
+	case current == finish:
+		delete(f.finish, key)
+	default:
+		f.finish[key] = current - cost
+	}
+}
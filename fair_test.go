@@ -0,0 +1,90 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestFairLimiter_Wait(t *testing.T) {
+	f := NewFairLimiter[string](rate.NewLimiter(100, 1))
+	f.Weight = func(key string) float64 {
+		if key == "heavy" {
+			return 2
+		}
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	var mux sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	// "hot" floods the limiter with waiters, while "light" and "heavy" each keep only a few waiting
+	for key, concurrency := range map[string]int{"hot": 50, "light": 2, "heavy": 2} {
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				for f.Wait(ctx, key) == nil {
+					mux.Lock()
+					counts[key]++
+					mux.Unlock()
+				}
+			}(key)
+		}
+	}
+	wg.Wait()
+	t.Log(counts)
+	if counts["light"] < counts["hot"]/2 {
+		t.Errorf("light key starved: %v", counts)
+	}
+	if counts["heavy"] < counts["light"]*3/2 {
+		t.Errorf("heavy key not weighted: %v", counts)
+	}
+}
+
+func TestFairLimiter_canceled(t *testing.T) {
+	limiter := rate.NewLimiter(1, 1)
+	limiter.Allow()
+	f := NewFairLimiter[string](limiter)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// requests which give up waiting do not push back the key's later requests
+	for i := 0; i < 10; i++ {
+		if err := f.Wait(ctx, "a"); err == nil {
+			t.Fatal("Wait() succeeded before the limiter permits it")
+		}
+	}
+	if last, ok := f.finish["a"]; ok {
+		t.Errorf("finish time of a = %v after its requests failed, want none", last)
+	}
+	// a request failing while an earlier one of its key waits moves back the key's finish time by its cost only
+	waiting, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- f.Wait(waiting, "b")
+	}()
+	for {
+		f.mux.Lock()
+		_, ok := f.finish["b"]
+		f.mux.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := f.Wait(ctx, "b"); err == nil {
+		t.Fatal("Wait() succeeded before the limiter permits it")
+	}
+	f.mux.Lock()
+	last := f.finish["b"]
+	f.mux.Unlock()
+	if last != 1 {
+		t.Errorf("finish time of b = %v, want 1 for its one waiting request", last)
+	}
+	stop()
+	<-done
+}
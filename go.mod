@@ -1,5 +1,5 @@
 module github.com/milo-minderbinder/ratelim
 
-go 1.20
+go 1.23
 
 require golang.org/x/time v0.3.0
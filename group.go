@@ -0,0 +1,68 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+
+	"github.com/milo-minderbinder/ratelim/semaphore"
+)
+
+// A Group runs funcs submitted for keys once the rate.Limiter of their key in a PerKeyLimiter permits them, with at
+// most a given number running or waiting at once, and collects the first error, like golang.org/x/sync/errgroup.
+type Group[K comparable] struct {
+	limiter *PerKeyLimiter[K]
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	slots   *semaphore.Weighted
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup returns a new Group running funcs through limiter, and a context derived from ctx which is canceled when a
+// func first returns an error or Wait returns. If concurrency is positive, at most that many funcs run or wait for
+// their limiter at once.
+func NewGroup[K comparable](
+	ctx context.Context,
+	limiter *PerKeyLimiter[K],
+	concurrency int,
+) (*Group[K], context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &Group[K]{limiter: limiter, ctx: ctx, cancel: cancel}
+	if concurrency > 0 {
+		g.slots = semaphore.NewWeighted(int64(concurrency))
+	}
+	return g, ctx
+}
+
+// Go calls f in a new goroutine once the rate.Limiter of key permits it, blocking until fewer than the Group's
+// concurrency are running or waiting. If the Group's context is done before f is permitted, f is not called and the
+// context's error is recorded instead.
+func (g *Group[K]) Go(key K, f func() error) {
+	if g.slots != nil {
+		// cannot fail, since the context is never done and the semaphore is at least of size 1
+		_ = g.slots.Acquire(context.Background(), 1)
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.slots != nil {
+			defer g.slots.Release(1)
+		}
+		if err := g.limiter.Do(g.ctx, key, f); err != nil {
+			g.errOnce.Do(
+				func() {
+					g.err = err
+					g.cancel(err)
+				},
+			)
+		}
+	}()
+}
+
+// Wait blocks until all funcs submitted with Go have returned or been abandoned, then returns the first error, if any.
+func (g *Group[K]) Wait() error {
+	g.wg.Wait()
+	g.cancel(g.err)
+	return g.err
+}
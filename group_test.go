@@ -0,0 +1,83 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	l := NewPerKeyLimiter[string](50, 1)
+	g, _ := NewGroup(context.Background(), l, 2)
+	var (
+		mu                  sync.Mutex
+		running, maxRunning int
+		calls               atomic.Int64
+	)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		key := "a"
+		if i%2 == 1 {
+			key = "b"
+		}
+		g.Go(
+			key, func() error {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				calls.Add(1)
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			},
+		)
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	// each key runs 3 funcs at 50/s with a burst of 1, so the last is permitted after 40ms
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want at least 40ms", elapsed)
+	}
+	if got := calls.Load(); got != 6 {
+		t.Errorf("called %d funcs, want 6", got)
+	}
+	if got := maxRunning; got > 2 {
+		t.Errorf("%d funcs ran at once, want at most 2", got)
+	}
+}
+
+func TestGroup_error(t *testing.T) {
+	l := NewPerKeyLimiter[string](1, 1)
+	l.Allow("b")
+	g, ctx := NewGroup(context.Background(), l, 0)
+	errFailed := errors.New("failed")
+	g.Go(
+		"a", func() error {
+			return errFailed
+		},
+	)
+	// the func for b waits a second for its limiter, but is abandoned when the func for a fails
+	g.Go(
+		"b", func() error {
+			t.Error("func called after the group failed")
+			return nil
+		},
+	)
+	start := time.Now()
+	if err := g.Wait(); !errors.Is(err, errFailed) {
+		t.Errorf("Wait() = %v, want %v", err, errFailed)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want the waiting func abandoned", elapsed)
+	}
+	if !errors.Is(context.Cause(ctx), errFailed) {
+		t.Errorf("context cause = %v, want %v", context.Cause(ctx), errFailed)
+	}
+}
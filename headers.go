@@ -0,0 +1,224 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A RateLimitStatus describes the rate limit quota of a client as reported by a server in its response headers.
+// Fields the server did not report are set to -1 (for Limit and Remaining) or the zero time.Time (for Reset).
+type RateLimitStatus struct {
+	// Limit is the number of requests allowed in the current window.
+	Limit int
+	// Remaining is the number of requests remaining in the current window.
+	Remaining int
+	// Reset is the time at which the quota is replenished.
+	Reset time.Time
+}
+
+// Exhausted reports whether the status indicates no requests may be sent until Reset.
+func (s RateLimitStatus) Exhausted() bool {
+	return s.Remaining == 0 && !s.Reset.IsZero()
+}
+
+// epochThreshold is the value above which a reset header is taken to be a Unix timestamp rather than a number of
+// seconds, since servers use both conventions (e.g. GitHub's X-RateLimit-Reset is a timestamp).
+const epochThreshold = 1_000_000_000
+
+// ParseRateLimitHeaders parses the rate limit headers of a response. It recognizes, in order of precedence, the
+// structured RateLimit header (e.g. "limit=100, remaining=50, reset=30"), the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, their widely used X-RateLimit-* equivalents, and Retry-After, which is treated as an
+// exhausted quota reset after the given delay. The ok result reports whether any of these headers was present.
+func ParseRateLimitHeaders(header http.Header) (status RateLimitStatus, ok bool) {
+	return parseRateLimitHeaders(header, time.Now())
+}
+
+func parseRateLimitHeaders(header http.Header, now time.Time) (status RateLimitStatus, ok bool) {
+	status = RateLimitStatus{Limit: -1, Remaining: -1}
+	if v := header.Get("RateLimit"); v != "" {
+		for _, param := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch strings.ToLower(name) {
+			case "limit":
+				ok = parseLeadingInt(value, &status.Limit) || ok
+			case "remaining":
+				ok = parseLeadingInt(value, &status.Remaining) || ok
+			case "reset":
+				ok = parseReset(value, now, &status.Reset) || ok
+			}
+		}
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if status.Limit < 0 {
+			ok = parseLeadingInt(header.Get(prefix+"Limit"), &status.Limit) || ok
+		}
+		if status.Remaining < 0 {
+			ok = parseLeadingInt(header.Get(prefix+"Remaining"), &status.Remaining) || ok
+		}
+		if status.Reset.IsZero() {
+			ok = parseReset(header.Get(prefix+"Reset"), now, &status.Reset) || ok
+		}
+	}
+	if after, found := parseRetryAfter(header.Get("Retry-After"), now); found {
+		status.Remaining = 0
+		if after.After(status.Reset) {
+			status.Reset = after
+		}
+		ok = true
+	}
+	return status, ok
+}
+
+// parseLeadingInt parses the integer at the beginning of s, ignoring any trailing policy parameters (such as the
+// ";w=60" in "100;w=60"), and stores it in dst.
+func parseLeadingInt(s string, dst *int) bool {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, ",; "); i >= 0 {
+		s = s[:i]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return false
+	}
+	*dst = n
+	return true
+}
+
+func parseReset(s string, now time.Time, dst *time.Time) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || seconds < 0 {
+		return false
+	}
+	if seconds >= epochThreshold {
+		*dst = time.Unix(0, int64(seconds*float64(time.Second)))
+	} else {
+		*dst = now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return true
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of seconds or an HTTP-date.
+func parseRetryAfter(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// holdUntil holds all requests for key until the given time, unless the key is already held until later.
+func (t *PerKeyRoundTripper[K]) holdUntil(key K, until time.Time) {
+	for {
+		current, loaded := t.holds.Load(key)
+		if loaded && !current.Before(until) {
+			return
+		}
+		if !loaded {
+			if _, loaded = t.holds.LoadOrStore(key, until); !loaded {
+				return
+			}
+			continue
+		}
+		if t.holds.CompareAndSwap(key, current, until) {
+			return
+		}
+	}
+}
+
+// waitHold blocks until any hold placed on key has expired or ctx is done.
+func (t *PerKeyRoundTripper[K]) waitHold(ctx context.Context, key K) error {
+	until, ok := t.holds.Load(key)
+	if !ok {
+		return nil
+	}
+	delay := time.Until(until)
+	if delay <= 0 {
+		t.holds.CompareAndDelete(key, until)
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// A QuotaPolicy is a quota advertised by a server: at most Quota requests per Window.
+type QuotaPolicy struct {
+	Quota  int
+	Window time.Duration
+}
+
+// Limit returns the average rate permitted by the policy.
+func (p QuotaPolicy) Limit() rate.Limit {
+	return rate.Limit(float64(p.Quota) / p.Window.Seconds())
+}
+
+// ParseRateLimitPolicy parses the quota policies advertised in the RateLimit-Policy header, or, failing that, in the
+// policy parameters of the RateLimit-Limit or X-RateLimit-Limit header. Both the numeric item form
+// (e.g. "10;w=1, 1000;w=3600") and the named form (e.g. `"burst";q=100;w=60`) are recognized. Items without a
+// window are ignored.
+func ParseRateLimitPolicy(header http.Header) []QuotaPolicy {
+	for _, name := range []string{"RateLimit-Policy", "RateLimit-Limit", "X-RateLimit-Limit"} {
+		var policies []QuotaPolicy
+		for _, v := range header.Values(name) {
+			for _, item := range strings.Split(v, ",") {
+				if p, ok := parseQuotaPolicy(item); ok {
+					policies = append(policies, p)
+				}
+			}
+		}
+		if len(policies) > 0 {
+			return policies
+		}
+	}
+	return nil
+}
+
+func parseQuotaPolicy(item string) (p QuotaPolicy, ok bool) {
+	params := strings.Split(strings.TrimSpace(item), ";")
+	quota, err := strconv.Atoi(strings.TrimSpace(params[0]))
+	if err == nil {
+		p.Quota = quota
+	}
+	var window float64
+	for _, param := range params[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch strings.ToLower(name) {
+		case "q":
+			if quota, err := strconv.Atoi(value); err == nil {
+				p.Quota = quota
+			}
+		case "w":
+			if w, err := strconv.ParseFloat(value, 64); err == nil {
+				window = w
+			}
+		}
+	}
+	if p.Quota <= 0 || window <= 0 {
+		return p, false
+	}
+	p.Window = time.Duration(window * float64(time.Second))
+	return p, true
+}
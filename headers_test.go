@@ -0,0 +1,85 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   RateLimitStatus
+		ok     bool
+	}{
+		{
+			name:   "none",
+			header: http.Header{},
+			want:   RateLimitStatus{Limit: -1, Remaining: -1},
+		},
+		{
+			name:   "structured",
+			header: http.Header{"Ratelimit": []string{"limit=100, remaining=0, reset=30"}},
+			want:   RateLimitStatus{Limit: 100, Remaining: 0, Reset: now.Add(30 * time.Second)},
+			ok:     true,
+		},
+		{
+			name: "github",
+			header: http.Header{
+				"X-Ratelimit-Limit":     []string{"5000"},
+				"X-Ratelimit-Remaining": []string{"4999"},
+				"X-Ratelimit-Reset":     []string{"1700003600"},
+			},
+			want: RateLimitStatus{Limit: 5000, Remaining: 4999, Reset: now.Add(time.Hour)},
+			ok:   true,
+		},
+		{
+			name: "policy suffix",
+			header: http.Header{
+				"Ratelimit-Limit":     []string{"10, 10;w=1, 1000;w=3600"},
+				"Ratelimit-Remaining": []string{"9"},
+			},
+			want: RateLimitStatus{Limit: 10, Remaining: 9},
+			ok:   true,
+		},
+		{
+			name:   "retry after",
+			header: http.Header{"Retry-After": []string{"120"}},
+			want:   RateLimitStatus{Limit: -1, Remaining: 0, Reset: now.Add(2 * time.Minute)},
+			ok:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok := parseRateLimitHeaders(tt.header, now)
+				if ok != tt.ok || got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining ||
+					!got.Reset.Equal(tt.want.Reset) {
+					t.Errorf("parseRateLimitHeaders() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_waitHold(t *testing.T) {
+	rt := PerOriginRoundTripper(1, 1, nil)
+	rt.holdUntil("a", time.Now().Add(50*time.Millisecond))
+	rt.holdUntil("a", time.Now())
+	start := time.Now()
+	if err := rt.waitHold(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("hold shortened: waited %v", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rt.holdUntil("b", time.Now().Add(time.Hour))
+	if err := rt.waitHold(ctx, "b"); err == nil {
+		t.Error("expected context error")
+	}
+}
@@ -0,0 +1,94 @@
+package ratelim
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hedgeable reports whether req may be hedged: it must use a safe method and have no body, so that sending it twice
+// is harmless.
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// cancelOnClose cancels the context of a response's request once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// send sends req through transport. If hedge is true, HedgeDelay is positive and req is hedgeable, a second attempt is
+// sent if the first has not completed within HedgeDelay and limiter has a spare token at that moment; the first
+// response to arrive is returned and the other attempt is canceled.
+func (t *PerKeyRoundTripper[K]) send(
+	req *http.Request,
+	transport http.RoundTripper,
+	limiter *rate.Limiter,
+	hedge bool,
+) (*http.Response, error) {
+	if !hedge || t.HedgeDelay <= 0 || !hedgeable(req) {
+		return transport.RoundTrip(req)
+	}
+	results := make(chan hedgeResult, 2)
+	attempt := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		resp, err := transport.RoundTrip(req.WithContext(ctx))
+		results <- hedgeResult{resp: resp, err: err, cancel: cancel}
+	}
+	go attempt()
+	timer := time.NewTimer(t.HedgeDelay)
+	defer timer.Stop()
+	attempts := 1
+	var first hedgeResult
+	for received := 0; received < attempts; {
+		select {
+		case <-timer.C:
+			if attempts == 1 && limiter.Allow() {
+				attempts++
+				go attempt()
+			}
+			continue
+		case r := <-results:
+			received++
+			if r.err != nil {
+				r.cancel()
+				if received == 1 {
+					first = r
+				}
+				continue
+			}
+			if attempts > received {
+				// cancel the losing attempt and release its response, if any, once it returns
+				go func() {
+					loser := <-results
+					if loser.resp != nil {
+						_ = loser.resp.Body.Close()
+					}
+					loser.cancel()
+				}()
+			}
+			r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: r.cancel}
+			return r.resp, nil
+		}
+	}
+	return nil, first.err
+}
@@ -0,0 +1,59 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_Hedge(t *testing.T) {
+	tests := []struct {
+		name      string
+		burst     int
+		wantHits  int32
+		maxWait   time.Duration
+		minWaited time.Duration
+	}{
+		{name: "spare token", burst: 2, wantHits: 2, maxWait: 300 * time.Millisecond},
+		{name: "no spare token", burst: 1, wantHits: 1, minWaited: 500 * time.Millisecond, maxWait: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var hits atomic.Int32
+				ts := httptest.NewServer(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							if hits.Add(1) == 1 {
+								select {
+								case <-time.After(500 * time.Millisecond):
+								case <-r.Context().Done():
+								}
+							}
+						},
+					),
+				)
+				defer ts.Close()
+				transport := PerOriginRoundTripper(0.1, tt.burst, nil)
+				transport.HedgeDelay = 50 * time.Millisecond
+				client := ts.Client()
+				client.Transport = transport
+				start := time.Now()
+				resp, err := client.Get(ts.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = resp.Body.Close()
+				elapsed := time.Since(start)
+				if elapsed > tt.maxWait || elapsed < tt.minWaited {
+					t.Errorf("response received after %v", elapsed)
+				}
+				if n := hits.Load(); n != tt.wantHits {
+					t.Errorf("server received %d requests, want %d", n, tt.wantHits)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,101 @@
+package ratelim
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWaitBuckets are the upper bounds of the buckets of wait-time histograms, unless configured otherwise.
+var DefaultWaitBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// A Histogram is a snapshot of the distribution of durations, such as the time requests waited for their limiter.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing order.
+	Bounds []time.Duration
+	// Counts are the number of durations in each bucket, and have one more element than Bounds, counting the
+	// durations above the last bound.
+	Counts []int64
+	// Count and Sum are the number and total of all durations.
+	Count int64
+	Sum   time.Duration
+}
+
+// Mean returns the mean duration, or 0 if there are none.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound of the q-quantile (0 <= q <= 1) of the durations: the bound of the first bucket by
+// which a fraction q of the durations has been counted. It returns -1 if the quantile lies above the last bound, and 0
+// if there are no durations.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(q * float64(h.Count))
+	if rank < 1 {
+		rank = 1
+	}
+	var cumulative int64
+	for i, n := range h.Counts {
+		if cumulative += n; cumulative >= rank {
+			if i < len(h.Bounds) {
+				return h.Bounds[i]
+			}
+			break
+		}
+	}
+	return -1
+}
+
+// sub returns the difference between h and base, an earlier snapshot of the same histogram.
+func (h Histogram) sub(base Histogram) Histogram {
+	d := Histogram{Bounds: h.Bounds, Counts: slices.Clone(h.Counts), Count: h.Count - base.Count, Sum: h.Sum - base.Sum}
+	for i := range base.Counts {
+		d.Counts[i] -= base.Counts[i]
+	}
+	return d
+}
+
+// histogram records a distribution of durations with atomic counters.
+type histogram struct {
+	bounds []time.Duration
+	counts []atomic.Int64
+	sum    atomic.Int64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	return &histogram{bounds: bounds, counts: make([]atomic.Int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(h.bounds, d)
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{Bounds: slices.Clone(h.bounds), Counts: make([]int64, len(h.counts)), Sum: time.Duration(h.sum.Load())}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+		s.Count += s.Counts[i]
+	}
+	return s
+}
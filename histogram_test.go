@@ -0,0 +1,49 @@
+package ratelim
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]time.Duration{100 * time.Millisecond, 10 * time.Millisecond, time.Second})
+	for _, d := range []time.Duration{0, 5, 10, 50, 100, 100, 900, 2000} {
+		h.observe(d * time.Millisecond)
+	}
+	s := h.snapshot()
+	if want := []int64{3, 3, 1, 1}; !reflect.DeepEqual(s.Counts, want) {
+		t.Errorf("Counts = %v, want %v", s.Counts, want)
+	}
+	if s.Count != 8 || s.Sum != 3165*time.Millisecond || s.Mean() != 395625*time.Microsecond {
+		t.Errorf("Count, Sum, Mean() = %d, %v, %v", s.Count, s.Sum, s.Mean())
+	}
+	for q, want := range map[float64]time.Duration{
+		0:    10 * time.Millisecond,
+		0.5:  100 * time.Millisecond,
+		0.75: 100 * time.Millisecond,
+		0.9:  time.Second,
+		1:    -1,
+	} {
+		if got := s.Quantile(q); got != want {
+			t.Errorf("Quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestPerKeyRoundTripper_waitHistogram(t *testing.T) {
+	rt := PerOriginRoundTripper(20, 1, nil)
+	rt.WaitBuckets = []time.Duration{time.Millisecond, 100 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		if err := rt.wait(context.Background(), "k", rt.LimiterConfig("k"), rt.limiter("k")); err != nil {
+			t.Fatal(err)
+		}
+		rt.recordRequest("k", time.Since(start), 0, nil)
+	}
+	// the first request is permitted at once, and the others wait 50ms each
+	if got, want := rt.Stats("k").Wait.Counts, []int64{1, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats().Wait.Counts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package ratelim
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// toASCIIHost converts each internationalized label of host to its ASCII-compatible (punycode) form, as in IDNA
+// (RFC 5891), so that a host name written in Unicode and in ASCII share a key. host must already be lower-cased; the
+// Unicode normalization and mapping of UTS #46 is not applied. A label which cannot be encoded is left unchanged.
+func toASCIIHost(host string) string {
+	if isASCII(host) {
+		return host
+	}
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		if encoded, ok := punycodeEncode(label); ok {
+			labels[i] = "xn--" + encoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Bootstring parameters for punycode (ref: https://www.rfc-editor.org/rfc/rfc3492#section-5)
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// punycodeEncode encodes s as punycode, without the ACE prefix
+// (ref: https://www.rfc-editor.org/rfc/rfc3492#section-6.3).
+func punycodeEncode(s string) (string, bool) {
+	if !utf8.ValidString(s) {
+		return "", false
+	}
+	input := []rune(s)
+	var out strings.Builder
+	for _, r := range input {
+		if r < utf8.RuneSelf {
+			out.WriteRune(r)
+		}
+	}
+	b := out.Len()
+	h := b
+	if b > 0 {
+		out.WriteByte('-')
+	}
+	n, delta, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+	for h < len(input) {
+		m := rune(utf8.MaxRune)
+		for _, r := range input {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range input {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := k - bias
+				if t < punycodeTMin {
+					t = punycodeTMin
+				} else if t > punycodeTMax {
+					t = punycodeTMax
+				}
+				if q < t {
+					break
+				}
+				out.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			out.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return out.String(), true
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
@@ -0,0 +1,28 @@
+package ratelim
+
+import (
+	"testing"
+)
+
+func TestToASCIIHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"ñ.example", "xn--ida.example"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.host, func(t *testing.T) {
+				if got := toASCIIHost(tt.host); got != tt.want {
+					t.Errorf("toASCIIHost() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,46 @@
+package ratelim
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// A KeyFuncPanicError reports a panic of the key func of a PerKeyRoundTripper, recovered while deriving the key of a
+// request.
+type KeyFuncPanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *KeyFuncPanicError) Error() string {
+	return fmt.Sprintf("ratelim: key func panicked: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it is an error.
+func (e *KeyFuncPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// safeKey returns the key of req, or the FallbackKey if the key func panics, reporting the panic to OnKeyError.
+func (t *PerKeyRoundTripper[K]) safeKey(req *http.Request) (key K) {
+	defer func() {
+		if v := recover(); v != nil {
+			key = t.FallbackKey
+			t.keyError(req, &KeyFuncPanicError{Value: v, Stack: debug.Stack()})
+		}
+	}()
+	return t.keyFunc(req)
+}
+
+// keyError reports err, raised while deriving the key of req, to OnKeyError, or else to the Logger, if any.
+func (t *PerKeyRoundTripper[K]) keyError(req *http.Request, err error) {
+	if t.OnKeyError != nil {
+		t.OnKeyError(req, err)
+	} else if t.Logger != nil {
+		t.Logger.Printf("%T - %v; using key %v for %s %s", t, err, t.FallbackKey, req.Method, req.URL)
+	}
+}
@@ -0,0 +1,60 @@
+package ratelim
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var errMalformed = errors.New("malformed request")
+
+// panickyKey returns the origin of a request, but panics with errMalformed for requests with an X-Malformed header.
+func panickyKey(req *http.Request) string {
+	if req.Header.Get("X-Malformed") != "" {
+		panic(errMalformed)
+	}
+	return TargetOrigin(req)
+}
+
+func TestPerKeyRoundTripper_FallbackKey(t *testing.T) {
+	rt := NewPerKeyRoundTripper(1000, 10, panickyKey, &flakyTransport{})
+	rt.FallbackKey = "fallback"
+	var errs []error
+	rt.OnKeyError = func(req *http.Request, err error) {
+		errs = append(errs, err)
+	}
+	for _, malformed := range []bool{false, true} {
+		req := mustNewRequest(t)
+		if malformed {
+			req.Header.Set("X-Malformed", "1")
+		}
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+	}
+	if _, ok := rt.Limiters().Load("fallback"); !ok {
+		t.Error("no limiter for the fallback key")
+	}
+	var panicked *KeyFuncPanicError
+	if len(errs) != 1 || !errors.As(errs[0], &panicked) || !errors.Is(errs[0], errMalformed) {
+		t.Fatalf("OnKeyError() called with %v, want a *KeyFuncPanicError wrapping %v", errs, errMalformed)
+	}
+	if len(panicked.Stack) == 0 {
+		t.Error("KeyFuncPanicError has no stack trace")
+	}
+
+	var buf bytes.Buffer
+	rt.OnKeyError = nil
+	rt.Logger = log.New(&buf, "", 0)
+	req := mustNewRequest(t)
+	req.Header.Set("X-Malformed", "1")
+	if key := rt.Key(req); key != "fallback" {
+		t.Errorf("Key() = %q, want the fallback key", key)
+	}
+	if !strings.Contains(buf.String(), "key func panicked: malformed request; using key fallback") {
+		t.Errorf("logged %q", buf.String())
+	}
+}
@@ -0,0 +1,72 @@
+// Package keys builds the key funcs of ratelim.PerKeyRoundTripper and ratelim.Middleware from smaller
+// transformations, e.g. extracting a request header, normalizing it and hashing it, so that tokens are neither kept
+// in memory nor logged in the clear:
+//
+//	keyFunc := keys.Compose(keys.Header("Authorization"), strings.TrimSpace, keys.Hashed)
+package keys
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+)
+
+// Compose returns a key func which derives the key of a request with extract, then applies each of transforms to it
+// in order.
+func Compose[K any](extract func(*http.Request) K, transforms ...func(K) K) func(*http.Request) K {
+	return func(r *http.Request) K {
+		key := extract(r)
+		for _, transform := range transforms {
+			key = transform(key)
+		}
+		return key
+	}
+}
+
+// Map returns a key func which derives the key of a request with extract, then converts it with f, e.g. to a key of
+// another type.
+func Map[K, L any](extract func(*http.Request) K, f func(K) L) func(*http.Request) L {
+	return func(r *http.Request) L {
+		return f(extract(r))
+	}
+}
+
+// Header returns a key func which derives the key of a request from the first value of its header name, or "" if
+// it has none.
+func Header(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// hashedBytes is the number of bytes of the hash of a key kept by Hashed and HMAC, which is ample to tell keys apart.
+const hashedBytes = 16
+
+// Hashed returns a hash of key, the hex encoding of the first 16 bytes of its SHA-256, so that sensitive keys, such
+// as API tokens, are neither kept in memory nor logged in the clear. The empty key is returned unchanged. Since keys
+// of low entropy, such as email addresses, can be recovered from an unkeyed hash by brute force, use HMAC for them.
+func Hashed(key string) string {
+	if key == "" {
+		return ""
+	}
+	return hashKey(sha256.New(), key)
+}
+
+// HMAC returns a func hashing keys as Hashed does, but with HMAC-SHA-256 keyed by secret, so that the keys cannot be
+// recovered from their hashes without it.
+func HMAC(secret []byte) func(key string) string {
+	secret = append([]byte(nil), secret...)
+	return func(key string) string {
+		if key == "" {
+			return ""
+		}
+		return hashKey(hmac.New(sha256.New, secret), key)
+	}
+}
+
+func hashKey(h hash.Hash, key string) string {
+	_, _ = h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil)[:hashedBytes])
+}
@@ -0,0 +1,68 @@
+package keys
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompose(t *testing.T) {
+	keyFunc := Compose(Header("X-User"), strings.TrimSpace, strings.ToLower)
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{" Alice@Example.com ", "alice@example.com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.header, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+				req.Header.Set("X-User", tt.header)
+				if got := keyFunc(req); got != tt.want {
+					t.Errorf("key = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestMap(t *testing.T) {
+	keyFunc := Map(Header("X-User"), func(user string) int { return len(user) })
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.Header.Set("X-User", "alice")
+	if got := keyFunc(req); got != 5 {
+		t.Errorf("key = %d, want 5", got)
+	}
+}
+
+func TestHashed(t *testing.T) {
+	hmacs := HMAC([]byte("secret"))
+	tests := []struct {
+		name string
+		hash func(string) string
+	}{
+		{"Hashed", Hashed},
+		{"HMAC", hmacs},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				a, b := tt.hash("token-a"), tt.hash("token-b")
+				if len(a) != 32 || strings.Contains(a, "token") {
+					t.Errorf("hash = %q, want 32 hex digits", a)
+				}
+				if a == b || a != tt.hash("token-a") {
+					t.Errorf("hashes of distinct keys %q and %q, want distinct and stable hashes", a, b)
+				}
+				if empty := tt.hash(""); empty != "" {
+					t.Errorf("hash of the empty key = %q, want it unchanged", empty)
+				}
+			},
+		)
+	}
+	if Hashed("token-a") == hmacs("token-a") || hmacs("token-a") == HMAC([]byte("other"))("token-a") {
+		t.Error("HMAC hashes do not depend on the secret")
+	}
+}
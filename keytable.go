@@ -0,0 +1,202 @@
+package ratelim
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// A KeyOverflowPolicy determines how a PerKeyRoundTripper handles a request for a new key once it keeps the state of
+// MaxKeys keys.
+type KeyOverflowPolicy int
+
+const (
+	// EvictLeastRecentKey evicts the state of the key least recently requested to make room for the new key, among
+	// those which could be recreated as they are: whose rate.Limiter has refilled and which have no requests waiting.
+	// If none of the maxEvictScan least recently requested keys can be evicted, requests for new keys are rejected as
+	// under RejectNewKeys, so that cycling through more than MaxKeys keys cannot refill their limiters.
+	EvictLeastRecentKey KeyOverflowPolicy = iota
+	// RejectNewKeys fails requests for new keys with a *TooManyKeysError until keys are evicted by EvictIdle.
+	RejectNewKeys
+	// CollapseNewKeys limits requests for new keys under the transport's OverflowKey, which they all share.
+	CollapseNewKeys
+)
+
+// A TooManyKeysError is returned for a request for a new key rejected under RejectNewKeys.
+type TooManyKeysError struct {
+	Key     any
+	MaxKeys int
+}
+
+func (e *TooManyKeysError) Error() string {
+	return fmt.Sprintf("ratelim: no room for key %v (max %d keys)", e.Key, e.MaxKeys)
+}
+
+// KeyLimitStats report the enforcement of the MaxKeys of a PerKeyRoundTripper.
+type KeyLimitStats struct {
+	// Keys is the number of keys whose state is kept.
+	Keys int
+	// Evicted is the number of keys evicted under EvictLeastRecentKey, and Rejected and Collapsed the numbers of
+	// requests rejected under RejectNewKeys and collapsed onto the OverflowKey under CollapseNewKeys.
+	Evicted, Rejected, Collapsed int64
+}
+
+// A keyTable tracks the keys of a PerKeyRoundTripper in order of their last request, to bound their number.
+type keyTable[K comparable] struct {
+	mux       sync.Mutex
+	order     list.List // of K, most recently requested first
+	elems     map[K]*list.Element
+	evicted   atomic.Int64
+	rejected  atomic.Int64
+	collapsed atomic.Int64
+}
+
+// maxEvictScan is the number of least recently requested keys considered for eviction under EvictLeastRecentKey.
+const maxEvictScan = 8
+
+// admit records a request for key, and reports whether it is admitted: a tracked key always is, and a new key is while
+// fewer than max keys are tracked, or if evictable is non-nil, in place of the least recently requested of the
+// maxEvictScan least recently requested keys for which evictable returns true, which is passed to evict. evict is
+// called before the lock of kt is released, so that the evicted key cannot be admitted again before its state is gone.
+func (kt *keyTable[K]) admit(key K, max int, evictable func(K) bool, evict func(K)) (ok, didEvict bool) {
+	kt.mux.Lock()
+	defer kt.mux.Unlock()
+	if e, found := kt.elems[key]; found {
+		kt.order.MoveToFront(e)
+		return true, false
+	}
+	if kt.elems == nil {
+		kt.elems = make(map[K]*list.Element)
+	}
+	if len(kt.elems) >= max {
+		if evictable == nil {
+			return false, false
+		}
+		var victim *list.Element
+		for e, i := kt.order.Back(), 0; e != nil && i < maxEvictScan; e, i = e.Prev(), i+1 {
+			if evictable(e.Value.(K)) {
+				victim = e
+				break
+			}
+		}
+		if victim == nil {
+			return false, false
+		}
+		evicted := kt.order.Remove(victim).(K)
+		delete(kt.elems, evicted)
+		evict(evicted)
+		didEvict = true
+	}
+	kt.elems[key] = kt.order.PushFront(key)
+	return true, didEvict
+}
+
+// remove stops tracking key, and passes it to forget before the lock of kt is released, as admit does.
+func (kt *keyTable[K]) remove(key K, forget func(K)) {
+	kt.mux.Lock()
+	defer kt.mux.Unlock()
+	if e, found := kt.elems[key]; found {
+		kt.order.Remove(e)
+		delete(kt.elems, key)
+	}
+	forget(key)
+}
+
+func (kt *keyTable[K]) len() int {
+	kt.mux.Lock()
+	defer kt.mux.Unlock()
+	return len(kt.elems)
+}
+
+// admitKey returns the key under which a request for key is limited, applying MaxKeys and KeyOverflow: key itself, or
+// the OverflowKey if it is collapsed, or a *TooManyKeysError if it is rejected.
+func (t *PerKeyRoundTripper[K]) admitKey(key K) (K, error) {
+	if t.MaxKeys <= 0 || (t.KeyOverflow == CollapseNewKeys && key == t.OverflowKey) {
+		return key, nil
+	}
+	var evictable func(K) bool
+	if t.KeyOverflow == EvictLeastRecentKey {
+		evictable = t.evictable
+	}
+	ok, didEvict := t.keyTable.admit(key, t.MaxKeys, evictable, t.forget)
+	switch {
+	case didEvict:
+		t.keyTable.evicted.Add(1)
+	case ok:
+	case t.KeyOverflow == CollapseNewKeys:
+		t.keyTable.collapsed.Add(1)
+		return t.OverflowKey, nil
+	default:
+		t.keyTable.rejected.Add(1)
+		return key, &TooManyKeysError{Key: key, MaxKeys: t.MaxKeys}
+	}
+	return key, nil
+}
+
+// evictable reports whether the state of key can be evicted without loosening its limits: its rate.Limiter, if any,
+// has refilled, and it has no requests waiting.
+func (t *PerKeyRoundTripper[K]) evictable(key K) bool {
+	if waiters, ok := t.waiters.Load(key); ok && waiters.Load() > 0 {
+		return false
+	}
+	limiter, ok := t.limiters.Load(key)
+	return !ok || limiter.Limit() == rate.Inf || limiter.Tokens() >= float64(limiter.Burst())
+}
+
+// KeyLimitStats returns the number of keys whose state is kept under MaxKeys, and how often it was enforced.
+func (t *PerKeyRoundTripper[K]) KeyLimitStats() KeyLimitStats {
+	return KeyLimitStats{
+		Keys:      t.keyTable.len(),
+		Evicted:   t.keyTable.evicted.Load(),
+		Rejected:  t.keyTable.rejected.Load(),
+		Collapsed: t.keyTable.collapsed.Load(),
+	}
+}
+
+// forget removes all the state kept for key, except the config set for it, including the circuit of its Breaker and
+// the state of its Shedder and LatencyController. Idle connections of a transport created for it by TransportFunc are
+// closed.
+func (t *PerKeyRoundTripper[K]) forget(key K) {
+	t.limiters.Delete(key)
+	t.stats.Delete(key)
+	for _, m := range []*Map[K]{t.softLimiters, t.smoothers, t.retryBudgets} {
+		m.Delete(key)
+	}
+	t.holds.Delete(key)
+	t.discovered.Delete(key)
+	t.waitQueues.Delete(key)
+	t.waiters.Delete(key)
+	t.softExceeded.Delete(key)
+	t.costs.Delete(key)
+	t.http2.Delete(key)
+	t.policies.Delete(key)
+	t.spikes.Delete(key)
+	t.lastSeen.Delete(key)
+	t.closeTransport(key)
+	if t.Breaker != nil {
+		t.Breaker.states.Delete(key)
+	}
+	if t.Shedder != nil {
+		t.Shedder.states.Delete(key)
+	}
+	if t.LatencyController != nil {
+		t.LatencyController.states.Delete(key)
+	}
+}
+
+// labeledKeyLimitStats returns the KeyLimitStats of t, with the given labels identifying it, if MaxKeys is set.
+func (t *PerKeyRoundTripper[K]) labeledKeyLimitStats(labels string) []labeledKeyLimitStats {
+	if t.MaxKeys <= 0 {
+		return nil
+	}
+	return []labeledKeyLimitStats{{labels: labels, stats: t.KeyLimitStats()}}
+}
+
+// labeledKeyLimitStats are the KeyLimitStats of a transport, with the Prometheus labels identifying it.
+type labeledKeyLimitStats struct {
+	labels string
+	stats  KeyLimitStats
+}
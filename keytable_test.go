@@ -0,0 +1,144 @@
+package ratelim
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyRoundTripper_MaxKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        rate.Limit
+		overflow     KeyOverflowPolicy
+		wantErr      bool
+		wantLimiters []string
+		wantStats    KeyLimitStats
+		wantMetric   string
+	}{
+		{
+			name:         "evict least recent key",
+			limit:        rate.Inf,
+			overflow:     EvictLeastRecentKey,
+			wantLimiters: []string{"a", "c"},
+			wantStats:    KeyLimitStats{Keys: 2, Evicted: 1},
+			wantMetric:   `ratelim_key_overflows_total{action="evicted"} 1`,
+		},
+		{
+			// evicting the drained limiters would refill them, so the new key is rejected instead
+			name:         "keep drained keys",
+			limit:        1000,
+			overflow:     EvictLeastRecentKey,
+			wantErr:      true,
+			wantLimiters: []string{"a", "b"},
+			wantStats:    KeyLimitStats{Keys: 2, Rejected: 1},
+			wantMetric:   `ratelim_key_overflows_total{action="rejected"} 1`,
+		},
+		{
+			name:         "reject new keys",
+			limit:        1000,
+			overflow:     RejectNewKeys,
+			wantErr:      true,
+			wantLimiters: []string{"a", "b"},
+			wantStats:    KeyLimitStats{Keys: 2, Rejected: 1},
+			wantMetric:   `ratelim_key_overflows_total{action="rejected"} 1`,
+		},
+		{
+			name:         "collapse new keys",
+			limit:        1000,
+			overflow:     CollapseNewKeys,
+			wantLimiters: []string{"a", "b", "overflow"},
+			wantStats:    KeyLimitStats{Keys: 2, Collapsed: 1},
+			wantMetric:   `ratelim_key_overflows_total{action="collapsed"} 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				host := func(req *http.Request) string {
+					return req.URL.Host
+				}
+				rt := NewPerKeyRoundTripper(tt.limit, 10, host, &flakyTransport{})
+				rt.MaxKeys = 2
+				rt.KeyOverflow = tt.overflow
+				rt.OverflowKey = "overflow"
+				var err error
+				for _, key := range []string{"a", "b", "a", "c"} {
+					req := mustNewRequest(t)
+					req.URL.Host = key
+					_, err = rt.RoundTrip(req)
+				}
+				var tooMany *TooManyKeysError
+				if tt.wantErr != errors.As(err, &tooMany) {
+					t.Errorf("RoundTrip() for the third key error = %v, want a *TooManyKeysError: %v", err, tt.wantErr)
+				}
+				limiters := rt.Limiters().Keys()
+				slices.Sort(limiters)
+				if !reflect.DeepEqual(limiters, tt.wantLimiters) {
+					t.Errorf("limiters of %q kept, want %q", limiters, tt.wantLimiters)
+				}
+				if stats := rt.KeyLimitStats(); stats != tt.wantStats {
+					t.Errorf("KeyLimitStats() = %+v, want %+v", stats, tt.wantStats)
+				}
+				var metrics strings.Builder
+				if err := rt.WriteMetrics(&metrics); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(metrics.String(), "ratelim_keys 2\n") ||
+					!strings.Contains(metrics.String(), tt.wantMetric+"\n") {
+					t.Errorf("WriteMetrics() wrote:\n%s\nwant ratelim_keys 2 and %s", metrics.String(), tt.wantMetric)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_evictable(t *testing.T) {
+	rt := NewPerKeyRoundTripper[string](1, 2, nil, &flakyTransport{})
+	if !rt.evictable("new") {
+		t.Error("evictable() = false for a key without state")
+	}
+	limiter := rt.limiter("a")
+	if !rt.evictable("a") {
+		t.Error("evictable() = false for a full limiter")
+	}
+	limiter.Allow()
+	if rt.evictable("a") {
+		t.Error("evictable() = true for a drained limiter")
+	}
+	rt.limiter("b")
+	loadOrCompute[string](rt.waiters, "b", newValue[atomic.Int64]).Add(1)
+	if rt.evictable("b") {
+		t.Error("evictable() = true for a key with waiters")
+	}
+}
+
+func TestKeyTable_forgetLocked(t *testing.T) {
+	var kt keyTable[string]
+	kt.admit("a", 1, nil, nil)
+	// the state of an evicted key is forgotten before another request can admit it again
+	var forgotten []string
+	forget := func(key string) {
+		if kt.mux.TryLock() {
+			kt.mux.Unlock()
+			t.Errorf("%s forgotten without the lock of the keyTable", key)
+		}
+		forgotten = append(forgotten, key)
+	}
+	evictable := func(string) bool {
+		return true
+	}
+	if ok, didEvict := kt.admit("b", 1, evictable, forget); !ok || !didEvict {
+		t.Fatalf("admit() = %t, %t; want true, true", ok, didEvict)
+	}
+	kt.remove("b", forget)
+	if want := []string{"a", "b"}; !slices.Equal(forgotten, want) {
+		t.Errorf("forgot %v, want %v", forgotten, want)
+	}
+}
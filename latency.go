@@ -0,0 +1,145 @@
+package ratelim
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A LatencyController adapts the rate.Limiter of each key to the health of the upstream serving it, as measured by
+// the latency and error rate of its responses, to protect upstreams which slow down before they begin throttling.
+//
+// For each key, it tracks a baseline latency, learned slowly while the key is healthy, and a recent latency and error
+// rate, which follow each response closely. When the recent latency exceeds the baseline by more than Tolerance, or
+// the recent error rate exceeds MaxErrorRate, the limit is multiplied by Backoff, at most once per Cooldown; while
+// neither does, it is increased by Recovery on each response until restored to the key's configured limit.
+type LatencyController[K comparable] struct {
+	// Tolerance is the factor by which the recent latency may exceed the baseline before the key is degraded; if not
+	// greater than 1, 2 is used.
+	Tolerance float64
+	// MaxErrorRate is the recent error rate above which the key is degraded; if not within (0, 1], 0.5 is used.
+	MaxErrorRate float64
+	// Backoff is the factor by which the limit is multiplied when degraded; if not within (0, 1), 0.5 is used.
+	Backoff float64
+	// Recovery is the amount by which the limit is increased on each healthy response; if not positive, one tenth of
+	// the configured limit is used.
+	Recovery rate.Limit
+	// MinLimit is the lowest limit the controller will back off to.
+	MinLimit rate.Limit
+	// Cooldown is the minimum time between backoffs, so that the limit is not cut once per slow response before the
+	// reduced rate has had any effect; if not positive, one second is used.
+	Cooldown time.Duration
+	// MinSamples is the number of responses observed for a key before it is adapted, while its baseline is learned;
+	// if not positive, 10 is used.
+	MinSamples int
+	states     *syncmap.SyncMap[K, *latencyState]
+	now        func() time.Time
+}
+
+// latencyState holds the moving averages of a key.
+type latencyState struct {
+	mux         sync.Mutex
+	samples     int
+	baseline    float64 // seconds
+	recent      float64 // seconds
+	errorRate   float64
+	lastBackoff time.Time
+}
+
+const (
+	// recentWeight and baselineWeight are the weights of each new sample in the exponentially weighted moving
+	// averages of recent latency and error rate, and of baseline latency.
+	recentWeight   = 0.2
+	baselineWeight = 0.02
+)
+
+// NewLatencyController returns a new LatencyController with default parameters.
+func NewLatencyController[K comparable]() *LatencyController[K] {
+	return &LatencyController[K]{
+		states: syncmap.New[K, *latencyState](),
+		now:    time.Now,
+	}
+}
+
+func orDefault[T int | float64 | time.Duration](value, fallback T, valid bool) T {
+	if valid {
+		return value
+	}
+	return fallback
+}
+
+// Health returns the baseline and recent latency and the recent error rate of key, as currently estimated.
+func (c *LatencyController[K]) Health(key K) (baseline, recent time.Duration, errorRate float64) {
+	s, ok := c.states.Load(key)
+	if !ok {
+		return 0, 0, 0
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return time.Duration(s.baseline * float64(time.Second)), time.Duration(s.recent * float64(time.Second)), s.errorRate
+}
+
+// Observe records a response for key, received latency after its request was sent, which failed if failed is true,
+// and adjusts limiter, whose configured (i.e. maximum) limit is max, accordingly.
+func (c *LatencyController[K]) Observe(
+	key K,
+	limiter *rate.Limiter,
+	max rate.Limit,
+	latency time.Duration,
+	failed bool,
+) {
+	if max == rate.Inf {
+		return
+	}
+	s := loadOrCompute[K](c.states, key, newValue[latencyState])
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	sample, failure := latency.Seconds(), 0.0
+	if failed {
+		failure = 1
+	}
+	if s.samples == 0 {
+		s.baseline, s.recent = sample, sample
+	}
+	s.samples++
+	s.recent += recentWeight * (sample - s.recent)
+	s.errorRate += recentWeight * (failure - s.errorRate)
+
+	tolerance := orDefault(c.Tolerance, 2, c.Tolerance > 1)
+	maxErrorRate := orDefault(c.MaxErrorRate, 0.5, c.MaxErrorRate > 0 && c.MaxErrorRate <= 1)
+	degraded := s.recent > s.baseline*tolerance || s.errorRate > maxErrorRate
+	if !degraded && !failed {
+		// only learn the baseline from healthy responses, so that it does not drift up with a degrading upstream
+		s.baseline += baselineWeight * (sample - s.baseline)
+	}
+	if s.samples < orDefault(c.MinSamples, 10, c.MinSamples > 0) {
+		return
+	}
+	current := limiter.Limit()
+	now := c.now()
+	if degraded {
+		if now.Sub(s.lastBackoff) < orDefault(c.Cooldown, time.Second, c.Cooldown > 0) {
+			return
+		}
+		s.lastBackoff = now
+		limit := current * rate.Limit(orDefault(c.Backoff, 0.5, c.Backoff > 0 && c.Backoff < 1))
+		if limit < c.MinLimit {
+			limit = c.MinLimit
+		}
+		if limit != current {
+			limiter.SetLimit(limit)
+		}
+		return
+	}
+	if current >= max {
+		return
+	}
+	limit := current + rate.Limit(orDefault(float64(c.Recovery), float64(max/10), c.Recovery > 0))
+	if limit > max {
+		limit = max
+	}
+	limiter.SetLimit(limit)
+}
@@ -0,0 +1,54 @@
+package ratelim
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestLatencyController_Observe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewLatencyController[string]()
+	c.Recovery = 1
+	c.now = func() time.Time { return now }
+	limiter := rate.NewLimiter(10, 1)
+	observe := func(n int, latency time.Duration, failed bool) {
+		for i := 0; i < n; i++ {
+			now = now.Add(100 * time.Millisecond)
+			c.Observe("k", limiter, 10, latency, failed)
+		}
+	}
+
+	observe(20, 100*time.Millisecond, false)
+	if limiter.Limit() != 10 {
+		t.Fatalf("limit = %v after healthy responses, want 10", limiter.Limit())
+	}
+	if baseline, _, _ := c.Health("k"); baseline != 100*time.Millisecond {
+		t.Errorf("baseline = %v, want 100ms", baseline)
+	}
+
+	// the recent latency passes twice the baseline within a few slow responses; backoffs are then a second apart
+	observe(5, time.Second, false)
+	if limiter.Limit() != 5 {
+		t.Errorf("limit = %v after slow responses, want 5", limiter.Limit())
+	}
+	observe(10, time.Second, false)
+	if limiter.Limit() != 2.5 {
+		t.Errorf("limit = %v after a second of slow responses, want 2.5", limiter.Limit())
+	}
+	if baseline, _, _ := c.Health("k"); baseline > 150*time.Millisecond {
+		t.Errorf("baseline drifted to %v while degraded", baseline)
+	}
+
+	// once latency has recovered, the limit is restored by Recovery per response
+	observe(20, 100*time.Millisecond, false)
+	if limiter.Limit() != 10 {
+		t.Errorf("limit = %v after recovery, want 10", limiter.Limit())
+	}
+
+	observe(5, 100*time.Millisecond, true)
+	if _, _, errorRate := c.Health("k"); errorRate <= 0.5 || limiter.Limit() != 5 {
+		t.Errorf("limit = %v with error rate %v, want 5", limiter.Limit(), errorRate)
+	}
+}
@@ -0,0 +1,186 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+	"golang.org/x/time/rate"
+)
+
+// A PerKeyLimiter rate limits operations grouped by a key, such as database calls per table or messages published per
+// queue, with a rate.Limiter per key. If no rate.Limiter exists for a key yet, one is instantiated with the rate.Limit
+// and burst returned by LimiterConfig, which are the defaults returned by LimiterDefaults unless overridden for the key
+// by SetLimiterConfig or LimiterConfigFunc. Only the Limit and Burst of a LimiterConfig apply to Wait, Allow and Do;
+// the other settings are applied by a PerKeyRoundTripper, which embeds a PerKeyLimiter.
+type PerKeyLimiter[K comparable] struct {
+	defaults atomic.Pointer[LimiterConfig]
+	rules    atomic.Pointer[func(key K) (LimiterConfig, bool)]
+	limiters *Map[K]
+	configs  *syncmap.SyncMap[K, LimiterConfig]
+	stats    *syncmap.SyncMap[K, *keyStats]
+	mux      sync.Mutex
+	// LimiterConfigFunc, if non-nil, is called with a key to override the LimiterDefaults used for that key; the
+	// returned config is used only if ok is true. The Rules applied by a TransportConfig take precedence over it.
+	LimiterConfigFunc func(key K) (cfg LimiterConfig, ok bool)
+	// Overrides, if non-nil, supplies configs overriding both LimiterConfigFunc and LimiterDefaults, such as from a
+	// feature flag system.
+	Overrides OverrideProvider[K]
+	// WaitBuckets are the upper bounds of the buckets of the per-key histograms of wait times reported by Stats; if
+	// nil, DefaultWaitBuckets are used. Changes apply only to keys first seen afterwards.
+	WaitBuckets []time.Duration
+	// StatsRotation, if set, rotates the Stats of each key into windows, returned by StatsWindows.
+	StatsRotation *StatsRotation
+}
+
+// NewPerKeyLimiter creates a new PerKeyLimiter. The defaultLimit and defaultBurst determine the rate.Limit and burst
+// parameters used to create a new rate.Limiter when none is mapped yet to a given key.
+func NewPerKeyLimiter[K comparable](defaultLimit rate.Limit, defaultBurst int) *PerKeyLimiter[K] {
+	l := &PerKeyLimiter[K]{
+		limiters: NewMap[K](),
+		configs:  syncmap.New[K, LimiterConfig](),
+		stats:    syncmap.New[K, *keyStats](),
+	}
+	l.defaults.Store(&LimiterConfig{Limit: defaultLimit, Burst: defaultBurst})
+	return l
+}
+
+func (l *PerKeyLimiter[K]) LimiterDefaults() (limit rate.Limit, burst int) {
+	defaults := l.defaults.Load()
+	return defaults.Limit, defaults.Burst
+}
+
+func (l *PerKeyLimiter[K]) SetLimiterDefaults(limit rate.Limit, burst int) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	defaults := *l.defaults.Load()
+	defaults.Limit = limit
+	defaults.Burst = burst
+	l.defaults.Store(&defaults)
+}
+
+// DefaultLimiterConfig returns the config used for keys which have no config of their own. Its Limit and Burst are
+// those returned by LimiterDefaults.
+func (l *PerKeyLimiter[K]) DefaultLimiterConfig() LimiterConfig {
+	return *l.defaults.Load()
+}
+
+// SetDefaultLimiterConfig sets the config used for keys which have no config of their own.
+func (l *PerKeyLimiter[K]) SetDefaultLimiterConfig(cfg LimiterConfig) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.defaults.Store(&cfg)
+}
+
+// Reconfigure updates the limit and burst of every existing rate.Limiter to match the current LimiterConfig of its
+// key, e.g. after changing the defaults, which otherwise apply only to limiters created afterwards.
+func (l *PerKeyLimiter[K]) Reconfigure() {
+	l.limiters.Range(
+		func(key K, limiter *rate.Limiter) bool {
+			l.LimiterConfig(key).apply(limiter)
+			return true
+		},
+	)
+}
+
+// LimiterConfig returns the config used to create a rate.Limiter for key.
+func (l *PerKeyLimiter[K]) LimiterConfig(key K) LimiterConfig {
+	if cfg, ok := l.configs.Load(key); ok {
+		return cfg
+	}
+	if l.Overrides != nil {
+		if cfg, ok := l.Overrides.GetLimit(key); ok {
+			return cfg
+		}
+	}
+	if rules := l.rules.Load(); rules != nil {
+		if cfg, ok := (*rules)(key); ok {
+			return cfg
+		}
+	}
+	if l.LimiterConfigFunc != nil {
+		if cfg, ok := l.LimiterConfigFunc(key); ok {
+			return cfg
+		}
+	}
+	return l.DefaultLimiterConfig()
+}
+
+// setRules sets the rules consulted by LimiterConfig before the LimiterConfigFunc, or removes them if rules is nil.
+// Unlike the LimiterConfigFunc field, they may be replaced while requests are being limited.
+func (l *PerKeyLimiter[K]) setRules(rules func(key K) (LimiterConfig, bool)) {
+	if rules == nil {
+		l.rules.Store(nil)
+		return
+	}
+	l.rules.Store(&rules)
+}
+
+// SetLimiterConfig overrides the config used for key, taking precedence over Overrides, LimiterConfigFunc and
+// LimiterDefaults. If a rate.Limiter already exists for key, its limit and burst are updated to match.
+func (l *PerKeyLimiter[K]) SetLimiterConfig(key K, cfg LimiterConfig) {
+	l.configs.Store(key, cfg)
+	if limiter, ok := l.limiters.Load(key); ok {
+		cfg.apply(limiter)
+	}
+}
+
+// DeleteLimiterConfig removes any config set for key by SetLimiterConfig. Any existing rate.Limiter for key is
+// updated to match the config which then applies.
+func (l *PerKeyLimiter[K]) DeleteLimiterConfig(key K) {
+	if _, ok := l.configs.LoadAndDelete(key); !ok {
+		return
+	}
+	if limiter, ok := l.limiters.Load(key); ok {
+		l.LimiterConfig(key).apply(limiter)
+	}
+}
+
+func (l *PerKeyLimiter[K]) limiter(key K) *rate.Limiter {
+	if limiter, ok := l.limiters.Load(key); ok {
+		return limiter
+	}
+	return loadOrCompute[K](
+		l.limiters, key, func() *rate.Limiter {
+			return l.LimiterConfig(key).NewLimiter()
+		},
+	)
+}
+
+func (l *PerKeyLimiter[K]) Limiters() *Map[K] {
+	return l.limiters
+}
+
+// Limiter returns the rate.Limiter of key, creating it the first time key is seen.
+func (l *PerKeyLimiter[K]) Limiter(key K) *rate.Limiter {
+	return l.limiter(key)
+}
+
+// Wait blocks until the rate.Limiter of key permits an operation, or ctx is done.
+func (l *PerKeyLimiter[K]) Wait(ctx context.Context, key K) error {
+	start := time.Now()
+	if err := l.limiter(key).Wait(ctx); err != nil {
+		return err
+	}
+	l.record(key, time.Since(start))
+	return nil
+}
+
+// Allow reports whether the rate.Limiter of key permits an operation now, consuming a token if so.
+func (l *PerKeyLimiter[K]) Allow(key K) bool {
+	if !l.limiter(key).Allow() {
+		return false
+	}
+	l.record(key, 0)
+	return true
+}
+
+// Do waits as Wait does, then calls f and returns its error.
+func (l *PerKeyLimiter[K]) Do(ctx context.Context, key K, f func() error) error {
+	if err := l.Wait(ctx, key); err != nil {
+		return err
+	}
+	return f()
+}
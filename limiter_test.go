@@ -0,0 +1,112 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"runtime/metrics"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyLimiter(t *testing.T) {
+	l := NewPerKeyLimiter[string](20, 1)
+	l.SetLimiterConfig("unlimited", LimiterConfig{Limit: 1000, Burst: 10})
+
+	if !l.Allow("orders") {
+		t.Error("Allow(orders) = false for a fresh key")
+	}
+	if l.Allow("orders") {
+		t.Error("Allow(orders) = true with an empty bucket")
+	}
+	start := time.Now()
+	if err := l.Wait(context.Background(), "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait(orders) returned after %v, want about 50ms", elapsed)
+	}
+	if !l.Allow("unlimited") {
+		t.Error("Allow(unlimited) = false, want keys limited independently")
+	}
+
+	errFailed := errors.New("failed")
+	called := false
+	err := l.Do(
+		context.Background(), "unlimited", func() error {
+			called = true
+			return errFailed
+		},
+	)
+	if !called || !errors.Is(err, errFailed) {
+		t.Errorf("Do() = %v, called %v; want the error of the func", err, called)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = l.Do(
+		ctx, "orders", func() error {
+			t.Error("Do() called the func with a canceled context")
+			return nil
+		},
+	)
+	if err == nil {
+		t.Error("Do() succeeded with a canceled context")
+	}
+
+	if got := l.Stats("orders").Requests; got != 2 {
+		t.Errorf("Stats(orders).Requests = %d, want 2", got)
+	}
+	if got := l.Stats("unlimited").Requests; got != 2 {
+		t.Errorf("Stats(unlimited).Requests = %d, want 2", got)
+	}
+}
+
+// mutexWait returns the total time goroutines have spent blocked on mutexes.
+func mutexWait() time.Duration {
+	samples := []metrics.Sample{{Name: "/sync/mutex/wait/total:seconds"}}
+	metrics.Read(samples)
+	return time.Duration(samples[0].Value.Float64() * float64(time.Second))
+}
+
+// BenchmarkPerKeyLimiter_newKeys looks up the limiters of existing keys while one in every 16 lookups is of a new key
+// whose config takes 50µs to look up, as from a remote store, which is done with the key's shard of the map locked.
+// With a single shard, as before the map was sharded, every lookup waits behind each new key; with the default
+// shards, only those of keys in the same shard do, as the blocked-ns/op metric shows.
+func BenchmarkPerKeyLimiter_newKeys(b *testing.B) {
+	for _, shards := range []int{1, 0} {
+		b.Run(
+			"shards="+strconv.Itoa(shards), func(b *testing.B) {
+				l := NewPerKeyLimiter[string](1e9, 1)
+				l.limiters = &Map[string]{ShardedMap: syncmap.NewSharded[string, *rate.Limiter](shards)}
+				l.LimiterConfigFunc = func(key string) (LimiterConfig, bool) {
+					time.Sleep(50 * time.Microsecond)
+					return LimiterConfig{}, false
+				}
+				keys := make([]string, 64)
+				for i := range keys {
+					keys[i] = "https://host-" + strconv.Itoa(i) + ".example.com"
+					l.Limiter(keys[i])
+				}
+				var next atomic.Int64
+				start := mutexWait()
+				b.ResetTimer()
+				b.RunParallel(
+					func(pb *testing.PB) {
+						for pb.Next() {
+							if i := next.Add(1); i%16 == 0 {
+								l.Limiter("https://new-" + strconv.FormatInt(i, 10) + ".example.com")
+							} else {
+								l.Limiter(keys[i%int64(len(keys))])
+							}
+						}
+					},
+				)
+				b.ReportMetric(float64(mutexWait()-start)/float64(b.N), "blocked-ns/op")
+			},
+		)
+	}
+}
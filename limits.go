@@ -0,0 +1,43 @@
+package ratelim
+
+import (
+	"sort"
+	"strings"
+)
+
+// A LimitRule applies a LimiterConfig to the keys matching Pattern, in which "*" matches any sequence of characters.
+type LimitRule struct {
+	Pattern string
+	Config  LimiterConfig
+}
+
+// A LimitTable maps string keys to their LimiterConfig by the first of its rules whose Pattern matches the key. Its
+// LimiterConfig method can be used as the LimiterConfigFunc of a PerKeyRoundTripper[string].
+type LimitTable []LimitRule
+
+// LimiterConfig returns the Config of the first rule matching key, if any.
+func (t LimitTable) LimiterConfig(key string) (cfg LimiterConfig, ok bool) {
+	for _, rule := range t {
+		if matchGlob(rule.Pattern, key) {
+			return rule.Config, true
+		}
+	}
+	return LimiterConfig{}, false
+}
+
+// Sort orders the rules from most to least specific, so that the first match is the most specific one: patterns with
+// more literal characters come first, and among those, patterns with fewer wildcards.
+func (t LimitTable) Sort() {
+	literal := func(pattern string) int {
+		return len(pattern) - strings.Count(pattern, "*")
+	}
+	sort.SliceStable(
+		t, func(i, j int) bool {
+			li, lj := literal(t[i].Pattern), literal(t[j].Pattern)
+			if li != lj {
+				return li > lj
+			}
+			return strings.Count(t[i].Pattern, "*") < strings.Count(t[j].Pattern, "*")
+		},
+	)
+}
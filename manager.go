@@ -0,0 +1,194 @@
+package ratelim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A TransportConfig configures the limits of one of a Manager's transports: the config of keys matching none of its
+// Rules, and the rules applying to the others.
+type TransportConfig struct {
+	Default LimiterConfig `json:"default"`
+	Rules   LimitTable    `json:"rules,omitempty"`
+}
+
+// Apply applies the config to t: its Default becomes the transport's default config, and its Rules replace those of
+// any config applied before, taking precedence over the transport's LimiterConfigFunc, after which existing limiters
+// are reconfigured. It may be called while t is in use, e.g. to reload a config file.
+func (c TransportConfig) Apply(t *PerKeyRoundTripper[string]) {
+	t.SetDefaultLimiterConfig(c.Default)
+	var rules func(string) (LimiterConfig, bool)
+	if len(c.Rules) > 0 {
+		rules = c.Rules.LimiterConfig
+	}
+	t.setRules(rules)
+	t.Reconfigure()
+}
+
+// LoadTransportConfig reads a TransportConfig in JSON and applies it to t.
+func LoadTransportConfig(r io.Reader, t *PerKeyRoundTripper[string]) error {
+	var cfg TransportConfig
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return fmt.Errorf("ratelim: decoding transport config: %w", err)
+	}
+	cfg.Apply(t)
+	return nil
+}
+
+// A Manager owns a set of named PerKeyRoundTripper, e.g. one per tenant or per class of upstream, and provides a
+// single surface to configure, observe and control them all.
+type Manager struct {
+	transports *syncmap.SyncMap[string, *PerKeyRoundTripper[string]]
+}
+
+// NewManager returns a new Manager without transports.
+func NewManager() *Manager {
+	return &Manager{transports: syncmap.New[string, *PerKeyRoundTripper[string]]()}
+}
+
+// Register adds a transport to the Manager under name, which must not already be in use. If the transport has no Name,
+// it is identified by name, or by the name it was first registered under, in profile labels and debug pages.
+func (m *Manager) Register(name string, t *PerKeyRoundTripper[string]) error {
+	if _, loaded := m.transports.LoadOrStore(name, t); loaded {
+		return fmt.Errorf("ratelim: transport %q already registered", name)
+	}
+	t.registeredName.CompareAndSwap(nil, &name)
+	return nil
+}
+
+// name returns the Name of t, or, if it has none, the name it was first registered under with a Manager.
+func (t *PerKeyRoundTripper[K]) name() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if name := t.registeredName.Load(); name != nil {
+		return *name
+	}
+	return ""
+}
+
+// Unregister removes the transport registered under name, if any.
+func (m *Manager) Unregister(name string) {
+	m.transports.Delete(name)
+}
+
+// Transport returns the transport registered under name.
+func (m *Manager) Transport(name string) (t *PerKeyRoundTripper[string], ok bool) {
+	return m.transports.Load(name)
+}
+
+// Names returns the names of the registered transports in sorted order.
+func (m *Manager) Names() []string {
+	names := m.transports.Keys()
+	slices.Sort(names)
+	return names
+}
+
+// Configure applies the config of each named transport, as TransportConfig.Apply does. Transports without a config are
+// left unchanged. It fails, without applying any config, if a config names a transport which is not registered.
+func (m *Manager) Configure(configs map[string]TransportConfig) error {
+	for name := range configs {
+		if _, ok := m.transports.Load(name); !ok {
+			return fmt.Errorf("ratelim: no transport registered as %q", name)
+		}
+	}
+	for name, cfg := range configs {
+		t, _ := m.transports.Load(name)
+		cfg.Apply(t)
+	}
+	return nil
+}
+
+// LoadConfig reads a JSON object mapping transport names to their TransportConfig, and applies it with Configure.
+func (m *Manager) LoadConfig(r io.Reader) error {
+	var configs map[string]TransportConfig
+	if err := json.NewDecoder(r).Decode(&configs); err != nil {
+		return fmt.Errorf("ratelim: decoding manager config: %w", err)
+	}
+	return m.Configure(configs)
+}
+
+// each calls f with each registered transport, in order of name.
+func (m *Manager) each(f func(name string, t *PerKeyRoundTripper[string])) {
+	for _, name := range m.Names() {
+		if t, ok := m.transports.Load(name); ok {
+			f(name, t)
+		}
+	}
+}
+
+// SetMode sets the Mode of every registered transport.
+func (m *Manager) SetMode(mode Mode) {
+	m.each(
+		func(_ string, t *PerKeyRoundTripper[string]) {
+			t.SetMode(mode)
+		},
+	)
+}
+
+// PauseAll pauses every registered transport for d, as PerKeyRoundTripper.PauseAll does.
+func (m *Manager) PauseAll(d time.Duration) {
+	m.each(
+		func(_ string, t *PerKeyRoundTripper[string]) {
+			t.PauseAll(d)
+		},
+	)
+}
+
+// ResumeAll ends the pause of every registered transport.
+func (m *Manager) ResumeAll() {
+	m.each(
+		func(_ string, t *PerKeyRoundTripper[string]) {
+			t.ResumeAll()
+		},
+	)
+}
+
+// Close closes every registered transport, stopping their background goroutines. It always returns nil.
+func (m *Manager) Close() error {
+	m.each(
+		func(_ string, t *PerKeyRoundTripper[string]) {
+			_ = t.Close()
+		},
+	)
+	return nil
+}
+
+// Stats returns the Stats of every key of every registered transport, by transport name.
+func (m *Manager) Stats() map[string]map[string]Stats {
+	all := make(map[string]map[string]Stats)
+	m.each(
+		func(name string, t *PerKeyRoundTripper[string]) {
+			all[name] = t.AllStats()
+		},
+	)
+	return all
+}
+
+// WriteMetrics writes the Stats of every key of every registered transport to w, as PerKeyRoundTripper.WriteMetrics
+// does, labeled by transport name and key.
+func (m *Manager) WriteMetrics(w io.Writer) error {
+	var (
+		all       []labeledStats
+		keyLimits []labeledKeyLimitStats
+	)
+	m.each(
+		func(name string, t *PerKeyRoundTripper[string]) {
+			all = append(all, t.labeledStats("transport="+quoteLabel(name)+",")...)
+			keyLimits = append(keyLimits, t.labeledKeyLimitStats("transport="+quoteLabel(name))...)
+		},
+	)
+	return writeMetrics(w, all, keyLimits)
+}
+
+// ServeHTTP serves the metrics written by WriteMetrics, so that a Manager can be mounted as a metrics endpoint.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WriteMetrics(w)
+}
@@ -0,0 +1,125 @@
+package ratelim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager()
+	acme, globex := PerOriginRoundTripper(1, 1, nil), PerOriginRoundTripper(1, 1, nil)
+	if err := m.Register("acme", acme); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("globex", globex); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("acme", globex); err == nil {
+		t.Error("Register() of a duplicate name succeeded")
+	}
+	if names := m.Names(); len(names) != 2 || names[0] != "acme" || names[1] != "globex" {
+		t.Errorf("Names() = %v", names)
+	}
+
+	acme.limiter("https://api.example.com")
+	config := `{
+		"acme": {
+			"default": {"Limit": 5, "Burst": 5},
+			"rules": [{"Pattern": "https://api.example.com", "Config": {"Limit": 50, "Burst": 10}}]
+		}
+	}`
+	if err := m.LoadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	if l := acme.limiter("https://api.example.com"); l.Limit() != 50 || l.Burst() != 10 {
+		t.Errorf("existing limiter not reconfigured: limit, burst = %v, %d", l.Limit(), l.Burst())
+	}
+	if cfg := acme.LimiterConfig("https://other.example.com"); cfg.Limit != 5 {
+		t.Errorf("default LimiterConfig() = %+v", cfg)
+	}
+	if cfg := globex.LimiterConfig("https://api.example.com"); cfg.Limit != 1 {
+		t.Errorf("unconfigured transport changed: LimiterConfig() = %+v", cfg)
+	}
+	if err := m.LoadConfig(strings.NewReader(`{"initech": {"default": {"Limit": 1}}}`)); err == nil {
+		t.Error("LoadConfig() of an unregistered transport succeeded")
+	}
+
+	m.SetMode(ModeReject)
+	if acme.Mode() != ModeReject || globex.Mode() != ModeReject {
+		t.Error("SetMode() did not reach every transport")
+	}
+	m.PauseAll(time.Minute)
+	if _, ok := globex.Paused(); !ok {
+		t.Error("PauseAll() did not reach every transport")
+	}
+	m.ResumeAll()
+
+	acme.recordRequest("https://api.example.com", 0, 0, nil)
+	globex.recordRequest("https://api.example.com", 0, 0, nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`ratelim_requests_total{transport="acme",key="https://api.example.com"} 1`,
+		`ratelim_requests_total{transport="globex",key="https://api.example.com"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, w.Body.String())
+		}
+	}
+	if strings.Count(w.Body.String(), "# TYPE ratelim_requests_total") != 1 {
+		t.Error("metrics declare ratelim_requests_total more than once")
+	}
+}
+
+func TestManager_LoadConfigConcurrently(t *testing.T) {
+	m := NewManager()
+	rt := PerOriginRoundTripper(rate.Inf, 1, &flakyTransport{})
+	rt.ProfileLabels = true
+	started, done := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(started)
+		for i := 0; ; i++ {
+			if i == 1 {
+				started <- struct{}{}
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			req, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	<-started
+	if err := m.Register("acme", rt); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		config := fmt.Sprintf(
+			`{"acme": {"default": {"Limit": 1}, "rules": [{"Pattern": "*", "Config": {"Limit": %d, "Burst": 1000}}]}}`,
+			1000+i,
+		)
+		if err := m.LoadConfig(strings.NewReader(config)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if cfg := rt.LimiterConfig("https://api.example.com"); cfg.Limit != 1049 {
+		t.Errorf("LimiterConfig() = %+v after the last reload, want its rule's limit of 1049", cfg)
+	}
+}
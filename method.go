@@ -0,0 +1,63 @@
+package ratelim
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// TargetOriginAndMethod is a key function for PerKeyRoundTripper which keys requests by their method and the origin of
+// their URL, as "METHOD origin", e.g. "POST https://api.example.com", for APIs which document separate limits for
+// reads and writes. A LimitTable can then set the limits of the methods of one origin with patterns such as
+// "POST https://api.example.com", or of one method of every origin with patterns such as "DELETE *".
+func TargetOriginAndMethod(r *http.Request) string {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + Origin(r.URL)
+}
+
+// SplitOriginAndMethod splits a key made by TargetOriginAndMethod into its method and origin.
+func SplitOriginAndMethod(key string) (method, origin string) {
+	method, origin, _ = strings.Cut(key, " ")
+	return method, origin
+}
+
+// PerOriginAndMethodRoundTripper returns a PerKeyRoundTripper which limits requests per origin and method, keyed by
+// TargetOriginAndMethod, so that e.g. the writes to an origin do not consume the allowance of its reads. Limits per
+// method can be set with MethodLimits, and per origin and method with a LimitTable, as its LimiterConfigFunc.
+func PerOriginAndMethodRoundTripper(
+	defaultLimit rate.Limit,
+	defaultBurst int,
+	roundTripper http.RoundTripper,
+) *PerKeyRoundTripper[string] {
+	return NewPerKeyRoundTripper(defaultLimit, defaultBurst, TargetOriginAndMethod, roundTripper)
+}
+
+// MethodLimits maps HTTP methods to the LimiterConfig of the keys made by TargetOriginAndMethod for them, whatever
+// their origin. Its LimiterConfig method can be used as the LimiterConfigFunc of a PerKeyRoundTripper[string].
+type MethodLimits map[string]LimiterConfig
+
+// ReadWriteLimits returns the MethodLimits applying read to the safe methods GET, HEAD, OPTIONS and TRACE, and write
+// to POST, PUT, PATCH and DELETE.
+func ReadWriteLimits(read, write LimiterConfig) MethodLimits {
+	return MethodLimits{
+		http.MethodGet:     read,
+		http.MethodHead:    read,
+		http.MethodOptions: read,
+		http.MethodTrace:   read,
+		http.MethodPost:    write,
+		http.MethodPut:     write,
+		http.MethodPatch:   write,
+		http.MethodDelete:  write,
+	}
+}
+
+// LimiterConfig returns the config of the method of key, a key made by TargetOriginAndMethod, if any.
+func (m MethodLimits) LimiterConfig(key string) (cfg LimiterConfig, ok bool) {
+	method, _ := SplitOriginAndMethod(key)
+	cfg, ok = m[method]
+	return cfg, ok
+}
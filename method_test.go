@@ -0,0 +1,74 @@
+package ratelim
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTargetOriginAndMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{name: "GET", method: http.MethodGet, url: "https://Example.com:443/a?b", want: "GET https://example.com"},
+		{name: "empty method", url: "http://example.com/", want: "GET http://example.com"},
+		{name: "lower-case method", method: "post", url: "https://example.com/", want: "POST https://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := &http.Request{Method: tt.method, URL: mustParseURL(t, tt.url)}
+				key := TargetOriginAndMethod(req)
+				if key != tt.want {
+					t.Errorf("TargetOriginAndMethod() = %q, want %q", key, tt.want)
+				}
+				method, origin := SplitOriginAndMethod(key)
+				if method+" "+origin != key {
+					t.Errorf("SplitOriginAndMethod(%q) = %q, %q", key, method, origin)
+				}
+			},
+		)
+	}
+}
+
+func TestPerOriginAndMethodRoundTripper(t *testing.T) {
+	rt := PerOriginAndMethodRoundTripper(10, 10, &flakyTransport{})
+	read, write := LimiterConfig{Limit: 100, Burst: 20}, LimiterConfig{Limit: 5, Burst: 1}
+	orders := LimiterConfig{Limit: 1, Burst: 1}
+	table := LimitTable{{Pattern: "POST https://api.example.com", Config: orders}}
+	methods := ReadWriteLimits(read, write)
+	rt.LimiterConfigFunc = func(key string) (LimiterConfig, bool) {
+		if cfg, ok := table.LimiterConfig(key); ok {
+			return cfg, true
+		}
+		return methods.LimiterConfig(key)
+	}
+	tests := []struct {
+		method string
+		url    string
+		want   LimiterConfig
+	}{
+		{method: http.MethodGet, url: "https://api.example.com/orders", want: read},
+		{method: http.MethodHead, url: "https://other.example.com/", want: read},
+		{method: http.MethodPost, url: "https://api.example.com/orders", want: orders},
+		{method: http.MethodDelete, url: "https://api.example.com/orders/1", want: write},
+		{method: "PROPFIND", url: "https://api.example.com/", want: LimiterConfig{Limit: 10, Burst: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.method+" "+tt.url, func(t *testing.T) {
+				req, _ := http.NewRequest(tt.method, tt.url, nil)
+				if cfg := rt.LimiterConfig(rt.Key(req)); cfg != tt.want {
+					t.Errorf("LimiterConfig() = %+v, want %+v", cfg, tt.want)
+				}
+			},
+		)
+	}
+	get, _ := http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	post, _ := http.NewRequest(http.MethodPost, "https://api.example.com/", nil)
+	if rt.Limiter(get) == rt.Limiter(post) {
+		t.Error("GET and POST requests to an origin share a limiter")
+	}
+}
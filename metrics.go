@@ -0,0 +1,114 @@
+package ratelim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// labeledStats are the Stats of a key, with the Prometheus labels identifying it.
+type labeledStats struct {
+	labels string
+	stats  Stats
+}
+
+// labeledStats returns the Stats of every key, labeled by key and by the given extra labels, sorted by key.
+func (t *PerKeyRoundTripper[K]) labeledStats(extra string) []labeledStats {
+	var all []labeledStats
+	for key, stats := range t.AllStats() {
+		all = append(all, labeledStats{labels: extra + "key=" + quoteLabel(fmt.Sprint(key)), stats: stats})
+	}
+	sort.Slice(
+		all, func(i, j int) bool {
+			return all[i].labels < all[j].labels
+		},
+	)
+	return all
+}
+
+// WriteMetrics writes the Stats of every key to w in the Prometheus text exposition format, labeled by key, so that
+// they can be served to a Prometheus scraper without further dependencies. Wait times are exported as a histogram in
+// seconds. If MaxKeys is set, its KeyLimitStats are written too.
+func (t *PerKeyRoundTripper[K]) WriteMetrics(w io.Writer) error {
+	return writeMetrics(w, t.labeledStats(""), t.labeledKeyLimitStats(""))
+}
+
+func writeMetrics(w io.Writer, all []labeledStats, keyLimits []labeledKeyLimitStats) error {
+	bw := bufio.NewWriter(w)
+	counters := []struct {
+		name, help string
+		value      func(Stats) int64
+	}{
+		{"ratelim_requests_total", "Requests sent per key.", func(s Stats) int64 { return s.Requests }},
+		{"ratelim_redirects_total", "Redirects followed per key.", func(s Stats) int64 { return s.Redirects }},
+		{"ratelim_retries_total", "Retries sent per key.", func(s Stats) int64 { return s.Retries }},
+		{"ratelim_streams_total", "Streaming requests sent per key.", func(s Stats) int64 { return s.Streams }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, s := range all {
+			fmt.Fprintf(bw, "%s{%s} %d\n", c.name, s.labels, c.value(s.stats))
+		}
+	}
+	const wait = "ratelim_wait_seconds"
+	fmt.Fprintf(bw, "# HELP %s Time requests waited to be permitted per key.\n# TYPE %s histogram\n", wait, wait)
+	for _, s := range all {
+		h := s.stats.Wait
+		var cumulative int64
+		for i, bound := range h.Bounds {
+			cumulative += h.Counts[i]
+			le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+			fmt.Fprintf(bw, "%s_bucket{%s,le=\"%s\"} %d\n", wait, s.labels, le, cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{%s,le=\"+Inf\"} %d\n", wait, s.labels, h.Count)
+		fmt.Fprintf(bw, "%s_sum{%s} %g\n", wait, s.labels, h.Sum.Seconds())
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", wait, s.labels, h.Count)
+	}
+	if len(keyLimits) > 0 {
+		writeKeyLimitMetrics(bw, keyLimits)
+	}
+	return bw.Flush()
+}
+
+// writeKeyLimitMetrics writes the KeyLimitStats of transports, whose labels identify them without a trailing comma.
+func writeKeyLimitMetrics(w io.Writer, all []labeledKeyLimitStats) {
+	const keys = "ratelim_keys"
+	fmt.Fprintf(w, "# HELP %s Keys whose state is kept under MaxKeys.\n# TYPE %s gauge\n", keys, keys)
+	for _, s := range all {
+		if s.labels == "" {
+			fmt.Fprintf(w, "%s %d\n", keys, s.stats.Keys)
+		} else {
+			fmt.Fprintf(w, "%s{%s} %d\n", keys, s.labels, s.stats.Keys)
+		}
+	}
+	const overflow = "ratelim_key_overflows_total"
+	fmt.Fprintf(
+		w, "# HELP %s Requests for new keys beyond MaxKeys, by action taken.\n# TYPE %s counter\n", overflow, overflow,
+	)
+	for _, s := range all {
+		prefix := s.labels
+		if prefix != "" {
+			prefix += ","
+		}
+		for _, a := range []struct {
+			action string
+			value  int64
+		}{
+			{"evicted", s.stats.Evicted},
+			{"rejected", s.stats.Rejected},
+			{"collapsed", s.stats.Collapsed},
+		} {
+			fmt.Fprintf(w, "%s{%saction=\"%s\"} %d\n", overflow, prefix, a.action, a.value)
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel quotes a Prometheus label value.
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,33 @@
+package ratelim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_WriteMetrics(t *testing.T) {
+	rt := PerOriginRoundTripper(1, 1, nil)
+	rt.WaitBuckets = []time.Duration{10 * time.Millisecond, time.Second}
+	rt.recordRequest(`https://a.example.com`, 0, 0, nil)
+	rt.recordRequest(`https://a.example.com`, 500*time.Millisecond, 0, nil)
+	rt.recordRequest(`b"\`, 2*time.Second, 0, nil)
+	var buf strings.Builder
+	if err := rt.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE ratelim_requests_total counter\n",
+		`ratelim_requests_total{key="https://a.example.com"} 2` + "\n",
+		`ratelim_requests_total{key="b\"\\"} 1` + "\n",
+		`ratelim_wait_seconds_bucket{key="https://a.example.com",le="0.01"} 1` + "\n",
+		`ratelim_wait_seconds_bucket{key="https://a.example.com",le="1"} 2` + "\n",
+		`ratelim_wait_seconds_bucket{key="https://a.example.com",le="+Inf"} 2` + "\n",
+		`ratelim_wait_seconds_sum{key="https://a.example.com"} 0.5` + "\n",
+		`ratelim_wait_seconds_bucket{key="b\"\\",le="1"} 0` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ratelim
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A Middleware rate limits the requests received by an http.Handler per key, rejecting those which exceed the limit
+// of their key with 429 Too Many Requests and a Retry-After header, rather than delaying them as PerKeyRoundTripper
+// does for outgoing requests.
+//
+// Since keys such as client addresses or tokens are chosen by clients, the limiters of keys are evicted once they have
+// refilled, every SweepInterval, so that the memory used is bounded by the keys seen within the time their limiters
+// take to refill rather than by all the keys ever seen. A limiter which has refilled is recreated as it was, so
+// evicting it never loosens the limit of its key.
+type Middleware[K comparable] struct {
+	keyFunc   func(*http.Request) K
+	defaults  LimiterConfig
+	limiters  *Map[middlewareKey[K]]
+	lastSweep atomic.Int64
+	// SweepInterval is the interval at which the limiters which have refilled are evicted, by the request which finds
+	// it has elapsed, in the background; if not positive, 1 minute is used.
+	SweepInterval time.Duration
+	// LimiterConfigFunc, if non-nil, is called with a key to override the default config used for that key; the
+	// returned config is used only if ok is true.
+	LimiterConfigFunc func(key K) (cfg LimiterConfig, ok bool)
+	// Profile, if non-nil, lets a trusted upstream select a named LimiterConfig for each request, which overrides
+	// that of its key.
+	Profile *TrustedProfile
+	// OnRejected, if non-nil, writes the response to a rejected request, after its Retry-After header has been set;
+	// by default, a 429 Too Many Requests status is written.
+	OnRejected func(w http.ResponseWriter, r *http.Request)
+}
+
+// middlewareKey distinguishes the limiters of a key per profile, so that requests of a key under different profiles
+// do not reconfigure each other's limiter.
+type middlewareKey[K comparable] struct {
+	profile string
+	key     K
+}
+
+// NewMiddleware returns a Middleware which limits the requests of each key, as derived by keyFunc, to defaultLimit
+// with a burst of defaultBurst, unless otherwise configured.
+func NewMiddleware[K comparable](
+	defaultLimit rate.Limit,
+	defaultBurst int,
+	keyFunc func(*http.Request) K,
+) *Middleware[K] {
+	return &Middleware[K]{
+		keyFunc:  keyFunc,
+		defaults: LimiterConfig{Limit: defaultLimit, Burst: defaultBurst},
+		limiters: NewMap[middlewareKey[K]](),
+	}
+}
+
+// config returns the profile name and LimiterConfig which apply to r, whose key is key.
+func (m *Middleware[K]) config(r *http.Request, key K) (string, LimiterConfig) {
+	if m.Profile != nil {
+		if name, cfg, ok := m.Profile.Select(r); ok {
+			return name, cfg
+		}
+	}
+	if m.LimiterConfigFunc != nil {
+		if cfg, ok := m.LimiterConfigFunc(key); ok {
+			return "", cfg
+		}
+	}
+	return "", m.defaults
+}
+
+// Allow reports whether r is permitted by the limiter of its key, consuming a token if so; if not, retryAfter is how
+// long until it would be permitted, or a negative duration if it never would be.
+func (m *Middleware[K]) Allow(r *http.Request) (ok bool, retryAfter time.Duration) {
+	key := m.keyFunc(r)
+	profile, cfg := m.config(r, key)
+	limiter := loadOrCompute[middlewareKey[K]](
+		m.limiters, middlewareKey[K]{profile: profile, key: key}, cfg.NewLimiter,
+	)
+	cfg.apply(limiter)
+	m.maybeSweep()
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return false, -1
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// maybeSweep starts EvictIdle in the background if SweepInterval has elapsed since it was last started.
+func (m *Middleware[K]) maybeSweep() {
+	interval := orDefault(m.SweepInterval, time.Minute, m.SweepInterval > 0)
+	now := time.Now().UnixNano()
+	last := m.lastSweep.Load()
+	if last == 0 {
+		// the first sweep is due an interval after the first request
+		m.lastSweep.CompareAndSwap(0, now)
+		return
+	}
+	if now-last < int64(interval) || !m.lastSweep.CompareAndSwap(last, now) {
+		return
+	}
+	go m.EvictIdle()
+}
+
+// EvictIdle evicts the limiters which have refilled, and returns the number of limiters evicted. It is called every
+// SweepInterval by the requests.
+func (m *Middleware[K]) EvictIdle() int {
+	now := time.Now()
+	evicted := 0
+	m.limiters.Range(
+		func(key middlewareKey[K], limiter *rate.Limiter) bool {
+			if limiter.Limit() == rate.Inf || limiter.TokensAt(now) >= float64(limiter.Burst()) {
+				if m.limiters.CompareAndDelete(key, limiter) {
+					evicted++
+				}
+			}
+			return true
+		},
+	)
+	return evicted
+}
+
+// Handler returns an http.Handler which passes the requests permitted by Allow to next, and rejects the others.
+func (m *Middleware[K]) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := m.Allow(r)
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if retryAfter >= 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+			if m.OnRejected != nil {
+				m.OnRejected(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		},
+	)
+}
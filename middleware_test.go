@@ -0,0 +1,90 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := NewMiddleware(
+		0.5, 2, func(r *http.Request) string {
+			return r.Header.Get("X-Client")
+		},
+	)
+	m.LimiterConfigFunc = func(key string) (LimiterConfig, bool) {
+		return LimiterConfig{Limit: 0.5, Burst: 3}, key == "big"
+	}
+	handler := m.Handler(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	serve := func(client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Client", client)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	for client, allowed := range map[string]int{"small": 2, "big": 3} {
+		for i := 0; i < allowed; i++ {
+			if w := serve(client); w.Code != http.StatusNoContent {
+				t.Fatalf("request %d of %s: status = %d, want %d", i, client, w.Code, http.StatusNoContent)
+			}
+		}
+		w := serve(client)
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+			t.Errorf(
+				"request over burst of %s: status = %d, Retry-After = %q, want %d, %q",
+				client, w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests, "2",
+			)
+		}
+	}
+}
+
+func TestMiddleware_EvictIdle(t *testing.T) {
+	m := NewMiddleware(
+		1000, 1, func(r *http.Request) string {
+			return r.Header.Get("X-Client")
+		},
+	)
+	m.LimiterConfigFunc = func(key string) (LimiterConfig, bool) {
+		return LimiterConfig{Limit: 0.5, Burst: 1}, key == "slow"
+	}
+	m.SweepInterval = 10 * time.Millisecond
+	allow := func(client string) bool {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Client", client)
+		ok, _ := m.Allow(r)
+		return ok
+	}
+	allow("slow")
+	for i := 0; i < 100; i++ {
+		allow(strconv.Itoa(i))
+	}
+	time.Sleep(5 * time.Millisecond)
+	// the limiter of slow has not refilled, and is kept
+	if evicted := m.EvictIdle(); evicted != 100 {
+		t.Errorf("EvictIdle() = %d, want 100", evicted)
+	}
+	if allow("slow") {
+		t.Error("request of slow allowed after eviction, want its limit kept")
+	}
+	for i := 0; i < 100; i++ {
+		allow(strconv.Itoa(i))
+	}
+	// requests evict the limiters every SweepInterval
+	time.Sleep(20 * time.Millisecond)
+	allow("slow")
+	for deadline := time.Now().Add(time.Second); m.limiters.Len() > 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := m.limiters.Len(); n != 1 {
+		t.Errorf("%d limiters kept, want 1", n)
+	}
+}
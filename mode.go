@@ -0,0 +1,50 @@
+package ratelim
+
+import (
+	"fmt"
+)
+
+// A Mode determines how a PerKeyRoundTripper treats all requests, whatever their key, so that limiting can be switched
+// off or all traffic stopped at once, e.g. from an admin endpoint during an incident.
+type Mode int32
+
+const (
+	// ModeLimit limits requests as configured; it is the default.
+	ModeLimit Mode = iota
+	// ModeUnlimited sends all requests at once, bypassing every limiter.
+	ModeUnlimited
+	// ModeReject fails all requests with ErrRejected without sending them.
+	ModeReject
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeLimit:
+		return "limit"
+	case ModeUnlimited:
+		return "unlimited"
+	case ModeReject:
+		return "reject"
+	}
+	return fmt.Sprintf("Mode(%d)", int32(m))
+}
+
+// Mode returns the transport's current Mode.
+func (t *PerKeyRoundTripper[K]) Mode() Mode {
+	return Mode(t.mode.Load())
+}
+
+// SetMode sets the transport's Mode, which applies to requests which have not yet begun waiting for their limiter.
+func (t *PerKeyRoundTripper[K]) SetMode(mode Mode) {
+	t.mode.Store(int32(mode))
+}
+
+// EnableAll sends all requests without limiting them, until the Mode is set again; it is SetMode(ModeUnlimited).
+func (t *PerKeyRoundTripper[K]) EnableAll() {
+	t.SetMode(ModeUnlimited)
+}
+
+// DisableAll rejects all requests with ErrRejected, until the Mode is set again; it is SetMode(ModeReject).
+func (t *PerKeyRoundTripper[K]) DisableAll() {
+	t.SetMode(ModeReject)
+}
@@ -0,0 +1,48 @@
+package ratelim
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_Mode(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	rt := PerOriginRoundTripper(1, 1, nil)
+	client := &http.Client{Transport: rt}
+	get := func() error {
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	rt.EnableAll()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("5 requests took %v in %v mode", elapsed, rt.Mode())
+	}
+	rt.DisableAll()
+	if err := get(); !errors.Is(err, ErrRejected) {
+		t.Errorf("Get() error = %v in %v mode, want %v", err, rt.Mode(), ErrRejected)
+	}
+	rt.SetMode(ModeLimit)
+	if err := get(); err != nil {
+		t.Errorf("Get() error = %v in %v mode", err, rt.Mode())
+	}
+}
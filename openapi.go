@@ -0,0 +1,170 @@
+package ratelim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// OpenAPIRateLimit is the value of an x-ratelimit extension in an OpenAPI document: at most Limit requests per Window,
+// in bursts of at most Burst.
+type OpenAPIRateLimit struct {
+	Limit float64 `json:"limit"`
+	// Window is a duration as parsed by time.ParseDuration; if empty, one second is used.
+	Window string `json:"window,omitempty"`
+	// Burst, if positive, is the burst size; otherwise it is Limit, rounded up.
+	Burst int `json:"burst,omitempty"`
+}
+
+// LimiterConfig returns the LimiterConfig of the extension.
+func (l OpenAPIRateLimit) LimiterConfig() (LimiterConfig, error) {
+	if l.Limit <= 0 {
+		return LimiterConfig{}, fmt.Errorf("ratelim: x-ratelimit limit must be positive, got %v", l.Limit)
+	}
+	window := time.Second
+	if l.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(l.Window); err != nil {
+			return LimiterConfig{}, fmt.Errorf("ratelim: x-ratelimit window: %w", err)
+		}
+		if window <= 0 {
+			return LimiterConfig{}, fmt.Errorf("ratelim: x-ratelimit window must be positive, got %v", window)
+		}
+	}
+	burst := l.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(l.Limit))
+	}
+	return LimiterConfig{Limit: rate.Limit(l.Limit / window.Seconds()), Burst: burst}, nil
+}
+
+type openAPIOperation struct {
+	RateLimit *OpenAPIRateLimit `json:"x-ratelimit"`
+}
+
+type openAPIPathItem struct {
+	RateLimit  *OpenAPIRateLimit            `json:"x-ratelimit"`
+	Operations map[string]*openAPIOperation `json:"-"`
+}
+
+func (p *openAPIPathItem) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields["x-ratelimit"]; ok {
+		if err := json.Unmarshal(raw, &p.RateLimit); err != nil {
+			return err
+		}
+	}
+	for _, method := range []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"} {
+		raw, ok := fields[method]
+		if !ok {
+			continue
+		}
+		var op openAPIOperation
+		if err := json.Unmarshal(raw, &op); err != nil {
+			return err
+		}
+		if p.Operations == nil {
+			p.Operations = make(map[string]*openAPIOperation)
+		}
+		p.Operations[method] = &op
+	}
+	return nil
+}
+
+type openAPIDocument struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	RateLimit *OpenAPIRateLimit          `json:"x-ratelimit"`
+	Paths     map[string]openAPIPathItem `json:"paths"`
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{[^/{}]*\}`)
+
+// LoadOpenAPI reads an OpenAPI 3 document in JSON and returns a LimitTable generated from its x-ratelimit extensions,
+// to be used with keys made by TargetPath with StripQuery set. An extension may be set on the document, applying to
+// all its paths; on a path item; or on an operation, in which case the path takes the lowest limit of its operations,
+// since keys do not distinguish methods. Path parameters become wildcards, and each path is prefixed with the origin
+// and path of each of the document's servers, or a wildcard if it has none. The table is sorted from most to least
+// specific.
+func LoadOpenAPI(r io.Reader) (LimitTable, error) {
+	var doc openAPIDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("ratelim: decoding OpenAPI document: %w", err)
+	}
+	prefixes, err := doc.prefixes()
+	if err != nil {
+		return nil, err
+	}
+	var table LimitTable
+	add := func(path string, l *OpenAPIRateLimit) error {
+		cfg, err := l.LimiterConfig()
+		if err != nil {
+			return fmt.Errorf("%w (path %q)", err, path)
+		}
+		for _, prefix := range prefixes {
+			table = append(table, LimitRule{Pattern: prefix + path, Config: cfg})
+		}
+		return nil
+	}
+	for path, item := range doc.Paths {
+		l := item.RateLimit
+		for _, op := range item.Operations {
+			if op.RateLimit != nil && (l == nil || op.RateLimit.perSecond() < l.perSecond()) {
+				l = op.RateLimit
+			}
+		}
+		if l == nil {
+			continue
+		}
+		if err := add(openAPIPathParam.ReplaceAllString(path, "*"), l); err != nil {
+			return nil, err
+		}
+	}
+	if doc.RateLimit != nil {
+		if err := add("*", doc.RateLimit); err != nil {
+			return nil, err
+		}
+	}
+	table.Sort()
+	return table, nil
+}
+
+// perSecond returns the rate of the extension, or 0 if it is invalid.
+func (l OpenAPIRateLimit) perSecond() float64 {
+	cfg, err := l.LimiterConfig()
+	if err != nil {
+		return 0
+	}
+	return float64(cfg.Limit)
+}
+
+// prefixes returns the patterns prefixed to each path: the normalized origin and base path of each server.
+func (doc *openAPIDocument) prefixes() ([]string, error) {
+	if len(doc.Servers) == 0 {
+		return []string{"*"}, nil
+	}
+	prefixes := make([]string, 0, len(doc.Servers))
+	for _, server := range doc.Servers {
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			return nil, fmt.Errorf("ratelim: OpenAPI server URL: %w", err)
+		}
+		prefix := "*"
+		if u.Host != "" {
+			prefix = OriginWith(u, OriginOptions{})
+		}
+		prefixes = append(prefixes, prefix+strings.TrimRight(u.EscapedPath(), "/"))
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,73 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+const testOpenAPIDocument = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "https://API.example.com/v1/"}],
+  "x-ratelimit": {"limit": 100, "window": "1m"},
+  "paths": {
+    "/users": {
+      "get": {"x-ratelimit": {"limit": 10}},
+      "post": {"x-ratelimit": {"limit": 2, "burst": 1}}
+    },
+    "/users/{id}": {
+      "x-ratelimit": {"limit": 20}
+    },
+    "/users/{id}/avatar": {
+      "x-ratelimit": {"limit": 1, "window": "10s", "burst": 3}
+    },
+    "/health": {
+      "get": {}
+    }
+  }
+}`
+
+func TestLoadOpenAPI(t *testing.T) {
+	table, err := LoadOpenAPI(strings.NewReader(testOpenAPIDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TargetPath(PathOptions{StripQuery: true})
+	tests := []struct {
+		url  string
+		want LimiterConfig
+	}{
+		{"https://api.example.com/v1/users?page=2", LimiterConfig{Limit: 2, Burst: 1}},
+		{"https://api.example.com/v1/users/42", LimiterConfig{Limit: 20, Burst: 20}},
+		{"https://api.example.com/v1/users/42/avatar", LimiterConfig{Limit: 0.1, Burst: 3}},
+		{"https://api.example.com/v1/health", LimiterConfig{Limit: rate.Limit(100.0 / 60), Burst: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.url, func(t *testing.T) {
+				got, ok := table.LimiterConfig(key(httptest.NewRequest(http.MethodGet, tt.url, nil)))
+				if !ok || got != tt.want {
+					t.Errorf("LimiterConfig() = %+v, %t, want %+v", got, ok, tt.want)
+				}
+			},
+		)
+	}
+	if _, ok := table.LimiterConfig("https://other.example.com/v1/users"); ok {
+		t.Error("LimiterConfig() matched a key of another server")
+	}
+}
+
+func TestLoadOpenAPI_invalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"paths": {"/a": {"x-ratelimit": {"limit": 0}}}}`,
+		`{"paths": {"/a": {"x-ratelimit": {"limit": 1, "window": "soon"}}}}`,
+		`{"paths": [`,
+	} {
+		if _, err := LoadOpenAPI(strings.NewReader(doc)); err == nil {
+			t.Errorf("LoadOpenAPI(%s) succeeded", doc)
+		}
+	}
+}
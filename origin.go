@@ -0,0 +1,165 @@
+package ratelim
+
+import (
+	"maps"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A schemeTable holds the default ports and aliases of URL schemes. It is replaced as a whole when a scheme is
+// registered, so that it is read without locking.
+type schemeTable struct {
+	ports   map[string]string
+	aliases map[string]string
+}
+
+var (
+	// schemesMux serializes the registration of schemes
+	schemesMux sync.Mutex
+	schemes    atomic.Pointer[schemeTable]
+)
+
+func init() {
+	schemes.Store(
+		&schemeTable{
+			ports:   map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"},
+			aliases: map[string]string{"ws": "http", "wss": "https"},
+		},
+	)
+}
+
+// registerScheme replaces the scheme table with a copy updated by update.
+func registerScheme(update func(*schemeTable)) {
+	schemesMux.Lock()
+	defer schemesMux.Unlock()
+	old := schemes.Load()
+	table := &schemeTable{ports: maps.Clone(old.ports), aliases: maps.Clone(old.aliases)}
+	update(table)
+	schemes.Store(table)
+}
+
+// DefaultPort returns the default port of a URL scheme, as registered with RegisterDefaultPort. Origin omits the port
+// of a URL when it is the default for its scheme, so that URLs with and without an explicit default port share a key.
+func DefaultPort(scheme string) (port string, ok bool) {
+	port, ok = schemes.Load().ports[strings.ToLower(scheme)]
+	return port, ok
+}
+
+// RegisterDefaultPort registers the default port of a URL scheme, replacing any registered before. The http, https,
+// ws and wss schemes are registered by default.
+func RegisterDefaultPort(scheme, port string) {
+	registerScheme(
+		func(table *schemeTable) {
+			table.ports[strings.ToLower(scheme)] = strings.TrimLeft(port, "0")
+		},
+	)
+}
+
+// SchemeAlias returns the scheme which Origin writes in place of scheme, as registered with RegisterSchemeAlias, if
+// any.
+func SchemeAlias(scheme string) (alias string, ok bool) {
+	alias, ok = schemes.Load().aliases[strings.ToLower(scheme)]
+	return alias, ok
+}
+
+// RegisterSchemeAlias makes Origin write alias in place of scheme, replacing any alias registered before, so that the
+// URLs of both schemes share a key and thus the quota of their origin. By default, ws is an alias of http and wss of
+// https, so that e.g. wss://example.com/socket and https://example.com/api share a key. Registering a scheme as its
+// own alias removes its alias.
+func RegisterSchemeAlias(scheme, alias string) {
+	registerScheme(
+		func(table *schemeTable) {
+			scheme, alias = strings.ToLower(scheme), strings.ToLower(alias)
+			if alias == scheme {
+				delete(table.aliases, scheme)
+			} else {
+				table.aliases[scheme] = alias
+			}
+		},
+	)
+}
+
+// OriginOptions control how aggressively OriginWith collapses URLs into a single key. The zero OriginOptions
+// normalize as Origin does.
+type OriginOptions struct {
+	// IgnorePort omits the port, so that all ports of a host share a key.
+	IgnorePort bool
+	// IgnoreScheme omits the scheme, so that e.g. http, https and wss URLs of a host share a key. The port is still
+	// omitted when it is the default for the URL's scheme, so https://example.com and http://example.com:443 share a
+	// key, but http://example.com:8080 does not.
+	IgnoreScheme bool
+	// StripTrailingDot removes the trailing dot of a fully qualified host name, so that example.com. and example.com
+	// share a key.
+	StripTrailingDot bool
+	// KeepScheme keeps the scheme of the URL rather than writing its alias, as registered with RegisterSchemeAlias,
+	// so that e.g. ws and http URLs of a host get separate keys.
+	KeepScheme bool
+}
+
+// OriginWith returns the origin of url normalized as opts direct. Without IgnoreScheme, the result is of the form
+// scheme://host[:port], like Origin, where scheme is the alias of the URL's scheme unless KeepScheme is set; with it,
+// host[:port].
+func OriginWith(url *url.URL, opts OriginOptions) string {
+	// apply normalization steps which are missing/incomplete in url.URL
+	// (ref: https://www.rfc-editor.org/rfc/rfc9110#name-uri-origin)
+	// first, while scheme is automatically lower-cased, host(name) is not
+	host := strings.ToLower(url.Hostname())
+	// IPv6 literals are written in their canonical form (RFC 5952) and re-bracketed, so that textual variants of an
+	// address match, and internationalized host names are converted to their ASCII form, so that Unicode and punycode
+	// spellings match
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+		host = "[" + addr.String() + "]"
+	} else {
+		host = toASCIIHost(host)
+	}
+	if opts.StripTrailingDot {
+		host = strings.TrimSuffix(host, ".")
+	}
+	// next, strip any leading zeros from port number, and omit it if it's the scheme's default
+	port := strings.TrimLeft(url.Port(), "0")
+	if defaultPort, ok := DefaultPort(url.Scheme); ok && port == defaultPort {
+		port = ""
+	}
+	if port != "" && !opts.IgnorePort {
+		host = host + ":" + port
+	}
+	if opts.IgnoreScheme {
+		return host
+	}
+	scheme := url.Scheme
+	if alias, ok := SchemeAlias(scheme); ok && !opts.KeepScheme {
+		scheme = alias
+	}
+	return scheme + "://" + host
+}
+
+// TargetOriginWith returns a key function for PerKeyRoundTripper which keys requests by the origin of their URL,
+// normalized as opts direct.
+func TargetOriginWith(opts OriginOptions) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return OriginWith(r.URL, opts)
+	}
+}
+
+// HostOrigin is a key function for PerKeyRoundTripper which keys requests by the origin of the virtual host they
+// target, taken from the request's Host field rather than its URL, for proxy-style usage where the URL's host is empty
+// or names the next hop rather than the server. If the URL has no scheme, https is assumed for requests received over
+// TLS and http otherwise. Requests with no Host fall back to the URL's host.
+func HostOrigin(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return Origin(&url.URL{Scheme: scheme, Host: host})
+}
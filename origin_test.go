@@ -0,0 +1,157 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrigin(t *testing.T) {
+	RegisterDefaultPort("gopher", "70")
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://Example.COM/path?q=1", "https://example.com"},
+		{"http://example.com:80/", "http://example.com"},
+		{"http://example.com:0080/", "http://example.com"},
+		{"http://example.com:443/", "http://example.com:443"},
+		{"ws://example.com:80/socket", "http://example.com"},
+		{"wss://example.com:443/socket", "https://example.com"},
+		{"wss://example.com:8443/socket", "https://example.com:8443"},
+		{"gopher://example.com:70/", "gopher://example.com"},
+		{"https://Bücher.example/", "https://xn--bcher-kva.example"},
+		{"https://b%C3%BCcher.example/", "https://xn--bcher-kva.example"},
+		{"http://[::1]/", "http://[::1]"},
+		{"http://[::1]:8080/", "http://[::1]:8080"},
+		{"http://[0:0:0:0:0:0:0:1]:80/", "http://[::1]"},
+		{"https://[2001:DB8:0:0:0:0:0:1]:443/", "https://[2001:db8::1]"},
+		{"https://[2001:0db8::0001]:8443/", "https://[2001:db8::1]:8443"},
+		{"http://[::ffff:192.0.2.1]/", "http://[::ffff:192.0.2.1]"},
+		{"http://192.0.2.1:8080/", "http://192.0.2.1:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.url, func(t *testing.T) {
+				if got := Origin(mustParseURL(t, tt.url)); got != tt.want {
+					t.Errorf("Origin() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestOrigin_webSockets(t *testing.T) {
+	tests := []struct{ socket, api string }{
+		{"ws://example.com/socket", "http://example.com/api"},
+		{"wss://example.com/socket", "https://example.com/api"},
+		{"wss://example.com:443/socket", "https://example.com/api"},
+		{"wss://example.com:8443/socket", "https://example.com:8443/api"},
+	}
+	for _, tt := range tests {
+		socket, api := Origin(mustParseURL(t, tt.socket)), Origin(mustParseURL(t, tt.api))
+		if socket != api {
+			t.Errorf("Origin(%s) = %q, want it to collapse with Origin(%s) = %q", tt.socket, socket, tt.api, api)
+		}
+	}
+}
+
+func TestRegisterSchemeAlias(t *testing.T) {
+	RegisterDefaultPort("h3test", "443")
+	RegisterSchemeAlias("H3Test", "https")
+	if got := Origin(mustParseURL(t, "h3test://example.com:443/")); got != "https://example.com" {
+		t.Errorf("Origin() = %q with an alias registered, want %q", got, "https://example.com")
+	}
+	RegisterSchemeAlias("h3test", "h3test")
+	if got := Origin(mustParseURL(t, "h3test://example.com/")); got != "h3test://example.com" {
+		t.Errorf("Origin() = %q with the alias removed, want %q", got, "h3test://example.com")
+	}
+	if _, ok := SchemeAlias("h3test"); ok {
+		t.Error("SchemeAlias() reports the removed alias")
+	}
+}
+
+func TestOriginWith(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts OriginOptions
+		want string
+	}{
+		{"zero options", "https://Example.com.:8443/", OriginOptions{}, "https://example.com.:8443"},
+		{"ignore port", "https://example.com:8443/", OriginOptions{IgnorePort: true}, "https://example.com"},
+		{"ignore scheme", "http://example.com:443/", OriginOptions{IgnoreScheme: true}, "example.com:443"},
+		{"ignore scheme default port", "wss://example.com:443/", OriginOptions{IgnoreScheme: true}, "example.com"},
+		{
+			"ignore scheme and port", "http://example.com:8080/", OriginOptions{IgnoreScheme: true, IgnorePort: true},
+			"example.com",
+		},
+		{"strip trailing dot", "https://example.com./", OriginOptions{StripTrailingDot: true}, "https://example.com"},
+		{"keep scheme", "wss://example.com:443/", OriginOptions{KeepScheme: true}, "wss://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := OriginWith(mustParseURL(t, tt.url), tt.opts); got != tt.want {
+					t.Errorf("OriginWith() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestOriginWith_IPv6(t *testing.T) {
+	u := mustParseURL(t, "https://[2001:DB8::0:1]:8443/")
+	if got, want := OriginWith(u, OriginOptions{IgnoreScheme: true}), "[2001:db8::1]:8443"; got != want {
+		t.Errorf("OriginWith(IgnoreScheme) = %q, want %q", got, want)
+	}
+	if got, want := OriginWith(u, OriginOptions{IgnorePort: true}), "https://[2001:db8::1]"; got != want {
+		t.Errorf("OriginWith(IgnorePort) = %q, want %q", got, want)
+	}
+}
+
+func TestHostOrigin(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		want string
+	}{
+		{
+			"host header", func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "http://10.0.0.1:8080/path", nil)
+				r.Host = "API.example.com:80"
+				return r
+			}, "http://api.example.com",
+		},
+		{
+			"inbound", func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/path", nil)
+				r.Host = "example.com"
+				return r
+			}, "http://example.com",
+		},
+		{
+			"inbound tls", func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://example.com:443/path", nil)
+				r.URL.Scheme, r.URL.Host = "", ""
+				return r
+			}, "https://example.com",
+		},
+		{
+			"no host header", func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://example.com:8443/path", nil)
+				r.Host = ""
+				return r
+			}, "https://example.com:8443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := HostOrigin(tt.req()); got != tt.want {
+					t.Errorf("HostOrigin() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,37 @@
+package ratelim
+
+import (
+	"context"
+	"time"
+)
+
+// An OverrideProvider supplies per-key limits from an external system, such as a feature flag service, without this
+// package depending on it. GetLimit is called each time the config of a key is looked up, on every request, so it
+// must be fast, e.g. by reading the local cache which flag SDKs keep.
+type OverrideProvider[K comparable] interface {
+	// GetLimit returns the config overriding the limits of key, if any.
+	GetLimit(key K) (cfg LimiterConfig, ok bool)
+}
+
+// ReconfigureKey updates the limit and burst of the rate.Limiter of key, if it exists, to match its current
+// LimiterConfig. Providers which stream changes can call it for each key whose override changed.
+func (l *PerKeyLimiter[K]) ReconfigureKey(key K) {
+	if limiter, ok := l.limiters.Load(key); ok {
+		l.LimiterConfig(key).apply(limiter)
+	}
+}
+
+// PollOverrides reconfigures every existing rate.Limiter every interval, so that changes to Overrides which are not
+// streamed with ReconfigureKey apply to keys already seen, until ctx is done.
+func (l *PerKeyLimiter[K]) PollOverrides(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			l.Reconfigure()
+		}
+	}
+}
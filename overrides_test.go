@@ -0,0 +1,65 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// flagOverrides is an OverrideProvider standing in for a feature flag system.
+type flagOverrides struct {
+	mux    sync.Mutex
+	limits map[string]rate.Limit
+}
+
+func (f *flagOverrides) set(key string, limit rate.Limit) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.limits[key] = limit
+}
+
+func (f *flagOverrides) GetLimit(key string) (LimiterConfig, bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	limit, ok := f.limits[key]
+	return LimiterConfig{Limit: limit, Burst: 1}, ok
+}
+
+func TestPerKeyLimiter_Overrides(t *testing.T) {
+	flags := &flagOverrides{limits: map[string]rate.Limit{"flagged": 2}}
+	l := NewPerKeyLimiter[string](10, 5)
+	l.Overrides = flags
+	l.LimiterConfigFunc = func(key string) (LimiterConfig, bool) {
+		return LimiterConfig{Limit: 3, Burst: 3}, key == "flagged" || key == "func"
+	}
+
+	if got := l.LimiterConfig("flagged").Limit; got != 2 {
+		t.Errorf("LimiterConfig(flagged).Limit = %v, want the override 2", got)
+	}
+	if got := l.LimiterConfig("func").Limit; got != 3 {
+		t.Errorf("LimiterConfig(func).Limit = %v, want 3", got)
+	}
+	l.SetLimiterConfig("flagged", LimiterConfig{Limit: 4, Burst: 1})
+	if got := l.LimiterConfig("flagged").Limit; got != 4 {
+		t.Errorf("LimiterConfig(flagged).Limit = %v, want the config set 4", got)
+	}
+	l.DeleteLimiterConfig("flagged")
+
+	// a streamed change applies at once to the key's limiter; a polled one on the next poll
+	limiter := l.Limiter("flagged")
+	flags.set("flagged", 6)
+	l.ReconfigureKey("flagged")
+	if got := limiter.Limit(); got != 6 {
+		t.Errorf("limit after ReconfigureKey() = %v, want 6", got)
+	}
+	flags.set("flagged", 8)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_ = l.PollOverrides(ctx, 10*time.Millisecond)
+	if got := limiter.Limit(); got != 8 {
+		t.Errorf("limit after PollOverrides() = %v, want 8", got)
+	}
+}
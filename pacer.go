@@ -0,0 +1,30 @@
+package ratelim
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// A Pacer paces a producer loop at the rate permitted by the rate.Limiter of a key, so that work is generated at the
+// allowed pace rather than queued up to block in RoundTrip or Wait.
+type Pacer struct {
+	limiter *rate.Limiter
+}
+
+// Pacer returns a Pacer for the rate.Limiter of key. Pacers share the key's limiter with Wait, Allow and RoundTrip.
+func (l *PerKeyLimiter[K]) Pacer(key K) *Pacer {
+	return &Pacer{limiter: l.limiter(key)}
+}
+
+// Next blocks until the key's limiter permits another unit of work, or ctx is done. The returned context is derived
+// from ctx and carries the token taken, as WithReservation does, so that a request sent with it through a
+// PerKeyRoundTripper sharing the limiter is not delayed again. Work paced by Next is not counted in Stats until it is
+// sent through a PerKeyRoundTripper.
+func (p *Pacer) Next(ctx context.Context) (context.Context, error) {
+	r := p.limiter.Reserve()
+	if err := waitReservation(ctx, r); err != nil {
+		return ctx, err
+	}
+	return WithReservation(ctx, r), nil
+}
@@ -0,0 +1,46 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPacer_Next(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	rt := PerOriginRoundTripper(20, 1, nil)
+	p := rt.Pacer(Origin(mustParseURL(t, server.URL)))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		ctx, err := p.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	// requests are paced at 20/s by Next, and not delayed again by RoundTrip
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 140*time.Millisecond {
+		t.Errorf("3 paced requests took %v, want about 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Next(ctx); err == nil {
+		t.Error("Next() succeeded with a canceled context and an empty bucket")
+	}
+}
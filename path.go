@@ -0,0 +1,101 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PathOptions control how NormalizePath collapses URL paths, so that keying by route does not create a key for every
+// resource. The zero PathOptions keep the path, query and fragment as they are.
+type PathOptions struct {
+	// Origin controls how the origin prefixed to the path by TargetPath is normalized.
+	Origin OriginOptions
+	// StripQuery removes the query string.
+	StripQuery bool
+	// StripFragment removes the fragment.
+	StripFragment bool
+	// StripTrailingSlash removes a trailing slash, so that /users/ and /users share a key. The root path is kept.
+	StripTrailingSlash bool
+	// ReplaceIDs replaces each path segment which is a decimal number or a UUID with IDPlaceholder, so that
+	// e.g. /users/123 and /users/456 share the key /users/{id}.
+	ReplaceIDs bool
+	// IDPlaceholder replaces IDs when ReplaceIDs is set; if empty, "{id}" is used.
+	IDPlaceholder string
+}
+
+// NormalizePath returns the path of url, with its query and fragment, normalized as opts direct. The path is
+// returned in its escaped form, and is "/" if empty.
+func NormalizePath(url *url.URL, opts PathOptions) string {
+	path := url.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if opts.ReplaceIDs {
+		placeholder := opts.IDPlaceholder
+		if placeholder == "" {
+			placeholder = "{id}"
+		}
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if isNumericID(segment) || isUUID(segment) {
+				segments[i] = placeholder
+			}
+		}
+		path = strings.Join(segments, "/")
+	}
+	if opts.StripTrailingSlash && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+	if !opts.StripQuery && url.RawQuery != "" {
+		path += "?" + url.RawQuery
+	}
+	if !opts.StripFragment && url.Fragment != "" {
+		path += "#" + url.EscapedFragment()
+	}
+	return path
+}
+
+func isNumericID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for i := 0; i < len(segment); i++ {
+		if segment[i] < '0' || segment[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether segment is a UUID in its canonical 8-4-4-4-12 hexadecimal form.
+func isUUID(segment string) bool {
+	if len(segment) != 36 {
+		return false
+	}
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TargetPath returns a key function for PerKeyRoundTripper which keys requests by the origin and path of their URL,
+// normalized as opts direct, to apply limits per route rather than per origin.
+func TargetPath(opts PathOptions) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return OriginWith(r.URL, opts.Origin) + NormalizePath(r.URL, opts)
+	}
+}
@@ -0,0 +1,49 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	all := PathOptions{StripQuery: true, StripFragment: true, StripTrailingSlash: true, ReplaceIDs: true}
+	tests := []struct {
+		name string
+		url  string
+		opts PathOptions
+		want string
+	}{
+		{"zero options", "https://example.com/users/123/?page=2#top", PathOptions{}, "/users/123/?page=2#top"},
+		{"empty path", "https://example.com", PathOptions{}, "/"},
+		{"strip query", "https://example.com/users?page=2", PathOptions{StripQuery: true}, "/users"},
+		{"strip fragment", "https://example.com/users#top", PathOptions{StripFragment: true}, "/users"},
+		{"strip trailing slash", "https://example.com/users//", PathOptions{StripTrailingSlash: true}, "/users"},
+		{"keep root", "https://example.com/", PathOptions{StripTrailingSlash: true}, "/"},
+		{"replace ids", "https://example.com/users/123/posts/0042", PathOptions{ReplaceIDs: true}, "/users/{id}/posts/{id}"},
+		{
+			"replace uuid", "https://example.com/orders/3F2504E0-4F89-11D3-9A0C-0305E82C3301/items",
+			PathOptions{ReplaceIDs: true, IDPlaceholder: ":id"}, "/orders/:id/items",
+		},
+		{"keep non-ids", "https://example.com/v2/users/me", all, "/v2/users/me"},
+		{"all", "https://example.com/users/123/?page=2#top", all, "/users/{id}"},
+		{"escaped", "https://example.com/files/a%2Fb/7", all, "/files/a%2Fb/{id}"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := NormalizePath(mustParseURL(t, tt.url), tt.opts); got != tt.want {
+					t.Errorf("NormalizePath() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestTargetPath(t *testing.T) {
+	key := TargetPath(PathOptions{StripQuery: true, ReplaceIDs: true})
+	r := httptest.NewRequest(http.MethodGet, "https://API.example.com:443/users/123?fields=name", nil)
+	if got, want := key(r), "https://api.example.com/users/{id}"; got != want {
+		t.Errorf("TargetPath() key = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A PausedError is returned for requests rejected because all requests were paused by RejectAll.
+type PausedError struct {
+	// Until is when the pause ends, or the zero time.Time if it lasts until ResumeAll is called.
+	Until time.Time
+}
+
+func (e *PausedError) Error() string {
+	if e.Until.IsZero() {
+		return "ratelim: all requests are paused"
+	}
+	return fmt.Sprintf("ratelim: all requests are paused until %s", e.Until.Format(time.RFC3339))
+}
+
+type pause struct {
+	until   time.Time // zero if indefinite
+	reject  bool
+	resumed chan struct{} // closed when the pause is ended or replaced
+}
+
+func (p *pause) active(now time.Time) bool {
+	return p != nil && (p.until.IsZero() || now.Before(p.until))
+}
+
+// setPause replaces the current pause with p, waking the requests waiting for the current one.
+func (t *PerKeyRoundTripper[K]) setPause(p *pause) {
+	if old := t.pause.Swap(p); old != nil {
+		close(old.resumed)
+	}
+}
+
+func (t *PerKeyRoundTripper[K]) newPause(d time.Duration, reject bool) *pause {
+	p := &pause{reject: reject, resumed: make(chan struct{})}
+	if d > 0 {
+		p.until = time.Now().Add(d)
+	}
+	return p
+}
+
+// PauseAll blocks all requests, whatever their key, for d, or until ResumeAll is called if d is not positive, e.g.
+// during an upstream maintenance window announced out of band. Requests already permitted are not affected.
+func (t *PerKeyRoundTripper[K]) PauseAll(d time.Duration) {
+	t.setPause(t.newPause(d, false))
+}
+
+// RejectAll pauses all requests as PauseAll does, but fails them with a *PausedError instead of blocking them.
+func (t *PerKeyRoundTripper[K]) RejectAll(d time.Duration) {
+	t.setPause(t.newPause(d, true))
+}
+
+// ResumeAll ends any pause begun by PauseAll or RejectAll, releasing the requests blocked by it.
+func (t *PerKeyRoundTripper[K]) ResumeAll() {
+	t.setPause(nil)
+}
+
+// Paused reports whether requests are paused, and until when; until is the zero time.Time if the pause lasts until
+// ResumeAll is called.
+func (t *PerKeyRoundTripper[K]) Paused() (until time.Time, ok bool) {
+	if p := t.pause.Load(); p.active(time.Now()) {
+		return p.until, true
+	}
+	return time.Time{}, false
+}
+
+// waitPause blocks until any pause of all requests has ended, or ctx is done, or fails if the pause rejects requests.
+func (t *PerKeyRoundTripper[K]) waitPause(ctx context.Context) error {
+	for {
+		p := t.pause.Load()
+		if !p.active(time.Now()) {
+			return nil
+		}
+		if p.reject {
+			return &PausedError{Until: p.until}
+		}
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !p.until.IsZero() {
+			timer = time.NewTimer(time.Until(p.until))
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.resumed:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_PauseAll(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 10, nil)
+	wait := func() error {
+		return rt.wait(context.Background(), "k", rt.LimiterConfig("k"), rt.limiter("k"))
+	}
+
+	rt.PauseAll(50 * time.Millisecond)
+	if _, ok := rt.Paused(); !ok {
+		t.Error("Paused() = false after PauseAll")
+	}
+	start := time.Now()
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("wait() returned after %v, want ~50ms", elapsed)
+	}
+
+	rt.PauseAll(0)
+	time.AfterFunc(20*time.Millisecond, rt.ResumeAll)
+	start = time.Now()
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("wait() returned %v after indefinite pause, want ~20ms", elapsed)
+	}
+
+	rt.RejectAll(time.Minute)
+	var paused *PausedError
+	if err := wait(); !errors.As(err, &paused) || paused.Until.IsZero() {
+		t.Errorf("wait() error = %v, want *PausedError", err)
+	}
+	rt.ResumeAll()
+	if _, ok := rt.Paused(); ok {
+		t.Error("Paused() = true after ResumeAll")
+	}
+	if err := wait(); err != nil {
+		t.Errorf("wait() error = %v after ResumeAll", err)
+	}
+}
@@ -0,0 +1,145 @@
+package ratelim
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A WindowLimiter enforces several quota policies at once, such as 10 requests per second and 1000 per hour, with a
+// rate.Limiter per policy whose burst is the policy's quota and which refills at its average rate. An operation is
+// permitted only once every policy permits it.
+type WindowLimiter struct {
+	policies []QuotaPolicy
+	limiters []*rate.Limiter
+}
+
+// NewWindowLimiter returns a new WindowLimiter enforcing the given policies, whose buckets start full.
+func NewWindowLimiter(policies ...QuotaPolicy) *WindowLimiter {
+	l := &WindowLimiter{policies: slices.Clone(policies)}
+	for _, p := range policies {
+		l.limiters = append(l.limiters, rate.NewLimiter(p.Limit(), p.Quota))
+	}
+	return l
+}
+
+// Policies returns the policies enforced by the limiter.
+func (l *WindowLimiter) Policies() []QuotaPolicy {
+	return slices.Clone(l.policies)
+}
+
+// Allow reports whether every policy permits an operation now, consuming a token from each if so.
+func (l *WindowLimiter) Allow() bool {
+	now := time.Now()
+	reservations := l.reserve(now, 1)
+	for _, r := range reservations {
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			cancelReservations(reservations, now)
+			return false
+		}
+	}
+	return true
+}
+
+// Wait blocks until every policy permits an operation, or ctx is done.
+func (l *WindowLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until every policy permits n operations, or ctx is done. It fails immediately if n exceeds the quota
+// of any policy.
+func (l *WindowLimiter) WaitN(ctx context.Context, n int) error {
+	now := time.Now()
+	reservations := l.reserve(now, n)
+	var delay time.Duration
+	for _, r := range reservations {
+		if !r.OK() {
+			cancelReservations(reservations, now)
+			return errBurstExceeded
+		}
+		delay = max(delay, r.DelayFrom(now))
+	}
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancelReservations(reservations, time.Now())
+		return ctx.Err()
+	}
+}
+
+func (l *WindowLimiter) reserve(now time.Time, n int) []*rate.Reservation {
+	reservations := make([]*rate.Reservation, len(l.limiters))
+	for i, limiter := range l.limiters {
+		reservations[i] = limiter.ReserveN(now, n)
+	}
+	return reservations
+}
+
+func cancelReservations(reservations []*rate.Reservation, now time.Time) {
+	for _, r := range reservations {
+		r.CancelAt(now)
+	}
+}
+
+// LimiterConfigForWindow returns the config enforcing p alone: a rate.Limiter whose burst is the policy's quota and
+// which refills at its average rate.
+func LimiterConfigForWindow(p QuotaPolicy) LimiterConfig {
+	return LimiterConfig{Limit: p.Limit(), Burst: p.Quota}
+}
+
+// Policies returns the quota policies of key learned from its responses, as enabled by TunePolicies, in order of
+// window, or nil if none were.
+func (t *PerKeyRoundTripper[K]) Policies(key K) []QuotaPolicy {
+	if tuned, ok := t.policies.Load(key); ok {
+		return slices.Clone(tuned.policies)
+	}
+	return nil
+}
+
+// tunePolicies replaces the limits of key with the quota policies advertised in header, unless they are those already
+// applied. The policy with the shortest window sets the Limit and Burst of the key's config, while the others are
+// enforced by a WindowLimiter.
+func (t *PerKeyRoundTripper[K]) tunePolicies(key K, header http.Header) {
+	policies := ParseRateLimitPolicy(header)
+	if len(policies) == 0 {
+		return
+	}
+	slices.SortStableFunc(
+		policies, func(a, b QuotaPolicy) int {
+			return cmp.Compare(a.Window, b.Window)
+		},
+	)
+	if tuned, ok := t.policies.Load(key); ok && slices.Equal(tuned.policies, policies) {
+		return
+	}
+	cfg := t.LimiterConfig(key)
+	shortest := LimiterConfigForWindow(policies[0])
+	cfg.Limit, cfg.Burst = shortest.Limit, shortest.Burst
+	t.SetLimiterConfig(key, cfg)
+	t.policies.Store(key, &tunedPolicies{policies: policies, windows: NewWindowLimiter(policies[1:]...)})
+}
+
+// tunedPolicies are the quota policies learned for a key, and the WindowLimiter enforcing those beyond the shortest.
+type tunedPolicies struct {
+	policies []QuotaPolicy
+	windows  *WindowLimiter
+}
+
+// waitWindows blocks until the WindowLimiter of key, if its policies were learned, permits n tokens, or ctx is done.
+func (t *PerKeyRoundTripper[K]) waitWindows(ctx context.Context, key K, n int) error {
+	tuned, ok := t.policies.Load(key)
+	if !ok || n <= 0 {
+		return nil
+	}
+	return tuned.windows.WaitN(ctx, n)
+}
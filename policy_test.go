@@ -0,0 +1,100 @@
+package ratelim
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWindowLimiter_Allow(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []QuotaPolicy
+		want     int
+	}{
+		{
+			name:     "short window binds",
+			policies: []QuotaPolicy{{2, time.Second}, {100, time.Hour}},
+			want:     2,
+		},
+		{
+			name:     "long window binds",
+			policies: []QuotaPolicy{{5, time.Second}, {3, time.Hour}},
+			want:     3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				l := NewWindowLimiter(tt.policies...)
+				got := 0
+				for range 10 {
+					if l.Allow() {
+						got++
+					}
+				}
+				if got != tt.want {
+					t.Errorf("Allow() permitted %d operations, want %d", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestWindowLimiter_WaitN(t *testing.T) {
+	l := NewWindowLimiter(QuotaPolicy{10, time.Second}, QuotaPolicy{2, time.Hour})
+	if err := l.WaitN(context.Background(), 3); err == nil {
+		t.Error("WaitN() beyond a quota succeeded")
+	}
+	if err := l.WaitN(context.Background(), 2); err != nil {
+		t.Fatalf("WaitN() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Error("Wait() for an exhausted hourly quota succeeded")
+	}
+	if tokens := l.limiters[1].Tokens(); tokens < 0 {
+		t.Errorf("hourly tokens = %v after a canceled Wait(), want the reserved token returned", tokens)
+	}
+}
+
+func TestPerKeyRoundTripper_TunePolicies(t *testing.T) {
+	rt := PerOriginRoundTripper(100, 100, headerTransport{"Ratelimit-Policy": {"1000;w=3600, 2;w=1"}})
+	rt.TunePolicies = true
+	rt.SetDefaultLimiterConfig(LimiterConfig{Limit: 100, Burst: 100, MaxWaiters: 5})
+	for range 2 {
+		if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []QuotaPolicy{{2, time.Second}, {1000, time.Hour}}
+	if got := rt.Policies("https://example.com"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Policies() = %v, want %v", got, want)
+	}
+	cfg := rt.LimiterConfig("https://example.com")
+	if cfg.Limit != 2 || cfg.Burst != 2 || cfg.MaxWaiters != 5 {
+		t.Errorf("LimiterConfig() = %+v, want the shortest window's limits", cfg)
+	}
+	if burst := rt.Limiter(mustNewRequest(t)).Burst(); burst != 2 {
+		t.Errorf("limiter burst = %d, want 2", burst)
+	}
+
+	rt.RoundTripper = headerTransport{"Ratelimit-Policy": {"1;w=3600"}}
+	if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := rt.Policies("https://example.com"); !reflect.DeepEqual(got, []QuotaPolicy{{1, time.Hour}}) {
+		t.Errorf("Policies() after a change = %v", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := mustNewRequest(t).WithContext(ctx)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Error("RoundTrip() beyond the hourly quota succeeded")
+	}
+	if got := rt.Policies("https://example.org"); got != nil {
+		t.Errorf("Policies() of an unseen key = %v, want nil", got)
+	}
+}
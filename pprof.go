@@ -0,0 +1,33 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+)
+
+// Labels of the goroutines waiting for a limiter when ProfileLabels is set.
+const (
+	profileLabelKey       = "ratelim.key"
+	profileLabelTransport = "ratelim.transport"
+)
+
+// withProfileLabels calls f, which waits for the limiter of key. If ProfileLabels is set, the goroutine carries pprof
+// labels naming key and, if set, the Name of the transport while f runs, so that goroutine profiles show which keys
+// blocked goroutines are waiting on.
+func (t *PerKeyRoundTripper[K]) withProfileLabels(ctx context.Context, key K, f func() error) error {
+	if !t.ProfileLabels {
+		return f()
+	}
+	labels := []string{profileLabelKey, fmt.Sprint(key)}
+	if name := t.name(); name != "" {
+		labels = append(labels, profileLabelTransport, name)
+	}
+	var err error
+	pprof.Do(
+		ctx, pprof.Labels(labels...), func(context.Context) {
+			err = f()
+		},
+	)
+	return err
+}
@@ -0,0 +1,56 @@
+package ratelim
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_ProfileLabels(t *testing.T) {
+	rt := NewPerKeyRoundTripper(
+		0.1, 1, func(r *http.Request) string {
+			return "blocked-key"
+		}, &countingTransport{},
+	)
+	rt.ProfileLabels = true
+	m := NewManager()
+	if err := m.Register("billing", rt); err != nil {
+		t.Fatal(err)
+	}
+	if name := rt.name(); name != "billing" {
+		t.Errorf("name() = %q, want %q", name, "billing")
+	}
+	rt.limiter("blocked-key").Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+		_, err := rt.RoundTrip(req)
+		done <- err
+	}()
+	want := []string{`"ratelim.key":"blocked-key"`, `"ratelim.transport":"billing"`}
+	var profile string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var buf bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+			t.Fatal(err)
+		}
+		if profile = buf.String(); strings.Contains(profile, want[0]) {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("RoundTrip() succeeded, want the wait to be canceled")
+	}
+	for _, label := range want {
+		if !strings.Contains(profile, label) {
+			t.Errorf("goroutine profile does not contain label %s", label)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package pqueue
+
+import "sync"
+
+// A Keyed is a set of Queues, one per key, safe for concurrent use. The queue of a key is created when a value is
+// first pushed for it, and discarded once it is empty.
+type Keyed[K comparable, V any] struct {
+	mux    sync.Mutex
+	queues map[K]*Queue[V]
+}
+
+// NewKeyed returns a new, empty Keyed.
+func NewKeyed[K comparable, V any]() *Keyed[K, V] {
+	return &Keyed[K, V]{queues: make(map[K]*Queue[V])}
+}
+
+// Push adds value to the queue of key with the given rank, and returns its item.
+func (k *Keyed[K, V]) Push(key K, value V, rank float64) *Item[V] {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	q, ok := k.queues[key]
+	if !ok {
+		q = &Queue[V]{}
+		k.queues[key] = q
+	}
+	return q.Push(value, rank)
+}
+
+// Peek returns the value at the head of the queue of key, without removing it.
+func (k *Keyed[K, V]) Peek(key K) (value V, ok bool) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if q, found := k.queues[key]; found {
+		if it, ok := q.Peek(); ok {
+			return it.Value, true
+		}
+	}
+	return value, false
+}
+
+// Pop removes and returns the value at the head of the queue of key.
+func (k *Keyed[K, V]) Pop(key K) (value V, ok bool) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	q, found := k.queues[key]
+	if !found {
+		return value, false
+	}
+	it, _ := q.Pop()
+	if q.Len() == 0 {
+		delete(k.queues, key)
+	}
+	return it.Value, true
+}
+
+// Remove removes it from the queue of key, and reports whether it was queued there.
+func (k *Keyed[K, V]) Remove(key K, it *Item[V]) bool {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	q, found := k.queues[key]
+	if !found || !q.Remove(it) {
+		return false
+	}
+	if q.Len() == 0 {
+		delete(k.queues, key)
+	}
+	return true
+}
+
+// Len returns the number of values queued for key.
+func (k *Keyed[K, V]) Len(key K) int {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if q, ok := k.queues[key]; ok {
+		return q.Len()
+	}
+	return 0
+}
+
+// Keys returns the keys which have values queued, in no particular order.
+func (k *Keyed[K, V]) Keys() []K {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	keys := make([]K, 0, len(k.queues))
+	for key := range k.queues {
+		keys = append(keys, key)
+	}
+	return keys
+}
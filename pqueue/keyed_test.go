@@ -0,0 +1,48 @@
+package pqueue
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyed[string, int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := "even"
+			if i%2 == 1 {
+				key = "odd"
+			}
+			k.Push(key, i, float64(-i))
+		}()
+	}
+	wg.Wait()
+	if got := k.Len("odd"); got != 5 {
+		t.Errorf("Len(odd) = %d, want 5", got)
+	}
+	if v, ok := k.Peek("even"); !ok || v != 8 {
+		t.Errorf("Peek(even) = %d, %v, want 8", v, ok)
+	}
+	for _, want := range []int{9, 7, 5, 3, 1} {
+		if v, ok := k.Pop("odd"); !ok || v != want {
+			t.Errorf("Pop(odd) = %d, %v, want %d", v, ok, want)
+		}
+	}
+	if _, ok := k.Pop("odd"); ok {
+		t.Error("Pop(odd) succeeded on an empty queue")
+	}
+	if keys := k.Keys(); len(keys) != 1 || keys[0] != "even" {
+		t.Errorf("Keys() = %v, want [even]", keys)
+	}
+
+	it := k.Push("other", -1, 0)
+	if k.Remove("even", it) {
+		t.Error("Remove() succeeded with the wrong key")
+	}
+	if !k.Remove("other", it) || k.Len("other") != 0 {
+		t.Error("Remove() did not remove the item")
+	}
+}
@@ -0,0 +1,102 @@
+// Package pqueue provides a priority queue ordered by ascending rank and by insertion within a rank, and a concurrent
+// set of them keyed by any comparable type, for dispatching work in priority order per key.
+package pqueue
+
+import "container/heap"
+
+// An Item is a value in a Queue.
+type Item[V any] struct {
+	Value V
+	rank  float64
+	seq   uint64
+	index int
+}
+
+// Rank returns the rank with which the item was pushed.
+func (it *Item[V]) Rank() float64 {
+	return it.rank
+}
+
+// Queued reports whether the item is still in its queue.
+func (it *Item[V]) Queued() bool {
+	return it.index >= 0
+}
+
+// A Queue is a priority queue whose items are ordered by ascending rank, and by the order in which they were pushed
+// within a rank. The zero value is an empty queue. A Queue is not safe for concurrent use; see Keyed.
+type Queue[V any] struct {
+	items items[V]
+	seq   uint64
+}
+
+// Push adds value to the queue with the given rank, and returns its item.
+func (q *Queue[V]) Push(value V, rank float64) *Item[V] {
+	q.seq++
+	it := &Item[V]{Value: value, rank: rank, seq: q.seq}
+	heap.Push(&q.items, it)
+	return it
+}
+
+// Peek returns the item at the head of the queue, without removing it.
+func (q *Queue[V]) Peek() (*Item[V], bool) {
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	return q.items[0], true
+}
+
+// Pop removes and returns the item at the head of the queue.
+func (q *Queue[V]) Pop() (*Item[V], bool) {
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	return heap.Pop(&q.items).(*Item[V]), true
+}
+
+// Remove removes it from the queue, and reports whether it was queued.
+func (q *Queue[V]) Remove(it *Item[V]) bool {
+	if !it.Queued() || it.index >= len(q.items) || q.items[it.index] != it {
+		return false
+	}
+	heap.Remove(&q.items, it.index)
+	return true
+}
+
+// Len returns the number of items in the queue.
+func (q *Queue[V]) Len() int {
+	return len(q.items)
+}
+
+// items implements heap.Interface, ordering items by ascending rank and sequence number.
+type items[V any] []*Item[V]
+
+func (q items[V]) Len() int { return len(q) }
+
+func (q items[V]) Less(i, j int) bool {
+	if q[i].rank != q[j].rank {
+		return q[i].rank < q[j].rank
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q items[V]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *items[V]) Push(x any) {
+	it := x.(*Item[V])
+	it.index = len(*q)
+	*q = append(*q, it)
+}
+
+func (q *items[V]) Pop() any {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*q = old[:n-1]
+	return it
+}
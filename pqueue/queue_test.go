@@ -0,0 +1,39 @@
+package pqueue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	var q Queue[string]
+	q.Push("low-1", 2)
+	q.Push("high", 0)
+	removed := q.Push("removed", 1)
+	q.Push("low-2", 2)
+	q.Push("mid", 1)
+	if !q.Remove(removed) {
+		t.Error("Remove() = false for a queued item")
+	}
+	if removed.Queued() || q.Remove(removed) {
+		t.Error("item still queued after Remove()")
+	}
+	if head, ok := q.Peek(); !ok || head.Value != "high" {
+		t.Errorf("Peek() = %v, %v, want high", head, ok)
+	}
+
+	var got []string
+	for {
+		it, ok := q.Pop()
+		if !ok {
+			break
+		}
+		got = append(got, it.Value)
+	}
+	if want := []string{"high", "mid", "low-1", "low-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after popping everything, want 0", q.Len())
+	}
+}
@@ -0,0 +1,253 @@
+// Package presets maps the origins of well-known APIs to rate limits and response header parsers which keep clients
+// within the documented (or commonly observed) limits of those APIs.
+package presets
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+// A Preset holds the rate limiting parameters for the origins matching Origin.
+type Preset struct {
+	// Origin is the origin (as returned by ratelim.Origin) to which the Preset applies. The leftmost label of the
+	// host may be the wildcard "*", which matches one or more labels, e.g. "https://*.googleapis.com".
+	Origin string
+	// Limit and Burst are the parameters of the rate.Limiter created for a matching origin.
+	Limit rate.Limit
+	Burst int
+	// HeaderParser parses the rate limit headers of responses from a matching origin; if nil,
+	// ratelim.ParseRateLimitHeaders is used.
+	HeaderParser func(http.Header) (ratelim.RateLimitStatus, bool)
+}
+
+// Presets is the list of known presets. Exact origins take precedence over wildcard origins, and longer wildcard
+// origins take precedence over shorter ones.
+var Presets = []Preset{
+	{
+		// 5,000 requests per hour for authenticated requests
+		// X-RateLimit-Reset is a Unix timestamp
+		Origin:       "https://api.github.com",
+		Limit:        rate.Every(time.Hour / 5000),
+		Burst:        10,
+		HeaderParser: ratelim.ParseRateLimitHeaders,
+	},
+	{
+		// 100 read/write operations per second in live mode, 25 in test mode; no quota headers are sent
+		Origin:       "https://api.stripe.com",
+		Limit:        25,
+		Burst:        25,
+		HeaderParser: ParseRetryAfter,
+	},
+	{
+		// quota errors may carry a Retry-After header
+		Origin:       "https://*.googleapis.com",
+		Limit:        10,
+		Burst:        10,
+		HeaderParser: ParseRetryAfter,
+	},
+	{
+		// crawler policy: at most 1 request per second
+		Origin:       "https://crates.io",
+		Limit:        1,
+		Burst:        1,
+		HeaderParser: ParseRetryAfter,
+	},
+	{
+		// 50 requests per second globally per bot/user
+		Origin:       "https://discord.com",
+		Limit:        50,
+		Burst:        10,
+		HeaderParser: ParseDiscordHeaders,
+	},
+	{
+		// 100 queries per minute per OAuth client
+		Origin:       "https://oauth.reddit.com",
+		Limit:        rate.Every(time.Minute / 100),
+		Burst:        10,
+		HeaderParser: ParseRedditHeaders,
+	},
+	{
+		// Tier 3 Web API methods: 50+ requests per minute; throttled requests get a Retry-After header
+		Origin:       "https://slack.com",
+		Limit:        rate.Every(time.Minute / 50),
+		Burst:        5,
+		HeaderParser: ParseRetryAfter,
+	},
+	{
+		// 1,000 requests per hour for authenticated requests
+		Origin:       "https://api.bitbucket.org",
+		Limit:        rate.Every(time.Hour / 1000),
+		Burst:        10,
+		HeaderParser: ratelim.ParseRateLimitHeaders,
+	},
+}
+
+// Lookup returns the preset for origin, if any.
+func Lookup(origin string) (Preset, bool) {
+	var best Preset
+	found := false
+	for _, p := range Presets {
+		if p.Origin == origin {
+			return p, true
+		}
+		if matchWildcard(p.Origin, origin) && (!found || len(p.Origin) > len(best.Origin)) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+func matchWildcard(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix := scheme + "://"
+	return strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, "."+host) &&
+		len(origin) > len(prefix)+len(host)+1
+}
+
+// ParseRetryAfter parses only the Retry-After header, for APIs which send no quota headers but tell throttled
+// clients when to retry. Any other rate limit headers are ignored, so that, e.g., those of a proxy in front of the API
+// are not mistaken for its own.
+func ParseRetryAfter(header http.Header) (ratelim.RateLimitStatus, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return ratelim.RateLimitStatus{}, false
+	}
+	return ratelim.ParseRateLimitHeaders(http.Header{"Retry-After": {v}})
+}
+
+// ParseDiscordHeaders parses the rate limit headers of Discord's API, which are the X-RateLimit-* headers, with a
+// fractional X-RateLimit-Reset timestamp, and X-RateLimit-Reset-After, the number of seconds until the reset, which
+// is preferred to the timestamp since it is unaffected by clock skew.
+func ParseDiscordHeaders(header http.Header) (ratelim.RateLimitStatus, bool) {
+	return parseDiscordHeaders(header, time.Now())
+}
+
+func parseDiscordHeaders(header http.Header, now time.Time) (ratelim.RateLimitStatus, bool) {
+	after, found := parseSeconds(header.Get("X-RateLimit-Reset-After"))
+	if !found {
+		return ratelim.ParseRateLimitHeaders(header)
+	}
+	header = header.Clone()
+	header.Del("X-RateLimit-Reset")
+	status, ok := ratelim.ParseRateLimitHeaders(header)
+	if !ok {
+		status = ratelim.RateLimitStatus{Limit: -1, Remaining: -1}
+	}
+	// a Retry-After beyond the reset, e.g. for the global limit, is kept
+	if reset := now.Add(after); reset.After(status.Reset) {
+		status.Reset = reset
+	}
+	return status, true
+}
+
+// ParseRedditHeaders parses the rate limit headers of Reddit's API: X-Ratelimit-Used, X-Ratelimit-Remaining, which
+// may be fractional (e.g. "598.0"), and X-Ratelimit-Reset, the number of seconds until the reset. The Limit is the sum
+// of the used and remaining requests.
+func ParseRedditHeaders(header http.Header) (ratelim.RateLimitStatus, bool) {
+	return parseRedditHeaders(header, time.Now())
+}
+
+func parseRedditHeaders(header http.Header, now time.Time) (ratelim.RateLimitStatus, bool) {
+	status := ratelim.RateLimitStatus{Limit: -1, Remaining: -1}
+	used, usedOK := parseCount(header.Get("X-Ratelimit-Used"))
+	remaining, remainingOK := parseCount(header.Get("X-Ratelimit-Remaining"))
+	reset, resetOK := parseSeconds(header.Get("X-Ratelimit-Reset"))
+	if remainingOK {
+		status.Remaining = remaining
+		if usedOK {
+			status.Limit = used + remaining
+		}
+	}
+	if resetOK {
+		status.Reset = now.Add(reset)
+	}
+	if retryAfter, ok := ParseRetryAfter(header); ok {
+		status.Remaining = 0
+		if retryAfter.Reset.After(status.Reset) {
+			status.Reset = retryAfter.Reset
+		}
+		return status, true
+	}
+	return status, usedOK || remainingOK || resetOK
+}
+
+// parseCount parses a non-negative, possibly fractional, count of requests, rounded down.
+func parseCount(s string) (int, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// parseSeconds parses a non-negative, possibly fractional, number of seconds.
+func parseSeconds(s string) (time.Duration, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 || f > math.MaxInt32 {
+		return 0, false
+	}
+	return time.Duration(f * float64(time.Second)), true
+}
+
+// Apply configures t to use the limits and header parsers of Presets for the origins they match. Any
+// LimiterConfigFunc or HeaderParser already set on t takes precedence over the presets, which apply only to keys
+// for which those report no result. The responses of origins matching no preset are left to any HeaderParser already
+// set, and otherwise are not parsed. Since presets are matched by origin, t should be keyed by ratelim.TargetOrigin.
+func Apply(t *ratelim.PerKeyRoundTripper[string]) {
+	configFunc := t.LimiterConfigFunc
+	t.LimiterConfigFunc = func(key string) (ratelim.LimiterConfig, bool) {
+		if configFunc != nil {
+			if cfg, ok := configFunc(key); ok {
+				return cfg, true
+			}
+		}
+		p, ok := Lookup(key)
+		if !ok {
+			return ratelim.LimiterConfig{}, false
+		}
+		return ratelim.LimiterConfig{Limit: p.Limit, Burst: p.Burst}, true
+	}
+	headerParser := t.HeaderParser
+	t.HeaderParser = func(key string, header http.Header) (ratelim.RateLimitStatus, bool) {
+		if headerParser != nil {
+			if status, ok := headerParser(key, header); ok {
+				return status, true
+			}
+		}
+		p, ok := Lookup(key)
+		if !ok {
+			return ratelim.RateLimitStatus{}, false
+		}
+		if p.HeaderParser != nil {
+			return p.HeaderParser(header)
+		}
+		return ratelim.ParseRateLimitHeaders(header)
+	}
+}
+
+// WithPresets returns an option applying Presets to a transport, as by Apply:
+//
+//	transport := ratelim.NewPerKeyRoundTripper(10, 10, ratelim.TargetOrigin, nil, presets.WithPresets())
+func WithPresets() ratelim.Option[string] {
+	return Apply
+}
+
+// PerOriginRoundTripper returns a ratelim.PerOriginRoundTripper to which Presets have been applied.
+func PerOriginRoundTripper(
+	defaultLimit rate.Limit,
+	defaultBurst int,
+	roundTripper http.RoundTripper,
+) *ratelim.PerKeyRoundTripper[string] {
+	return ratelim.NewPerKeyRoundTripper(defaultLimit, defaultBurst, ratelim.TargetOrigin, roundTripper, WithPresets())
+}
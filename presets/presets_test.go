@@ -0,0 +1,136 @@
+package presets
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   string
+		found  bool
+	}{
+		{"https://api.github.com", "https://api.github.com", true},
+		{"http://api.github.com", "", false},
+		{"https://sheets.googleapis.com", "https://*.googleapis.com", true},
+		{"https://a.b.googleapis.com", "https://*.googleapis.com", true},
+		{"https://googleapis.com", "", false},
+		{"https://example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.origin, func(t *testing.T) {
+				p, found := Lookup(tt.origin)
+				if found != tt.found || p.Origin != tt.want {
+					t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.origin, p.Origin, found, tt.want, tt.found)
+				}
+			},
+		)
+	}
+}
+
+func TestApply(t *testing.T) {
+	rt := PerOriginRoundTripper(100, 100, nil)
+	if cfg := rt.LimiterConfig("https://crates.io"); cfg.Limit != 1 || cfg.Burst != 1 {
+		t.Errorf("preset not applied: %+v", cfg)
+	}
+	if cfg := rt.LimiterConfig("https://example.com"); cfg.Limit != 100 || cfg.Burst != 100 {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestWithPresets(t *testing.T) {
+	rt := ratelim.NewPerKeyRoundTripper(100, 100, ratelim.TargetOrigin, nil, WithPresets())
+	if cfg := rt.LimiterConfig("https://api.github.com"); cfg.Limit != rate.Every(time.Hour/5000) || cfg.Burst != 10 {
+		t.Errorf("preset not applied: %+v", cfg)
+	}
+	header := http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"30"}}
+	if _, ok := rt.HeaderParser("https://oauth.reddit.com", header); !ok {
+		t.Error("HeaderParser() did not parse the headers of a preset origin")
+	}
+	if status, ok := rt.HeaderParser("https://example.com", header); ok {
+		t.Errorf("HeaderParser() = %+v for an origin without a preset, want no result", status)
+	}
+}
+
+func TestHeaderParsers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		parse  func(http.Header, time.Time) (ratelim.RateLimitStatus, bool)
+		header http.Header
+		want   ratelim.RateLimitStatus
+		wantOK bool
+	}{
+		{
+			name:  "discord reset after",
+			parse: parseDiscordHeaders,
+			header: http.Header{
+				"X-Ratelimit-Limit":       {"5"},
+				"X-Ratelimit-Remaining":   {"0"},
+				"X-Ratelimit-Reset":       {"1470173023.123"},
+				"X-Ratelimit-Reset-After": {"1.5"},
+			},
+			want:   ratelim.RateLimitStatus{Limit: 5, Remaining: 0, Reset: now.Add(1500 * time.Millisecond)},
+			wantOK: true,
+		},
+		{
+			name:  "discord global retry after",
+			parse: parseDiscordHeaders,
+			header: http.Header{
+				"X-Ratelimit-Remaining":   {"3"},
+				"X-Ratelimit-Reset-After": {"1"},
+				"Retry-After":             {"Mon, 01 Jan 2024 12:01:00 GMT"},
+			},
+			want:   ratelim.RateLimitStatus{Limit: -1, Remaining: 0, Reset: now.Add(time.Minute)},
+			wantOK: true,
+		},
+		{
+			name:  "reddit",
+			parse: parseRedditHeaders,
+			header: http.Header{
+				"X-Ratelimit-Used":      {"2"},
+				"X-Ratelimit-Remaining": {"598.0"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+			want:   ratelim.RateLimitStatus{Limit: 600, Remaining: 598, Reset: now.Add(30 * time.Second)},
+			wantOK: true,
+		},
+		{
+			name:   "reddit without headers",
+			parse:  parseRedditHeaders,
+			header: http.Header{"X-Ratelimit-Limit": {"100"}},
+			want:   ratelim.RateLimitStatus{Limit: -1, Remaining: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				status, ok := tt.parse(tt.header, now)
+				if ok != tt.wantOK {
+					t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+				}
+				if status.Limit != tt.want.Limit || status.Remaining != tt.want.Remaining ||
+					!status.Reset.Equal(tt.want.Reset) {
+					t.Errorf("status = %+v, want %+v", status, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if status, ok := ParseRetryAfter(http.Header{"X-Ratelimit-Remaining": {"0"}}); ok {
+		t.Errorf("ParseRetryAfter() = %+v without a Retry-After header, want no result", status)
+	}
+	status, ok := ParseRetryAfter(http.Header{"Retry-After": {"10"}, "X-Ratelimit-Limit": {"100"}})
+	if !ok || !status.Exhausted() || status.Limit != -1 {
+		t.Errorf("ParseRetryAfter() = %+v, %v; want an exhausted status ignoring other headers", status, ok)
+	}
+}
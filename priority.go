@@ -0,0 +1,153 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/pqueue"
+	"golang.org/x/time/rate"
+)
+
+var (
+	errBurstExceeded  = errors.New("ratelim: wait exceeds limiter's burst")
+	errDeadlineExceed = errors.New("ratelim: wait would exceed context deadline")
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx carrying the given request priority. When priority scheduling is enabled on a
+// PerKeyRoundTripper, waiting requests with a higher priority acquire tokens before those with a lower one; requests
+// without a priority have priority 0.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the request priority carried by ctx, or 0 if none.
+func PriorityFromContext(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+// A waitQueue orders the waiters for a single rate.Limiter by ascending rank, and by arrival within a rank. Only the
+// waiter at the head of the queue holds a reservation on the limiter at any time; if a waiter with a lower rank
+// arrives before that reservation is ready, the reservation is canceled and the new waiter takes its place.
+type waitQueue struct {
+	mux   sync.Mutex
+	queue pqueue.Queue[*waiter]
+}
+
+type waiter struct {
+	item        *pqueue.Item[*waiter]
+	wake        chan struct{}
+	reservation *rate.Reservation
+	readyAt     time.Time
+}
+
+func (w *waiter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks until a waiter with the given rank has reached the head of the queue and obtained a token from limiter,
+// or ctx is done.
+func (s *waitQueue) Wait(ctx context.Context, limiter *rate.Limiter, rank float64) error {
+	w := &waiter{wake: make(chan struct{}, 1)}
+	s.mux.Lock()
+	if head, ok := s.queue.Peek(); ok {
+		s.preempt(head.Value, rank)
+	}
+	w.item = s.queue.Push(w, rank)
+	s.mux.Unlock()
+
+	for {
+		s.mux.Lock()
+		if err := ctx.Err(); err != nil {
+			s.remove(w)
+			s.mux.Unlock()
+			return err
+		}
+		var timer *time.Timer
+		if head, _ := s.queue.Peek(); head.Value == w && w.reservation == nil {
+			r := limiter.Reserve()
+			if !r.OK() {
+				s.remove(w)
+				s.mux.Unlock()
+				return errBurstExceeded
+			}
+			delay := r.Delay()
+			if delay == 0 {
+				s.remove(w)
+				s.mux.Unlock()
+				return nil
+			}
+			w.reservation, w.readyAt = r, time.Now().Add(delay)
+			if deadline, ok := ctx.Deadline(); ok && deadline.Before(w.readyAt) {
+				s.remove(w)
+				s.mux.Unlock()
+				return errDeadlineExceed
+			}
+			timer = time.NewTimer(delay)
+		}
+		s.mux.Unlock()
+
+		if timer == nil {
+			select {
+			case <-w.wake:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		select {
+		case <-timer.C:
+			s.mux.Lock()
+			if w.reservation != nil {
+				// the reservation was not canceled by a preempting waiter, so the token is ours
+				s.remove(w)
+				s.mux.Unlock()
+				return nil
+			}
+			s.mux.Unlock()
+		case <-w.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+// preempt cancels the reservation of head, if any, when a waiter arrives with a lower rank before the reservation is
+// ready. s.mux must be held.
+func (s *waitQueue) preempt(head *waiter, rank float64) {
+	if head.reservation == nil || rank >= head.item.Rank() || !time.Now().Before(head.readyAt) {
+		return
+	}
+	head.reservation.Cancel()
+	head.reservation = nil
+	head.signal()
+}
+
+// len returns the number of requests waiting in the queue.
+func (s *waitQueue) len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.queue.Len()
+}
+
+// remove removes w from the queue, cancels any reservation it still holds, and wakes the new head of the queue.
+// s.mux must be held.
+func (s *waitQueue) remove(w *waiter) {
+	if !s.queue.Remove(w.item) {
+		return
+	}
+	if w.reservation != nil && time.Now().Before(w.readyAt) {
+		w.reservation.Cancel()
+	}
+	w.reservation = nil
+	if head, ok := s.queue.Peek(); ok {
+		head.Value.signal()
+	}
+}
@@ -0,0 +1,99 @@
+package ratelim
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestScheduler_Wait(t *testing.T) {
+	limiter := rate.NewLimiter(10, 1)
+	limiter.Allow()
+	s := &waitQueue{}
+	waiters := []struct {
+		name     string
+		priority int
+	}{
+		{"low1", 0},
+		{"low2", 0},
+		{"low3", 0},
+		{"high", 1},
+	}
+	var mux sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for _, w := range waiters {
+		wg.Add(1)
+		go func(name string, priority int) {
+			defer wg.Done()
+			if err := s.Wait(context.Background(), limiter, -float64(priority)); err != nil {
+				t.Error(name, err)
+				return
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			order = append(order, name)
+		}(w.name, w.priority)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	want := []string{"high", "low1", "low2", "low3"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("completion order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestScheduler_WaitCanceled(t *testing.T) {
+	limiter := rate.NewLimiter(10, 1)
+	limiter.Allow()
+	s := &waitQueue{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Wait(ctx, limiter, 0); err == nil {
+		t.Fatal("expected error")
+	}
+	if s.queue.Len() != 0 {
+		t.Fatalf("waiter not removed: %d queued", s.queue.Len())
+	}
+	start := time.Now()
+	if err := s.Wait(context.Background(), limiter, 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("canceled reservation not released: waited %v", elapsed)
+	}
+}
+
+func TestPerKeyRoundTripper_Ordered(t *testing.T) {
+	transport := PerOriginRoundTripper(50, 1, nil)
+	transport.SetLimiterConfig("ordered", LimiterConfig{Limit: 50, Burst: 1, Ordered: true})
+	limiter := transport.limiter("ordered")
+	var mux sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := transport.wait(context.Background(), "ordered", transport.LimiterConfig("ordered"), limiter); err != nil {
+				t.Error(err)
+				return
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			order = append(order, i)
+		}(i)
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	for i := range order {
+		if order[i] != i {
+			t.Fatalf("requests not ordered: %v", order)
+		}
+	}
+}
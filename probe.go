@@ -0,0 +1,194 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A LimitProbe searches for the practical rate limit of an endpoint: it sends requests at increasing rates until some
+// are throttled, then binary-searches between the highest rate which was not throttled and the lowest which was.
+// Probing sends real traffic, up to Max requests per second, and should only be aimed at endpoints which tolerate it.
+type LimitProbe struct {
+	// NewRequest returns each request sent by the probe.
+	NewRequest func(ctx context.Context) (*http.Request, error)
+	// Transport sends the requests; if nil, a clone of http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// Min and Max bound the rates searched, in requests per second. The search starts at Min, doubling the rate
+	// until it is throttled or reaches Max.
+	Min, Max rate.Limit
+	// Tolerance is the precision of the search: it stops once the bounds are within Tolerance of each other. If not
+	// positive, a tenth of Min is used.
+	Tolerance rate.Limit
+	// Step is how long each rate is tried; if not positive, 5s is used.
+	Step time.Duration
+	// Cooldown is how long the probe pauses after a throttled step, for the endpoint's quota to recover.
+	Cooldown time.Duration
+	// Margin is the fraction by which the suggested limit is lower than the highest rate which was not throttled.
+	Margin float64
+	// Classify classifies the responses; if nil, StatusClassifier is used.
+	Classify ResponseClassifier
+}
+
+// A ProbeStep records the requests sent at one rate by a LimitProbe.
+type ProbeStep struct {
+	Rate      rate.Limit
+	Requests  int
+	Throttled int
+	Errors    int
+}
+
+// A ProbeResult is the outcome of a LimitProbe.
+type ProbeResult struct {
+	// Limit is the highest rate tried which was not throttled.
+	Limit rate.Limit
+	// Config is the suggested config for the endpoint: Limit less the probe's Margin, with a burst of a second's
+	// worth of requests.
+	Config LimiterConfig
+	// Steps are the rates tried, in order.
+	Steps []ProbeStep
+}
+
+// Run runs the probe until the limit is found, ctx is done or a step fails with errors only.
+func (p *LimitProbe) Run(ctx context.Context) (ProbeResult, error) {
+	var result ProbeResult
+	if p.Min <= 0 || p.Max < p.Min {
+		return result, fmt.Errorf("ratelim: probe needs 0 < Min <= Max, got %v and %v", p.Min, p.Max)
+	}
+	tolerance := p.Tolerance
+	if tolerance <= 0 {
+		tolerance = p.Min / 10
+	}
+	try := func(r rate.Limit) (bool, error) {
+		step, err := p.step(ctx, r)
+		result.Steps = append(result.Steps, step)
+		if err != nil {
+			return false, err
+		}
+		if step.Throttled == 0 {
+			return true, nil
+		}
+		return false, sleep(ctx, p.Cooldown)
+	}
+	// ramp up until throttled, then binary-search between the last passing rate and the first throttled one
+	lo, hi := rate.Limit(0), rate.Limit(0)
+	for r := p.Min; ; r = min(2*r, p.Max) {
+		ok, err := try(r)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			hi = r
+			break
+		}
+		lo = r
+		if r == p.Max {
+			break
+		}
+	}
+	if lo == 0 {
+		return result, fmt.Errorf("ratelim: probe throttled at its minimum rate of %v", p.Min)
+	}
+	for hi != 0 && hi-lo > tolerance {
+		mid := (lo + hi) / 2
+		ok, err := try(mid)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	result.Limit = lo
+	limit := lo * rate.Limit(1-p.Margin)
+	result.Config = LimiterConfig{Limit: limit, Burst: max(1, int(math.Floor(float64(limit))))}
+	return result, nil
+}
+
+// step sends requests at rate r for the duration of a step, and counts their outcomes.
+func (p *LimitProbe) step(ctx context.Context, r rate.Limit) (ProbeStep, error) {
+	transport := p.Transport
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	classify := p.Classify
+	if classify == nil {
+		classify = StatusClassifier
+	}
+	duration := p.Step
+	if duration <= 0 {
+		duration = 5 * time.Second
+	}
+	step := ProbeStep{Rate: r}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	pacer := rate.NewLimiter(r, 1)
+	stepCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	for pacer.Wait(stepCtx) == nil {
+		req, err := p.NewRequest(ctx)
+		if err != nil {
+			return step, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := transport.RoundTrip(req)
+			outcome := classify(resp, err)
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			step.Requests++
+			switch outcome {
+			case OutcomeThrottled:
+				step.Throttled++
+			case OutcomeError:
+				step.Errors++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return step, err
+	}
+	if step.Requests > 0 && step.Errors == step.Requests {
+		if firstErr == nil {
+			firstErr = errors.New("error responses")
+		}
+		return step, fmt.Errorf("ratelim: probe step at %v failed: %w", r, firstErr)
+	}
+	return step, nil
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestLimitProbe_Run(t *testing.T) {
+	limiter := rate.NewLimiter(40, 2)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if !limiter.Allow() {
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+		),
+	)
+	defer server.Close()
+	p := &LimitProbe{
+		NewRequest: func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		},
+		Min:       10,
+		Max:       160,
+		Tolerance: 10,
+		Step:      300 * time.Millisecond,
+		Cooldown:  50 * time.Millisecond,
+		Margin:    0.5,
+	}
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Limit < 20 || result.Limit > 50 {
+		t.Errorf("Limit = %v, want about 40 (steps: %+v)", result.Limit, result.Steps)
+	}
+	if got, want := result.Config.Limit, result.Limit/2; got != want {
+		t.Errorf("Config.Limit = %v, want %v", got, want)
+	}
+	if got := result.Steps[0]; got.Rate != 10 || got.Throttled != 0 || got.Requests == 0 {
+		t.Errorf("first step = %+v, want requests at 10/s without throttling", got)
+	}
+}
+
+func TestLimitProbe_RunThrottledAtMin(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		),
+	)
+	defer server.Close()
+	p := &LimitProbe{
+		NewRequest: func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		},
+		Min:  10,
+		Max:  100,
+		Step: 50 * time.Millisecond,
+	}
+	if _, err := p.Run(context.Background()); err == nil {
+		t.Error("Run() succeeded, want an error")
+	}
+}
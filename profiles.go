@@ -0,0 +1,157 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ProfileRule activates the named limit profile for the keys matching Pattern whenever Cron fires. The profile
+// remains active for those keys until another rule with the same Pattern fires.
+type ProfileRule struct {
+	// Pattern is matched against the string form of each key (as formatted by fmt.Sprint); "*" matches any sequence
+	// of characters, and all other characters match themselves.
+	Pattern string
+	Cron    string
+	Profile string
+}
+
+// A ProfileScheduler switches the keys matching each of its rules' patterns between named limit profiles as its
+// rules' cron schedules fire, so that calendars such as weekday and weekend limits or month-end freezes can be
+// declared rather than coded. Use its LimiterConfig method as a PerKeyRoundTripper's LimiterConfigFunc, and call Run
+// to keep the active profiles current.
+type ProfileScheduler[K comparable] struct {
+	profiles map[string]LimiterConfig
+	rules    []ProfileRule
+	crons    []*Cron
+	patterns []string
+
+	mux    sync.RWMutex
+	active map[string]string
+}
+
+// NewProfileScheduler returns a new ProfileScheduler for the given profiles and rules, with the active profile of each
+// pattern set to that of its rule which fired most recently. Patterns are matched in the order they first appear in
+// rules.
+func NewProfileScheduler[K comparable](
+	profiles map[string]LimiterConfig,
+	rules []ProfileRule,
+) (*ProfileScheduler[K], error) {
+	s := &ProfileScheduler[K]{
+		profiles: profiles,
+		rules:    rules,
+		crons:    make([]*Cron, len(rules)),
+	}
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		if _, ok := profiles[rule.Profile]; !ok {
+			return nil, fmt.Errorf("ratelim: profile rule %d: unknown profile %q", i, rule.Profile)
+		}
+		cron, err := ParseCron(rule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("ratelim: profile rule %d: %w", i, err)
+		}
+		s.crons[i] = cron
+		if !seen[rule.Pattern] {
+			seen[rule.Pattern] = true
+			s.patterns = append(s.patterns, rule.Pattern)
+		}
+	}
+	s.update(time.Now())
+	return s, nil
+}
+
+// update sets the active profile of each pattern to that of its rule which fired most recently at or before t.
+func (s *ProfileScheduler[K]) update(t time.Time) {
+	active := make(map[string]string, len(s.patterns))
+	latest := make(map[string]time.Time, len(s.patterns))
+	for i, rule := range s.rules {
+		prev := s.crons[i].Prev(t)
+		if prev.IsZero() {
+			continue
+		}
+		if last, ok := latest[rule.Pattern]; !ok || prev.After(last) {
+			latest[rule.Pattern] = prev
+			active[rule.Pattern] = rule.Profile
+		}
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.active = active
+}
+
+// ActiveProfile returns the name of the profile currently active for key, if any.
+func (s *ProfileScheduler[K]) ActiveProfile(key K) (string, bool) {
+	str := fmt.Sprint(key)
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for _, pattern := range s.patterns {
+		if matchGlob(pattern, str) {
+			profile, ok := s.active[pattern]
+			return profile, ok
+		}
+	}
+	return "", false
+}
+
+// LimiterConfig returns the config of the profile currently active for key, if any.
+func (s *ProfileScheduler[K]) LimiterConfig(key K) (LimiterConfig, bool) {
+	profile, ok := s.ActiveProfile(key)
+	if !ok {
+		return LimiterConfig{}, false
+	}
+	return s.profiles[profile], true
+}
+
+// Run updates the active profiles each time one of the rules fires, then calls onChange (if non-nil), until ctx is
+// done. To apply profile changes to existing limiters, pass a PerKeyRoundTripper's Reconfigure method as onChange.
+func (s *ProfileScheduler[K]) Run(ctx context.Context, onChange func()) error {
+	for {
+		now := time.Now()
+		var next time.Time
+		for _, cron := range s.crons {
+			if n := cron.Next(now); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+		if next.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		s.update(next)
+		if onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// matchGlob reports whether s matches pattern, in which "*" matches any sequence of characters and all other
+// characters match themselves.
+func matchGlob(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
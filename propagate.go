@@ -0,0 +1,64 @@
+package ratelim
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// isRateLimitHeader reports whether name, in canonical form, is a rate limit header: RateLimit, RateLimit-*, including
+// RateLimit-Policy, or X-RateLimit-*.
+func isRateLimitHeader(name string) bool {
+	return name == "Ratelimit" || strings.HasPrefix(name, "Ratelimit-") || strings.HasPrefix(name, "X-Ratelimit-")
+}
+
+// CopyRateLimitHeaders copies the rate limit headers of src, such as the headers of a response from an upstream, to
+// dst, such as the headers of the response to the original caller of a handler or middleware: RateLimit,
+// RateLimit-*, X-RateLimit-* and Retry-After. Headers of dst with the same names are replaced.
+func CopyRateLimitHeaders(dst, src http.Header) {
+	for name, values := range src {
+		name = http.CanonicalHeaderKey(name)
+		if isRateLimitHeader(name) || name == "Retry-After" {
+			dst[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// localRateLimitStatus returns the RateLimitStatus of limiter at now: its burst as Limit, the whole tokens it holds
+// as Remaining, and when its bucket will be full again as Reset.
+func localRateLimitStatus(limiter *rate.Limiter, now time.Time) RateLimitStatus {
+	limit, burst, tokens := limiter.Limit(), limiter.Burst(), limiter.TokensAt(now)
+	status := RateLimitStatus{Limit: burst, Remaining: max(int(math.Floor(tokens)), 0), Reset: now}
+	if limit != rate.Inf && limit > 0 && tokens < float64(burst) {
+		status.Reset = now.Add(time.Duration((float64(burst) - tokens) / float64(limit) * float64(time.Second)))
+	}
+	return status
+}
+
+// translateRateLimitHeaders replaces the rate limit headers of header, as recognized by isRateLimitHeader, with the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of the status which permits the fewest requests:
+// upstream, parsed from header, or that of limiter. Retry-After is left unchanged.
+func translateRateLimitHeaders(header http.Header, upstream RateLimitStatus, ok bool, limiter *rate.Limiter) {
+	now := time.Now()
+	status := localRateLimitStatus(limiter, now)
+	if ok && upstream.Remaining >= 0 && upstream.Remaining < status.Remaining {
+		status = upstream
+	}
+	for name := range header {
+		if isRateLimitHeader(name) {
+			delete(header, name)
+		}
+	}
+	if status.Limit >= 0 {
+		header.Set("RateLimit-Limit", strconv.Itoa(status.Limit))
+	}
+	header.Set("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	if !status.Reset.IsZero() {
+		reset := max(math.Ceil(status.Reset.Sub(now).Seconds()), 0)
+		header.Set("RateLimit-Reset", strconv.FormatFloat(reset, 'f', 0, 64))
+	}
+}
@@ -0,0 +1,88 @@
+package ratelim
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCopyRateLimitHeaders(t *testing.T) {
+	src := http.Header{
+		"X-Ratelimit-Remaining": {"5"},
+		"Ratelimit-Policy":      {"10;w=1"},
+		"Retry-After":           {"3"},
+		"Content-Type":          {"application/json"},
+	}
+	src["ratelimit"] = []string{"limit=10, remaining=5, reset=1"}
+	dst := http.Header{"X-Ratelimit-Remaining": {"100"}, "Content-Type": {"text/plain"}}
+	CopyRateLimitHeaders(dst, src)
+	want := http.Header{
+		"X-Ratelimit-Remaining": {"5"},
+		"Ratelimit-Policy":      {"10;w=1"},
+		"Ratelimit":             {"limit=10, remaining=5, reset=1"},
+		"Retry-After":           {"3"},
+		"Content-Type":          {"text/plain"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("CopyRateLimitHeaders() = %v, want %v", dst, want)
+	}
+}
+
+// headerTransport responds to every request with an empty 200 response with the given headers.
+type headerTransport http.Header
+
+func (h headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header(h).Clone(), Body: http.NoBody}, nil
+}
+
+func TestPerKeyRoundTripper_TranslateRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream http.Header
+		want     http.Header
+	}{
+		{
+			name: "local limit binds",
+			upstream: http.Header{
+				"X-Ratelimit-Limit":     {"100"},
+				"X-Ratelimit-Remaining": {"50"},
+				"X-Ratelimit-Reset":     {"30"},
+			},
+			want: http.Header{"Ratelimit-Limit": {"10"}, "Ratelimit-Remaining": {"9"}, "Ratelimit-Reset": {"1"}},
+		},
+		{
+			name:     "upstream binds",
+			upstream: http.Header{"Ratelimit": {"limit=100, remaining=2, reset=30"}, "Ratelimit-Policy": {"100;w=60"}},
+			want:     http.Header{"Ratelimit-Limit": {"100"}, "Ratelimit-Remaining": {"2"}, "Ratelimit-Reset": {"30"}},
+		},
+		{
+			name:     "retry after",
+			upstream: http.Header{"Retry-After": {"20"}},
+			want: http.Header{
+				"Retry-After":         {"20"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"20"},
+			},
+		},
+		{
+			name:     "no upstream headers",
+			upstream: http.Header{},
+			want:     http.Header{"Ratelimit-Limit": {"10"}, "Ratelimit-Remaining": {"9"}, "Ratelimit-Reset": {"1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rt := PerOriginRoundTripper(10, 10, headerTransport(tt.upstream))
+				rt.TranslateRateLimitHeaders = true
+				resp, err := rt.RoundTrip(mustNewRequest(t))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(resp.Header, tt.want) {
+					t.Errorf("response headers = %v, want %v", resp.Header, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,44 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/time/rate"
+)
+
+// TargetProxy returns a key function for PerKeyRoundTripper which keys requests by the origin of the proxy that proxy
+// chooses for them, as http.Transport.Proxy does, for environments where the bottleneck is an egress proxy rather than
+// the servers behind it. Requests sent directly, or for which proxy fails, are keyed by their own origin.
+func TargetProxy(proxy func(*http.Request) (*url.URL, error)) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if proxyURL, err := proxy(r); err == nil && proxyURL != nil {
+			return Origin(proxyURL)
+		}
+		return Origin(r.URL)
+	}
+}
+
+// PerProxyRoundTripper returns a PerKeyRoundTripper which limits requests per proxy, keyed by TargetProxy with the
+// Proxy function of roundTripper if it is an *http.Transport, or http.ProxyFromEnvironment otherwise. A nil
+// roundTripper is replaced with the same default transport as PerOriginRoundTripper, which uses the proxies configured
+// by the environment.
+func PerProxyRoundTripper(
+	defaultLimit rate.Limit,
+	defaultBurst int,
+	roundTripper http.RoundTripper,
+) *PerKeyRoundTripper[string] {
+	if roundTripper == nil {
+		roundTripper = defaultTransport()
+	}
+	proxy := http.ProxyFromEnvironment
+	if transport, ok := roundTripper.(*http.Transport); ok {
+		proxy = transport.Proxy
+		if proxy == nil {
+			proxy = func(*http.Request) (*url.URL, error) {
+				return nil, nil
+			}
+		}
+	}
+	return NewPerKeyRoundTripper(defaultLimit, defaultBurst, TargetProxy(proxy), roundTripper)
+}
@@ -0,0 +1,53 @@
+package ratelim
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestTargetProxy(t *testing.T) {
+	proxy := func(r *http.Request) (*url.URL, error) {
+		switch r.URL.Hostname() {
+		case "internal.example.com":
+			return nil, nil
+		case "broken.example.com":
+			return nil, errors.New("no proxy")
+		}
+		return url.Parse("http://Proxy.corp.example:3128")
+	}
+	key := TargetProxy(proxy)
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.example.com/v1", "http://proxy.corp.example:3128"},
+		{"https://other.example.org/", "http://proxy.corp.example:3128"},
+		{"https://internal.example.com/", "https://internal.example.com"},
+		{"https://broken.example.com/", "https://broken.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.url, func(t *testing.T) {
+				if got := key(httptest.NewRequest(http.MethodGet, tt.url, nil)); got != tt.want {
+					t.Errorf("TargetProxy() key = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestPerProxyRoundTripper(t *testing.T) {
+	proxyURL := mustParseURL(t, "http://proxy.example:8080")
+	rt := PerProxyRoundTripper(1, 1, &http.Transport{Proxy: http.ProxyURL(proxyURL)})
+	r := httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	if got, want := rt.Key(r), "http://proxy.example:8080"; got != want {
+		t.Errorf("Key() = %q, want %q", got, want)
+	}
+	rt = PerProxyRoundTripper(1, 1, &http.Transport{})
+	if got, want := rt.Key(r), "https://api.example.com"; got != want {
+		t.Errorf("Key() without proxy = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,311 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A QuotaPeriod is the calendar period over which a Quota is counted.
+type QuotaPeriod int
+
+const (
+	// QuotaHourly counts a quota per clock hour.
+	QuotaHourly QuotaPeriod = iota
+	// QuotaDaily counts a quota per calendar day.
+	QuotaDaily
+	// QuotaMonthly counts a quota per calendar month.
+	QuotaMonthly
+)
+
+func (p QuotaPeriod) String() string {
+	switch p {
+	case QuotaHourly:
+		return "hourly"
+	case QuotaDaily:
+		return "daily"
+	case QuotaMonthly:
+		return "monthly"
+	}
+	return "unknown"
+}
+
+// Start returns the start of the period containing t, in the location of t.
+func (p QuotaPeriod) Start(t time.Time) time.Time {
+	switch p {
+	case QuotaHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case QuotaMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// End returns the end of the period starting at start.
+func (p QuotaPeriod) End(start time.Time) time.Time {
+	switch p {
+	case QuotaHourly:
+		return start.Add(time.Hour)
+	case QuotaMonthly:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// A QuotaExceededError is returned for a request whose key has used up its Quota for the current period.
+type QuotaExceededError struct {
+	Key   string
+	Limit int64
+	// Reset is the end of the period, when the quota is replenished.
+	Reset time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("ratelim: quota of %d requests for key %s exceeded until %v", e.Limit, e.Key, e.Reset)
+}
+
+// A QuotaStore records how much of a Quota each key has used per period, e.g. so that consumption survives restarts
+// or is shared by several instances. Implementations must be safe for concurrent use.
+type QuotaStore interface {
+	// Consume adds n to the consumption of key in the period starting at period, unless that would exceed limit,
+	// and returns the resulting consumption and whether n was added.
+	Consume(ctx context.Context, key string, period time.Time, n, limit int64) (used int64, ok bool, err error)
+	// Used returns the consumption of key in the period starting at period.
+	Used(ctx context.Context, key string, period time.Time) (int64, error)
+}
+
+// quotaUsage is the consumption of a key in the period starting at the Unix time period.
+type quotaUsage struct {
+	period int64
+	used   int64
+}
+
+// memoryQuotaStore is the QuotaStore of a Quota without a Store, which does not survive restarts. It keeps only the
+// consumption of each key's latest period, which a later period replaces.
+type memoryQuotaStore struct {
+	used *syncmap.SyncMap[string, quotaUsage]
+}
+
+func (s *memoryQuotaStore) Consume(
+	_ context.Context,
+	key string,
+	period time.Time,
+	n, limit int64,
+) (used int64, ok bool, err error) {
+	p := period.Unix()
+	s.used.Update(
+		key, func(old quotaUsage, loaded bool) (quotaUsage, bool) {
+			switch {
+			case !loaded || old.period < p:
+				old = quotaUsage{period: p}
+			case old.period > p:
+				// the period ended while the request was admitted, and its consumption is no longer kept
+				used, ok = n, n <= limit
+				return old, true
+			}
+			if ok = old.used+n <= limit; ok {
+				old.used += n
+			}
+			used = old.used
+			return old, true
+		},
+	)
+	return used, ok, nil
+}
+
+func (s *memoryQuotaStore) Used(_ context.Context, key string, period time.Time) (int64, error) {
+	if u, ok := s.used.Load(key); ok && u.period == period.Unix() {
+		return u.used, nil
+	}
+	return 0, nil
+}
+
+// A Quota limits the requests for each key to Limit per calendar Period, such as the daily or monthly quotas of many
+// paid APIs, on top of the rate limits of a PerKeyRoundTripper.
+type Quota struct {
+	Limit  int64
+	Period QuotaPeriod
+	// Location is the time zone of the calendar; if nil, UTC is used.
+	Location *time.Location
+	// Store records the consumption of the quota; if nil, it is kept in memory and lost on restart.
+	Store QuotaStore
+	// MaxBanked, if positive, banks the allowance a key leaves unused in a period, up to MaxBanked requests, adding it
+	// to the key's Limit in the following periods, as the quotas of some APIs do, e.g. so that budget unused overnight
+	// can be spent the next morning. Requests consume the period's own allowance first. The bank of each key is kept
+	// in memory, from the first period in which the key is seen, even if the Store is persistent.
+	MaxBanked int64
+	// now returns the current time; it is replaced in tests.
+	now        func() time.Time
+	memoryOnce sync.Once
+	memory     *memoryQuotaStore
+	banksOnce  sync.Once
+	banks      *syncmap.SyncMap[string, *quotaBank]
+}
+
+// A quotaBank is the allowance banked by a key of a Quota for a period.
+type quotaBank struct {
+	mux     sync.Mutex
+	period  time.Time
+	balance int64
+}
+
+// NewQuota returns a Quota of limit requests per period, recorded in store, or in memory if store is nil.
+func NewQuota(limit int64, period QuotaPeriod, store QuotaStore) *Quota {
+	return &Quota{Limit: limit, Period: period, Store: store}
+}
+
+func (q *Quota) store() QuotaStore {
+	if q.Store != nil {
+		return q.Store
+	}
+	q.memoryOnce.Do(
+		func() {
+			q.memory = &memoryQuotaStore{used: syncmap.New[string, quotaUsage]()}
+		},
+	)
+	return q.memory
+}
+
+// period returns the start of the current period.
+func (q *Quota) period() time.Time {
+	now := time.Now
+	if q.now != nil {
+		now = q.now
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return q.Period.Start(now().In(loc))
+}
+
+// Banked returns the allowance banked by key for the current period, which is 0 unless MaxBanked is positive.
+func (q *Quota) Banked(ctx context.Context, key string) (int64, error) {
+	banked, err := q.banked(ctx, key, q.period())
+	if err != nil {
+		return 0, fmt.Errorf("ratelim: reading quota: %w", err)
+	}
+	return banked, nil
+}
+
+// banked returns the allowance banked by key for the period starting at period, first banking what was left unused
+// in the periods since the key's bank was last updated.
+func (q *Quota) banked(ctx context.Context, key string, period time.Time) (int64, error) {
+	if q.MaxBanked <= 0 {
+		return 0, nil
+	}
+	q.banksOnce.Do(
+		func() {
+			q.banks = syncmap.New[string, *quotaBank]()
+		},
+	)
+	bank := loadOrCompute[string](q.banks, key, newValue[quotaBank])
+	bank.mux.Lock()
+	defer bank.mux.Unlock()
+	if bank.period.IsZero() {
+		bank.period = period
+	}
+	if !bank.period.Before(period) {
+		return bank.balance, nil
+	}
+	used, err := q.store().Used(ctx, key, bank.period)
+	if err != nil {
+		return 0, err
+	}
+	balance := q.Limit + bank.balance - used
+	// each period without requests banks its whole allowance
+	for p := q.Period.End(bank.period); p.Before(period) && balance < q.MaxBanked; p = q.Period.End(p) {
+		balance += q.Limit
+	}
+	bank.period, bank.balance = period, min(max(balance, 0), q.MaxBanked)
+	return bank.balance, nil
+}
+
+// Consume consumes n requests of the quota of key for the current period, failing with a *QuotaExceededError if
+// fewer than n are left.
+func (q *Quota) Consume(ctx context.Context, key string, n int64) error {
+	period := q.period()
+	banked, err := q.banked(ctx, key, period)
+	if err != nil {
+		return fmt.Errorf("ratelim: consuming quota: %w", err)
+	}
+	limit := q.Limit + banked
+	_, ok, err := q.store().Consume(ctx, key, period, n, limit)
+	if err != nil {
+		return fmt.Errorf("ratelim: consuming quota: %w", err)
+	}
+	if !ok {
+		return &QuotaExceededError{Key: key, Limit: limit, Reset: q.Period.End(period)}
+	}
+	return nil
+}
+
+// check fails with a *QuotaExceededError if the quota of key for the current period is used up, without consuming it.
+func (q *Quota) check(ctx context.Context, key string) error {
+	period := q.period()
+	banked, err := q.banked(ctx, key, period)
+	if err != nil {
+		return fmt.Errorf("ratelim: reading quota: %w", err)
+	}
+	used, err := q.store().Used(ctx, key, period)
+	if err != nil {
+		return fmt.Errorf("ratelim: reading quota: %w", err)
+	}
+	if limit := q.Limit + banked; used >= limit {
+		return &QuotaExceededError{Key: key, Limit: limit, Reset: q.Period.End(period)}
+	}
+	return nil
+}
+
+// Remaining returns the number of requests left in the quota of key for the current period, including any banked
+// allowance.
+func (q *Quota) Remaining(ctx context.Context, key string) (int64, error) {
+	period := q.period()
+	banked, err := q.banked(ctx, key, period)
+	if err != nil {
+		return 0, fmt.Errorf("ratelim: reading quota: %w", err)
+	}
+	used, err := q.store().Used(ctx, key, period)
+	if err != nil {
+		return 0, fmt.Errorf("ratelim: reading quota: %w", err)
+	}
+	return max(q.Limit+banked-used, 0), nil
+}
+
+// checkBudgets fails a request for key early if its ByteBudget or Quota is used up, without counting it against either.
+// The budget of ctx is checked before, by RoundTrip.
+func (t *PerKeyRoundTripper[K]) checkBudgets(ctx context.Context, key K) error {
+	if t.ByteBudget != nil {
+		if err := t.ByteBudget.check(fmt.Sprint(key)); err != nil {
+			return err
+		}
+	}
+	if t.Quota != nil {
+		return t.Quota.check(ctx, fmt.Sprint(key))
+	}
+	return nil
+}
+
+// chargeBudgets counts a request for key, once every other limit has permitted it, against its ByteBudget, waiting
+// for the pace the budget sets, the budget of ctx, and then its Quota, which is consumed last since nothing can reject
+// the request after it, so that requests rejected or canceled before being sent use up no quota.
+func (t *PerKeyRoundTripper[K]) chargeBudgets(ctx context.Context, key K) error {
+	if t.ByteBudget != nil {
+		if err := t.ByteBudget.Wait(ctx, fmt.Sprint(key)); err != nil {
+			return err
+		}
+	}
+	if err := spendBudget(ctx); err != nil {
+		return err
+	}
+	if t.Quota != nil {
+		if err := t.Quota.Consume(ctx, fmt.Sprint(key), 1); err != nil {
+			refundBudget(ctx)
+			return err
+		}
+	}
+	return nil
+}
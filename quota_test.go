@@ -0,0 +1,181 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	at := time.Date(2024, 2, 29, 13, 45, 10, 0, time.UTC)
+	tests := []struct {
+		period     QuotaPeriod
+		start, end time.Time
+	}{
+		{QuotaHourly, time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 14, 0, 0, 0, time.UTC)},
+		{QuotaDaily, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{QuotaMonthly, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.period.String(), func(t *testing.T) {
+				start := tt.period.Start(at)
+				if !start.Equal(tt.start) {
+					t.Errorf("Start() = %v, want %v", start, tt.start)
+				}
+				if end := tt.period.End(start); !end.Equal(tt.end) {
+					t.Errorf("End() = %v, want %v", end, tt.end)
+				}
+			},
+		)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	q := NewQuota(3, QuotaMonthly, nil)
+	q.now = func() time.Time {
+		return now
+	}
+	if err := q.Consume(ctx, "a", 2); err != nil {
+		t.Fatal(err)
+	}
+	var exceeded *QuotaExceededError
+	if err := q.Consume(ctx, "a", 2); !errors.As(err, &exceeded) {
+		t.Fatalf("Consume() = %v, want a *QuotaExceededError", err)
+	}
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !exceeded.Reset.Equal(want) {
+		t.Errorf("Reset = %v, want %v", exceeded.Reset, want)
+	}
+	if remaining, err := q.Remaining(ctx, "b"); err != nil || remaining != 3 {
+		t.Errorf("Remaining(b) = %d, %v; want 3", remaining, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if remaining, err := q.Remaining(ctx, "a"); err != nil || remaining != 3 {
+		t.Errorf("Remaining(a) in the next month = %d, %v; want 3", remaining, err)
+	}
+}
+
+func TestMemoryQuotaStore(t *testing.T) {
+	ctx := context.Background()
+	s := &memoryQuotaStore{used: syncmap.New[string, quotaUsage]()}
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+	for _, key := range []string{"a", "b"} {
+		if _, ok, _ := s.Consume(ctx, key, first, 2, 3); !ok {
+			t.Fatalf("Consume(%s) in the first period failed", key)
+		}
+	}
+	if used, ok, _ := s.Consume(ctx, "a", second, 1, 3); !ok || used != 1 {
+		t.Errorf("Consume() in the second period = %d, %t; want 1, true", used, ok)
+	}
+	// the second period replaces the consumption of the first, without touching other keys
+	if n := s.used.Len(); n != 2 {
+		t.Errorf("kept %d entries, want 2", n)
+	}
+	if used, _ := s.Used(ctx, "a", first); used != 0 {
+		t.Errorf("Used() in the replaced period = %d, want 0", used)
+	}
+	if used, _ := s.Used(ctx, "b", first); used != 2 {
+		t.Errorf("Used(b) = %d, want 2", used)
+	}
+	// a request of the ended period is not counted against the current one
+	if _, ok, _ := s.Consume(ctx, "a", first, 2, 3); !ok {
+		t.Error("Consume() in the ended period failed")
+	}
+	if used, _ := s.Used(ctx, "a", second); used != 1 {
+		t.Errorf("Used() = %d after a request of the ended period, want 1", used)
+	}
+}
+
+func TestPerKeyRoundTripper_Quota(t *testing.T) {
+	transport := &flakyTransport{}
+	rt := NewPerKeyRoundTripper(1000, 10, TargetOrigin, transport)
+	rt.Quota = NewQuota(2, QuotaDaily, nil)
+	var exceeded *QuotaExceededError
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		_, err := rt.RoundTrip(req)
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && !errors.As(err, &exceeded) {
+			t.Errorf("RoundTrip() beyond the quota = %v, want a *QuotaExceededError", err)
+		}
+	}
+	if transport.requests != 2 {
+		t.Errorf("sent %d requests, want 2", transport.requests)
+	}
+}
+
+func TestPerKeyRoundTripper_QuotaRejected(t *testing.T) {
+	rt := NewPerKeyRoundTripper(1, 1, TargetOrigin, &flakyTransport{})
+	rt.Quota = NewQuota(2, QuotaDaily, nil)
+	key := "http://example.com"
+	rt.SetLimiterConfig(key, LimiterConfig{Limit: 1, Burst: 1, MaxWaiters: 1})
+	rt.limiter(key).Allow()
+	// a request canceled while waiting for the limiter, and one rejected for another waiting, are not sent
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Error("RoundTrip() succeeded with a context done before the limiter permits it")
+	}
+	loadOrCompute[string](rt.waiters, key, newValue[atomic.Int64]).Add(1)
+	req, _ = http.NewRequest(http.MethodGet, key, nil)
+	var tooMany *TooManyWaitersError
+	if _, err := rt.RoundTrip(req); !errors.As(err, &tooMany) {
+		t.Errorf("RoundTrip() error = %v, want a *TooManyWaitersError", err)
+	}
+	if remaining, err := rt.Quota.Remaining(context.Background(), key); err != nil || remaining != 2 {
+		t.Errorf("Remaining() = %d, %v after requests which were not sent, want 2", remaining, err)
+	}
+}
+
+func TestQuota_MaxBanked(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	q := NewQuota(3, QuotaDaily, nil)
+	q.MaxBanked = 4
+	q.now = func() time.Time {
+		return now
+	}
+	steps := []struct {
+		days        int
+		consume     int64
+		wantBanked  int64
+		wantExceeds bool
+	}{
+		{days: 0, consume: 1, wantBanked: 0},
+		// 2 left unused yesterday
+		{days: 1, consume: 5, wantBanked: 2},
+		{days: 0, consume: 1, wantBanked: 2, wantExceeds: true},
+		{days: 1, consume: 3, wantBanked: 0},
+		// 3 idle days bank up to the maximum
+		{days: 4, consume: 7, wantBanked: 4},
+		{days: 1, consume: 4, wantBanked: 0, wantExceeds: true},
+	}
+	for i, step := range steps {
+		now = now.AddDate(0, 0, step.days)
+		if banked, err := q.Banked(ctx, "a"); err != nil || banked != step.wantBanked {
+			t.Fatalf("step %d: Banked() = %d, %v; want %d", i, banked, err, step.wantBanked)
+		}
+		var exceeded *QuotaExceededError
+		err := q.Consume(ctx, "a", step.consume)
+		if step.wantExceeds && !errors.As(err, &exceeded) {
+			t.Fatalf("step %d: Consume() = %v, want a *QuotaExceededError", i, err)
+		} else if !step.wantExceeds && err != nil {
+			t.Fatalf("step %d: Consume() error = %v", i, err)
+		}
+	}
+	if remaining, err := q.Remaining(ctx, "a"); err != nil || remaining != 3 {
+		t.Errorf("Remaining() = %d, %v; want 3", remaining, err)
+	}
+}
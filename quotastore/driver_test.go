@@ -0,0 +1,155 @@
+package quotastore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// fakeDB is an in-memory stand-in for a database, implementing the statements of the stores with a map, since no SQL
+// driver is available to the tests. It checks the Go side of the stores, not their SQL.
+type fakeDB struct {
+	mux  sync.Mutex
+	used map[fakeRow]int64
+	// statements maps the text of each statement the stores may run to its implementation.
+	statements map[string]fakeStatement
+}
+
+// A fakeStatement implements a statement, returning the rows of a query or the number of rows affected by an exec.
+type fakeStatement func(db *fakeDB, args []driver.Value) ([][]driver.Value, int64)
+
+type fakeRow struct {
+	key    string
+	period int64
+}
+
+var fakeDrivers atomic.Int64
+
+// openFake returns a *sql.DB backed by a new fakeDB with the given statements.
+func openFake(statements map[string]fakeStatement) *sql.DB {
+	db := &fakeDB{used: make(map[fakeRow]int64), statements: statements}
+	name := fmt.Sprintf("fake-%d", fakeDrivers.Add(1))
+	sql.Register(name, &fakeDriver{db: db})
+	conn, err := sql.Open(name, "")
+	if err != nil {
+		panic(err)
+	}
+	return conn
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	f, ok := c.db.statements[query]
+	if !ok {
+		return nil, fmt.Errorf("fake: unexpected statement %q", query)
+	}
+	return &fakeStmt{db: c.db, f: f}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("fake: transactions are not supported")
+}
+
+type fakeStmt struct {
+	db *fakeDB
+	f  fakeStatement
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) run(args []driver.Value) ([][]driver.Value, int64) {
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+	return s.f(s.db, args)
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, affected := s.run(args)
+	return driver.RowsAffected(affected), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _ := s.run(args)
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"used"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeStatements implements the statements of a dialect: creating the table, consuming with an upsert, reading the
+// usage and pruning.
+func fakeStatements(d dialect) map[string]fakeStatement {
+	return map[string]fakeStatement{
+		d.schema: func(*fakeDB, []driver.Value) ([][]driver.Value, int64) {
+			return nil, 0
+		},
+		d.consume: func(db *fakeDB, args []driver.Value) ([][]driver.Value, int64) {
+			row := fakeRow{key: args[0].(string), period: args[1].(int64)}
+			n, limit := args[2].(int64), args[3].(int64)
+			if db.used[row]+n > limit {
+				return nil, 0
+			}
+			db.used[row] += n
+			return [][]driver.Value{{db.used[row]}}, 1
+		},
+		d.used: func(db *fakeDB, args []driver.Value) ([][]driver.Value, int64) {
+			row := fakeRow{key: args[0].(string), period: args[1].(int64)}
+			if used, ok := db.used[row]; ok {
+				return [][]driver.Value{{used}}, 0
+			}
+			return nil, 0
+		},
+		d.prune: func(db *fakeDB, args []driver.Value) ([][]driver.Value, int64) {
+			var deleted int64
+			for row := range db.used {
+				if row.period < args[0].(int64) {
+					delete(db.used, row)
+					deleted++
+				}
+			}
+			return nil, deleted
+		},
+	}
+}
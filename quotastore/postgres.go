@@ -0,0 +1,40 @@
+package quotastore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+var postgresDialect = dialect{
+	schema: `CREATE TABLE IF NOT EXISTS ratelim_quota (
+	key TEXT NOT NULL,
+	period BIGINT NOT NULL,
+	used BIGINT NOT NULL,
+	PRIMARY KEY (key, period)
+)`,
+	consume: `INSERT INTO ratelim_quota AS q (key, period, used) VALUES ($1, $2, $3)
+ON CONFLICT (key, period) DO UPDATE SET used = q.used + EXCLUDED.used WHERE q.used + EXCLUDED.used <= $4
+RETURNING q.used`,
+	used:  `SELECT used FROM ratelim_quota WHERE key = $1 AND period = $2`,
+	prune: `DELETE FROM ratelim_quota WHERE period < $1`,
+}
+
+// Postgres is a ratelim.QuotaStore keeping consumption in a PostgreSQL database, so that a fleet of instances sharing
+// the database enforces a quota together. Each consumption is a single atomic upsert, which takes the row lock of its
+// key and period, so no advisory locks or transactions are needed. It requires PostgreSQL 9.5 or later.
+type Postgres struct {
+	store
+}
+
+var _ ratelim.QuotaStore = (*Postgres)(nil)
+
+// NewPostgres returns a Postgres store using db, creating its ratelim_quota table if it does not exist.
+func NewPostgres(ctx context.Context, db *sql.DB) (*Postgres, error) {
+	s, err := newStore(ctx, db, postgresDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{store: s}, nil
+}
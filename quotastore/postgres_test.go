@@ -0,0 +1,48 @@
+package quotastore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostgres(t *testing.T) {
+	ctx := context.Background()
+	db := openFake(fakeStatements(postgresDialect))
+	defer db.Close()
+	store, err := NewPostgres(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// concurrent consumers, as on several instances, never exceed the limit together
+	var (
+		wg       sync.WaitGroup
+		mux      sync.Mutex
+		accepted int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := store.Consume(ctx, "api", month, 1, 8)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mux.Lock()
+				accepted++
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 8 {
+		t.Errorf("accepted %d consumptions, want 8", accepted)
+	}
+	if used, err := store.Used(ctx, "api", month); err != nil || used != 8 {
+		t.Errorf("Used() = %d, %v; want 8", used, err)
+	}
+}
@@ -0,0 +1,41 @@
+package quotastore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+// sqliteDialect requires SQLite 3.35 or later, for RETURNING.
+var sqliteDialect = dialect{
+	schema: `CREATE TABLE IF NOT EXISTS ratelim_quota (
+	key TEXT NOT NULL,
+	period INTEGER NOT NULL,
+	used INTEGER NOT NULL,
+	PRIMARY KEY (key, period)
+)`,
+	consume: `INSERT INTO ratelim_quota (key, period, used) VALUES (?, ?, ?)
+ON CONFLICT (key, period) DO UPDATE SET used = used + excluded.used WHERE used + excluded.used <= ?
+RETURNING used`,
+	used:  `SELECT used FROM ratelim_quota WHERE key = ? AND period = ?`,
+	prune: `DELETE FROM ratelim_quota WHERE period < ?`,
+}
+
+// SQLite is a ratelim.QuotaStore persisting consumption in a SQLite database, so that the daily or monthly quotas of
+// single-instance tools, such as CLIs and desktop apps, survive restarts without any external service. It requires
+// SQLite 3.35 or later.
+type SQLite struct {
+	store
+}
+
+var _ ratelim.QuotaStore = (*SQLite)(nil)
+
+// NewSQLite returns a SQLite store using db, creating its ratelim_quota table if it does not exist.
+func NewSQLite(ctx context.Context, db *sql.DB) (*SQLite, error) {
+	s, err := newStore(ctx, db, sqliteDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{store: s}, nil
+}
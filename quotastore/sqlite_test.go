@@ -0,0 +1,58 @@
+package quotastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim"
+)
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openFake(fakeStatements(sqliteDialect))
+	defer db.Close()
+	store, err := NewSQLite(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, want := range []bool{true, true, false} {
+		if _, ok, err := store.Consume(ctx, "api", day, 2, 5); err != nil || ok != want {
+			t.Errorf("Consume() #%d = %v, %v; want %v", i, ok, err, want)
+		}
+	}
+	if used, ok, err := store.Consume(ctx, "api", day, 10, 5); err != nil || ok || used != 4 {
+		t.Errorf("Consume() beyond the limit = %d, %v, %v; want 4, false", used, ok, err)
+	}
+	if used, err := store.Used(ctx, "api", day); err != nil || used != 4 {
+		t.Errorf("Used() = %d, %v; want 4", used, err)
+	}
+	if used, err := store.Used(ctx, "api", day.AddDate(0, 0, 1)); err != nil || used != 0 {
+		t.Errorf("Used() of the next day = %d, %v; want 0", used, err)
+	}
+	if n, err := store.Prune(ctx, day.AddDate(0, 0, 1)); err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v; want 1", n, err)
+	}
+}
+
+func TestSQLite_quota(t *testing.T) {
+	ctx := context.Background()
+	db := openFake(fakeStatements(sqliteDialect))
+	defer db.Close()
+	store, err := NewSQLite(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second Quota on the same database, as after a restart, sees the consumption of the first
+	if err := ratelim.NewQuota(2, ratelim.QuotaDaily, store).Consume(ctx, "api", 2); err != nil {
+		t.Fatal(err)
+	}
+	q := ratelim.NewQuota(2, ratelim.QuotaDaily, store)
+	var exceeded *ratelim.QuotaExceededError
+	if err := q.Consume(ctx, "api", 1); !errors.As(err, &exceeded) {
+		t.Errorf("Consume() = %v, want a *QuotaExceededError", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package quotastore provides implementations of ratelim.QuotaStore backed by SQL databases through database/sql. The
+// caller opens the database with a driver of its choice, so that this package adds no dependencies.
+package quotastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// dialect holds the statements of a store in the SQL dialect of its database. Periods are stored as Unix times.
+type dialect struct {
+	schema  string
+	consume string
+	used    string
+	prune   string
+}
+
+// store implements ratelim.QuotaStore with the statements of a dialect.
+type store struct {
+	db *sql.DB
+	dialect
+}
+
+func newStore(ctx context.Context, db *sql.DB, d dialect) (store, error) {
+	if _, err := db.ExecContext(ctx, d.schema); err != nil {
+		return store{}, err
+	}
+	return store{db: db, dialect: d}, nil
+}
+
+// Consume implements ratelim.QuotaStore with a single upsert, which only adds n if the result is within limit, so
+// that concurrent consumers, in this process or others, cannot exceed the limit together.
+func (s store) Consume(
+	ctx context.Context,
+	key string,
+	period time.Time,
+	n, limit int64,
+) (used int64, ok bool, err error) {
+	if n > limit {
+		used, err = s.Used(ctx, key, period)
+		return used, false, err
+	}
+	err = s.db.QueryRowContext(ctx, s.consume, key, period.Unix(), n, limit).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		used, err = s.Used(ctx, key, period)
+		return used, false, err
+	}
+	return used, err == nil, err
+}
+
+// Used implements ratelim.QuotaStore.
+func (s store) Used(ctx context.Context, key string, period time.Time) (int64, error) {
+	var used int64
+	err := s.db.QueryRowContext(ctx, s.used, key, period.Unix()).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return used, err
+}
+
+// Prune deletes the consumption recorded for periods starting before the given time, and returns the number of rows
+// deleted.
+func (s store) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.prune, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
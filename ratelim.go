@@ -1,38 +1,24 @@
 package ratelim
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
-	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
 
+	"github.com/milo-minderbinder/ratelim/semaphore"
 	"github.com/milo-minderbinder/ratelim/syncmap"
 )
 
 func Origin(url *url.URL) string {
-	// apply normalization steps which are missing/incomplete in url.URL
-	// (ref: https://www.rfc-editor.org/rfc/rfc9110#name-uri-origin)
-	// first, while scheme is automatically lower-cased, host(name) is not
-	host := strings.ToLower(url.Hostname())
-	// next, strip any leading zeros from port number
-	port := strings.TrimLeft(url.Port(), "0")
-	if port == "" {
-		return fmt.Sprintf("%s://%s", url.Scheme, host)
-	}
-	if defaultPort, ok := map[string]string{
-		"http":  "80",
-		"https": "443",
-	}[url.Scheme]; ok && port == defaultPort {
-		return fmt.Sprintf("%s://%s", url.Scheme, host)
-	}
-	return fmt.Sprintf("%s://%s:%s", url.Scheme, host, port)
+	return OriginWith(url, OriginOptions{})
 }
 
 func TargetOrigin(r *http.Request) string {
@@ -54,106 +40,556 @@ func defaultTransport() *http.Transport {
 	}
 }
 
+// A Map maps keys to their rate.Limiter. It is sharded by key, so that looking up the limiters of distinct keys from
+// many goroutines at once does not contend for a single lock.
 type Map[K comparable] struct {
-	*syncmap.SyncMap[K, *rate.Limiter]
+	*syncmap.ShardedMap[K, *rate.Limiter]
 }
 
 func NewMap[K comparable]() *Map[K] {
 	return &Map[K]{
-		SyncMap: syncmap.New[K, *rate.Limiter](),
+		ShardedMap: syncmap.NewSharded[K, *rate.Limiter](0),
 	}
 }
 
+// loadOrComputer is implemented by syncmap.SyncMap and syncmap.ShardedMap.
+type loadOrComputer[K comparable, V any] interface {
+	LoadOrCompute(key K, f func() V) (V, bool)
+}
+
+// loadOrCompute returns the value stored in m for key, if any; otherwise it stores and returns the value returned by
+// f, which is only called the first time key is seen, so that values are not constructed on every lookup.
+func loadOrCompute[K comparable, V any](m loadOrComputer[K, V], key K, f func() V) V {
+	value, _ := m.LoadOrCompute(key, f)
+	return value
+}
+
+// newValue returns a pointer to a new zero value of type T, for use with loadOrCompute.
+func newValue[T any]() *T {
+	return new(T)
+}
+
 // A PerKeyRoundTripper rate limits each request sent through RoundTrip. Requests are grouped by Key and mapped to a
-// rate.Limiter. If no rate.Limiter exists for a given Key value yet, one is instantiated with the default rate.Limit
-// and burst as returned by LimiterDefaults.
+// rate.Limiter by its embedded PerKeyLimiter. If no rate.Limiter exists for a given Key value yet, one is instantiated
+// with the rate.Limit and burst returned by LimiterConfig, which are the defaults returned by LimiterDefaults unless
+// overridden for the key by SetLimiterConfig or LimiterConfigFunc.
 //
 // In this way, requests can be rate limited per host, for example, or whatever grouping makes sense for
 // a given use case.
 type PerKeyRoundTripper[K comparable] struct {
-	defaultLimit rate.Limit
-	defaultBurst int
+	*PerKeyLimiter[K]
 	keyFunc      func(*http.Request) K
-	limiters     *Map[K]
-	mux          sync.RWMutex
+	holds        *syncmap.SyncMap[K, time.Time]
+	discovered   *syncmap.SyncMap[K, *discoveryState]
+	waitQueues   *syncmap.SyncMap[K, *waitQueue]
+	waiters      *syncmap.SyncMap[K, *atomic.Int64]
+	softLimiters *Map[K]
+	softExceeded *syncmap.SyncMap[K, *atomic.Int64]
+	smoothers    *Map[K]
+	transports   *syncmap.SyncMap[K, http.RoundTripper]
+	retryBudgets *Map[K]
+	mode         atomic.Int32
+	pause        atomic.Pointer[pause]
+	tagConfigs   *syncmap.SyncMap[string, LimiterConfig]
+	tagLimiters  *Map[string]
+	costs        *syncmap.SyncMap[K, *fractionalCost]
+	streams      *semaphore.Keyed[K]
+	http2Streams *semaphore.Keyed[K]
+	http2        *syncmap.SyncMap[K, bool]
+	policies     *syncmap.SyncMap[K, *tunedPolicies]
+	spikes       *syncmap.SyncMap[K, *spikeState]
+	turns        *semaphore.Keyed[K]
+	lastSeen     *syncmap.SyncMap[K, *atomic.Int64]
+	keyTable     keyTable[K]
+	// registeredName is the name the transport was first registered under with a Manager
+	registeredName atomic.Pointer[string]
+	sweeper        sweeper
 	http.RoundTripper
 	Logger *log.Logger
+	// FallbackKey is the key of requests for which the key func panics, e.g. on a malformed request, so that they
+	// share its limiter rather than crash the program. The panic is reported to OnKeyError.
+	FallbackKey K
+	// OnKeyError, if non-nil, is called with each request whose key could not be derived, and a *KeyFuncPanicError
+	// reporting why; otherwise, the error is logged to the Logger, if any.
+	OnKeyError func(req *http.Request, err error)
+	// Adapter, if non-nil, adapts the rate.Limiter of each key according to the responses received for that key.
+	Adapter *Adapter
+	// HeaderParser, if non-nil, is called with the key and headers of each response. When the returned status reports
+	// that the key's quota is exhausted, requests for the key are held until the status' Reset time.
+	HeaderParser func(key K, header http.Header) (status RateLimitStatus, ok bool)
+	// TunePolicies, if true, replaces the limits of each key with the quota policies advertised in the headers of its
+	// responses (see ParseRateLimitPolicy), whenever they change, so that requests stay within every window of a
+	// server's layered policies, e.g. "10;w=1, 1000;w=3600", without configuring them. The policy with the shortest
+	// window sets the Limit and Burst of the key's config, and the others are enforced as by a WindowLimiter.
+	TunePolicies bool
+	// Discovery, if non-nil, enables the discovery of each key's limits by a probe request sent before the first
+	// request for the key.
+	Discovery *Discovery
+	// ResponseCost, if non-nil, is called with each response to determine the total cost of its request in tokens,
+	// for APIs which meter requests by a cost reported in the response. Since one token is consumed before sending
+	// each request, any cost beyond that is charged to the key's rate.Limiter after the response is received.
+	ResponseCost func(resp *http.Response) int
+	// Quota, if non-nil, limits the requests for each key per calendar period, identifying keys by their default
+	// format; requests beyond it fail with a *QuotaExceededError without being sent. A request is only counted against
+	// it once every other limit has permitted it, so that requests rejected or canceled before being sent use none.
+	Quota *Quota
+	// ByteBudget, if non-nil, limits the response body bytes received for each key per calendar period, identifying
+	// keys by their default format as Quota does; requests are slowed as the budget nears exhaustion, if configured,
+	// and fail with a *ByteBudgetExceededError without being sent once it is exhausted.
+	ByteBudget *ByteBudget
+	// Exempt, if non-nil, is called with each request, and the requests for which it returns true are sent at once, as
+	// in ModeUnlimited, e.g. requests with a sampled trace (see SampledTrace and BaggageMember). They are still charged
+	// to their key's limiter, delaying the requests after them, so that the rate of requests sent remains within its
+	// limit over time.
+	Exempt func(req *http.Request) bool
+	// Streaming, if non-nil, identifies streaming requests, such as Server-Sent Events (see AcceptsEventStream) or
+	// long polls, which hold their connection open long after being sent. They are charged to their key's limiter once,
+	// when sent, whatever their ResponseCost, or if their key's config sets MaxStreams, they are limited to that many
+	// open at once instead. The time from their response until its body is closed is tracked in the key's Stats.
+	Streaming func(req *http.Request) bool
+	// WeightedConcurrency, if true, makes each request take as many slots of its key's MaxStreams and MaxHTTP2Streams
+	// as its cost (see WithCost and RequestCost), rounded up, rather than one, so that a heavy request counts as
+	// several light ones in flight, as it does against the key's rate limit. A request never takes more slots than its
+	// key has.
+	WeightedConcurrency bool
+	// TranslateRateLimitHeaders, if true, replaces the rate limit headers of each response, such as RateLimit-* and
+	// X-RateLimit-*, with RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers reporting whichever of the
+	// upstream's quota, as parsed by HeaderParser or ParseRateLimitHeaders, and the key's limiter permits the fewest
+	// requests, for proxies whose callers should see their remaining budget through both. Retry-After is left
+	// unchanged. See CopyRateLimitHeaders for handlers which send requests upstream themselves.
+	TranslateRateLimitHeaders bool
+	// Synthesize429, if true, makes requests rejected by the transport's limits without being sent, such as with a
+	// *TooManyWaitersError or a *QuotaExceededError, return a synthesized response with status 429 Too Many Requests
+	// and the error's message as body, rather than the error, so that clients which already handle 429 responses need
+	// no new error paths. Its Retry-After header is set to when the request could be retried, if known.
+	Synthesize429 bool
+	// Audit, if non-nil, records the decision taken for each request.
+	Audit *AuditLog
+	// RequestCost, if non-nil, is called with each request without a cost set by WithCost to determine its cost in
+	// tokens, which may be fractional, e.g. 0.5 for HEAD requests; otherwise, each request costs 1 token.
+	RequestCost func(req *http.Request) float64
+	// CostEstimator, if non-nil, replaces RequestCost and ResponseCost: it estimates the cost of each request without
+	// a cost set by WithCost before it is sent, and settles the actual cost of each request once its response is
+	// received, charging any difference to the key's rate.Limiter, or crediting it to the key's later requests. As
+	// with ResponseCost, the cost of streaming requests is not settled.
+	CostEstimator CostEstimator
+	// PriorityScheduling, if true, queues the requests waiting for each key's rate.Limiter so that they acquire tokens
+	// in order of the priority set on their context by WithPriority, and in order of arrival within a priority (as
+	// for keys whose LimiterConfig is Ordered).
+	PriorityScheduling bool
+	// GlobalLimiter, if non-nil, is a limiter shared by all keys, which each request must also wait for after being
+	// permitted by its key's rate.Limiter.
+	GlobalLimiter *FairLimiter[K]
+	// OnSoftLimitExceeded, if non-nil, is called with each request which exceeds the SoftLimit of its key.
+	OnSoftLimitExceeded func(key K, req *http.Request)
+	// HedgeDelay, if positive, enables hedging of requests with safe methods and no body: if a response has not been
+	// received within HedgeDelay, and the key's rate.Limiter has a spare token at that moment, a second attempt is
+	// sent, and the first response to arrive is used.
+	HedgeDelay time.Duration
+	// TransportFunc, if non-nil, is called with each key the first time it is seen to create the http.RoundTripper
+	// used to send its requests, so that connection pools can be tuned per key; if it returns nil, the underlying
+	// http.RoundTripper is used for the key.
+	TransportFunc func(key K) http.RoundTripper
+	// ChargeRedirectsToOrigin, if true, charges each request sent while following a redirect to the key of the
+	// original request, rather than its own, so that a redirect chain consumes the quota of the key it was started for.
+	ChargeRedirectsToOrigin bool
+	// Retry, if non-nil, retries requests which fail with a transient transport error.
+	Retry *RetryPolicy
+	// RequestIDHeader, if set, names a request header, such as X-Request-ID or traceparent, whose value is included
+	// in the log line of each request, to correlate it with the logs of the application and server.
+	RequestIDHeader string
+	// LatencyController, if non-nil, adapts the rate.Limiter of each key to the latency and error rate of its
+	// responses.
+	LatencyController *LatencyController[K]
+	// Shedder, if non-nil, rejects a fraction of the requests for each key while the error rate of its responses is
+	// high, failing them with a *SheddingError.
+	Shedder *Shedder[K]
+	// Breaker, if non-nil, rejects the requests for each key with a *BreakerOpenError while its circuit is open.
+	Breaker *Breaker[K]
+	// TagHeader, if set, names a request header whose value tags the request as WithTag does, unless its context is
+	// already tagged.
+	TagHeader string
+	// Trace, if set, marks each wait for a limiter with a runtime/trace region while an execution trace is being
+	// recorded.
+	Trace bool
+	// ProfileLabels, if set, attaches pprof labels naming the key and the transport's Name to each goroutine while it
+	// waits for a limiter.
+	ProfileLabels bool
+	// Name, if set, identifies the transport in profile labels and debug pages; otherwise, the name it is registered
+	// under with a Manager does.
+	Name string
+	// IdleTimeout, if positive, is how long a key may have no requests before the state kept for it is evicted by
+	// EvictIdle, once its rate.Limiter has refilled. The Stats of keys, the limits learned from their responses and
+	// the states of the Breaker, Shedder and LatencyController are kept, so that they outlive idle periods, unless
+	// MaxKeys is also set; only then does the memory used by keys seen once not grow without bound.
+	IdleTimeout time.Duration
+	// Schedule, if non-nil, varies the default LimiterConfig by time of day, as RunSchedule does.
+	Schedule *Schedule
+	// SweepInterval is the interval at which idle keys are evicted; if not positive, 1 minute is used. Idle keys are
+	// evicted, and the Schedule applied, by a single background goroutine, which is started by the first request once
+	// IdleTimeout or Schedule is set, and stopped by Close.
+	SweepInterval time.Duration
+	// MaxKeys, if positive, bounds the number of keys whose state, such as their limiters, Stats and Breaker
+	// circuits, is kept at once, for keys of untrusted cardinality, such as client IP addresses on the public
+	// internet. Requests for new keys beyond it are handled according to KeyOverflow, and its enforcement is reported
+	// by KeyLimitStats and WriteMetrics. The configs set for keys are kept regardless. Keys evicted by EvictIdle make
+	// room for new keys.
+	MaxKeys     int
+	KeyOverflow KeyOverflowPolicy
+	// OverflowKey is the key under which the requests for new keys beyond MaxKeys are limited under CollapseNewKeys;
+	// it does not count against MaxKeys.
+	OverflowKey K
 }
 
+// An Option configures a PerKeyRoundTripper created by NewPerKeyRoundTripper.
+type Option[K comparable] func(*PerKeyRoundTripper[K])
+
 // NewPerKeyRoundTripper creates a new PerKeyRoundTripper. The defaultLimit and defaultBurst determine the rate.Limit
 // and burst parameters used to create a new rate.Limiter when none is mapped yet to a given Key value. The keyFunc is
 // the function used to derive the Key used to map any given request to a particular rate.Limiter. The roundTripper
 // parameter sets the underlying http.RoundTripper used to send each request after applying the rate limiter; if nil, a
-// new *http.Transport is created with defaults based on http.DefaultTransport.
+// new *http.Transport is created with defaults based on http.DefaultTransport. The opts are applied in order to the
+// new PerKeyRoundTripper before it is returned.
 func NewPerKeyRoundTripper[K comparable](
 	defaultLimit rate.Limit,
 	defaultBurst int,
 	keyFunc func(*http.Request) K,
 	roundTripper http.RoundTripper,
+	opts ...Option[K],
 ) *PerKeyRoundTripper[K] {
 	if roundTripper == nil {
 		roundTripper = defaultTransport()
 	}
-	return &PerKeyRoundTripper[K]{
-		defaultLimit: defaultLimit,
-		defaultBurst: defaultBurst,
-		keyFunc:      keyFunc,
-		limiters:     NewMap[K](),
-		RoundTripper: roundTripper,
+	t := &PerKeyRoundTripper[K]{
+		PerKeyLimiter: NewPerKeyLimiter[K](defaultLimit, defaultBurst),
+		keyFunc:       keyFunc,
+		holds:         syncmap.New[K, time.Time](),
+		discovered:    syncmap.New[K, *discoveryState](),
+		waitQueues:    syncmap.New[K, *waitQueue](),
+		waiters:       syncmap.New[K, *atomic.Int64](),
+		softLimiters:  NewMap[K](),
+		softExceeded:  syncmap.New[K, *atomic.Int64](),
+		smoothers:     NewMap[K](),
+		transports:    syncmap.New[K, http.RoundTripper](),
+		retryBudgets:  NewMap[K](),
+		tagConfigs:    syncmap.New[string, LimiterConfig](),
+		tagLimiters:   NewMap[string](),
+		costs:         syncmap.New[K, *fractionalCost](),
+		http2:         syncmap.New[K, bool](),
+		policies:      syncmap.New[K, *tunedPolicies](),
+		spikes:        syncmap.New[K, *spikeState](),
+		lastSeen:      syncmap.New[K, *atomic.Int64](),
+		RoundTripper:  roundTripper,
 	}
+	t.streams = semaphore.NewKeyed(
+		func(key K) int64 {
+			return int64(max(t.LimiterConfig(key).MaxStreams, 1))
+		},
+	)
+	t.turns = semaphore.NewKeyed(
+		func(K) int64 {
+			return 1
+		},
+	)
+	t.http2Streams = semaphore.NewKeyed(
+		func(key K) int64 {
+			return int64(max(t.LimiterConfig(key).MaxHTTP2Streams, 1))
+		},
+	)
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
-func (t *PerKeyRoundTripper[K]) LimiterDefaults() (limit rate.Limit, burst int) {
-	t.mux.RLock()
-	defer t.mux.RUnlock()
-	return t.defaultLimit, t.defaultBurst
-}
-
-func (t *PerKeyRoundTripper[K]) SetLimiterDefaults(limit rate.Limit, burst int) {
-	t.mux.Lock()
-	defer t.mux.Unlock()
-	t.defaultLimit = limit
-	t.defaultBurst = burst
-}
-
+// Key returns the key of req, as derived by the transport's key func, or the FallbackKey if the key func panics.
 func (t *PerKeyRoundTripper[K]) Key(req *http.Request) K {
-	return t.keyFunc(req)
+	return t.safeKey(req)
 }
 
 func (t *PerKeyRoundTripper[K]) Limiter(req *http.Request) *rate.Limiter {
-	limiter, _ := t.limiters.LoadOrStore(t.Key(req), rate.NewLimiter(t.LimiterDefaults()))
-	return limiter
-}
-
-func (t *PerKeyRoundTripper[K]) Limiters() *Map[K] {
-	return t.limiters
+	return t.limiter(t.Key(req))
 }
 
-func (t *PerKeyRoundTripper[K]) RoundTrip(req *http.Request) (*http.Response, error) {
-	limiter := t.Limiter(req)
+func (t *PerKeyRoundTripper[K]) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	t.startSweeper()
+	key, hops, chain := t.roundTripKey(req)
+	var (
+		permitted bool
+		waited    time.Duration
+	)
+	if t.Synthesize429 {
+		defer func() {
+			if err == nil || permitted {
+				return
+			}
+			if synthesized, ok := t.tooManyRequests(req, err); ok {
+				resp, err = synthesized, nil
+			}
+		}()
+	}
+	if t.Audit != nil {
+		defer func() {
+			t.audit(key, req, permitted, waited, err)
+		}()
+	}
+	reservation, reserved := claimReservation(req.Context())
+	if reserved {
+		// the token reserved for a request which is not sent is returned to the limiter, whichever limit rejects it
+		defer func() {
+			if !permitted {
+				reservation.Cancel()
+			}
+		}()
+	}
+	if key, err = t.admitKey(key); err != nil {
+		return nil, err
+	}
+	t.touch(key)
+	mode := t.Mode()
+	if mode == ModeReject {
+		return nil, ErrRejected
+	}
+	if err := checkBudget(req.Context()); err != nil {
+		return nil, err
+	}
+	if mode == ModeUnlimited {
+		if err := spendBudget(req.Context()); err != nil {
+			return nil, err
+		}
+		if reserved {
+			// the request is not limited, so it needs no reserved token
+			reservation.Cancel()
+		}
+		permitted = true
+		return t.transport(key).RoundTrip(req)
+	}
+	if t.exempt(req) {
+		if err := spendBudget(req.Context()); err != nil {
+			return nil, err
+		}
+		permitted = true
+		if !reserved {
+			charge(t.limiter(key), 1)
+		}
+		return t.transport(key).RoundTrip(req)
+	}
+	req = t.tagRequest(req)
+	if err := t.checkBudgets(req.Context(), key); err != nil {
+		return nil, err
+	}
+	if t.Discovery != nil {
+		t.discover(req, key)
+	}
+	limiter := t.limiter(key)
 	start := time.Now()
-	if err := limiter.Wait(req.Context()); err != nil {
+	cfg := t.LimiterConfig(key)
+	if err := t.arrestSpike(key, cfg); err != nil {
+		return nil, err
+	}
+	if t.Shedder != nil {
+		if err := t.Shedder.Allow(key); err != nil {
+			return nil, err
+		}
+	}
+	var permit *BreakerPermit[K]
+	if t.Breaker != nil {
+		if permit, err = t.Breaker.Allow(key, req); err != nil {
+			return nil, err
+		}
+	}
+	t.checkSoftLimit(key, cfg, req)
+	cost, tokens := t.requestTokens(req, key)
+	streaming := t.streaming(req)
+	capped := streaming && cfg.MaxStreams > 0
+	if capped {
+		tokens = 0
+	}
+	endTrace := t.traceWait(req.Context(), key)
+	var release, endTurn func()
+	err = t.withProfileLabels(
+		req.Context(), key, func() (err error) {
+			if endTurn, err = t.serialize(req.Context(), key, cfg); err != nil {
+				return err
+			}
+			if err := t.waitN(req.Context(), key, cfg, limiter, tokens, reservation); err != nil {
+				return err
+			}
+			release, err = t.acquireSlots(req.Context(), key, cfg, capped, t.slotWeight(cost))
+			return err
+		},
+	)
+	endTrace()
+	release = joinReleases(release, endTurn)
+	if err == nil {
+		err = t.chargeBudgets(req.Context(), key)
+	}
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		if permit != nil {
+			permit.Cancel()
+		}
 		return nil, err
 	}
 	wait := time.Since(start)
+	permitted, waited = true, wait
 	defer func() {
 		logger := t.Logger
 		if logger == nil || logger.Writer() == io.Discard {
 			return
 		}
 		total := time.Since(start)
+		extra := ""
+		if hops > 0 {
+			extra = fmt.Sprintf("\thops: %d\tvia: %s", hops, formatRedirectChain(chain))
+		}
+		if id := t.RequestID(req); id != "" {
+			extra += "\tid: " + id
+		}
 		logger.Printf(
-			"%T - key: %v\twait: %dms\tresp: %dms\ttotal: %dms\treq: %s %s",
+			"%T - key: %v\twait: %dms\tresp: %dms\ttotal: %dms\treq: %s %s%s",
 			t,
-			t.Key(req),
+			key,
 			wait.Milliseconds(),
 			(total - wait).Milliseconds(),
 			total.Milliseconds(),
 			req.Method,
 			req.URL.String(),
+			extra,
 		)
 	}()
-	return t.RoundTripper.RoundTrip(req)
+	t.recordRequest(key, wait, hops, chain)
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		req, cancel = withTimeout(req, cfg.Timeout)
+		defer func() {
+			cancelWithBody(resp, cancel)
+		}()
+	}
+	sent := time.Now()
+	resp, err = t.sendWithRetries(req, key, cfg, limiter)
+	t.learnProtocol(key, cfg, resp)
+	if streaming {
+		t.trackStream(key, resp)
+	}
+	releaseOnClose(resp, release)
+	if t.ByteBudget != nil {
+		t.ByteBudget.countBytes(fmt.Sprint(key), resp)
+	}
+	if t.LatencyController != nil || t.Shedder != nil {
+		failed := StatusClassifier(resp, err) == OutcomeError
+		if t.LatencyController != nil {
+			t.LatencyController.Observe(key, limiter, cfg.Limit, time.Since(sent), failed)
+		}
+		if t.Shedder != nil {
+			t.Shedder.Observe(key, failed)
+		}
+	}
+	if permit != nil {
+		permit.Done(t.Breaker.Classify(resp, err) == OutcomeError)
+	}
+	if t.Adapter != nil {
+		t.Adapter.Adapt(limiter, cfg.Limit, t.Adapter.Classify(resp, err))
+	}
+	if !streaming {
+		t.settleCost(key, limiter, req, resp, cost)
+	}
+	if (t.HeaderParser != nil || t.TranslateRateLimitHeaders) && resp != nil {
+		var (
+			status RateLimitStatus
+			ok     bool
+		)
+		if t.HeaderParser != nil {
+			if status, ok = t.HeaderParser(key, resp.Header); ok && status.Exhausted() {
+				t.holdUntil(key, status.Reset)
+			}
+		} else {
+			status, ok = ParseRateLimitHeaders(resp.Header)
+		}
+		if t.TranslateRateLimitHeaders {
+			translateRateLimitHeaders(resp.Header, status, ok, limiter)
+		}
+	}
+	if t.TunePolicies && resp != nil {
+		t.tunePolicies(key, resp.Header)
+	}
+	return resp, err
+}
+
+// wait blocks until limiter, configured by cfg, permits a request for key, or ctx is done.
+func (t *PerKeyRoundTripper[K]) wait(ctx context.Context, key K, cfg LimiterConfig, limiter *rate.Limiter) error {
+	return t.waitN(ctx, key, cfg, limiter, 1, nil)
+}
+
+// waitN is like wait, but takes the given number of tokens from the key's limiter: a request with no tokens is not
+// delayed by it, while the tokens beyond the first are charged once the request is permitted, as with ResponseCost.
+// If reservation is non-nil, it is waited for in place of the first token, as it was taken from the limiter already;
+// the other steps, such as MaxWaiters, holds and the GlobalLimiter, apply as to any other request. Canceling the
+// reservation if the request is not sent is left to the caller.
+func (t *PerKeyRoundTripper[K]) waitN(
+	ctx context.Context,
+	key K,
+	cfg LimiterConfig,
+	limiter *rate.Limiter,
+	tokens int,
+	reservation *rate.Reservation,
+) error {
+	if err := t.waitPause(ctx); err != nil {
+		return err
+	}
+	waiters := loadOrCompute[K](t.waiters, key, newValue[atomic.Int64])
+	defer waiters.Add(-1)
+	if n := waiters.Add(1); cfg.MaxWaiters > 0 && n > int64(cfg.MaxWaiters) {
+		return &TooManyWaitersError{Key: key, MaxWaiters: cfg.MaxWaiters}
+	}
+	if err := t.waitHold(ctx, key); err != nil {
+		return err
+	}
+	if reservation != nil {
+		// RoundTrip cancels the reservation if the request is not sent
+		if err := delayReservation(ctx, reservation); err != nil {
+			return err
+		}
+		charge(limiter, tokens-1)
+	} else if tokens > 0 {
+		if err := t.waitKey(ctx, key, cfg, limiter); err != nil {
+			return err
+		}
+		charge(limiter, tokens-1)
+	}
+	if tokens > 0 {
+		if err := t.waitWindows(ctx, key, tokens); err != nil {
+			return err
+		}
+	}
+	if err := t.waitTag(ctx); err != nil {
+		return err
+	}
+	if t.GlobalLimiter != nil {
+		return t.GlobalLimiter.Wait(ctx, key)
+	}
+	return nil
+}
+
+// waitKey blocks until the limiter of key, and its smoother if the config sets a MaxRelease, permit a request.
+func (t *PerKeyRoundTripper[K]) waitKey(ctx context.Context, key K, cfg LimiterConfig, limiter *rate.Limiter) error {
+	if t.PriorityScheduling || cfg.Ordered {
+		s := loadOrCompute[K](t.waitQueues, key, newValue[waitQueue])
+		if err := s.Wait(ctx, limiter, -float64(PriorityFromContext(ctx))); err != nil {
+			return err
+		}
+	} else if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	if cfg.MaxRelease > 0 {
+		smoothing := cfg.smoothingConfig()
+		smoother := loadOrCompute[K](t.smoothers, key, smoothing.NewLimiter)
+		smoothing.apply(smoother)
+		return smoother.Wait(ctx)
+	}
+	return nil
 }
 
 func PerOriginRoundTripper(
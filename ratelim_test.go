@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -123,3 +124,50 @@ func TestPerOriginRoundTripper(t *testing.T) {
 		)
 	}
 }
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func BenchmarkPerKeyRoundTripper_Limiter(b *testing.B) {
+	transport := PerOriginRoundTripper(rate.Inf, 0, nil)
+	reqs := make([]*http.Request, 64)
+	for i := range reqs {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://host-%d.example.com/", i), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		reqs[i] = req
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			i := rand.Intn(len(reqs))
+			for pb.Next() {
+				transport.Limiter(reqs[i%len(reqs)])
+				i++
+			}
+		},
+	)
+}
+
+func TestPerKeyRoundTripper_limiterAllocs(t *testing.T) {
+	transport := PerOriginRoundTripper(10, 1, nil)
+	limiter := transport.limiter("https://example.com")
+	allocs := testing.AllocsPerRun(
+		100, func() {
+			if transport.limiter("https://example.com") != limiter {
+				t.Fatal("limiter replaced")
+			}
+		},
+	)
+	if allocs != 0 {
+		t.Errorf("existing limiter lookup allocated %v times", allocs)
+	}
+}
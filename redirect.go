@@ -0,0 +1,48 @@
+package ratelim
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RedirectChain returns the chain of requests which led to req while following redirects, from the original request
+// to req itself, as recorded by http.Client in the Response of each redirected request. The chain of a request which
+// is not a redirect holds only req.
+func RedirectChain(req *http.Request) []*http.Request {
+	chain := []*http.Request{req}
+	for r := req; r.Response != nil && r.Response.Request != nil; r = r.Response.Request {
+		chain = append(chain, r.Response.Request)
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// roundTripKey returns the key whose limiter req is charged to: its own key, or if ChargeRedirectsToOrigin is set,
+// the key of the original request of its redirect chain. hops is the number of redirects followed to reach req, and
+// chain the keys of each hop when hops is positive.
+func (t *PerKeyRoundTripper[K]) roundTripKey(req *http.Request) (key K, hops int, chain []K) {
+	if req.Response == nil {
+		return t.Key(req), 0, nil
+	}
+	requests := RedirectChain(req)
+	chain = make([]K, len(requests))
+	for i, r := range requests {
+		chain[i] = t.Key(r)
+	}
+	key = chain[len(chain)-1]
+	if t.ChargeRedirectsToOrigin {
+		key = chain[0]
+	}
+	return key, len(chain) - 1, chain
+}
+
+// formatRedirectChain formats the keys of a redirect chain for the log.
+func formatRedirectChain[K comparable](chain []K) string {
+	hops := make([]string, len(chain))
+	for i, key := range chain {
+		hops[i] = fmt.Sprint(key)
+	}
+	return strings.Join(hops, " -> ")
+}
@@ -0,0 +1,71 @@
+package ratelim
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPerKeyRoundTripper_redirects(t *testing.T) {
+	target := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer target.Close()
+	origin := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/first" {
+					http.Redirect(w, r, "/second", http.StatusFound)
+					return
+				}
+				http.Redirect(w, r, target.URL+"/final", http.StatusFound)
+			},
+		),
+	)
+	defer origin.Close()
+	originKey, targetKey := Origin(mustParseURL(t, origin.URL)), Origin(mustParseURL(t, target.URL))
+
+	for _, chargeOrigin := range []bool{false, true} {
+		var buf bytes.Buffer
+		rt := PerOriginRoundTripper(1000, 10, nil)
+		rt.ChargeRedirectsToOrigin = chargeOrigin
+		rt.Logger = log.New(&buf, "", 0)
+		resp, err := (&http.Client{Transport: rt}).Get(origin.URL + "/first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		want := map[string]Stats{
+			originKey: {Requests: 2, Redirects: 1},
+			targetKey: {Requests: 1, Redirects: 1, CrossKeyRedirects: 1},
+		}
+		if chargeOrigin {
+			want = map[string]Stats{originKey: {Requests: 3, Redirects: 2, CrossKeyRedirects: 1}}
+		}
+		got := rt.AllStats()
+		if len(got) != len(want) {
+			t.Errorf("ChargeRedirectsToOrigin=%t: AllStats() has %d keys, want %d", chargeOrigin, len(got), len(want))
+		}
+		for key, w := range want {
+			g := got[key]
+			if g.Requests != w.Requests || g.Redirects != w.Redirects || g.CrossKeyRedirects != w.CrossKeyRedirects {
+				t.Errorf("ChargeRedirectsToOrigin=%t: Stats(%s) = %+v, want %+v", chargeOrigin, key, g, w)
+			}
+		}
+		if _, ok := rt.limiters.Load(targetKey); ok == chargeOrigin {
+			t.Errorf("ChargeRedirectsToOrigin=%t: limiter for redirect target exists = %t", chargeOrigin, ok)
+		}
+		via := "hops: 2\tvia: " + originKey + " -> " + originKey + " -> " + targetKey
+		if !strings.Contains(buf.String(), via) {
+			t.Errorf("ChargeRedirectsToOrigin=%t: log does not contain %q:\n%s", chargeOrigin, via, buf.String())
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ratelim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ReadAuditLog reads the records of an AuditLog. Only the time and key of each record are required, so a trace of
+// requests from another source can be replayed as lines of {"time": ..., "key": ...}. A limit may be a number or
+// "inf", as written by AuditRecord.MarshalJSON. Empty lines are skipped.
+func ReadAuditLog(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("ratelim: audit log line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ratelim: reading audit log: %w", err)
+	}
+	return records, nil
+}
+
+// Arrival returns the time at which the request of the record arrived, before it waited.
+func (r AuditRecord) Arrival() time.Time {
+	return r.Time.Add(-time.Duration(r.WaitMillis * float64(time.Millisecond)))
+}
+
+// ReplayCounts counts the decisions taken for a set of requests.
+type ReplayCounts struct {
+	Requests int `json:"requests"`
+	Passed   int `json:"passed"`
+	Waited   int `json:"waited"`
+	Rejected int `json:"rejected"`
+	// Wait is the total, and MaxWait the longest, wait of the requests which were permitted.
+	Wait    time.Duration `json:"wait"`
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+func (c *ReplayCounts) add(action string, wait time.Duration) {
+	c.Requests++
+	switch action {
+	case AuditPass:
+		c.Passed++
+	case AuditWait:
+		c.Waited++
+	case AuditReject:
+		c.Rejected++
+		return
+	}
+	c.Wait += wait
+	c.MaxWait = max(c.MaxWait, wait)
+}
+
+// A ReplayResult counts the decisions taken for the requests of an audit log, in total and by key.
+type ReplayResult struct {
+	ReplayCounts
+	Keys map[string]ReplayCounts `json:"keys"`
+}
+
+func (r *ReplayResult) add(key, action string, wait time.Duration) {
+	r.ReplayCounts.add(action, wait)
+	if r.Keys == nil {
+		r.Keys = make(map[string]ReplayCounts)
+	}
+	c := r.Keys[key]
+	c.add(action, wait)
+	r.Keys[key] = c
+}
+
+// SummarizeAudit counts the decisions recorded in records, for comparison with the result of a Replay.
+func SummarizeAudit(records []AuditRecord) ReplayResult {
+	var result ReplayResult
+	for _, r := range records {
+		result.add(r.Key, r.Action, time.Duration(r.WaitMillis*float64(time.Millisecond)))
+	}
+	return result
+}
+
+// A Replay simulates the decisions which a PerKeyRoundTripper configured with Config would have taken for the requests
+// of an audit log, to evaluate alternative limits against recorded traffic. Each request is assumed to arrive when its
+// record shows it did and to wait for its key's limiter, in virtual time, unless it would wait longer than MaxWait.
+// Requests are assumed to cost 1 token, and other features limiting requests, such as quotas and tags, are not
+// simulated.
+type Replay struct {
+	// Config supplies the limits of each key, as for a transport of a Manager.
+	Config TransportConfig
+	// MaxWait, if positive, is the longest a request may wait; requests which would wait longer are rejected, as if
+	// their context had this deadline.
+	MaxWait time.Duration
+	// WaitThreshold is the wait below which a request is counted as passing rather than waiting; if not positive,
+	// 1ms is used.
+	WaitThreshold time.Duration
+}
+
+// Run replays records, in order of arrival.
+func (p Replay) Run(records []AuditRecord) ReplayResult {
+	records = slices.Clone(records)
+	slices.SortStableFunc(
+		records, func(a, b AuditRecord) int {
+			return a.Arrival().Compare(b.Arrival())
+		},
+	)
+	threshold := p.WaitThreshold
+	if threshold <= 0 {
+		threshold = time.Millisecond
+	}
+	limiters := make(map[string]*rate.Limiter)
+	var result ReplayResult
+	for _, r := range records {
+		limiter, ok := limiters[r.Key]
+		if !ok {
+			cfg := p.Config.Default
+			if c, ok := p.Config.Rules.LimiterConfig(r.Key); ok {
+				cfg = c
+			}
+			limiter = rate.NewLimiter(cfg.Limit, cfg.Burst)
+			limiters[r.Key] = limiter
+		}
+		arrival := r.Arrival()
+		reservation := limiter.ReserveN(arrival, 1)
+		if !reservation.OK() {
+			result.add(r.Key, AuditReject, 0)
+			continue
+		}
+		wait := reservation.DelayFrom(arrival)
+		switch {
+		case p.MaxWait > 0 && wait > p.MaxWait:
+			reservation.CancelAt(arrival)
+			result.add(r.Key, AuditReject, 0)
+		case wait >= threshold:
+			result.add(r.Key, AuditWait, wait)
+		default:
+			result.add(r.Key, AuditPass, wait)
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package ratelim
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := log.Record(AuditRecord{Time: start, Key: "a", Action: AuditWait, WaitMillis: 1500}); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("\n{\"time\":\"2024-01-01T00:00:01Z\",\"key\":\"b\"}\n")
+	records, err := ReadAuditLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if got, want := records[0].Arrival(), start.Add(-1500*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Arrival() = %v, want %v", got, want)
+	}
+	if records[1].Key != "b" || !records[1].Arrival().Equal(start.Add(time.Second)) {
+		t.Errorf("trace record = %+v", records[1])
+	}
+	_, err = ReadAuditLog(strings.NewReader("{}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadAuditLog() error = %v, want an error at line 2", err)
+	}
+}
+
+func TestReplay_Run(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var records []AuditRecord
+	for range 5 {
+		records = append(records, AuditRecord{Time: start, Key: "a", Action: AuditPass})
+	}
+	// recorded after waiting, but arrived with the others
+	records = append(records, AuditRecord{Time: start.Add(time.Second), Key: "b", Action: AuditWait, WaitMillis: 1000})
+	config := TransportConfig{
+		Default: LimiterConfig{Limit: 1, Burst: 2},
+		Rules:   LimitTable{{Pattern: "b", Config: LimiterConfig{Limit: 1, Burst: 1}}},
+	}
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		want    ReplayCounts
+		wantA   ReplayCounts
+	}{
+		{
+			name:  "unbounded wait",
+			want:  ReplayCounts{Requests: 6, Passed: 3, Waited: 3, Wait: 6 * time.Second, MaxWait: 3 * time.Second},
+			wantA: ReplayCounts{Requests: 5, Passed: 2, Waited: 3, Wait: 6 * time.Second, MaxWait: 3 * time.Second},
+		},
+		{
+			name:    "max wait",
+			maxWait: 1500 * time.Millisecond,
+			want:    ReplayCounts{Requests: 6, Passed: 3, Waited: 1, Rejected: 2, Wait: time.Second, MaxWait: time.Second},
+			wantA:   ReplayCounts{Requests: 5, Passed: 2, Waited: 1, Rejected: 2, Wait: time.Second, MaxWait: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				result := Replay{Config: config, MaxWait: tt.maxWait}.Run(records)
+				if result.ReplayCounts != tt.want {
+					t.Errorf("Run() = %+v, want %+v", result.ReplayCounts, tt.want)
+				}
+				if got := result.Keys["a"]; got != tt.wantA {
+					t.Errorf("Run() for key a = %+v, want %+v", got, tt.wantA)
+				}
+			},
+		)
+	}
+	summary := SummarizeAudit(records)
+	want := ReplayCounts{Requests: 6, Passed: 5, Waited: 1, Wait: time.Second, MaxWait: time.Second}
+	if summary.ReplayCounts != want {
+		t.Errorf("SummarizeAudit() = %+v, want %+v", summary.ReplayCounts, want)
+	}
+}
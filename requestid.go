@@ -0,0 +1,23 @@
+package ratelim
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestID returns the value of the RequestIDHeader of req, used to correlate the log lines of requests with the
+// logs of the application and server; it is empty if RequestIDHeader is not set or req does not have it. For the W3C
+// traceparent header, only the trace ID is returned, so that it matches the trace ID logged elsewhere.
+func (t *PerKeyRoundTripper[K]) RequestID(req *http.Request) string {
+	if t.RequestIDHeader == "" {
+		return ""
+	}
+	id := req.Header.Get(t.RequestIDHeader)
+	if http.CanonicalHeaderKey(t.RequestIDHeader) == "Traceparent" {
+		// version-traceid-parentid-flags (ref: https://www.w3.org/TR/trace-context/#traceparent-header)
+		if parts := strings.Split(id, "-"); len(parts) >= 4 && len(parts[1]) == 32 {
+			return parts[1]
+		}
+	}
+	return id
+}
@@ -0,0 +1,63 @@
+package ratelim
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPerKeyRoundTripper_RequestID(t *testing.T) {
+	tests := []struct {
+		header string
+		value  string
+		want   string
+	}{
+		{"X-Request-ID", "abc-123", "abc-123"},
+		{"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"traceparent", "garbled", "garbled"},
+		{"", "abc-123", ""},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.header+"="+tt.value, func(t *testing.T) {
+				rt := PerOriginRoundTripper(1, 1, nil)
+				rt.RequestIDHeader = tt.header
+				req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+				if tt.header != "" {
+					req.Header.Set(tt.header, tt.value)
+				}
+				if got := rt.RequestID(req); got != tt.want {
+					t.Errorf("RequestID() = %q, want %q", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_RequestIDLogged(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	var buf bytes.Buffer
+	rt := PerOriginRoundTripper(1000, 1, nil)
+	rt.Logger = log.New(&buf, "", 0)
+	rt.RequestIDHeader = "X-Request-ID"
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if !strings.Contains(buf.String(), "\tid: abc-123\n") {
+		t.Errorf("log line does not contain request ID: %q", buf.String())
+	}
+}
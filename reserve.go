@@ -0,0 +1,115 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type reservationKey struct{}
+
+// A heldReservation is a reservation carried by a context, which only the first request sent with the context claims,
+// so that redirects and other requests sharing the context neither skip their own wait nor cancel it again.
+type heldReservation struct {
+	r       *rate.Reservation
+	claimed atomic.Bool
+}
+
+// WithReservation returns a copy of ctx carrying r, a reservation obtained from PerKeyRoundTripper.Reserve. The first
+// request sent by RoundTrip with such a context is sent as soon as r permits, rather than waiting for a new token from
+// its key's limiter; its other limits, such as MaxWaiters, holds, MaxStreams and the GlobalLimiter, apply as to any
+// request. If the request is rejected by any of them, or is not sent for any other reason, r is canceled.
+func WithReservation(ctx context.Context, r *rate.Reservation) context.Context {
+	return context.WithValue(ctx, reservationKey{}, &heldReservation{r: r})
+}
+
+// claimReservation returns the reservation carried by ctx, unless it is nil or has been claimed already.
+func claimReservation(ctx context.Context) (*rate.Reservation, bool) {
+	held, ok := ctx.Value(reservationKey{}).(*heldReservation)
+	if !ok || held.r == nil || !held.claimed.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	return held.r, true
+}
+
+// waitReservation blocks until r permits its request, or ctx is done, in which case r is canceled.
+func waitReservation(ctx context.Context, r *rate.Reservation) error {
+	if err := delayReservation(ctx, r); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+// delayReservation blocks until r permits its request, or ctx is done. Unlike waitReservation, it leaves canceling r
+// to the caller.
+func delayReservation(ctx context.Context, r *rate.Reservation) error {
+	if !r.OK() {
+		return errBurstExceeded
+	}
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reserve reserves a token for req from the rate.Limiter of its key, without waiting. The caller must either send the
+// request no sooner than the reservation's Delay (e.g. by sending it with a context from WithReservation, so that
+// RoundTrip does not wait for another token) or Cancel the reservation.
+func (t *PerKeyRoundTripper[K]) Reserve(req *http.Request) *rate.Reservation {
+	return t.Limiter(req).Reserve()
+}
+
+// EstimateWait returns how long req would currently have to wait before being sent, including any hold placed on its
+// key, computed from the tokens and limit of its key's limiter without reserving a token, so that estimating never
+// changes the limiter's state. It returns rate.InfDuration if the key's limiter would never permit the request. The
+// estimate may be invalidated by concurrent requests.
+func (t *PerKeyRoundTripper[K]) EstimateWait(req *http.Request) time.Duration {
+	key := t.Key(req)
+	now := time.Now()
+	delay := tokenDelay(t.limiter(key), now)
+	if delay == rate.InfDuration {
+		return delay
+	}
+	if until, ok := t.holds.Load(key); ok {
+		if hold := until.Sub(now); hold > delay {
+			delay = hold
+		}
+	}
+	return delay
+}
+
+// tokenDelay returns the time from now until limiter has a token available, as ReserveN(now, 1) would delay it, or
+// rate.InfDuration if it never will.
+func tokenDelay(limiter *rate.Limiter, now time.Time) time.Duration {
+	limit := limiter.Limit()
+	if limit == rate.Inf {
+		return 0
+	}
+	if limiter.Burst() < 1 {
+		return rate.InfDuration
+	}
+	missing := 1 - limiter.TokensAt(now)
+	if missing <= 0 {
+		return 0
+	}
+	if limit <= 0 {
+		return rate.InfDuration
+	}
+	delay := missing / float64(limit) * float64(time.Second)
+	if delay >= float64(rate.InfDuration) {
+		return rate.InfDuration
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,166 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyRoundTripper_EstimateWait(t *testing.T) {
+	transport := PerOriginRoundTripper(10, 1, nil)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := transport.EstimateWait(req); d != 0 {
+		t.Errorf("EstimateWait() = %v before any request, want 0", d)
+	}
+	r := transport.Reserve(req)
+	if d := r.Delay(); d != 0 {
+		t.Errorf("first reservation delayed %v", d)
+	}
+	if d := transport.EstimateWait(req); d < 90*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("EstimateWait() = %v after reservation, want ~100ms", d)
+	}
+	// estimating must not consume tokens
+	if d := transport.EstimateWait(req); d < 90*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("EstimateWait() = %v after estimate, want ~100ms", d)
+	}
+	later := time.Now().Add(time.Second)
+	tokens := transport.Limiter(req).TokensAt(later)
+	for i := 0; i < 100; i++ {
+		transport.EstimateWait(req)
+	}
+	if after := transport.Limiter(req).TokensAt(later); after != tokens {
+		t.Errorf("tokens = %v after estimates, want %v", after, tokens)
+	}
+	transport.holdUntil(transport.Key(req), time.Now().Add(time.Second))
+	if d := transport.EstimateWait(req); d < 900*time.Millisecond {
+		t.Errorf("EstimateWait() = %v while held, want ~1s", d)
+	}
+	r = transport.Reserve(req)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitReservation(ctx, r); err == nil {
+		t.Error("waitReservation() with canceled context succeeded")
+	}
+}
+
+func TestTokenDelay(t *testing.T) {
+	now := time.Now()
+	drained := func(limit rate.Limit, burst int) *rate.Limiter {
+		limiter := rate.NewLimiter(limit, burst)
+		limiter.AllowN(now, burst)
+		return limiter
+	}
+	tests := []struct {
+		name    string
+		limiter *rate.Limiter
+		want    time.Duration
+	}{
+		{"available", rate.NewLimiter(1, 1), 0},
+		{"drained", drained(10, 1), 100 * time.Millisecond},
+		{"infinite", drained(rate.Inf, 0), 0},
+		{"zero burst", rate.NewLimiter(10, 0), rate.InfDuration},
+		{"zero limit", drained(0, 1), rate.InfDuration},
+		{"tiny limit", drained(1e-12, 1), rate.InfDuration},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if d := tokenDelay(tt.limiter, now); d != tt.want {
+					t.Errorf("tokenDelay() = %v, want %v", d, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_reservationAdmission(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*PerKeyRoundTripper[string], string)
+		wantErr   bool
+		// wantDelay is the delay of the next reservation from the limiter; 100ms if the reserved token was returned
+		wantDelay time.Duration
+	}{
+		{
+			name: "too many waiters",
+			configure: func(rt *PerKeyRoundTripper[string], key string) {
+				rt.SetLimiterConfig(key, LimiterConfig{Limit: 10, Burst: 2, MaxWaiters: 1})
+				loadOrCompute[string](rt.waiters, key, newValue[atomic.Int64]).Add(1)
+			},
+			wantErr:   true,
+			wantDelay: 100 * time.Millisecond,
+		},
+		{
+			name: "rejected mode",
+			configure: func(rt *PerKeyRoundTripper[string], key string) {
+				rt.SetMode(ModeReject)
+			},
+			wantErr:   true,
+			wantDelay: 100 * time.Millisecond,
+		},
+		{
+			name: "quota exceeded",
+			configure: func(rt *PerKeyRoundTripper[string], key string) {
+				rt.Quota = NewQuota(0, QuotaDaily, nil)
+			},
+			wantErr:   true,
+			wantDelay: 100 * time.Millisecond,
+		},
+		{
+			name: "unlimited mode",
+			configure: func(rt *PerKeyRoundTripper[string], key string) {
+				rt.SetMode(ModeUnlimited)
+			},
+			wantDelay: 100 * time.Millisecond,
+		},
+		{
+			// the reservation took the exempt request's token, which is not charged again
+			name: "exempt",
+			configure: func(rt *PerKeyRoundTripper[string], key string) {
+				rt.Exempt = func(*http.Request) bool {
+					return true
+				}
+			},
+			wantDelay: 200 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				transport := NewPerKeyRoundTripper(10, 2, TargetOrigin, &flakyTransport{})
+				req := mustNewRequest(t)
+				tt.configure(transport, transport.Key(req))
+				limiter := transport.Limiter(req)
+				limiter.AllowN(time.Now(), 2)
+				r := transport.Reserve(req)
+				_, err := transport.RoundTrip(req.WithContext(WithReservation(req.Context(), r)))
+				if (err != nil) != tt.wantErr {
+					t.Errorf("RoundTrip() with a reservation error = %v, want error %v", err, tt.wantErr)
+				}
+				delay := limiter.Reserve().Delay()
+				if delay > tt.wantDelay || delay < tt.wantDelay-20*time.Millisecond {
+					t.Errorf("next reservation delayed %v, want ~%v", delay, tt.wantDelay)
+				}
+			},
+		)
+	}
+}
+
+func TestWithReservation_claimedOnce(t *testing.T) {
+	transport := NewPerKeyRoundTripper(10, 1, TargetOrigin, &flakyTransport{})
+	req := mustNewRequest(t)
+	ctx := WithReservation(req.Context(), transport.Reserve(req))
+	if _, ok := claimReservation(ctx); !ok {
+		t.Fatal("claimReservation() found no reservation")
+	}
+	if _, ok := claimReservation(ctx); ok {
+		t.Error("claimReservation() claimed a reservation twice")
+	}
+}
@@ -0,0 +1,128 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A RetryPolicy retries requests which fail with a transient transport error, such as a reset connection or a
+// timeout. Only requests which are idempotent, and whose body can be sent again, are retried; each retry waits for the
+// key's rate.Limiter like any other request.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries of each request.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled before each further retry; if zero, retries are not
+	// delayed other than by the key's rate.Limiter.
+	Backoff time.Duration
+	// BudgetLimit and BudgetBurst, if BudgetLimit is positive, bound the rate of retries for each key, so that a key
+	// whose requests all fail is not sent a multiple of its requests in retries.
+	BudgetLimit rate.Limit
+	BudgetBurst int
+	// Retryable, if non-nil, reports whether a request which failed with err should be retried; by default,
+	// IsTransientError is used.
+	Retryable func(err error) bool
+}
+
+// IsTransientError reports whether err, returned by an http.RoundTripper, is a transient network error after which a
+// request may succeed if retried: a reset, refused or aborted connection, a connection closed before the response was
+// complete, or a timeout other than that of the request's context.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// idempotent reports whether req may be sent again: its method must be idempotent, or it must have an idempotency key,
+// as for the retries of http.Transport, and its body must be empty or recreatable by GetBody.
+func idempotent(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// retryable reports whether the request for key, which failed with err on its attempt'th retry, should be retried.
+func (t *PerKeyRoundTripper[K]) retryable(key K, req *http.Request, err error, attempt int) bool {
+	p := t.Retry
+	if p == nil || attempt >= p.MaxRetries || req.Context().Err() != nil || !idempotent(req) {
+		return false
+	}
+	retryable := IsTransientError
+	if p.Retryable != nil {
+		retryable = p.Retryable
+	}
+	if !retryable(err) {
+		return false
+	}
+	if p.BudgetLimit > 0 {
+		budget := loadOrCompute(
+			t.retryBudgets, key, func() *rate.Limiter {
+				return rate.NewLimiter(p.BudgetLimit, p.BudgetBurst)
+			},
+		)
+		return budget.Allow()
+	}
+	return true
+}
+
+// sendWithRetries sends req as send does, retrying it as directed by Retry; each retry waits for limiter again.
+func (t *PerKeyRoundTripper[K]) sendWithRetries(
+	req *http.Request,
+	key K,
+	cfg LimiterConfig,
+	limiter *rate.Limiter,
+) (*http.Response, error) {
+	transport := t.transport(key)
+	resp, err := t.send(req, transport, limiter, !cfg.Serialize)
+	for attempt := 0; err != nil && t.retryable(key, req, err, attempt); attempt++ {
+		if backoff := t.Retry.Backoff << attempt; backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, err
+			case <-timer.C:
+			}
+		}
+		if spendBudget(req.Context()) != nil {
+			return nil, err
+		}
+		// a retry which is not sent after all spends none of the budget
+		if waitErr := t.wait(req.Context(), key, cfg, limiter); waitErr != nil {
+			refundBudget(req.Context())
+			return nil, err
+		}
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				refundBudget(req.Context())
+				return nil, err
+			}
+		}
+		t.keyStats(key).retries.Add(1)
+		resp, err = t.send(retry, transport, limiter, !cfg.Serialize)
+	}
+	return resp, err
+}
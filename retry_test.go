@@ -0,0 +1,87 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// flakyTransport fails the first failures requests it is sent with a connection reset.
+type flakyTransport struct {
+	failures int
+	requests int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+	}
+	if f.requests <= f.failures {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{io.ErrUnexpectedEOF, true},
+		{&net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{errors.New("tls: bad certificate"), false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPerKeyRoundTripper_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		failures     int
+		policy       RetryPolicy
+		wantRequests int
+		wantErr      bool
+	}{
+		{"recovers", http.MethodGet, 2, RetryPolicy{MaxRetries: 3}, 3, false},
+		{"max retries", http.MethodGet, 5, RetryPolicy{MaxRetries: 2}, 3, true},
+		{"not idempotent", http.MethodPost, 1, RetryPolicy{MaxRetries: 3}, 1, true},
+		{"budget", http.MethodPut, 5, RetryPolicy{MaxRetries: 3, BudgetLimit: 0.001, BudgetBurst: 1}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				transport := &flakyTransport{failures: tt.failures}
+				rt := PerOriginRoundTripper(1000, 10, transport)
+				rt.Retry = &tt.policy
+				req, err := http.NewRequest(tt.method, "https://example.com/", strings.NewReader("body"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = rt.RoundTrip(req)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("RoundTrip() error = %v, wantErr %t", err, tt.wantErr)
+				}
+				if transport.requests != tt.wantRequests {
+					t.Errorf("transport sent %d requests, want %d", transport.requests, tt.wantRequests)
+				}
+				if stats := rt.Stats("https://example.com"); stats.Retries != int64(tt.wantRequests-1) {
+					t.Errorf("Stats().Retries = %d, want %d", stats.Retries, tt.wantRequests-1)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,124 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A RetryAdapter provides retry policies for clients in the style of hashicorp/go-retryablehttp, which retry requests
+// themselves, informed by the limiters of the PerKeyRoundTripper sending their requests. Its CheckRetry and Backoff
+// methods have the signatures of retryablehttp.CheckRetry and retryablehttp.Backoff, so that their method values can
+// be assigned to the client's policies:
+//
+//	transport := ratelim.NewPerKeyRoundTripper(...)
+//	transport.Synthesize429 = true
+//	adapter := ratelim.NewRetryAdapter(transport)
+//	client := retryablehttp.NewClient()
+//	client.HTTPClient.Transport = transport
+//	client.CheckRetry, client.Backoff = adapter.CheckRetry, adapter.Backoff
+//
+// Since Backoff is only given the response to the last attempt, from whose request the key is derived, the transport
+// should set Synthesize429, so that requests it rejects have a response too. The rejections behind such responses are
+// recognized by RejectionError.
+type RetryAdapter[K comparable] struct {
+	Transport *PerKeyRoundTripper[K]
+	// ShouldRetry, if non-nil, reports whether a request should be retried, given the response to its last attempt or
+	// the error it failed with; by default, requests are retried after a transient error, as reported by
+	// IsTransientError, or a response with status 429 or 5xx other than 501, as by retryablehttp.DefaultRetryPolicy.
+	ShouldRetry func(ctx context.Context, resp *http.Response, err error) (bool, error)
+	// BaseBackoff, if non-nil, returns the delay before the attemptNum'th retry; by default, it is min doubled for each
+	// attempt, up to max, or the delay of the Retry-After header of a 429 or 503 response, as by
+	// retryablehttp.DefaultBackoff.
+	BaseBackoff func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration
+}
+
+// NewRetryAdapter returns a RetryAdapter for the requests sent by transport, with the default policies.
+func NewRetryAdapter[K comparable](transport *PerKeyRoundTripper[K]) *RetryAdapter[K] {
+	return &RetryAdapter[K]{Transport: transport}
+}
+
+// CheckRetry reports whether a request should be retried, given the response to its last attempt or the error it
+// failed with, as by ShouldRetry. It is never retried once ctx is done, if it was rejected by a limit which cannot be
+// met before its period ends, such as a *QuotaExceededError, whether with that error or with a response synthesized
+// under Synthesize429, or if the limiter of its key would not permit it before the deadline of ctx.
+func (a *RetryAdapter[K]) CheckRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return false, ctxErr
+	}
+	var (
+		quota *QuotaExceededError
+		bytes *ByteBudgetExceededError
+	)
+	rejection := err
+	if rejection == nil {
+		rejection = RejectionError(resp)
+	}
+	if errors.Is(rejection, errBurstExceeded) || errors.As(rejection, &quota) || errors.As(rejection, &bytes) {
+		return false, nil
+	}
+	checkRetry := a.ShouldRetry
+	if checkRetry == nil {
+		checkRetry = defaultCheckRetry
+	}
+	retry, checkErr := checkRetry(ctx, resp, err)
+	if !retry || resp == nil || resp.Request == nil {
+		return retry, checkErr
+	}
+	wait := a.Transport.EstimateWait(resp.Request)
+	if deadline, ok := ctx.Deadline(); wait == rate.InfDuration || (ok && time.Now().Add(wait).After(deadline)) {
+		return false, checkErr
+	}
+	return true, checkErr
+}
+
+// Backoff returns the delay before the attemptNum'th retry of the request whose last attempt got resp, as by
+// BaseBackoff, but at least until the limiter of its key permits it, which may exceed max. Without a response, such as
+// after a transport error, the limiter of the key cannot be consulted.
+func (a *RetryAdapter[K]) Backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	backoff := a.BaseBackoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+	delay := backoff(min, max, attemptNum, resp)
+	if resp == nil || resp.Request == nil {
+		return delay
+	}
+	wait := a.Transport.EstimateWait(resp.Request)
+	if wait == rate.InfDuration || wait < delay {
+		return delay
+	}
+	return wait
+}
+
+// defaultCheckRetry retries requests after a transient error, or a response with status 429 or 5xx other than 501.
+func defaultCheckRetry(_ context.Context, resp *http.Response, err error) (bool, error) {
+	if err != nil {
+		return IsTransientError(err), nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true, nil
+	}
+	return resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented), nil
+}
+
+// defaultBackoff returns min doubled attemptNum times, up to max, or the delay of the Retry-After header of a 429 or
+// 503 response.
+func defaultBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil &&
+		(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		now := time.Now()
+		if at, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return at.Sub(now)
+		}
+	}
+	delay := float64(min) * math.Pow(2, float64(attemptNum))
+	if delay > float64(max) || math.IsInf(delay, 0) {
+		return max
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,94 @@
+package ratelim
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryAdapter_CheckRetry(t *testing.T) {
+	transport := PerOriginRoundTripper(1, 1, nil)
+	adapter := NewRetryAdapter(transport)
+	req := mustNewRequest(t)
+	// use up the token of the key, so that its next token is a second away
+	transport.Limiter(req).Allow()
+	withRequest := func(status int) *http.Response {
+		resp := newResponse(status, nil, "")
+		resp.Request = req
+		return resp
+	}
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	canceledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	// a request beyond the quota of a transport synthesizing 429s gets a response rather than an error
+	quotaTransport := PerOriginRoundTripper(1000, 10, &flakyTransport{})
+	quotaTransport.Synthesize429 = true
+	quotaTransport.Quota = NewQuota(0, QuotaDaily, nil)
+	synthesized, err := quotaTransport.RoundTrip(mustNewRequest(t))
+	if err != nil || synthesized.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("RoundTrip() beyond the quota = %v, %v, want a synthesized 429 response", synthesized, err)
+	}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		resp    *http.Response
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{"OK", context.Background(), withRequest(http.StatusOK), nil, false, false},
+		{"TooManyRequests", context.Background(), withRequest(http.StatusTooManyRequests), nil, true, false},
+		{"BadGateway", context.Background(), withRequest(http.StatusBadGateway), nil, true, false},
+		{"NotImplemented", context.Background(), withRequest(http.StatusNotImplemented), nil, false, false},
+		{"TransientError", context.Background(), nil, syscall.ECONNRESET, true, false},
+		{"OtherError", context.Background(), nil, io.ErrClosedPipe, false, false},
+		{"QuotaExceeded", context.Background(), nil, &QuotaExceededError{Key: "k"}, false, false},
+		{"SynthesizedQuotaExceeded", context.Background(), synthesized, nil, false, false},
+		{"BurstExceeded", context.Background(), nil, errBurstExceeded, false, false},
+		{"DeadlineBeforeToken", shortCtx, withRequest(http.StatusTooManyRequests), nil, false, false},
+		{"Canceled", canceledCtx, withRequest(http.StatusTooManyRequests), nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				retry, err := adapter.CheckRetry(tt.ctx, tt.resp, tt.err)
+				if retry != tt.want || (err != nil) != tt.wantErr {
+					t.Errorf("CheckRetry() = %v, %v, want %v, error %v", retry, err, tt.want, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func TestRetryAdapter_Backoff(t *testing.T) {
+	transport := PerOriginRoundTripper(1, 1, nil)
+	adapter := NewRetryAdapter(transport)
+	req := mustNewRequest(t)
+	resp := newResponse(http.StatusBadGateway, nil, "")
+	resp.Request = req
+	if d := adapter.Backoff(10*time.Millisecond, time.Second, 2, resp); d != 40*time.Millisecond {
+		t.Errorf("Backoff() = %v with a token available, want 40ms", d)
+	}
+	if d := adapter.Backoff(10*time.Millisecond, 50*time.Millisecond, 5, nil); d != 50*time.Millisecond {
+		t.Errorf("Backoff() = %v, want it capped at 50ms", d)
+	}
+	throttled := newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}}, "")
+	if d := adapter.Backoff(10*time.Millisecond, time.Second, 0, throttled); d < 2*time.Second || d > 3*time.Second {
+		t.Errorf("Backoff() = %v, want the Retry-After of 3s", d)
+	}
+	// the key's next token is a second away, beyond the max backoff
+	transport.Limiter(req).Allow()
+	if d := adapter.Backoff(10*time.Millisecond, 100*time.Millisecond, 0, resp); d < 900*time.Millisecond {
+		t.Errorf("Backoff() = %v, want at least until the key's next token in ~1s", d)
+	}
+	adapter.BaseBackoff = func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		return 5 * time.Second
+	}
+	if d := adapter.Backoff(10*time.Millisecond, 100*time.Millisecond, 0, resp); d != 5*time.Second {
+		t.Errorf("Backoff() = %v, want the 5s of BaseBackoff", d)
+	}
+}
@@ -0,0 +1,135 @@
+package ratelim
+
+import (
+	"context"
+	"time"
+)
+
+// A ScheduleWindow is a recurring period of the day during which a Schedule applies Config.
+type ScheduleWindow struct {
+	// Days are the days of the week on which the window starts; if empty, the window starts every day.
+	Days []time.Weekday
+	// Start and End are the times of day, as durations since midnight (e.g. 9*time.Hour for 09:00), at which the
+	// window starts and ends. They are wall clock times, which hold on days when daylight saving time begins or ends. If
+	// End is not after Start, the window ends at End on the following day.
+	Start, End time.Duration
+	Config     LimiterConfig
+}
+
+func (w ScheduleWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w ScheduleWindow) wraps() bool {
+	return w.End <= w.Start
+}
+
+// A Schedule varies a LimiterConfig by time of day, e.g. to allow 1 request per second during business hours and 10
+// overnight.
+type Schedule struct {
+	// Location is the time zone in which the windows' times of day are interpreted; if nil, time.Local is used.
+	Location *time.Location
+	// Windows are the scheduled windows; where windows overlap, the first applies.
+	Windows []ScheduleWindow
+	// Default is the config applied outside all windows.
+	Default LimiterConfig
+}
+
+func (s *Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// midnight returns midnight of the day of t, offset by days, in loc.
+func midnight(t time.Time, days int, loc *time.Location) time.Time {
+	return timeOfDay(t, days, 0, loc)
+}
+
+// timeOfDay returns the time of day, given as a duration since midnight, on the day of t, offset by days, in loc.
+// The time is built from its wall clock fields rather than added to midnight, which would be an hour off on days when
+// daylight saving time begins or ends.
+func timeOfDay(t time.Time, days int, offset time.Duration, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	hh, mm := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	return time.Date(y, m, d+days, hh, mm, int(offset%time.Minute/time.Second), int(offset%time.Second), loc)
+}
+
+// ConfigAt returns the config scheduled at t.
+func (s *Schedule) ConfigAt(t time.Time) LimiterConfig {
+	loc := s.location()
+	today := midnight(t, 0, loc)
+	yesterday := midnight(t, -1, loc)
+	for _, w := range s.Windows {
+		if w.startsOn(today.Weekday()) {
+			start, end := timeOfDay(t, 0, w.Start, loc), timeOfDay(t, 0, w.End, loc)
+			if w.wraps() {
+				end = timeOfDay(t, 1, w.End, loc)
+			}
+			if !t.Before(start) && t.Before(end) {
+				return w.Config
+			}
+		}
+		if w.wraps() && w.startsOn(yesterday.Weekday()) && t.Before(timeOfDay(t, 0, w.End, loc)) {
+			return w.Config
+		}
+	}
+	return s.Default
+}
+
+// NextTransition returns the first time after t at which a window starts or ends, or the zero time.Time if the
+// Schedule has no windows.
+func (s *Schedule) NextTransition(t time.Time) time.Time {
+	loc := s.location()
+	var next time.Time
+	for days := -1; days <= 7; days++ {
+		day := midnight(t, days, loc)
+		for _, w := range s.Windows {
+			if !w.startsOn(day.Weekday()) {
+				continue
+			}
+			end := timeOfDay(day, 0, w.End, loc)
+			if w.wraps() {
+				end = timeOfDay(day, 1, w.End, loc)
+			}
+			for _, boundary := range []time.Time{timeOfDay(day, 0, w.Start, loc), end} {
+				if boundary.After(t) && (next.IsZero() || boundary.Before(next)) {
+					next = boundary
+				}
+			}
+		}
+	}
+	return next
+}
+
+// RunSchedule sets the default LimiterConfig of t according to s, updating the limiters of all keys without a config
+// of their own at each transition of the schedule, until ctx is done. Setting the transport's Schedule instead applies
+// it from the transport's background goroutine.
+func (t *PerKeyRoundTripper[K]) RunSchedule(ctx context.Context, s *Schedule) error {
+	for {
+		now := time.Now()
+		t.SetDefaultLimiterConfig(s.ConfigAt(now))
+		t.Reconfigure()
+		next := s.NextTransition(now)
+		if next.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
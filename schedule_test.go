@@ -0,0 +1,89 @@
+package ratelim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	business := LimiterConfig{Limit: 1, Burst: 1}
+	overnight := LimiterConfig{Limit: 10, Burst: 10}
+	weekend := LimiterConfig{Limit: 5, Burst: 5}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	s := &Schedule{
+		Location: time.UTC,
+		Windows: []ScheduleWindow{
+			{Days: weekdays, Start: 9 * time.Hour, End: 17 * time.Hour, Config: business},
+			{Days: weekdays, Start: 22 * time.Hour, End: 6 * time.Hour, Config: overnight},
+		},
+		Default: weekend,
+	}
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		t    time.Time
+		want LimiterConfig
+		next time.Time
+	}{
+		{at(1, 8, 0), weekend, at(1, 9, 0)},
+		{at(1, 9, 0), business, at(1, 17, 0)},
+		{at(1, 12, 30), business, at(1, 17, 0)},
+		{at(1, 23, 0), overnight, at(2, 6, 0)},
+		{at(2, 5, 59), overnight, at(2, 6, 0)},
+		// Friday night's window extends into Saturday morning
+		{at(6, 3, 0), overnight, at(6, 6, 0)},
+		{at(6, 12, 0), weekend, at(8, 9, 0)},
+	}
+	for _, tt := range tests {
+		if got := s.ConfigAt(tt.t); got != tt.want {
+			t.Errorf("ConfigAt(%v) = %+v, want %+v", tt.t, got, tt.want)
+		}
+		if got := s.NextTransition(tt.t); !got.Equal(tt.next) {
+			t.Errorf("NextTransition(%v) = %v, want %v", tt.t, got, tt.next)
+		}
+	}
+}
+
+func TestSchedule_daylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	business := LimiterConfig{Limit: 1, Burst: 1}
+	overnight := LimiterConfig{Limit: 10, Burst: 10}
+	s := &Schedule{
+		Location: loc,
+		Windows: []ScheduleWindow{
+			{Start: 9 * time.Hour, End: 17 * time.Hour, Config: business},
+			{Start: 22 * time.Hour, End: 6 * time.Hour, Config: overnight},
+		},
+	}
+	// clocks sprang forward on 2024-03-10, a 23-hour day, and fell back on 2024-11-03, a 25-hour day
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, loc)
+	}
+	tests := []struct {
+		t    time.Time
+		want LimiterConfig
+		next time.Time
+	}{
+		{at(3, 10, 8, 0), LimiterConfig{}, at(3, 10, 9, 0)},
+		{at(3, 10, 9, 30), business, at(3, 10, 17, 0)},
+		{at(3, 10, 16, 30), business, at(3, 10, 17, 0)},
+		{at(3, 10, 5, 30), overnight, at(3, 10, 6, 0)},
+		{at(11, 3, 8, 30), LimiterConfig{}, at(11, 3, 9, 0)},
+		{at(11, 3, 9, 0), business, at(11, 3, 17, 0)},
+		{at(11, 3, 5, 30), overnight, at(11, 3, 6, 0)},
+		{at(11, 2, 21, 0), LimiterConfig{}, at(11, 2, 22, 0)},
+	}
+	for _, tt := range tests {
+		if got := s.ConfigAt(tt.t); got != tt.want {
+			t.Errorf("ConfigAt(%v) = %+v, want %+v", tt.t, got, tt.want)
+		}
+		if got := s.NextTransition(tt.t); !got.Equal(tt.next) {
+			t.Errorf("NextTransition(%v) = %v, want %v", tt.t, got, tt.next)
+		}
+	}
+}
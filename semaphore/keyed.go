@@ -0,0 +1,97 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+)
+
+type keyedEntry struct {
+	sem  *Weighted
+	refs int
+}
+
+// A Keyed is a set of Weighted semaphores, one per key, such as the requests in flight per origin. The semaphore of a
+// key is created on first use with the size returned for the key, and discarded once no weight is held or waited for
+// it.
+type Keyed[K comparable] struct {
+	size    func(key K) int64
+	mux     sync.Mutex
+	entries map[K]*keyedEntry
+}
+
+// NewKeyed returns a new Keyed whose semaphores have the sizes returned by size.
+func NewKeyed[K comparable](size func(key K) int64) *Keyed[K] {
+	return &Keyed[K]{size: size, entries: make(map[K]*keyedEntry)}
+}
+
+// ref returns the entry of key, creating it if needed, and counts a reference to it.
+func (k *Keyed[K]) ref(key K) *keyedEntry {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	e, ok := k.entries[key]
+	if !ok {
+		e = &keyedEntry{sem: NewWeighted(k.size(key))}
+		k.entries[key] = e
+	}
+	e.refs++
+	return e
+}
+
+// unref drops a reference to the entry of key, discarding it once it has none.
+func (k *Keyed[K]) unref(key K, e *keyedEntry) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if e.refs--; e.refs == 0 {
+		delete(k.entries, key)
+	}
+}
+
+// Acquire acquires a weight of n from the semaphore of key, as Weighted.Acquire does. Each successful Acquire must be
+// matched by a Release of the same weight.
+func (k *Keyed[K]) Acquire(ctx context.Context, key K, n int64) error {
+	e := k.ref(key)
+	if err := e.sem.Acquire(ctx, n); err != nil {
+		k.unref(key, e)
+		return err
+	}
+	return nil
+}
+
+// TryAcquire acquires a weight of n from the semaphore of key without blocking, and reports whether it succeeded.
+func (k *Keyed[K]) TryAcquire(key K, n int64) bool {
+	e := k.ref(key)
+	if !e.sem.TryAcquire(n) {
+		k.unref(key, e)
+		return false
+	}
+	return true
+}
+
+// Release releases a weight of n acquired from the semaphore of key.
+func (k *Keyed[K]) Release(key K, n int64) {
+	k.mux.Lock()
+	e, ok := k.entries[key]
+	k.mux.Unlock()
+	if !ok {
+		panic("semaphore: released a key which is not held")
+	}
+	e.sem.Release(n)
+	k.unref(key, e)
+}
+
+// Held returns the weight currently held for key.
+func (k *Keyed[K]) Held(key K) int64 {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if e, ok := k.entries[key]; ok {
+		return e.sem.Held()
+	}
+	return 0
+}
+
+// Len returns the number of keys for which weight is held or waited for.
+func (k *Keyed[K]) Len() int {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	return len(k.entries)
+}
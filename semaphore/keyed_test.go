@@ -0,0 +1,38 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyed(
+		func(key string) int64 {
+			if key == "large" {
+				return 10
+			}
+			return 1
+		},
+	)
+	ctx := context.Background()
+	if err := k.Acquire(ctx, "small", 1); err != nil {
+		t.Fatal(err)
+	}
+	if k.TryAcquire("small", 1) {
+		t.Error("TryAcquire(small) succeeded on a full semaphore")
+	}
+	if !k.TryAcquire("large", 5) {
+		t.Error("TryAcquire(large, 5) failed, want keys limited independently")
+	}
+	if got := k.Held("large"); got != 5 {
+		t.Errorf("Held(large) = %d, want 5", got)
+	}
+	if got := k.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	k.Release("small", 1)
+	k.Release("large", 5)
+	if got := k.Len(); got != 0 {
+		t.Errorf("Len() = %d after releasing everything, want 0", got)
+	}
+}
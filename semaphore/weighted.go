@@ -0,0 +1,129 @@
+// Package semaphore provides a weighted semaphore, and a set of them keyed by any comparable type, to bound the use of
+// a resource such as the number of requests in flight, where some uses may count for more than others.
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// A Weighted is a semaphore of a fixed size, from which weights are acquired and released. Waiters acquire in the
+// order in which they began waiting, so that a heavy waiter is not starved by lighter ones.
+type Weighted struct {
+	size    int64
+	mux     sync.Mutex
+	cur     int64
+	waiters list.List
+}
+
+// NewWeighted returns a new Weighted of the given size.
+func NewWeighted(size int64) *Weighted {
+	return &Weighted{size: size}
+}
+
+// Size returns the size of the semaphore.
+func (s *Weighted) Size() int64 {
+	return s.size
+}
+
+// Acquire acquires a weight of n, blocking until it is available or ctx is done. On failure it returns ctx.Err() and
+// leaves the semaphore unchanged. A weight larger than the semaphore's size fails immediately, unless ctx is already
+// done, as it could never be acquired.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	done := ctx.Done()
+	s.mux.Lock()
+	select {
+	case <-done:
+		s.mux.Unlock()
+		return ctx.Err()
+	default:
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mux.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mux.Unlock()
+		return fmt.Errorf("semaphore: weight %d exceeds size %d", n, s.size)
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{n: n, ready: ready})
+	s.mux.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-done:
+		s.mux.Lock()
+		select {
+		case <-ready:
+			// acquired after ctx was done: keep the weight rather than undo the acquisition
+			s.mux.Unlock()
+			return nil
+		default:
+		}
+		front := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		if front && s.size > s.cur {
+			// waiters behind this one may now fit
+			s.notifyWaiters()
+		}
+		s.mux.Unlock()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire acquires a weight of n without blocking, and reports whether it succeeded.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release releases a weight of n. It panics if more weight is released than is held.
+func (s *Weighted) Release(n int64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// Held returns the weight currently held.
+func (s *Weighted) Held() int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.cur
+}
+
+// notifyWaiters grants the weights of the waiters at the front of the queue, in order, while they fit. s.mux must be
+// held.
+func (s *Weighted) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(waiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,77 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waiting returns the number of waiters of s.
+func waiting(s *Weighted) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.waiters.Len()
+}
+
+func TestWeighted(t *testing.T) {
+	s := NewWeighted(3)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if s.TryAcquire(2) {
+		t.Error("TryAcquire(2) succeeded with 1 available")
+	}
+	acquired := make(chan struct{})
+	go func() {
+		if err := s.Acquire(ctx, 3); err != nil {
+			t.Error(err)
+		}
+		close(acquired)
+	}()
+	for deadline := time.Now().Add(time.Second); waiting(s) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	// a waiter is queued, so lighter weights must not overtake it
+	if s.TryAcquire(1) {
+		t.Error("TryAcquire(1) overtook a waiter")
+	}
+	s.Release(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter not granted its weight after Release")
+	}
+	if got := s.Held(); got != 3 {
+		t.Errorf("Held() = %d, want 3", got)
+	}
+	s.Release(3)
+}
+
+func TestWeighted_AcquireCanceled(t *testing.T) {
+	s := NewWeighted(2)
+	if err := s.Acquire(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := waiting(s); got != 0 {
+		t.Errorf("%d waiters left after a canceled Acquire, want 0", got)
+	}
+	if err := s.Acquire(context.Background(), 3); err == nil {
+		t.Error("Acquire() of more than the size succeeded")
+	}
+}
+
+func TestWeighted_ReleaseTooMuch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Release() of more than held did not panic")
+		}
+	}()
+	NewWeighted(1).Release(1)
+}
@@ -0,0 +1,43 @@
+package ratelim
+
+import (
+	"context"
+)
+
+// serialize waits for the turn of a request for key, if the key's config sets Serialize, or until ctx is done. It
+// returns a func ending the turn, or nil if the key's requests are not serialized.
+func (t *PerKeyRoundTripper[K]) serialize(ctx context.Context, key K, cfg LimiterConfig) (end func(), err error) {
+	if !cfg.Serialize {
+		return nil, nil
+	}
+	if err := t.turns.Acquire(ctx, key, 1); err != nil {
+		return nil, err
+	}
+	return func() {
+		t.turns.Release(key, 1)
+	}, nil
+}
+
+// Serialized reports whether a request for key is currently taking its turn, as for keys whose config sets
+// Serialize.
+func (t *PerKeyRoundTripper[K]) Serialized(key K) bool {
+	return t.turns.Held(key) > 0
+}
+
+// joinReleases returns a func calling each of the non-nil releases, or nil if all are nil.
+func joinReleases(releases ...func()) func() {
+	var nonNil []func()
+	for _, r := range releases {
+		if r != nil {
+			nonNil = append(nonNil, r)
+		}
+	}
+	if len(nonNil) == 0 {
+		return nil
+	}
+	return func() {
+		for _, r := range nonNil {
+			r()
+		}
+	}
+}
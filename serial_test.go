@@ -0,0 +1,65 @@
+package ratelim
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// seqTransport records the X-Seq header of the requests it is sent, in order, and responds with an empty 200 response.
+type seqTransport struct {
+	mux  sync.Mutex
+	seqs []string
+}
+
+func (s *seqTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.seqs = append(s.seqs, req.Header.Get("X-Seq"))
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestPerKeyRoundTripper_Serialize(t *testing.T) {
+	transport := &seqTransport{}
+	rt := PerOriginRoundTripper(1000, 100, transport)
+	rt.SetLimiterConfig("https://example.com", LimiterConfig{Limit: 1000, Burst: 100, Serialize: true})
+	send := func(url string, seq int) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("X-Seq", strconv.Itoa(seq))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Error(err)
+		}
+		return resp
+	}
+	first := send("https://example.com/", 1)
+	if !rt.Serialized("https://example.com") {
+		t.Error("Serialized() = false while a request is open")
+	}
+	var wg sync.WaitGroup
+	for seq := 2; seq <= 4; seq++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = send("https://example.com/", seq).Body.Close()
+		}()
+		// let each request queue before the next arrives
+		time.Sleep(10 * time.Millisecond)
+	}
+	// other keys are not serialized
+	_ = send("https://example.org/", 0).Body.Close()
+	if want := []string{"1", "0"}; !reflect.DeepEqual(transport.seqs, want) {
+		t.Fatalf("sent %q while the first request was open, want %q", transport.seqs, want)
+	}
+	_ = first.Body.Close()
+	wg.Wait()
+	if want := []string{"1", "0", "2", "3", "4"}; !reflect.DeepEqual(transport.seqs, want) {
+		t.Errorf("sent %q, want %q", transport.seqs, want)
+	}
+	if rt.Serialized("https://example.com") {
+		t.Error("Serialized() = true after all responses were closed")
+	}
+}
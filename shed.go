@@ -0,0 +1,140 @@
+package ratelim
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/milo-minderbinder/ratelim/syncmap"
+)
+
+// A SheddingError is returned for a request rejected by a Shedder.
+type SheddingError struct {
+	Key       any
+	ErrorRate float64
+}
+
+func (e *SheddingError) Error() string {
+	return fmt.Sprintf("ratelim: request for key %v shed at error rate %.2f", e.Key, e.ErrorRate)
+}
+
+// A Shedder rejects a growing fraction of the requests for a key as the recent error rate of its responses rises above
+// Threshold, to relieve a failing upstream gradually rather than all at once. The fraction rejected grows linearly
+// from 0 at Threshold to MaxShed at an error rate of 1.
+//
+// The error rate is measured over a sliding Window, so that it decays once a key stops failing even while most of its
+// requests are being shed.
+type Shedder[K comparable] struct {
+	// Threshold is the error rate above which requests are shed; if not within [0, 1), 0.2 is used.
+	Threshold float64
+	// MaxShed is the fraction of requests shed at an error rate of 1; if not within (0, 1], 0.9 is used, so that some
+	// requests still probe whether the upstream has recovered.
+	MaxShed float64
+	// Window is the period over which the error rate is measured; if not positive, 10 seconds is used.
+	Window time.Duration
+	// MinRequests is the number of responses in the window below which no requests are shed; if not positive, 10 is
+	// used.
+	MinRequests int
+	states      *syncmap.SyncMap[K, *shedState]
+	now         func() time.Time
+	random      func() float64
+}
+
+type shedCounts struct {
+	requests, errors float64
+}
+
+type shedState struct {
+	mux         sync.Mutex
+	windowStart time.Time
+	current     shedCounts
+	previous    shedCounts
+}
+
+// NewShedder returns a new Shedder with default parameters.
+func NewShedder[K comparable]() *Shedder[K] {
+	return &Shedder[K]{
+		states: syncmap.New[K, *shedState](),
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+func (s *Shedder[K]) window() time.Duration {
+	return orDefault(s.Window, 10*time.Second, s.Window > 0)
+}
+
+// counts returns the counts of the sliding window ending at now, rolling the state's windows forward as needed.
+// state.mux must be held.
+func (s *Shedder[K]) counts(state *shedState, now time.Time) shedCounts {
+	window := s.window()
+	if elapsed := now.Sub(state.windowStart); elapsed >= 2*window {
+		state.windowStart, state.previous, state.current = now, shedCounts{}, shedCounts{}
+	} else if elapsed >= window {
+		state.windowStart, state.previous, state.current = state.windowStart.Add(window), state.current, shedCounts{}
+	}
+	weight := 1 - float64(now.Sub(state.windowStart))/float64(window)
+	return shedCounts{
+		requests: state.current.requests + weight*state.previous.requests,
+		errors:   state.current.errors + weight*state.previous.errors,
+	}
+}
+
+// ErrorRate returns the error rate of key over the sliding window, or 0 if fewer than MinRequests responses have been
+// observed in it.
+func (s *Shedder[K]) ErrorRate(key K) float64 {
+	state, ok := s.states.Load(key)
+	if !ok {
+		return 0
+	}
+	state.mux.Lock()
+	defer state.mux.Unlock()
+	return s.errorRate(s.counts(state, s.now()))
+}
+
+func (s *Shedder[K]) errorRate(c shedCounts) float64 {
+	if c.requests < float64(orDefault(s.MinRequests, 10, s.MinRequests > 0)) {
+		return 0
+	}
+	return c.errors / c.requests
+}
+
+// ShedFraction returns the fraction of requests for key currently shed.
+func (s *Shedder[K]) ShedFraction(key K) float64 {
+	return s.shedFraction(s.ErrorRate(key))
+}
+
+func (s *Shedder[K]) shedFraction(errorRate float64) float64 {
+	threshold := orDefault(s.Threshold, 0.2, s.Threshold >= 0 && s.Threshold < 1)
+	maxShed := orDefault(s.MaxShed, 0.9, s.MaxShed > 0 && s.MaxShed <= 1)
+	if errorRate <= threshold {
+		return 0
+	}
+	return (errorRate - threshold) / (1 - threshold) * maxShed
+}
+
+// Allow reports whether a request for key may be sent, or returns a *SheddingError if it is shed.
+func (s *Shedder[K]) Allow(key K) error {
+	errorRate := s.ErrorRate(key)
+	if fraction := s.shedFraction(errorRate); fraction > 0 && s.random() < fraction {
+		return &SheddingError{Key: key, ErrorRate: errorRate}
+	}
+	return nil
+}
+
+// Observe records a response for key, which failed if failed is true.
+func (s *Shedder[K]) Observe(key K, failed bool) {
+	state := loadOrCompute[K](s.states, key, newValue[shedState])
+	state.mux.Lock()
+	defer state.mux.Unlock()
+	now := s.now()
+	if state.windowStart.IsZero() {
+		state.windowStart = now
+	}
+	s.counts(state, now)
+	state.current.requests++
+	if failed {
+		state.current.errors++
+	}
+}
@@ -0,0 +1,54 @@
+package ratelim
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestShedder(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewShedder[string]()
+	s.Threshold, s.MaxShed, s.Window = 0.5, 0.8, 10*time.Second
+	s.now = func() time.Time { return now }
+	s.random = func() float64 { return 0.3 }
+	observe := func(requests, errors int) {
+		for i := 0; i < requests; i++ {
+			s.Observe("k", i < errors)
+		}
+	}
+
+	observe(5, 5)
+	if s.ErrorRate("k") != 0 {
+		t.Errorf("ErrorRate() = %v below MinRequests, want 0", s.ErrorRate("k"))
+	}
+	observe(15, 5)
+	if rate, fraction := s.ErrorRate("k"), s.ShedFraction("k"); rate != 0.5 || fraction != 0 {
+		t.Errorf("ErrorRate(), ShedFraction() = %v, %v at threshold, want 0.5, 0", rate, fraction)
+	}
+	observe(20, 20)
+	// 30 errors of 40 requests: (0.75-0.5)/(1-0.5)*0.8 = 0.4 of requests are shed
+	if fraction := s.ShedFraction("k"); math.Abs(fraction-0.4) > 1e-9 {
+		t.Errorf("ShedFraction() = %v, want 0.4", fraction)
+	}
+	var shed *SheddingError
+	if err := s.Allow("k"); !errors.As(err, &shed) || shed.ErrorRate != 0.75 {
+		t.Errorf("Allow() = %v, want *SheddingError at error rate 0.75", err)
+	}
+	s.random = func() float64 { return 0.5 }
+	if err := s.Allow("k"); err != nil {
+		t.Errorf("Allow() = %v, want nil above shed fraction", err)
+	}
+
+	// halfway through the next window, the previous window's counts are weighted by half
+	now = now.Add(15 * time.Second)
+	observe(10, 0)
+	if rate := s.ErrorRate("k"); rate != 0.5 {
+		t.Errorf("ErrorRate() = %v in next window, want 0.5", rate)
+	}
+	now = now.Add(20 * time.Second)
+	if rate := s.ErrorRate("k"); rate != 0 {
+		t.Errorf("ErrorRate() = %v after two idle windows, want 0", rate)
+	}
+}
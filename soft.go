@@ -0,0 +1,46 @@
+package ratelim
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// checkSoftLimit reports whether req exceeds the soft limit of key, if any, in which case the excess is counted, logged
+// and passed to OnSoftLimitExceeded.
+func (t *PerKeyRoundTripper[K]) checkSoftLimit(key K, cfg LimiterConfig, req *http.Request) bool {
+	if cfg.SoftLimit <= 0 {
+		return false
+	}
+	soft := cfg.softConfig()
+	limiter := loadOrCompute[K](t.softLimiters, key, soft.NewLimiter)
+	soft.apply(limiter)
+	if limiter.Allow() {
+		return false
+	}
+	count := loadOrCompute[K](t.softExceeded, key, newValue[atomic.Int64])
+	count.Add(1)
+	if logger := t.Logger; logger != nil && logger.Writer() != io.Discard {
+		logger.Printf(
+			"%T - key: %v\tsoft limit exceeded: %g r/s\treq: %s %s",
+			t,
+			key,
+			float64(cfg.SoftLimit),
+			req.Method,
+			req.URL.String(),
+		)
+	}
+	if t.OnSoftLimitExceeded != nil {
+		t.OnSoftLimitExceeded(key, req)
+	}
+	return true
+}
+
+// SoftLimitExceeded returns the number of requests for key which have exceeded its soft limit.
+func (t *PerKeyRoundTripper[K]) SoftLimitExceeded(key K) int64 {
+	count, ok := t.softExceeded.Load(key)
+	if !ok {
+		return 0
+	}
+	return count.Load()
+}
@@ -0,0 +1,29 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPerKeyRoundTripper_SoftLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	transport := PerOriginRoundTripper(1000, 10, nil)
+	key := Origin(mustParseURL(t, ts.URL))
+	transport.SetLimiterConfig(key, LimiterConfig{Limit: 1000, Burst: 10, SoftLimit: 1, SoftBurst: 2})
+	var reported int
+	transport.OnSoftLimitExceeded = func(string, *http.Request) { reported++ }
+	client := ts.Client()
+	client.Transport = transport
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if n := transport.SoftLimitExceeded(key); n != 3 || reported != 3 {
+		t.Errorf("soft limit exceeded %d times, reported %d; want 3", n, reported)
+	}
+}
@@ -0,0 +1,77 @@
+package ratelim
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A SpikeArrestError is returned for a request rejected by the SpikeArrest of its key's config.
+type SpikeArrestError struct {
+	Key any
+	// Allowance is the number of requests permitted per Interval.
+	Allowance int
+	Interval  time.Duration
+	// Until is the end of the sub-interval whose allowance the request exceeded.
+	Until time.Time
+}
+
+func (e *SpikeArrestError) Error() string {
+	return fmt.Sprintf(
+		"ratelim: spike arrested for key %v (max %d requests per %v)", e.Key, e.Allowance, e.Interval,
+	)
+}
+
+// spikeArrest returns the sub-interval and the number of requests permitted per sub-interval by the config's
+// SpikeArrest, or false if it has none.
+func (c LimiterConfig) spikeArrest() (interval time.Duration, allowance int, ok bool) {
+	if c.SpikeArrest <= 0 || c.SpikeArrest == rate.Inf {
+		return 0, 0, false
+	}
+	interval = c.SpikeArrestInterval
+	if interval <= 0 {
+		interval = time.Duration(float64(time.Second) / float64(c.SpikeArrest))
+	}
+	if interval <= 0 {
+		return 0, 0, false
+	}
+	allowance = max(int(math.Ceil(float64(c.SpikeArrest)*interval.Seconds())), 1)
+	return interval, allowance, true
+}
+
+// spikeState counts the requests of a key in its current sub-interval.
+type spikeState struct {
+	mux      sync.Mutex
+	interval int64
+	count    int
+}
+
+// arrestSpike counts a request for key against the current sub-interval of the SpikeArrest of cfg, failing with a
+// *SpikeArrestError if the sub-interval's allowance is used up.
+func (t *PerKeyRoundTripper[K]) arrestSpike(key K, cfg LimiterConfig) error {
+	interval, allowance, ok := cfg.spikeArrest()
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	current := now.UnixNano() / int64(interval)
+	s := loadOrCompute[K](t.spikes, key, newValue[spikeState])
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.interval != current {
+		s.interval, s.count = current, 0
+	}
+	if s.count >= allowance {
+		return &SpikeArrestError{
+			Key:       key,
+			Allowance: allowance,
+			Interval:  interval,
+			Until:     time.Unix(0, (current+1)*int64(interval)),
+		}
+	}
+	s.count++
+	return nil
+}
@@ -0,0 +1,91 @@
+package ratelim
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimiterConfig_spikeArrest(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           LimiterConfig
+		wantInterval  time.Duration
+		wantAllowance int
+		wantOK        bool
+	}{
+		{
+			name: "disabled",
+			cfg:  LimiterConfig{Limit: 10, Burst: 10},
+		},
+		{
+			name:          "one request per sub-interval",
+			cfg:           LimiterConfig{SpikeArrest: 100},
+			wantInterval:  10 * time.Millisecond,
+			wantAllowance: 1,
+			wantOK:        true,
+		},
+		{
+			name:          "sub-interval",
+			cfg:           LimiterConfig{SpikeArrest: 30, SpikeArrestInterval: 100 * time.Millisecond},
+			wantInterval:  100 * time.Millisecond,
+			wantAllowance: 3,
+			wantOK:        true,
+		},
+		{
+			name:          "rounded up",
+			cfg:           LimiterConfig{SpikeArrest: 0.5, SpikeArrestInterval: time.Second},
+			wantInterval:  time.Second,
+			wantAllowance: 1,
+			wantOK:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				interval, allowance, ok := tt.cfg.spikeArrest()
+				if interval != tt.wantInterval || allowance != tt.wantAllowance || ok != tt.wantOK {
+					t.Errorf(
+						"spikeArrest() = %v, %d, %v; want %v, %d, %v",
+						interval, allowance, ok, tt.wantInterval, tt.wantAllowance, tt.wantOK,
+					)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_SpikeArrest(t *testing.T) {
+	transport := &flakyTransport{}
+	rt := PerOriginRoundTripper(1000, 1000, transport)
+	// 2 requests per hour, so that the test does not straddle sub-intervals
+	rt.SetDefaultLimiterConfig(
+		LimiterConfig{Limit: 1000, Burst: 1000, SpikeArrest: 2.0 / 3600, SpikeArrestInterval: time.Hour},
+	)
+	for range 2 {
+		if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var spike *SpikeArrestError
+	if _, err := rt.RoundTrip(mustNewRequest(t)); !errors.As(err, &spike) {
+		t.Fatalf("RoundTrip() error = %v, want a *SpikeArrestError", err)
+	}
+	if spike.Allowance != 2 || !spike.Until.After(time.Now()) {
+		t.Errorf("SpikeArrestError = %+v", spike)
+	}
+	if transport.requests != 2 {
+		t.Errorf("transport sent %d requests, want 2", transport.requests)
+	}
+
+	rt.Synthesize429 = true
+	resp, err := rt.RoundTrip(mustNewRequest(t))
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v, want a synthesized response", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("response = %s with Retry-After %q", resp.Status, resp.Header.Get("Retry-After"))
+	}
+}
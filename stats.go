@@ -0,0 +1,211 @@
+package ratelim
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats summarize the requests sent for a key.
+type Stats struct {
+	// Requests is the number of requests sent for the key, or of operations permitted by a PerKeyLimiter.
+	Requests int64
+	// Redirects is the number of those requests which followed a redirect.
+	Redirects int64
+	// CrossKeyRedirects is the number of those redirects whose previous hop had a different key, as when a redirect
+	// crosses origins; with ChargeRedirectsToOrigin, they are charged to the key of the original request.
+	CrossKeyRedirects int64
+	// Retries is the number of retries sent for the key's requests under its RetryPolicy, which are not counted in
+	// Requests.
+	Retries int64
+	// Wait is the distribution of the time requests for the key waited to be permitted.
+	Wait Histogram
+	// Streams is the number of those requests which were streaming requests, of which OpenStreams have a response
+	// body which is not yet closed. StreamTime is the total time from the response of the closed streams until their
+	// body was closed.
+	Streams     int64
+	OpenStreams int64
+	StreamTime  time.Duration
+}
+
+// sub returns the difference between s and base, an earlier snapshot of the same counters.
+func (s Stats) sub(base Stats) Stats {
+	return Stats{
+		Requests:          s.Requests - base.Requests,
+		Redirects:         s.Redirects - base.Redirects,
+		CrossKeyRedirects: s.CrossKeyRedirects - base.CrossKeyRedirects,
+		Retries:           s.Retries - base.Retries,
+		Wait:              s.Wait.sub(base.Wait),
+		Streams:           s.Streams - base.Streams,
+		OpenStreams:       s.OpenStreams,
+		StreamTime:        s.StreamTime - base.StreamTime,
+	}
+}
+
+// StatsRotation configures the rotation of each key's Stats into windows of a fixed Interval, of which the last Keep
+// are kept, so that per-interval rates can be read without diffing cumulative Stats.
+type StatsRotation struct {
+	Interval time.Duration
+	// Keep is the number of windows kept; if not positive, 1 is used.
+	Keep int
+}
+
+// A StatsWindow holds the Stats of the requests sent for a key from Start until End.
+type StatsWindow struct {
+	Start, End time.Time
+	Stats
+}
+
+type keyStats struct {
+	requests          atomic.Int64
+	redirects         atomic.Int64
+	crossKeyRedirects atomic.Int64
+	retries           atomic.Int64
+	wait              *histogram
+	streams           atomic.Int64
+	openStreams       atomic.Int64
+	streamTime        atomic.Int64
+
+	mu          sync.Mutex
+	windowStart time.Time
+	base        Stats
+	windows     []StatsWindow
+}
+
+func (s *keyStats) snapshot() Stats {
+	return Stats{
+		Requests:          s.requests.Load(),
+		Redirects:         s.redirects.Load(),
+		CrossKeyRedirects: s.crossKeyRedirects.Load(),
+		Retries:           s.retries.Load(),
+		Wait:              s.wait.snapshot(),
+		Streams:           s.streams.Load(),
+		OpenStreams:       s.openStreams.Load(),
+		StreamTime:        time.Duration(s.streamTime.Load()),
+	}
+}
+
+// rotate closes the windows of r which ended by now, keeping the last r.Keep of them. The requests counted since the
+// last rotation are attributed to the first of those windows; those after it are empty, since requests are always
+// counted after a rotation.
+func (s *keyStats) rotate(r *StatsRotation, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+		return
+	}
+	n := int(now.Sub(s.windowStart) / r.Interval)
+	if n <= 0 {
+		return
+	}
+	keep := max(r.Keep, 1)
+	current := s.snapshot()
+	delta := current.sub(s.base)
+	s.base = current
+	for i := range n {
+		if i > 0 && n-i > keep {
+			// the window would be discarded below
+			continue
+		}
+		start := s.windowStart.Add(time.Duration(i) * r.Interval)
+		w := StatsWindow{Start: start, End: start.Add(r.Interval), Stats: delta}
+		if i > 0 {
+			w.Stats = delta.sub(delta)
+		}
+		s.windows = append(s.windows, w)
+	}
+	if len(s.windows) > keep {
+		s.windows = append([]StatsWindow(nil), s.windows[len(s.windows)-keep:]...)
+	}
+	s.windowStart = s.windowStart.Add(time.Duration(n) * r.Interval)
+}
+
+// keyStats returns the statistics of key, creating them the first time key is seen.
+func (l *PerKeyLimiter[K]) keyStats(key K) *keyStats {
+	if s, ok := l.stats.Load(key); ok {
+		return s
+	}
+	return loadOrCompute(
+		l.stats, key, func() *keyStats {
+			buckets := l.WaitBuckets
+			if buckets == nil {
+				buckets = DefaultWaitBuckets
+			}
+			s := &keyStats{wait: newHistogram(buckets)}
+			if l.rotatesStats() {
+				s.windowStart = time.Now()
+			}
+			return s
+		},
+	)
+}
+
+func (l *PerKeyLimiter[K]) rotatesStats() bool {
+	return l.StatsRotation != nil && l.StatsRotation.Interval > 0
+}
+
+// record counts an operation, or request, permitted for key after waiting for wait, and returns the key's statistics.
+func (l *PerKeyLimiter[K]) record(key K, wait time.Duration) *keyStats {
+	s := l.keyStats(key)
+	if l.rotatesStats() {
+		s.rotate(l.StatsRotation, time.Now())
+	}
+	s.requests.Add(1)
+	s.wait.observe(wait)
+	return s
+}
+
+// recordRequest counts a request sent for key after waiting for wait, which followed hops redirects through the keys
+// of chain.
+func (t *PerKeyRoundTripper[K]) recordRequest(key K, wait time.Duration, hops int, chain []K) {
+	s := t.record(key, wait)
+	if hops > 0 {
+		s.redirects.Add(1)
+		if chain[len(chain)-2] != chain[len(chain)-1] {
+			s.crossKeyRedirects.Add(1)
+		}
+	}
+}
+
+// Stats returns the Stats of the requests sent for key.
+func (l *PerKeyLimiter[K]) Stats(key K) Stats {
+	if s, ok := l.stats.Load(key); ok {
+		return s.snapshot()
+	}
+	return Stats{}
+}
+
+// AllStats returns the Stats of every key for which requests have been sent.
+func (l *PerKeyLimiter[K]) AllStats() map[K]Stats {
+	all := make(map[K]Stats)
+	l.stats.Range(
+		func(key K, s *keyStats) bool {
+			all[key] = s.snapshot()
+			return true
+		},
+	)
+	return all
+}
+
+// StatsWindows returns the windows of the Stats of key which have ended, oldest first, if StatsRotation is set.
+func (l *PerKeyLimiter[K]) StatsWindows(key K) []StatsWindow {
+	s, ok := l.stats.Load(key)
+	if !ok || !l.rotatesStats() {
+		return nil
+	}
+	s.rotate(l.StatsRotation, time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StatsWindow(nil), s.windows...)
+}
+
+// ResetStats discards the Stats, and their windows, of key.
+func (l *PerKeyLimiter[K]) ResetStats(key K) {
+	l.stats.Delete(key)
+}
+
+// ResetAllStats discards the Stats, and their windows, of every key.
+func (l *PerKeyLimiter[K]) ResetAllStats() {
+	l.stats.Clear()
+}
@@ -0,0 +1,64 @@
+package ratelim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyStats_rotate(t *testing.T) {
+	r := &StatsRotation{Interval: time.Minute, Keep: 2}
+	s := &keyStats{wait: newHistogram(DefaultWaitBuckets)}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.rotate(r, start)
+	s.requests.Add(3)
+	s.wait.observe(time.Second)
+	s.rotate(r, start.Add(90*time.Second))
+	s.requests.Add(2)
+
+	if len(s.windows) != 1 {
+		t.Fatalf("got %d windows, want 1", len(s.windows))
+	}
+	w := s.windows[0]
+	if !w.Start.Equal(start) || !w.End.Equal(start.Add(time.Minute)) {
+		t.Errorf("window = [%v, %v), want [%v, %v)", w.Start, w.End, start, start.Add(time.Minute))
+	}
+	if w.Requests != 3 || w.Wait.Count != 1 {
+		t.Errorf("window counted %d requests and %d waits, want 3 and 1", w.Requests, w.Wait.Count)
+	}
+
+	// after an idle hour, only the last Keep windows are kept: the one which counted the 2 requests has been dropped
+	s.rotate(r, start.Add(time.Hour+time.Second))
+	if len(s.windows) != 2 {
+		t.Fatalf("got %d windows, want 2", len(s.windows))
+	}
+	for _, w := range s.windows {
+		if w.Requests != 0 {
+			t.Errorf("window [%v, %v) counted %d requests, want 0", w.Start, w.End, w.Requests)
+		}
+	}
+	if got, want := s.windows[1].End, start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("last window ends at %v, want %v", got, want)
+	}
+}
+
+func TestPerKeyRoundTripper_ResetStats(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 10, nil)
+	rt.StatsRotation = &StatsRotation{Interval: time.Hour}
+	rt.recordRequest("a", 0, 0, nil)
+	rt.recordRequest("b", 0, 0, nil)
+	if got := rt.StatsWindows("a"); len(got) != 0 {
+		t.Errorf("StatsWindows() = %v, want no ended windows", got)
+	}
+
+	rt.ResetStats("a")
+	if got := rt.Stats("a").Requests; got != 0 {
+		t.Errorf("Stats(a).Requests = %d after ResetStats, want 0", got)
+	}
+	if got := rt.Stats("b").Requests; got != 1 {
+		t.Errorf("Stats(b).Requests = %d, want 1", got)
+	}
+	rt.ResetAllStats()
+	if got := rt.AllStats(); len(got) != 0 {
+		t.Errorf("AllStats() = %v after ResetAllStats, want none", got)
+	}
+}
@@ -0,0 +1,45 @@
+package ratelim
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AcceptsEventStream reports whether req accepts a text/event-stream response, as Server-Sent Events requests do, e.g.
+// to be used as the Streaming func of a PerKeyRoundTripper.
+func AcceptsEventStream(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(mediaRange); err == nil && mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streaming reports whether req is a streaming request according to the transport's Streaming func.
+func (t *PerKeyRoundTripper[K]) streaming(req *http.Request) bool {
+	return t.Streaming != nil && t.Streaming(req)
+}
+
+// trackStream counts resp, the response to a streaming request for key, if any, as an open stream until its body is
+// closed, after which it records the duration of the stream.
+func (t *PerKeyRoundTripper[K]) trackStream(key K, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	s := t.keyStats(key)
+	s.streams.Add(1)
+	s.openStreams.Add(1)
+	start := time.Now()
+	resp.Body = &onCloseBody{
+		ReadCloser: resp.Body,
+		done: func() {
+			s.openStreams.Add(-1)
+			s.streamTime.Add(int64(time.Since(start)))
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package ratelim
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAcceptsEventStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []string
+		want   bool
+	}{
+		{name: "event stream", accept: []string{"text/event-stream"}, want: true},
+		{name: "media range list", accept: []string{"application/json;q=0.9, text/event-stream;q=1"}, want: true},
+		{name: "second header", accept: []string{"application/json", "text/event-stream"}, want: true},
+		{name: "other", accept: []string{"application/json"}},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+				for _, accept := range tt.accept {
+					req.Header.Add("Accept", accept)
+				}
+				if got := AcceptsEventStream(req); got != tt.want {
+					t.Errorf("AcceptsEventStream() = %t, want %t", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func newStreamRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	return req
+}
+
+func TestPerKeyRoundTripper_Streaming(t *testing.T) {
+	rt := PerOriginRoundTripper(1, 1, &flakyTransport{})
+	rt.Streaming = AcceptsEventStream
+	rt.ResponseCost = func(resp *http.Response) int {
+		return 10
+	}
+	resp, err := rt.RoundTrip(newStreamRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := rt.Stats("https://example.com")
+	if stats.Streams != 1 || stats.OpenStreams != 1 {
+		t.Errorf("Stats() of open stream = %+v", stats)
+	}
+	time.Sleep(20 * time.Millisecond)
+	_ = resp.Body.Close()
+	_ = resp.Body.Close()
+	stats = rt.Stats("https://example.com")
+	if stats.OpenStreams != 0 || stats.StreamTime < 20*time.Millisecond {
+		t.Errorf("Stats() of closed stream = %+v", stats)
+	}
+	// the stream was charged a single token, regardless of its ResponseCost
+	if tokens := rt.Limiter(newStreamRequest(t)).Tokens(); tokens < -0.1 {
+		t.Errorf("limiter has %.2f tokens, want 0", tokens)
+	}
+}
+
+func TestPerKeyRoundTripper_MaxStreams(t *testing.T) {
+	rt := PerOriginRoundTripper(1, 1, &flakyTransport{})
+	rt.Streaming = AcceptsEventStream
+	rt.SetDefaultLimiterConfig(LimiterConfig{Limit: 1, Burst: 1, MaxStreams: 2})
+	var open []*http.Response
+	for range 2 {
+		resp, err := rt.RoundTrip(newStreamRequest(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		open = append(open, resp)
+	}
+	// streams are not rate limited
+	if tokens := rt.Limiter(newStreamRequest(t)).Tokens(); tokens < 0.9 {
+		t.Errorf("limiter has %.2f tokens, want 1", tokens)
+	}
+	opened := make(chan *http.Response)
+	go func() {
+		resp, err := rt.RoundTrip(newStreamRequest(t))
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- resp
+	}()
+	select {
+	case <-opened:
+		t.Fatal("third stream opened while 2 were open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_ = open[0].Body.Close()
+	select {
+	case resp := <-opened:
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("third stream did not open after one was closed")
+	}
+	_ = open[1].Body.Close()
+	if n := rt.streams.Len(); n != 0 {
+		t.Errorf("%d keys hold streams after all were closed", n)
+	}
+}
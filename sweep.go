@@ -0,0 +1,169 @@
+package ratelim
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A sweeper is the background goroutine of a PerKeyRoundTripper, which evicts idle keys and applies its Schedule.
+type sweeper struct {
+	started atomic.Bool
+	mux     sync.Mutex
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+	// scheduled is the config last applied from the Schedule
+	scheduled *LimiterConfig
+}
+
+// sweeps reports whether the transport needs its background goroutine.
+func (t *PerKeyRoundTripper[K]) sweeps() bool {
+	return t.IdleTimeout > 0 || t.Schedule != nil
+}
+
+// startSweeper starts the background goroutine, unless it is not needed, already started or the transport is closed.
+// The Schedule is applied before it starts, so that the request starting it is subject to the scheduled config.
+func (t *PerKeyRoundTripper[K]) startSweeper() {
+	s := &t.sweeper
+	if !t.sweeps() || s.started.Load() {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.started.Load() || s.closed {
+		return
+	}
+	t.applySchedule(time.Now())
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go t.sweep(s.stop, s.done)
+	s.started.Store(true)
+}
+
+// sweep evicts idle keys every SweepInterval, and applies the Schedule at each of its transitions, until stop is
+// closed, then closes done.
+func (t *PerKeyRoundTripper[K]) sweep(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	interval := orDefault(t.SweepInterval, time.Minute, t.SweepInterval > 0)
+	nextSweep := time.Now().Add(interval)
+	for {
+		wake := nextSweep
+		if t.Schedule != nil {
+			if next := t.Schedule.NextTransition(time.Now()); !next.IsZero() && next.Before(wake) {
+				wake = next
+			}
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		now := time.Now()
+		t.applySchedule(now)
+		if !now.Before(nextSweep) {
+			t.EvictIdle()
+			nextSweep = now.Add(interval)
+		}
+	}
+}
+
+// applySchedule sets the default config to the one scheduled at now by the Schedule, if any, and reconfigures the
+// existing limiters if it changed.
+func (t *PerKeyRoundTripper[K]) applySchedule(now time.Time) {
+	if t.Schedule == nil {
+		return
+	}
+	cfg := t.Schedule.ConfigAt(now)
+	if last := t.sweeper.scheduled; last != nil && *last == cfg {
+		return
+	}
+	t.sweeper.scheduled = &cfg
+	t.SetDefaultLimiterConfig(cfg)
+	t.Reconfigure()
+}
+
+// Close stops the background goroutine which evicts idle keys and applies the Schedule, if it was started, and waits
+// for it to exit. Requests may still be sent afterwards, but the goroutine is not restarted. It always returns nil.
+func (t *PerKeyRoundTripper[K]) Close() error {
+	s := &t.sweeper
+	s.mux.Lock()
+	s.closed = true
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mux.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
+
+// touch records that a request for key was just seen, if idle keys are evicted.
+func (t *PerKeyRoundTripper[K]) touch(key K) {
+	if t.IdleTimeout <= 0 {
+		return
+	}
+	loadOrCompute[K](t.lastSeen, key, newValue[atomic.Int64]).Store(time.Now().UnixNano())
+}
+
+// EvictIdle removes the state kept for each key which has had no requests for IdleTimeout and whose rate.Limiter has
+// refilled, so that it would be recreated as it is, and returns the number of keys evicted. Its limiters, holds, waiter
+// counts, wait queue, costs and transport are removed. Its Stats, the configs set for it, the limits learned from its
+// responses or by discovery, and the states of the Breaker, Shedder and LatencyController are kept, unless MaxKeys is
+// set, in which case all its state but its configs is removed, making room for new keys. It is called every
+// SweepInterval by the background goroutine.
+func (t *PerKeyRoundTripper[K]) EvictIdle() int {
+	if t.IdleTimeout <= 0 {
+		return 0
+	}
+	now := time.Now()
+	evicted := 0
+	t.lastSeen.Range(
+		func(key K, seen *atomic.Int64) bool {
+			if now.Sub(time.Unix(0, seen.Load())) < t.IdleTimeout || !evictFull(t.limiters, key, now) {
+				return true
+			}
+			for _, m := range []*Map[K]{t.softLimiters, t.smoothers, t.retryBudgets} {
+				evictFull(m, key, now)
+			}
+			t.costs.Delete(key)
+			t.spikes.Delete(key)
+			if until, ok := t.holds.Load(key); ok && !until.After(now) {
+				t.holds.CompareAndDelete(key, until)
+			}
+			if waiters, ok := t.waiters.Load(key); ok && waiters.Load() == 0 {
+				t.waiters.CompareAndDelete(key, waiters)
+			}
+			if queue, ok := t.waitQueues.Load(key); ok && queue.len() == 0 {
+				t.waitQueues.CompareAndDelete(key, queue)
+			}
+			t.http2.Delete(key)
+			t.closeTransport(key)
+			t.lastSeen.CompareAndDelete(key, seen)
+			if t.MaxKeys > 0 {
+				t.keyTable.remove(key, t.forget)
+			}
+			evicted++
+			return true
+		},
+	)
+	return evicted
+}
+
+// evictFull deletes the rate.Limiter of key from m, if its bucket is full at now, and reports whether m no longer has
+// a limiter for key.
+func evictFull[K comparable](m *Map[K], key K, now time.Time) bool {
+	limiter, ok := m.Load(key)
+	if !ok {
+		return true
+	}
+	if limiter.Limit() != rate.Inf && limiter.TokensAt(now) < float64(limiter.Burst()) {
+		return false
+	}
+	m.CompareAndDelete(key, limiter)
+	return true
+}
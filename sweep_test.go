@@ -0,0 +1,73 @@
+package ratelim
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPerKeyRoundTripper_EvictIdle(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 1, &flakyTransport{})
+	rt.IdleTimeout = 10 * time.Millisecond
+	rt.TransportFunc = func(string) http.RoundTripper {
+		return &flakyTransport{}
+	}
+	rt.SetLimiterConfig("https://example.org", LimiterConfig{Limit: rate.Every(time.Hour), Burst: 1})
+	for _, url := range []string{"https://example.com/", "https://example.org/"} {
+		req := mustNewRequest(t)
+		req.URL = mustParseURL(t, url)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if evicted := rt.EvictIdle(); evicted != 0 {
+		t.Errorf("EvictIdle() = %d before IdleTimeout, want 0", evicted)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// the limiter of example.org has not refilled yet
+	if evicted := rt.EvictIdle(); evicted != 1 {
+		t.Errorf("EvictIdle() = %d, want 1", evicted)
+	}
+	if keys := rt.Limiters().Keys(); len(keys) != 1 || keys[0] != "https://example.org" {
+		t.Errorf("limiters of %q kept, want only https://example.org", keys)
+	}
+	if stats := rt.Stats("https://example.com"); stats.Requests != 1 {
+		t.Errorf("Stats().Requests = %d after eviction, want 1", stats.Requests)
+	}
+	for name, keys := range map[string][]string{"waiters": rt.waiters.Keys(), "transports": rt.transports.Keys()} {
+		if len(keys) != 1 || keys[0] != "https://example.org" {
+			t.Errorf("%s of %q kept, want only https://example.org", name, keys)
+		}
+	}
+}
+
+func TestPerKeyRoundTripper_sweeper(t *testing.T) {
+	rt := PerOriginRoundTripper(1000, 1, &flakyTransport{})
+	rt.IdleTimeout = time.Millisecond
+	rt.SweepInterval = 5 * time.Millisecond
+	rt.Schedule = &Schedule{Default: LimiterConfig{Limit: 500, Burst: 5}}
+	if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := rt.LimiterConfig("https://example.com"); cfg != rt.Schedule.Default {
+		t.Errorf("LimiterConfig() = %+v, want the scheduled config", cfg)
+	}
+	deadline := time.Now().Add(time.Second)
+	for rt.Limiters().Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := rt.Limiters().Len(); n != 0 {
+		t.Errorf("%d limiters left, want the idle key evicted", n)
+	}
+	if err := rt.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := rt.RoundTrip(mustNewRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	if rt.sweeper.stop != nil {
+		t.Error("background goroutine restarted after Close()")
+	}
+}
@@ -0,0 +1,214 @@
+package syncmap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type expiringEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func (e expiringEntry[V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// An ExpiringMap is a concurrent map whose entries expire after a time-to-live (TTL). Expired entries are never
+// returned; they are removed lazily when accessed, and in bulk by DeleteExpired, which Run calls periodically in the
+// background. A callback may be set to be notified of each entry removed by expiry.
+type ExpiringMap[K comparable, V any] struct {
+	mux        sync.Mutex
+	wrapped    map[K]expiringEntry[V]
+	defaultTTL time.Duration
+	onExpire   func(key K, value V)
+	now        func() time.Time
+}
+
+// NewExpiring returns a new ExpiringMap whose entries expire after defaultTTL unless stored with a TTL of their own; a
+// defaultTTL which is not positive means entries do not expire by default. If onExpire is non-nil, it is called with
+// each entry removed by expiry, without the map locked.
+func NewExpiring[K comparable, V any](defaultTTL time.Duration, onExpire func(key K, value V)) *ExpiringMap[K, V] {
+	return &ExpiringMap[K, V]{
+		wrapped:    make(map[K]expiringEntry[V]),
+		defaultTTL: defaultTTL,
+		onExpire:   onExpire,
+		now:        time.Now,
+	}
+}
+
+func (m *ExpiringMap[K, V]) expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// load returns the unexpired entry for key, removing it if expired. m.mux must be held; the expired entry, if any, is
+// returned so that onExpire may be called once m.mux is released.
+func (m *ExpiringMap[K, V]) load(key K, now time.Time) (e expiringEntry[V], ok bool, expired *expiringEntry[V]) {
+	e, ok = m.wrapped[key]
+	if ok && e.expired(now) {
+		delete(m.wrapped, key)
+		return e, false, &e
+	}
+	return e, ok, nil
+}
+
+func (m *ExpiringMap[K, V]) notify(key K, expired *expiringEntry[V]) {
+	if expired != nil && m.onExpire != nil {
+		m.onExpire(key, expired.value)
+	}
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no unexpired value is present.
+// The ok result indicates whether value was found in the map.
+func (m *ExpiringMap[K, V]) Load(key K) (value V, ok bool) {
+	m.mux.Lock()
+	e, ok, expired := m.load(key, m.now())
+	m.mux.Unlock()
+	m.notify(key, expired)
+	return e.value, ok
+}
+
+// Store sets the value for a key, expiring after the default TTL.
+func (m *ExpiringMap[K, V]) Store(key K, value V) {
+	m.StoreWithTTL(key, value, m.defaultTTL)
+}
+
+// StoreWithTTL sets the value for a key, expiring after ttl; if ttl is not positive, the entry does not expire.
+func (m *ExpiringMap[K, V]) StoreWithTTL(key K, value V, ttl time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.wrapped[key] = expiringEntry[V]{value: value, expires: m.expiry(m.now(), ttl)}
+}
+
+// LoadOrCompute returns the existing unexpired value for the key if present.
+// Otherwise, it calls f and stores its result, expiring after the default TTL, and returns it.
+// The loaded result is true if the value was loaded, false if computed.
+//
+// f is called while the map is locked, so it must not call any methods on m.
+func (m *ExpiringMap[K, V]) LoadOrCompute(key K, f func() V) (actual V, loaded bool) {
+	m.mux.Lock()
+	now := m.now()
+	e, ok, expired := m.load(key, now)
+	if !ok {
+		e = expiringEntry[V]{value: f(), expires: m.expiry(now, m.defaultTTL)}
+		m.wrapped[key] = e
+	}
+	m.mux.Unlock()
+	m.notify(key, expired)
+	return e.value, ok
+}
+
+// Touch resets the TTL of the entry for key to the default TTL, reporting whether an unexpired entry was present.
+// Touching entries as they are used makes the default TTL an idle timeout.
+func (m *ExpiringMap[K, V]) Touch(key K) bool {
+	m.mux.Lock()
+	now := m.now()
+	e, ok, expired := m.load(key, now)
+	if ok {
+		e.expires = m.expiry(now, m.defaultTTL)
+		m.wrapped[key] = e
+	}
+	m.mux.Unlock()
+	m.notify(key, expired)
+	return ok
+}
+
+// ExpiresAt returns the time at which the entry for key expires, which is the zero time.Time if it does not expire.
+// The ok result reports whether an unexpired entry was present.
+func (m *ExpiringMap[K, V]) ExpiresAt(key K) (expires time.Time, ok bool) {
+	m.mux.Lock()
+	e, ok, expired := m.load(key, m.now())
+	m.mux.Unlock()
+	m.notify(key, expired)
+	return e.expires, ok
+}
+
+// Delete deletes the value for a key, without calling the expiry callback.
+func (m *ExpiringMap[K, V]) Delete(key K) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.wrapped, key)
+}
+
+// Len returns the number of entries in the map, including any expired entries not yet removed.
+func (m *ExpiringMap[K, V]) Len() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return len(m.wrapped)
+}
+
+// Keys returns a slice containing the keys of the map's unexpired entries.
+func (m *ExpiringMap[K, V]) Keys() []K {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	now := m.now()
+	keys := make([]K, 0, len(m.wrapped))
+	for key, e := range m.wrapped {
+		if !e.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Range calls f sequentially for each key and unexpired value present in the map.
+// If f returns false, range stops the iteration.
+//
+// As with SyncMap.Range, Range does not correspond to any consistent snapshot of the map's contents, and f may call
+// any method on m.
+func (m *ExpiringMap[K, V]) Range(f func(key K, value V) bool) {
+	for _, key := range m.Keys() {
+		value, ok := m.Load(key)
+		if !ok {
+			continue
+		}
+		if !f(key, value) {
+			break
+		}
+	}
+}
+
+// DeleteExpired removes all expired entries, calling the expiry callback for each, and returns the number removed.
+func (m *ExpiringMap[K, V]) DeleteExpired() int {
+	m.mux.Lock()
+	now := m.now()
+	var expired []Entry[K, V]
+	for key, e := range m.wrapped {
+		if e.expired(now) {
+			delete(m.wrapped, key)
+			expired = append(expired, Entry[K, V]{Key: key, Value: e.value})
+		}
+	}
+	m.mux.Unlock()
+	if m.onExpire != nil {
+		for _, e := range expired {
+			m.onExpire(e.Key, e.Value)
+		}
+	}
+	return len(expired)
+}
+
+// Run calls DeleteExpired every interval until ctx is done.
+func (m *ExpiringMap[K, V]) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.DeleteExpired()
+		}
+	}
+}
+
+func (m *ExpiringMap[K, V]) String() string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return fmt.Sprintf("%T{len:%d, defaultTTL:%v}", m, len(m.wrapped), m.defaultTTL)
+}
@@ -0,0 +1,50 @@
+package syncmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiringMap(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var expired []string
+	m := NewExpiring[string, int](
+		time.Minute, func(key string, value int) {
+			expired = append(expired, key)
+		},
+	)
+	m.now = func() time.Time { return now }
+
+	m.Store("default", 1)
+	m.StoreWithTTL("short", 2, time.Second)
+	m.StoreWithTTL("forever", 3, 0)
+	m.LoadOrCompute("idle", func() int { return 4 })
+
+	now = now.Add(2 * time.Second)
+	if _, ok := m.Load("short"); ok {
+		t.Error("short entry not expired")
+	}
+	if len(expired) != 1 || expired[0] != "short" {
+		t.Errorf("expired = %v after lazy expiry, want [short]", expired)
+	}
+
+	now = now.Add(50 * time.Second)
+	if !m.Touch("idle") {
+		t.Error("Touch() found no entry")
+	}
+	now = now.Add(30 * time.Second)
+	if n := m.DeleteExpired(); n != 1 {
+		t.Errorf("DeleteExpired() = %d, want 1", n)
+	}
+	if _, ok := m.Load("default"); ok {
+		t.Error("default entry not expired")
+	}
+	for _, key := range []string{"idle", "forever"} {
+		if _, ok := m.Load(key); !ok {
+			t.Errorf("%s entry expired", key)
+		}
+	}
+	if m.Len() != 2 || len(expired) != 2 {
+		t.Errorf("Len() = %d, expired = %v", m.Len(), expired)
+	}
+}
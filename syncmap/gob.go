@@ -0,0 +1,76 @@
+package syncmap
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+func gobEncode[K comparable, V any](wrapped map[K]V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wrapped); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode[K comparable, V any](data []byte) (map[K]V, error) {
+	var decoded map[K]V
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// MarshalBinary encodes a consistent snapshot of the SyncMap with encoding/gob, which also uses it to encode a SyncMap
+// nested in other values. As with any map encoded by encoding/gob, interface values must have their concrete types
+// registered with gob.Register.
+func (m *SyncMap[K, V]) MarshalBinary() ([]byte, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return gobEncode(m.wrapped)
+}
+
+// UnmarshalBinary decodes data encoded by MarshalBinary into the SyncMap. As with UnmarshalJSON, existing entries are
+// kept unless replaced by a decoded entry.
+func (m *SyncMap[K, V]) UnmarshalBinary(data []byte) error {
+	decoded, err := gobDecode[K, V](data)
+	if err != nil {
+		return err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.wrapped == nil {
+		m.wrapped = make(map[K]V, len(decoded))
+	}
+	for key, value := range decoded {
+		m.set(key, value)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the map with encoding/gob, reading its shards in turn. The encoding is the same as that of a
+// SyncMap with the same entries, and does not record the number of shards.
+func (m *ShardedMap[K, V]) MarshalBinary() ([]byte, error) {
+	entries := m.Entries()
+	snapshot := make(map[K]V, len(entries))
+	for _, e := range entries {
+		snapshot[e.Key] = e.Value
+	}
+	return gobEncode(snapshot)
+}
+
+// UnmarshalBinary decodes data encoded by MarshalBinary into the map. As with UnmarshalJSON, existing entries are kept
+// unless replaced by a decoded entry, and a zero ShardedMap is given the default number of shards.
+func (m *ShardedMap[K, V]) UnmarshalBinary(data []byte) error {
+	decoded, err := gobDecode[K, V](data)
+	if err != nil {
+		return err
+	}
+	if m.shards == nil {
+		*m = *NewSharded[K, V](0)
+	}
+	for key, value := range decoded {
+		m.Store(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package syncmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+type state struct {
+	Tokens *SyncMap[string, float64]
+	Ports  *ShardedMap[int, float64]
+}
+
+func TestGob(t *testing.T) {
+	in := state{
+		Tokens: New[string, float64](),
+		Ports:  NewSharded[int, float64](4),
+	}
+	in.Tokens.Store("https://example.com", 2.5)
+	in.Ports.Store(443, 10)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out state
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := out.Tokens.Load("https://example.com"); !ok || v != 2.5 {
+		t.Errorf("decoded Tokens.Load() = %v, %t, want 2.5, true", v, ok)
+	}
+	if v, ok := out.Ports.Load(443); !ok || v != 10 || out.Ports.Len() != 1 {
+		t.Errorf("decoded Ports.Load() = %v, %t, want 10, true", v, ok)
+	}
+}
@@ -0,0 +1,58 @@
+package syncmap
+
+import (
+	"encoding/json"
+)
+
+// MarshalJSON encodes a consistent snapshot of the SyncMap as a JSON object. As with any map encoded by encoding/json,
+// the key type must be a string or integer type or implement encoding.TextMarshaler.
+func (m *SyncMap[K, V]) MarshalJSON() ([]byte, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return json.Marshal(m.wrapped)
+}
+
+// UnmarshalJSON decodes a JSON object into the SyncMap. As with any map decoded by encoding/json, existing entries are
+// kept unless replaced by an entry of the object.
+func (m *SyncMap[K, V]) UnmarshalJSON(data []byte) error {
+	var decoded map[K]V
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.wrapped == nil {
+		m.wrapped = make(map[K]V, len(decoded))
+	}
+	for key, value := range decoded {
+		m.set(key, value)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the map as a JSON object, reading its shards in turn. As with any map encoded by encoding/json,
+// the key type must be a string or integer type or implement encoding.TextMarshaler.
+func (m *ShardedMap[K, V]) MarshalJSON() ([]byte, error) {
+	entries := m.Entries()
+	snapshot := make(map[K]V, len(entries))
+	for _, e := range entries {
+		snapshot[e.Key] = e.Value
+	}
+	return json.Marshal(snapshot)
+}
+
+// UnmarshalJSON decodes a JSON object into the map. As with any map decoded by encoding/json, existing entries are
+// kept unless replaced by an entry of the object. A zero ShardedMap is given the default number of shards.
+func (m *ShardedMap[K, V]) UnmarshalJSON(data []byte) error {
+	var decoded map[K]V
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if m.shards == nil {
+		*m = *NewSharded[K, V](0)
+	}
+	for key, value := range decoded {
+		m.Store(key, value)
+	}
+	return nil
+}
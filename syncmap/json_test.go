@@ -0,0 +1,47 @@
+package syncmap
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type limits struct {
+	Origins *SyncMap[string, float64] `json:"origins"`
+	Ports   *ShardedMap[int, float64] `json:"ports"`
+	Empty   *SyncMap[string, int]     `json:"empty"`
+}
+
+func TestJSON(t *testing.T) {
+	in := limits{
+		Origins: New[string, float64](),
+		Ports:   NewSharded[int, float64](4),
+		Empty:   New[string, int](),
+	}
+	in.Origins.Store("https://example.com", 2.5)
+	in.Ports.Store(443, 10)
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"origins":{"https://example.com":2.5},"ports":{"443":10},"empty":{}}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+	var out limits
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := out.Origins.Load("https://example.com"); v != 2.5 {
+		t.Errorf("decoded origin limit = %v, want 2.5", v)
+	}
+	if v, _ := out.Ports.Load(443); v != 10 {
+		t.Errorf("decoded port limit = %v, want 10", v)
+	}
+	out.Origins.Store("extra", 1)
+	if err := json.Unmarshal([]byte(`{"https://example.com":3}`), out.Origins); err != nil {
+		t.Fatal(err)
+	}
+	if n := out.Origins.Len(); n != 2 {
+		t.Errorf("decoding replaced existing entries: %d left", n)
+	}
+}
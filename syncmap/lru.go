@@ -0,0 +1,179 @@
+package syncmap
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// An LRUMap is a concurrent map holding at most a fixed number of entries. When storing a new key would exceed its
+// capacity, the least recently used entry is evicted; an entry is used when it is stored or loaded.
+//
+// An LRUMap bounds the memory used for per-key state when the number of distinct keys is unbounded, at the cost of
+// forgetting the state of keys which have not been used recently.
+type LRUMap[K comparable, V any] struct {
+	mux      sync.Mutex
+	wrapped  map[K]*list.Element
+	order    *list.List // of Entry[K, V], most recently used first
+	capacity int
+	onEvict  func(key K, value V)
+}
+
+// NewLRU returns a new LRUMap holding at most capacity entries, which must be positive. If onEvict is non-nil, it is
+// called with each entry evicted to make room for another, without the map locked.
+func NewLRU[K comparable, V any](capacity int, onEvict func(key K, value V)) *LRUMap[K, V] {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("syncmap: LRU capacity must be positive, got %d", capacity))
+	}
+	return &LRUMap[K, V]{
+		wrapped:  make(map[K]*list.Element),
+		order:    list.New(),
+		capacity: capacity,
+		onEvict:  onEvict,
+	}
+}
+
+// Capacity returns the maximum number of entries held by the map.
+func (m *LRUMap[K, V]) Capacity() int {
+	return m.capacity
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no value is present, marking the entry as
+// most recently used. The ok result indicates whether value was found in the map.
+func (m *LRUMap[K, V]) Load(key K) (value V, ok bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	e, ok := m.wrapped[key]
+	if !ok {
+		return value, false
+	}
+	m.order.MoveToFront(e)
+	return e.Value.(Entry[K, V]).Value, true
+}
+
+// Peek returns the value stored in the map for a key like Load, without marking the entry as used.
+func (m *LRUMap[K, V]) Peek(key K) (value V, ok bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	e, ok := m.wrapped[key]
+	if !ok {
+		return value, false
+	}
+	return e.Value.(Entry[K, V]).Value, true
+}
+
+// store sets the value for key and returns the entries evicted to make room for it. m.mux must be held.
+func (m *LRUMap[K, V]) store(key K, value V) (evicted []Entry[K, V]) {
+	if e, ok := m.wrapped[key]; ok {
+		e.Value = Entry[K, V]{Key: key, Value: value}
+		m.order.MoveToFront(e)
+		return nil
+	}
+	m.wrapped[key] = m.order.PushFront(Entry[K, V]{Key: key, Value: value})
+	for m.order.Len() > m.capacity {
+		e := m.order.Back()
+		entry := m.order.Remove(e).(Entry[K, V])
+		delete(m.wrapped, entry.Key)
+		evicted = append(evicted, entry)
+	}
+	return evicted
+}
+
+func (m *LRUMap[K, V]) notify(evicted []Entry[K, V]) {
+	if m.onEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		m.onEvict(e.Key, e.Value)
+	}
+}
+
+// Store sets the value for a key, marking the entry as most recently used and evicting the least recently used entry
+// if the map is full.
+func (m *LRUMap[K, V]) Store(key K, value V) {
+	m.mux.Lock()
+	evicted := m.store(key, value)
+	m.mux.Unlock()
+	m.notify(evicted)
+}
+
+// LoadOrCompute returns the existing value for the key if present, marking it as most recently used.
+// Otherwise, it calls f and stores its result as Store would, and returns it.
+// The loaded result is true if the value was loaded, false if computed.
+//
+// f is called while the map is locked, so it must not call any methods on m.
+func (m *LRUMap[K, V]) LoadOrCompute(key K, f func() V) (actual V, loaded bool) {
+	m.mux.Lock()
+	if e, ok := m.wrapped[key]; ok {
+		m.order.MoveToFront(e)
+		m.mux.Unlock()
+		return e.Value.(Entry[K, V]).Value, true
+	}
+	actual = f()
+	evicted := m.store(key, actual)
+	m.mux.Unlock()
+	m.notify(evicted)
+	return actual, false
+}
+
+// Delete deletes the value for a key, without calling the eviction callback.
+func (m *LRUMap[K, V]) Delete(key K) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if e, ok := m.wrapped[key]; ok {
+		m.order.Remove(e)
+		delete(m.wrapped, key)
+	}
+}
+
+// Len returns the number of entries in the map.
+func (m *LRUMap[K, V]) Len() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return len(m.wrapped)
+}
+
+// Entries returns a slice containing the map's entries, from most to least recently used.
+func (m *LRUMap[K, V]) Entries() []Entry[K, V] {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	entries := make([]Entry[K, V], 0, m.order.Len())
+	for e := m.order.Front(); e != nil; e = e.Next() {
+		entries = append(entries, e.Value.(Entry[K, V]))
+	}
+	return entries
+}
+
+// Keys returns a slice containing the map's keys, from most to least recently used.
+func (m *LRUMap[K, V]) Keys() []K {
+	entries := m.Entries()
+	keys := make([]K, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+// Range calls f sequentially for each key and value present in the map, from most to least recently used, without
+// marking entries as used. If f returns false, range stops the iteration.
+//
+// Range iterates over a snapshot of the map's entries, so f may call any method on m.
+func (m *LRUMap[K, V]) Range(f func(key K, value V) bool) {
+	for _, e := range m.Entries() {
+		if !f(e.Key, e.Value) {
+			break
+		}
+	}
+}
+
+// Clear deletes all the entries, without calling the eviction callback.
+func (m *LRUMap[K, V]) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	clear(m.wrapped)
+	m.order.Init()
+}
+
+func (m *LRUMap[K, V]) String() string {
+	return fmt.Sprintf("%T%v", m, m.Entries())
+}
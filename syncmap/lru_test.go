@@ -0,0 +1,38 @@
+package syncmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLRUMap(t *testing.T) {
+	var evicted []string
+	m := NewLRU[string, int](
+		3, func(key string, value int) {
+			evicted = append(evicted, key)
+		},
+	)
+	m.Store("a", 1)
+	m.Store("b", 2)
+	m.Store("c", 3)
+	m.Load("a")
+	m.Peek("b")
+	m.Store("d", 4)
+	if !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Errorf("evicted = %v, want [b]", evicted)
+	}
+	if v, loaded := m.LoadOrCompute("c", func() int { return -1 }); !loaded || v != 3 {
+		t.Errorf("LoadOrCompute(c) = %d, %t, want 3, true", v, loaded)
+	}
+	m.LoadOrCompute("e", func() int { return 5 })
+	if want := []string{"e", "c", "d"}; !reflect.DeepEqual(m.Keys(), want) {
+		t.Errorf("Keys() = %v, want %v", m.Keys(), want)
+	}
+	if !reflect.DeepEqual(evicted, []string{"b", "a"}) {
+		t.Errorf("evicted = %v, want [b a]", evicted)
+	}
+	m.Delete("c")
+	if m.Len() != 2 || len(evicted) != 2 {
+		t.Errorf("Len() = %d, evicted = %v after Delete", m.Len(), evicted)
+	}
+}
@@ -0,0 +1,70 @@
+package syncmap
+
+// Map is the API shared by SyncMap and ShardedMap, so that code holding per-key state can be written once and
+// configured with either: a SyncMap for few keys, or a ShardedMap where contention on a single lock dominates.
+type Map[K comparable, V any] interface {
+	Load(key K) (value V, ok bool)
+	Store(key K, value V)
+	Swap(key K, value V) (previous V, loaded bool)
+	Delete(key K)
+	LoadAndDelete(key K) (value V, loaded bool)
+	LoadOrStore(key K, value V) (actual V, loaded bool)
+	LoadOrCompute(key K, f func() V) (actual V, loaded bool)
+	LoadOrComputeErr(key K, f func() (V, error)) (actual V, loaded bool, err error)
+	Update(key K, f func(old V, loaded bool) (new V, keep bool)) (value V, ok bool)
+	CompareAndSwap(key K, old V, new V) bool
+	CompareAndDelete(key K, old V) (deleted bool)
+	CompareAndSwapFunc(key K, old V, new V, equal func(a, b V) bool) (swapped bool)
+	CompareAndDeleteFunc(key K, old V, equal func(a, b V) bool) (deleted bool)
+	Range(f func(key K, value V) bool)
+	Keys() []K
+	Values() []V
+	Entries() []Entry[K, V]
+	Len() int
+	Clear()
+}
+
+var (
+	_ Map[string, int] = (*SyncMap[string, int])(nil)
+	_ Map[string, int] = (*ShardedMap[string, int])(nil)
+)
+
+// A ReadOnlyMap is a view of a map without its mutation methods, to be handed to consumers which should only observe
+// it.
+type ReadOnlyMap[K comparable, V any] interface {
+	Load(key K) (value V, ok bool)
+	Range(f func(key K, value V) bool)
+	Keys() []K
+	Len() int
+}
+
+// readOnly hides the concrete map, so that a ReadOnlyMap cannot be converted back to it by a type assertion.
+type readOnly[K comparable, V any] struct {
+	m ReadOnlyMap[K, V]
+}
+
+func (r readOnly[K, V]) Load(key K) (value V, ok bool) {
+	return r.m.Load(key)
+}
+
+func (r readOnly[K, V]) Range(f func(key K, value V) bool) {
+	r.m.Range(f)
+}
+
+func (r readOnly[K, V]) Keys() []K {
+	return r.m.Keys()
+}
+
+func (r readOnly[K, V]) Len() int {
+	return r.m.Len()
+}
+
+// ReadOnly returns a read-only view of the SyncMap, which reflects later changes to it.
+func (m *SyncMap[K, V]) ReadOnly() ReadOnlyMap[K, V] {
+	return readOnly[K, V]{m: m}
+}
+
+// ReadOnly returns a read-only view of the map, which reflects later changes to it.
+func (m *ShardedMap[K, V]) ReadOnly() ReadOnlyMap[K, V] {
+	return readOnly[K, V]{m: m}
+}
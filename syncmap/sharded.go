@@ -0,0 +1,400 @@
+package syncmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"math"
+	"reflect"
+	"runtime"
+)
+
+// A ShardedMap is a concurrent map split into a number of SyncMap shards by the hash of each key, so that operations
+// on keys in different shards do not contend for the same lock. It is suited to workloads with many distinct keys
+// accessed by many goroutines at once.
+type ShardedMap[K comparable, V any] struct {
+	shards []*SyncMap[K, V]
+	mask   uint64
+	seed   maphash.Seed
+}
+
+// NewSharded returns a new ShardedMap with the given number of shards, rounded up to a power of two. If shards is not
+// positive, four times GOMAXPROCS is used.
+func NewSharded[K comparable, V any](shards int) *ShardedMap[K, V] {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	m := &ShardedMap[K, V]{
+		shards: make([]*SyncMap[K, V], n),
+		mask:   uint64(n - 1),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range m.shards {
+		m.shards[i] = New[K, V]()
+	}
+	return m
+}
+
+// Shards returns the number of shards in the map.
+func (m *ShardedMap[K, V]) Shards() int {
+	return len(m.shards)
+}
+
+func (m *ShardedMap[K, V]) shard(key K) *SyncMap[K, V] {
+	return m.shards[m.index(key)]
+}
+
+func (m *ShardedMap[K, V]) index(key K) uint64 {
+	return hashKey(m.seed, key) & m.mask
+}
+
+// hashKey returns a hash of key under seed which is equal for equal keys. Strings and integers are hashed directly,
+// and other comparable types by walking their values with reflection.
+func hashKey[K comparable](seed maphash.Seed, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return hashUint64(seed, uint64(k))
+	case int64:
+		return hashUint64(seed, uint64(k))
+	case uint64:
+		return hashUint64(seed, k)
+	}
+	return hashValue(seed, reflect.ValueOf(key))
+}
+
+func hashValue(seed maphash.Seed, v reflect.Value) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	writeValue(&h, v)
+	return h.Sum64()
+}
+
+func hashUint64(seed maphash.Seed, n uint64) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	return maphash.Bytes(seed, b[:])
+}
+
+func writeUint64(h *maphash.Hash, n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	_, _ = h.Write(b[:])
+}
+
+// writeValue writes v to h such that values which are == write the same bytes: floats are written with negative zero
+// as zero, interfaces as the type and value they hold, and pointers and channels as their addresses. Blank struct
+// fields, which == ignores, are skipped.
+func writeValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		// the nil interface
+		_ = h.WriteByte(0)
+	case reflect.String:
+		_, _ = h.WriteString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			_ = h.WriteByte(1)
+		} else {
+			_ = h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		writeFloat(h, real(v.Complex()))
+		writeFloat(h, imag(v.Complex()))
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Chan:
+		writeUint64(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			_ = h.WriteByte(0)
+			return
+		}
+		_, _ = h.WriteString(v.Elem().Type().String())
+		writeValue(h, v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Name != "_" {
+				writeValue(h, v.Field(i))
+			}
+		}
+	default:
+		panic("syncmap: hash of unhashable type " + v.Type().String())
+	}
+}
+
+func writeFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		f = 0 // +0 == -0
+	}
+	writeUint64(h, math.Float64bits(f))
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no value is present.
+// The ok result indicates whether value was found in the map.
+func (m *ShardedMap[K, V]) Load(key K) (value V, ok bool) {
+	return m.shard(key).Load(key)
+}
+
+// Store sets the value for a key.
+func (m *ShardedMap[K, V]) Store(key K, value V) {
+	m.shard(key).Store(key, value)
+}
+
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *ShardedMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	return m.shard(key).Swap(key, value)
+}
+
+// Delete deletes the value for a key.
+func (m *ShardedMap[K, V]) Delete(key K) {
+	m.shard(key).Delete(key)
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *ShardedMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	return m.shard(key).LoadAndDelete(key)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *ShardedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	return m.shard(key).LoadOrStore(key, value)
+}
+
+// LoadOrCompute returns the existing value for the key if present.
+// Otherwise, it calls f and stores and returns its result.
+// The loaded result is true if the value was loaded, false if computed.
+//
+// f is called at most once per key stored, while the key's shard is locked, so it must not call any methods on m.
+func (m *ShardedMap[K, V]) LoadOrCompute(key K, f func() V) (actual V, loaded bool) {
+	return m.shard(key).LoadOrCompute(key, f)
+}
+
+// LoadOrComputeErr is like LoadOrCompute, but f may fail, in which case nothing is
+// stored and its error is returned.
+func (m *ShardedMap[K, V]) LoadOrComputeErr(key K, f func() (V, error)) (actual V, loaded bool, err error) {
+	return m.shard(key).LoadOrComputeErr(key, f)
+}
+
+// Update atomically updates the entry for key, as SyncMap.Update does.
+//
+// f is called while the key's shard is locked, so it must not call any methods on m.
+func (m *ShardedMap[K, V]) Update(key K, f func(old V, loaded bool) (new V, keep bool)) (value V, ok bool) {
+	return m.shard(key).Update(key, f)
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the map is equal to old.
+// The old value must be of a comparable type.
+func (m *ShardedMap[K, V]) CompareAndSwap(key K, old V, new V) bool {
+	return m.shard(key).CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+func (m *ShardedMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	return m.shard(key).CompareAndDelete(key, old)
+}
+
+// CompareAndSwapFunc swaps the old and new values for key if a value is present in the map and equal(value, old)
+// returns true, as SyncMap.CompareAndSwapFunc does.
+func (m *ShardedMap[K, V]) CompareAndSwapFunc(key K, old V, new V, equal func(a, b V) bool) (swapped bool) {
+	return m.shard(key).CompareAndSwapFunc(key, old, new, equal)
+}
+
+// CompareAndDeleteFunc deletes the entry for key if a value is present in the map and equal(value, old) returns true,
+// as SyncMap.CompareAndDeleteFunc does.
+func (m *ShardedMap[K, V]) CompareAndDeleteFunc(key K, old V, equal func(a, b V) bool) (deleted bool) {
+	return m.shard(key).CompareAndDeleteFunc(key, old, equal)
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// As with SyncMap.Range, Range does not correspond to any consistent snapshot of the map's contents.
+func (m *ShardedMap[K, V]) Range(f func(key K, value V) bool) {
+	for _, shard := range m.shards {
+		stopped := false
+		shard.Range(
+			func(key K, value V) bool {
+				if !f(key, value) {
+					stopped = true
+				}
+				return !stopped
+			},
+		)
+		if stopped {
+			return
+		}
+	}
+}
+
+// Keys returns a slice containing the map's keys.
+func (m *ShardedMap[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range m.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Values returns a slice containing the map's values.
+func (m *ShardedMap[K, V]) Values() []V {
+	var values []V
+	for _, shard := range m.shards {
+		values = append(values, shard.Values()...)
+	}
+	return values
+}
+
+// Entries returns a slice containing the map's entries. Each shard's entries are a consistent snapshot of that
+// shard, but the shards are read in turn.
+func (m *ShardedMap[K, V]) Entries() []Entry[K, V] {
+	var entries []Entry[K, V]
+	for _, shard := range m.shards {
+		entries = append(entries, shard.Entries()...)
+	}
+	return entries
+}
+
+// All returns an iterator over the map's entries, with the same semantics as Range.
+func (m *ShardedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(yield)
+	}
+}
+
+// KeysSeq returns an iterator over the map's keys; it is the iterator form of Keys.
+func (m *ShardedMap[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Range(
+			func(key K, _ V) bool {
+				return yield(key)
+			},
+		)
+	}
+}
+
+// ValuesSeq returns an iterator over the map's values; it is the iterator form of Values.
+func (m *ShardedMap[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Range(
+			func(_ K, value V) bool {
+				return yield(value)
+			},
+		)
+	}
+}
+
+// Len returns the number of entries in the map, summed over its shards in turn.
+func (m *ShardedMap[K, V]) Len() int {
+	n := 0
+	for _, shard := range m.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Clear empties each shard in turn.
+func (m *ShardedMap[K, V]) Clear() {
+	for _, shard := range m.shards {
+		shard.Clear()
+	}
+}
+
+// Clone returns a shallow copy of the map with the same number of shards. Each shard is copied as a consistent
+// snapshot, but the shards are copied in turn.
+func (m *ShardedMap[K, V]) Clone() *ShardedMap[K, V] {
+	clone := &ShardedMap[K, V]{
+		shards: make([]*SyncMap[K, V], len(m.shards)),
+		mask:   m.mask,
+		seed:   m.seed,
+	}
+	for i, shard := range m.shards {
+		clone.shards[i] = shard.Clone()
+	}
+	return clone
+}
+
+// Filter returns a new map with the same number of shards, containing the entries for which pred returns true, as
+// SyncMap.Filter does for each shard in turn.
+func (m *ShardedMap[K, V]) Filter(pred func(key K, value V) bool) *ShardedMap[K, V] {
+	filtered := &ShardedMap[K, V]{
+		shards: make([]*SyncMap[K, V], len(m.shards)),
+		mask:   m.mask,
+		seed:   m.seed,
+	}
+	for i, shard := range m.shards {
+		filtered.shards[i] = shard.Filter(pred)
+	}
+	return filtered
+}
+
+// DeleteFunc deletes the entries for which pred returns true, as SyncMap.DeleteFunc does for each shard in turn, and
+// returns the number deleted.
+func (m *ShardedMap[K, V]) DeleteFunc(pred func(key K, value V) bool) int {
+	n := 0
+	for _, shard := range m.shards {
+		n += shard.DeleteFunc(pred)
+	}
+	return n
+}
+
+// Merge stores each entry of other in the map, as SyncMap.Merge does, merging into each shard in turn.
+func (m *ShardedMap[K, V]) Merge(other *ShardedMap[K, V], resolve func(old, new V) V) {
+	byShard := make([][]Entry[K, V], len(m.shards))
+	for _, e := range other.Entries() {
+		i := m.index(e.Key)
+		byShard[i] = append(byShard[i], e)
+	}
+	for i, entries := range byShard {
+		if len(entries) > 0 {
+			m.shards[i].mergeEntries(entries, resolve)
+		}
+	}
+}
+
+// Call blocks all other methods on the receiver, by locking every shard in turn, and calls f on a map holding the
+// entries of all shards. Once f returns, the entries it leaves in the map replace those of the shards. Changes made by
+// f are not reported to watchers.
+func (m *ShardedMap[K, V]) Call(f func(map[K]V)) {
+	for _, shard := range m.shards {
+		shard.mux.Lock()
+		defer shard.mux.Unlock()
+	}
+	all := make(map[K]V)
+	for _, shard := range m.shards {
+		for key, value := range shard.wrapped {
+			all[key] = value
+		}
+	}
+	f(all)
+	for _, shard := range m.shards {
+		shard.wrapped = make(map[K]V)
+	}
+	for key, value := range all {
+		m.shards[m.index(key)].wrapped[key] = value
+	}
+}
+
+func (m *ShardedMap[K, V]) String() string {
+	return fmt.Sprintf("%T{shards:%d}", m, len(m.shards))
+}
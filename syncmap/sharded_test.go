@@ -0,0 +1,244 @@
+package syncmap
+
+import (
+	"hash/maphash"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewSharded(t *testing.T) {
+	for _, tt := range []struct{ shards, want int }{{1, 1}, {3, 4}, {16, 16}, {17, 32}} {
+		if m := NewSharded[string, int](tt.shards); m.Shards() != tt.want {
+			t.Errorf("NewSharded(%d) has %d shards, want %d", tt.shards, m.Shards(), tt.want)
+		}
+	}
+	m := NewSharded[string, int](8)
+	for i := 0; i < 100; i++ {
+		m.Store(strconv.Itoa(i), i)
+	}
+	if n := len(m.Keys()); n != 100 {
+		t.Fatalf("Keys() returned %d keys, want 100", n)
+	}
+	if n := m.Len(); n != 100 {
+		t.Fatalf("Len() = %d, want 100", n)
+	}
+	count := 0
+	m.Range(
+		func(key string, value int) bool {
+			count++
+			return count < 10
+		},
+	)
+	if count != 10 {
+		t.Errorf("Range visited %d entries after stopping at 10", count)
+	}
+	clone := m.Clone()
+	if v, ok := m.LoadAndDelete("42"); !ok || v != 42 {
+		t.Errorf("LoadAndDelete() = %v, %v", v, ok)
+	}
+	if _, ok := m.Load("42"); ok {
+		t.Error("deleted key still present")
+	}
+	if v, ok := clone.Load("42"); !ok || v != 42 {
+		t.Errorf("clone changed with original: Load() = %v, %v", v, ok)
+	}
+	m.Merge(
+		clone, func(old, new int) int {
+			return -new
+		},
+	)
+	if v, _ := m.Load("42"); v != 42 {
+		t.Errorf("merged new entry = %d, want 42", v)
+	}
+	if v, _ := m.Load("7"); v != -7 {
+		t.Errorf("merged existing entry = %d, want -7", v)
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	type pair struct {
+		name string
+		_    int
+		n    any
+	}
+	seed := maphash.MakeSeed()
+	x, y := 1, 1
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{"equal structs", pair{name: "a", n: 1}, pair{name: "a", n: 1}, true},
+		{"distinct structs", pair{name: "a", n: 1}, pair{name: "a", n: 2}, false},
+		{"zeros", 0.0, math.Copysign(0, -1), true},
+		{"same pointer", &x, &x, true},
+		{"distinct pointers", &x, &y, false},
+		{"arrays", [2]string{"a", "b"}, [2]string{"a", "b"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				// hashes of distinct keys may collide, but are unlikely to for these
+				if got := hashKey(seed, tt.a) == hashKey(seed, tt.b); got != tt.want {
+					t.Errorf("hashes of %v and %v equal = %v, want %v", tt.a, tt.b, got, tt.want)
+				}
+			},
+		)
+	}
+	var nilKey any
+	if hashKey(seed, nilKey) != hashKey(seed, nilKey) {
+		t.Error("hashes of the nil interface differ")
+	}
+	m := NewSharded[pair, int](16)
+	m.Store(pair{name: "a", n: "x"}, 1)
+	if v, ok := m.Load(pair{name: "a", n: "x"}); !ok || v != 1 {
+		t.Errorf("Load() of an equal struct key = %v, %v, want 1, true", v, ok)
+	}
+}
+
+func TestShardedMap_Call(t *testing.T) {
+	m := NewSharded[string, int](8)
+	for i := 0; i < 10; i++ {
+		m.Store(strconv.Itoa(i), i)
+	}
+	m.Call(
+		func(all map[string]int) {
+			if len(all) != 10 {
+				t.Errorf("Call() got %d entries, want 10", len(all))
+			}
+			delete(all, "3")
+			all["10"] = 10
+		},
+	)
+	if _, ok := m.Load("3"); ok {
+		t.Error("entry deleted by Call() still present")
+	}
+	if v, ok := m.Load("10"); !ok || v != 10 {
+		t.Errorf("Load() of entry added by Call() = %v, %v", v, ok)
+	}
+	if n := m.Len(); n != 10 {
+		t.Errorf("Len() = %d after Call(), want 10", n)
+	}
+}
+
+type loadOrStorer interface {
+	LoadOrStore(key string, value int) (int, bool)
+}
+
+func benchmarkLoadOrStore(b *testing.B, m loadOrStorer, numKeys int) {
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = "https://host-" + strconv.Itoa(i) + ".example.com"
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			i := int(next.Add(1)) * 7919
+			for pb.Next() {
+				m.LoadOrStore(keys[i%numKeys], i)
+				i++
+			}
+		},
+	)
+}
+
+func BenchmarkLoadOrStore(b *testing.B) {
+	for _, numKeys := range []int{16, 4096} {
+		b.Run(
+			"SyncMap/keys="+strconv.Itoa(numKeys), func(b *testing.B) {
+				benchmarkLoadOrStore(b, New[string, int](), numKeys)
+			},
+		)
+		b.Run(
+			"ShardedMap/keys="+strconv.Itoa(numKeys), func(b *testing.B) {
+				benchmarkLoadOrStore(b, NewSharded[string, int](0), numKeys)
+			},
+		)
+	}
+}
+
+type storer interface {
+	Store(key string, value int)
+	Load(key string) (int, bool)
+}
+
+// benchmarkMixed stores one value for every 4 loads, as when limiters for new keys are created while existing ones are
+// looked up.
+func benchmarkMixed(b *testing.B, m storer, numKeys int) {
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = "https://host-" + strconv.Itoa(i) + ".example.com"
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			i := int(next.Add(1)) * 7919
+			for pb.Next() {
+				if i%5 == 0 {
+					m.Store(keys[i%numKeys], i)
+				} else {
+					m.Load(keys[i%numKeys])
+				}
+				i++
+			}
+		},
+	)
+}
+
+func BenchmarkMixed(b *testing.B) {
+	for _, numKeys := range []int{16, 4096} {
+		b.Run(
+			"SyncMap/keys="+strconv.Itoa(numKeys), func(b *testing.B) {
+				benchmarkMixed(b, New[string, int](), numKeys)
+			},
+		)
+		b.Run(
+			"ShardedMap/keys="+strconv.Itoa(numKeys), func(b *testing.B) {
+				benchmarkMixed(b, NewSharded[string, int](0), numKeys)
+			},
+		)
+	}
+}
+
+// benchmarkWriteHeavy stores and deletes distinct keys, so every operation takes a write lock.
+func benchmarkWriteHeavy(b *testing.B, m Map[string, int], numKeys int) {
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = "https://host-" + strconv.Itoa(i) + ".example.com"
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(
+		func(pb *testing.PB) {
+			i := int(next.Add(1)) * 7919
+			for pb.Next() {
+				if i%2 == 0 {
+					m.Store(keys[i%numKeys], i)
+				} else {
+					m.Delete(keys[i%numKeys])
+				}
+				i++
+			}
+		},
+	)
+}
+
+func BenchmarkWriteHeavy(b *testing.B) {
+	const numKeys = 4096
+	b.Run(
+		"SyncMap", func(b *testing.B) {
+			benchmarkWriteHeavy(b, New[string, int](), numKeys)
+		},
+	)
+	for _, shards := range []int{4, 16, 64} {
+		b.Run(
+			"ShardedMap/shards="+strconv.Itoa(shards), func(b *testing.B) {
+				benchmarkWriteHeavy(b, NewSharded[string, int](shards), numKeys)
+			},
+		)
+	}
+}
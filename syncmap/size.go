@@ -0,0 +1,45 @@
+package syncmap
+
+// set stores value for key, reporting the change to watchers and the size hook. m.mux must be held for writing.
+func (m *SyncMap[K, V]) set(key K, value V) {
+	if m.sizeHook != nil {
+		if _, ok := m.wrapped[key]; !ok {
+			m.sizeHook(1)
+		}
+	}
+	m.wrapped[key] = value
+	m.emit(OpStore, key, value)
+}
+
+// remove deletes the entry for key, whose value is value, reporting the change to watchers and the size hook. m.mux
+// must be held for writing, and key must be present.
+func (m *SyncMap[K, V]) remove(key K, value V) {
+	delete(m.wrapped, key)
+	m.resized(-1)
+	m.emit(OpDelete, key, value)
+}
+
+// resized reports a change in the number of entries to the size hook. m.mux must be held for writing.
+func (m *SyncMap[K, V]) resized(delta int) {
+	if m.sizeHook != nil && delta != 0 {
+		m.sizeHook(delta)
+	}
+}
+
+// SetSizeHook sets a function to be called with the change in the number of entries whenever a key is inserted or
+// deleted, so that the number of keys can be exported as a gauge without polling Len. The hook is called while the
+// SyncMap is locked, so it must be fast and must not call any methods on m; a nil hook removes it. Changes made by Call
+// are not reported.
+func (m *SyncMap[K, V]) SetSizeHook(hook func(delta int)) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.sizeHook = hook
+}
+
+// SetSizeHook sets a function to be called with the change in the number of entries whenever a key is inserted or
+// deleted, as SyncMap.SetSizeHook does. The hook may be called concurrently for keys in different shards.
+func (m *ShardedMap[K, V]) SetSizeHook(hook func(delta int)) {
+	for _, shard := range m.shards {
+		shard.SetSizeHook(hook)
+	}
+}
@@ -0,0 +1,49 @@
+package syncmap
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetSizeHook(t *testing.T) {
+	for _, m := range []interface {
+		Map[string, int]
+		SetSizeHook(func(int))
+		DeleteFunc(func(string, int) bool) int
+	}{New[string, int](), NewSharded[string, int](4)} {
+		var size atomic.Int64
+		m.SetSizeHook(
+			func(delta int) {
+				size.Add(int64(delta))
+			},
+		)
+		m.Store("a", 1)
+		m.Store("a", 2)
+		m.LoadOrStore("b", 3)
+		m.LoadOrCompute("c", func() int { return 4 })
+		m.Update(
+			"d", func(old int, loaded bool) (int, bool) {
+				return 5, true
+			},
+		)
+		m.CompareAndSwap("a", 2, 6)
+		if size.Load() != 4 {
+			t.Errorf("%T: size = %d after inserts, want 4", m, size.Load())
+		}
+		m.Delete("a")
+		m.Delete("missing")
+		m.CompareAndDelete("b", 3)
+		m.DeleteFunc(
+			func(key string, value int) bool {
+				return key == "c"
+			},
+		)
+		if size.Load() != 1 || m.Len() != 1 {
+			t.Errorf("%T: size = %d, Len() = %d after deletes, want 1", m, size.Load(), m.Len())
+		}
+		m.Clear()
+		if size.Load() != 0 {
+			t.Errorf("%T: size = %d after Clear, want 0", m, size.Load())
+		}
+	}
+}
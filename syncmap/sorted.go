@@ -0,0 +1,62 @@
+package syncmap
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+func sortKeys[K comparable](keys []K, less func(a, b K) bool) []K {
+	slices.SortFunc(
+		keys, func(a, b K) int {
+			switch {
+			case less(a, b):
+				return -1
+			case less(b, a):
+				return 1
+			}
+			return 0
+		},
+	)
+	return keys
+}
+
+// SortedKeys returns a slice containing the SyncMap's keys, sorted by less.
+func (m *SyncMap[K, V]) SortedKeys(less func(a, b K) bool) []K {
+	return sortKeys(m.Keys(), less)
+}
+
+// SortedKeys returns a slice containing the map's keys, sorted by less.
+func (m *ShardedMap[K, V]) SortedKeys(less func(a, b K) bool) []K {
+	return sortKeys(m.Keys(), less)
+}
+
+// OrderedKeys returns a slice containing the keys of m in ascending order.
+func OrderedKeys[K cmp.Ordered, V any](m Map[K, V]) []K {
+	keys := m.Keys()
+	slices.Sort(keys)
+	return keys
+}
+
+// OrderedEntries returns a slice containing the entries of m in ascending order of key.
+func OrderedEntries[K cmp.Ordered, V any](m Map[K, V]) []Entry[K, V] {
+	entries := m.Entries()
+	slices.SortFunc(
+		entries, func(a, b Entry[K, V]) int {
+			return cmp.Compare(a.Key, b.Key)
+		},
+	)
+	return entries
+}
+
+// Ordered returns an iterator over the entries of m in ascending order of key. The entries are read from m before
+// the first is yielded, so the loop body may call any method on m.
+func Ordered[K cmp.Ordered, V any](m Map[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, e := range OrderedEntries(m) {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package syncmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortedKeys(t *testing.T) {
+	m := New[string, int]()
+	s := NewSharded[string, int](4)
+	for i, key := range []string{"c", "a", "d", "b"} {
+		m.Store(key, i)
+		s.Store(key, i)
+	}
+	desc := func(a, b string) bool { return a > b }
+	if got, want := m.SortedKeys(desc), []string{"d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SyncMap.SortedKeys() = %v, want %v", got, want)
+	}
+	if got, want := s.SortedKeys(desc), []string{"d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ShardedMap.SortedKeys() = %v, want %v", got, want)
+	}
+	if got, want := OrderedKeys[string, int](s), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderedKeys() = %v, want %v", got, want)
+	}
+	var values []int
+	for key, value := range Ordered[string, int](m) {
+		values = append(values, value)
+		m.Delete(key)
+	}
+	if want := []int{1, 3, 0, 2}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Ordered() yielded values %v, want %v", values, want)
+	}
+}
@@ -2,12 +2,16 @@ package syncmap
 
 import (
 	"fmt"
+	"iter"
+	"maps"
 	"sync"
 )
 
 type SyncMap[K comparable, V any] struct {
-	wrapped map[K]V
-	mux     sync.RWMutex
+	wrapped  map[K]V
+	mux      sync.RWMutex
+	watchers []*watcher[K, V]
+	sizeHook func(delta int)
 }
 
 func New[K comparable, V any]() *SyncMap[K, V] {
@@ -37,7 +41,7 @@ func (m *SyncMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	previous, loaded = m.wrapped[key]
-	m.wrapped[key] = value
+	m.set(key, value)
 	return previous, loaded
 }
 
@@ -54,8 +58,9 @@ func (m *SyncMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
 	}
 	m.mux.Lock()
 	defer m.mux.Unlock()
-	value, loaded = m.wrapped[key]
-	delete(m.wrapped, key)
+	if value, loaded = m.wrapped[key]; loaded {
+		m.remove(key, value)
+	}
 	return value, loaded
 }
 
@@ -71,10 +76,62 @@ func (m *SyncMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
 	if actual, loaded = m.wrapped[key]; loaded {
 		return actual, loaded
 	}
-	m.wrapped[key] = value
+	m.set(key, value)
 	return value, false
 }
 
+// LoadOrCompute returns the existing value for the key if present.
+// Otherwise, it calls f and stores and returns its result.
+// The loaded result is true if the value was loaded, false if computed.
+//
+// f is called at most once per key stored, while the map is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) LoadOrCompute(key K, f func() V) (actual V, loaded bool) {
+	if actual, loaded = m.Load(key); loaded {
+		return actual, loaded
+	}
+	actual, loaded, _ = m.LoadOrComputeErr(
+		key, func() (V, error) {
+			return f(), nil
+		},
+	)
+	return actual, loaded
+}
+
+// LoadOrComputeErr is like LoadOrCompute, but f may fail, in which case nothing is
+// stored and its error is returned.
+func (m *SyncMap[K, V]) LoadOrComputeErr(key K, f func() (V, error)) (actual V, loaded bool, err error) {
+	if actual, loaded = m.Load(key); loaded {
+		return actual, loaded, nil
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if actual, loaded = m.wrapped[key]; loaded {
+		return actual, loaded, nil
+	}
+	if actual, err = f(); err != nil {
+		return actual, false, err
+	}
+	m.set(key, actual)
+	return actual, false, nil
+}
+
+// Update atomically updates the entry for key: f is called with the current value and whether it was present, and
+// the value it returns is stored if keep is true, or the entry deleted otherwise. Update returns the value f returned
+// and keep.
+//
+// f is called while the map is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) Update(key K, f func(old V, loaded bool) (new V, keep bool)) (value V, ok bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	old, loaded := m.wrapped[key]
+	if value, ok = f(old, loaded); ok {
+		m.set(key, value)
+	} else if loaded {
+		m.remove(key, old)
+	}
+	return value, ok
+}
+
 // CompareAndSwap swaps the old and new values for key
 // if the value stored in the map is equal to old.
 // The old value must be of a comparable type.
@@ -87,7 +144,7 @@ func (m *SyncMap[K, V]) CompareAndSwap(key K, old V, new V) bool {
 	if value, _ := m.wrapped[key]; any(value) != any(old) {
 		return false
 	}
-	m.wrapped[key] = new
+	m.set(key, new)
 	return true
 }
 
@@ -102,10 +159,40 @@ func (m *SyncMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
 	}
 	m.mux.Lock()
 	defer m.mux.Unlock()
-	if value, loaded := m.wrapped[key]; !loaded || any(value) != any(old) {
+	value, loaded := m.wrapped[key]
+	if !loaded || any(value) != any(old) {
 		return false
 	}
-	delete(m.wrapped, key)
+	m.remove(key, value)
+	return true
+}
+
+// CompareAndSwapFunc swaps the old and new values for key if a value is present in the map and equal(value, old)
+// returns true. Unlike CompareAndSwap, it works for values of any type, and compares them as equal defines rather than
+// as interface values.
+//
+// equal is called while the map is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) CompareAndSwapFunc(key K, old V, new V, equal func(a, b V) bool) (swapped bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if value, loaded := m.wrapped[key]; !loaded || !equal(value, old) {
+		return false
+	}
+	m.set(key, new)
+	return true
+}
+
+// CompareAndDeleteFunc deletes the entry for key if a value is present in the map and equal(value, old) returns true.
+//
+// equal is called while the map is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) CompareAndDeleteFunc(key K, old V, equal func(a, b V) bool) (deleted bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	value, loaded := m.wrapped[key]
+	if !loaded || !equal(value, old) {
+		return false
+	}
+	m.remove(key, value)
 	return true
 }
 
@@ -143,14 +230,149 @@ func (m *SyncMap[K, V]) Keys() []K {
 	return keys
 }
 
+// Values returns a slice containing the SyncMap's values.
+func (m *SyncMap[K, V]) Values() []V {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	values := make([]V, 0, len(m.wrapped))
+	for _, value := range m.wrapped {
+		values = append(values, value)
+	}
+	return values
+}
+
+// An Entry is a key and its value.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Entries returns a slice containing the SyncMap's entries, as a consistent snapshot of its contents.
+func (m *SyncMap[K, V]) Entries() []Entry[K, V] {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	entries := make([]Entry[K, V], 0, len(m.wrapped))
+	for key, value := range m.wrapped {
+		entries = append(entries, Entry[K, V]{Key: key, Value: value})
+	}
+	return entries
+}
+
+// All returns an iterator over the SyncMap's entries, with the same semantics as Range.
+func (m *SyncMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Range(yield)
+	}
+}
+
+// KeysSeq returns an iterator over the SyncMap's keys; it is the iterator form of Keys.
+func (m *SyncMap[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Range(
+			func(key K, _ V) bool {
+				return yield(key)
+			},
+		)
+	}
+}
+
+// ValuesSeq returns an iterator over the SyncMap's values; it is the iterator form of Values.
+func (m *SyncMap[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Range(
+			func(_ K, value V) bool {
+				return yield(value)
+			},
+		)
+	}
+}
+
+// Len returns the number of entries in the SyncMap.
+func (m *SyncMap[K, V]) Len() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return len(m.wrapped)
+}
+
 // Clear reassigns the underlying map to a newly allocated empty map.
 func (m *SyncMap[K, V]) Clear() {
 	m.mux.Lock()
 	defer m.mux.Unlock()
+	if len(m.watchers) > 0 {
+		for key, value := range m.wrapped {
+			m.emit(OpDelete, key, value)
+		}
+	}
+	m.resized(-len(m.wrapped))
 	m.wrapped = make(map[K]V)
 }
 
-// Call blocks all other methods on the receiver and calls f on the map.
+// Clone returns a shallow copy of the SyncMap, as a consistent snapshot of its contents.
+func (m *SyncMap[K, V]) Clone() *SyncMap[K, V] {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	wrapped := make(map[K]V, len(m.wrapped))
+	for key, value := range m.wrapped {
+		wrapped[key] = value
+	}
+	return &SyncMap[K, V]{wrapped: wrapped}
+}
+
+// Filter returns a new SyncMap containing the entries for which pred returns true, as a consistent snapshot of the
+// SyncMap's contents. pred is called while the SyncMap is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) Filter(pred func(key K, value V) bool) *SyncMap[K, V] {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	wrapped := make(map[K]V)
+	for key, value := range m.wrapped {
+		if pred(key, value) {
+			wrapped[key] = value
+		}
+	}
+	return &SyncMap[K, V]{wrapped: wrapped}
+}
+
+// DeleteFunc deletes the entries for which pred returns true, under a single lock, and returns the number deleted.
+// pred is called while the SyncMap is locked, so it must not call any methods on m.
+func (m *SyncMap[K, V]) DeleteFunc(pred func(key K, value V) bool) int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n := len(m.wrapped)
+	maps.DeleteFunc(
+		m.wrapped, func(key K, value V) bool {
+			if pred(key, value) {
+				m.emit(OpDelete, key, value)
+				return true
+			}
+			return false
+		},
+	)
+	m.resized(len(m.wrapped) - n)
+	return n - len(m.wrapped)
+}
+
+// Merge stores each entry of other in the SyncMap. Where a key is present in both, the value stored is the result of
+// calling resolve with the existing and the new value, or the new value if resolve is nil. A snapshot of other is
+// taken before the SyncMap is locked, and resolve is called while it is locked, so resolve must not call any methods
+// on m.
+func (m *SyncMap[K, V]) Merge(other *SyncMap[K, V], resolve func(old, new V) V) {
+	m.mergeEntries(other.Entries(), resolve)
+}
+
+func (m *SyncMap[K, V]) mergeEntries(entries []Entry[K, V], resolve func(old, new V) V) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, e := range entries {
+		value := e.Value
+		if old, ok := m.wrapped[e.Key]; ok && resolve != nil {
+			value = resolve(old, e.Value)
+		}
+		m.set(e.Key, value)
+	}
+}
+
+// Call blocks all other methods on the receiver and calls f on the map. Changes made by f are not reported to
+// watchers.
 func (m *SyncMap[K, V]) Call(f func(map[K]V)) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
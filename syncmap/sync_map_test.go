@@ -1,7 +1,13 @@
 package syncmap
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -15,3 +21,250 @@ func TestNew(t *testing.T) {
 		t.Fatal("underlying map not initialized:", m)
 	}
 }
+
+func TestSyncMap_LoadOrCompute(t *testing.T) {
+	m := New[string, int]()
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.LoadOrCompute(
+				"k", func() int {
+					calls.Add(1)
+					return i
+				},
+			)
+		}(i)
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("f called %d times, want 1", n)
+	}
+
+	failure := errors.New("failed")
+	if _, _, err := m.LoadOrComputeErr("e", func() (int, error) { return 1, failure }); err != failure {
+		t.Errorf("LoadOrComputeErr() error = %v, want %v", err, failure)
+	}
+	if _, ok := m.Load("e"); ok {
+		t.Error("value stored despite error")
+	}
+	v, loaded, err := m.LoadOrComputeErr("e", func() (int, error) { return 2, nil })
+	if v != 2 || loaded || err != nil {
+		t.Errorf("LoadOrComputeErr() = %v, %v, %v; want 2, false, nil", v, loaded, err)
+	}
+	v, loaded, err = m.LoadOrComputeErr("e", func() (int, error) { return 3, nil })
+	if v != 2 || !loaded || err != nil {
+		t.Errorf("LoadOrComputeErr() = %v, %v, %v; want 2, true, nil", v, loaded, err)
+	}
+}
+
+func TestSyncMap_Len(t *testing.T) {
+	m := New[int, int]()
+	for i := 0; i < 5; i++ {
+		m.Store(i, i)
+	}
+	m.Store(0, 1)
+	m.Delete(4)
+	if n := m.Len(); n != 4 {
+		t.Errorf("Len() = %d, want 4", n)
+	}
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestSyncMap_ValuesEntries(t *testing.T) {
+	m := New[string, int]()
+	want := map[string]int{"a": 1, "b": 2, "c": 3}
+	for k, v := range want {
+		m.Store(k, v)
+	}
+	sum := 0
+	for _, v := range m.Values() {
+		sum += v
+	}
+	if sum != 6 {
+		t.Errorf("Values() sum to %d, want 6", sum)
+	}
+	entries := m.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("Entries() returned %d entries, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		if want[e.Key] != e.Value {
+			t.Errorf("entry %q = %d, want %d", e.Key, e.Value, want[e.Key])
+		}
+	}
+}
+
+func TestSyncMap_All(t *testing.T) {
+	m := New[string, int]()
+	for i, k := range []string{"a", "b", "c", "d"} {
+		m.Store(k, i)
+	}
+	got := maps.Collect(m.All())
+	if len(got) != 4 || got["c"] != 2 {
+		t.Errorf("maps.Collect(All()) = %v", got)
+	}
+	keys := slices.Sorted(m.KeysSeq())
+	if !slices.Equal(keys, []string{"a", "b", "c", "d"}) {
+		t.Errorf("slices.Sorted(KeysSeq()) = %v", keys)
+	}
+	sum := 0
+	for v := range m.ValuesSeq() {
+		sum += v
+	}
+	if sum != 6 {
+		t.Errorf("ValuesSeq() sum to %d, want 6", sum)
+	}
+	n := 0
+	for range m.All() {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("iteration did not stop at break: %d", n)
+	}
+}
+
+func TestSyncMap_Clone(t *testing.T) {
+	m := New[string, int]()
+	m.Store("a", 1)
+	clone := m.Clone()
+	m.Store("a", 2)
+	m.Store("b", 3)
+	if v, _ := clone.Load("a"); v != 1 {
+		t.Errorf("clone changed with original: a = %d", v)
+	}
+	if clone.Len() != 1 {
+		t.Errorf("clone has %d entries, want 1", clone.Len())
+	}
+	clone.Store("c", 4)
+	if _, ok := m.Load("c"); ok {
+		t.Error("original changed with clone")
+	}
+}
+
+func TestSyncMap_Merge(t *testing.T) {
+	m := New[string, int]()
+	m.Store("a", 1)
+	m.Store("b", 2)
+	other := New[string, int]()
+	other.Store("b", 10)
+	other.Store("c", 20)
+	m.Merge(
+		other, func(old, new int) int {
+			return old + new
+		},
+	)
+	want := map[string]int{"a": 1, "b": 12, "c": 20}
+	if got := maps.Collect(m.All()); !maps.Equal(got, want) {
+		t.Errorf("merged map = %v, want %v", got, want)
+	}
+	m.Merge(other, nil)
+	if v, _ := m.Load("b"); v != 10 {
+		t.Errorf("merge without resolver: b = %d, want 10", v)
+	}
+	m.Merge(m, nil)
+	if m.Len() != 3 {
+		t.Errorf("self merge changed length to %d", m.Len())
+	}
+}
+
+func TestSyncMap_Update(t *testing.T) {
+	m := New[string, int]()
+	increment := func(old int, _ bool) (int, bool) {
+		return old + 1, true
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Update("count", increment)
+		}()
+	}
+	wg.Wait()
+	if v, _ := m.Load("count"); v != 100 {
+		t.Errorf("count = %d after 100 concurrent increments", v)
+	}
+	v, ok := m.Update(
+		"count", func(old int, loaded bool) (int, bool) {
+			return 0, false
+		},
+	)
+	if ok || v != 0 {
+		t.Errorf("Update() = %d, %v; want 0, false", v, ok)
+	}
+	if _, loaded := m.Load("count"); loaded {
+		t.Error("entry not deleted by Update")
+	}
+}
+
+func TestSyncMap_FilterDeleteFunc(t *testing.T) {
+	even := func(key string, value int) bool {
+		return value%2 == 0
+	}
+	for _, m := range []interface {
+		Map[string, int]
+		DeleteFunc(func(string, int) bool) int
+	}{New[string, int](), NewSharded[string, int](4)} {
+		for i := 0; i < 10; i++ {
+			m.Store(strconv.Itoa(i), i)
+		}
+		var filtered Map[string, int]
+		switch m := m.(type) {
+		case *SyncMap[string, int]:
+			filtered = m.Filter(even)
+		case *ShardedMap[string, int]:
+			filtered = m.Filter(even)
+		}
+		if filtered.Len() != 5 || m.Len() != 10 {
+			t.Errorf("%T: Filter() kept %d of %d entries, want 5 of 10", m, filtered.Len(), m.Len())
+		}
+		if n := m.DeleteFunc(even); n != 5 {
+			t.Errorf("%T: DeleteFunc() = %d, want 5", m, n)
+		}
+		if _, ok := m.Load("4"); ok || m.Len() != 5 {
+			t.Errorf("%T: DeleteFunc() left %d entries", m, m.Len())
+		}
+	}
+}
+
+func TestSyncMap_CompareAndSwapFunc(t *testing.T) {
+	for _, m := range []Map[string, []int]{New[string, []int](), NewSharded[string, []int](2)} {
+		if m.CompareAndSwapFunc("a", nil, []int{1}, slices.Equal[[]int]) {
+			t.Errorf("%T: CompareAndSwapFunc() swapped absent key", m)
+		}
+		m.Store("a", []int{1, 2})
+		if m.CompareAndSwapFunc("a", []int{1}, []int{3}, slices.Equal[[]int]) {
+			t.Errorf("%T: CompareAndSwapFunc() swapped unequal value", m)
+		}
+		if !m.CompareAndSwapFunc("a", []int{1, 2}, []int{3}, slices.Equal[[]int]) {
+			t.Errorf("%T: CompareAndSwapFunc() did not swap equal value", m)
+		}
+		if m.CompareAndDeleteFunc("a", []int{1, 2}, slices.Equal[[]int]) {
+			t.Errorf("%T: CompareAndDeleteFunc() deleted unequal value", m)
+		}
+		if !m.CompareAndDeleteFunc("a", []int{3}, slices.Equal[[]int]) || m.Len() != 0 {
+			t.Errorf("%T: CompareAndDeleteFunc() did not delete equal value", m)
+		}
+	}
+}
+
+func TestSyncMap_ReadOnly(t *testing.T) {
+	m := New[string, int]()
+	ro := m.ReadOnly()
+	m.Store("a", 1)
+	if v, ok := ro.Load("a"); !ok || v != 1 || ro.Len() != 1 {
+		t.Errorf("ReadOnly() view does not reflect Store: Load() = %d, %t", v, ok)
+	}
+	if _, ok := ro.(*SyncMap[string, int]); ok {
+		t.Error("ReadOnly() view can be converted back to *SyncMap")
+	}
+}
@@ -0,0 +1,118 @@
+package syncmap
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// An Op is the kind of change reported by an Event.
+type Op int
+
+const (
+	// OpStore reports that a value was stored for a key, whether or not the key was present.
+	OpStore Op = iota
+	// OpDelete reports that the entry for a key was deleted.
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpStore:
+		return "store"
+	case OpDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// An Event is a change to an entry of a map, as reported by Watch. For OpDelete, Value is the value deleted.
+type Event[K comparable, V any] struct {
+	Op    Op
+	Key   K
+	Value V
+}
+
+type watcher[K comparable, V any] struct {
+	events  chan Event[K, V]
+	dropped atomic.Int64
+}
+
+// send delivers e without blocking, dropping it if the channel's buffer is full.
+func (w *watcher[K, V]) send(e Event[K, V]) {
+	select {
+	case w.events <- e:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// A Watch is a subscription to the changes made to a map.
+type Watch[K comparable, V any] struct {
+	w *watcher[K, V]
+}
+
+// Events returns the channel on which changes are delivered, in the order they were made to each key. The channel is
+// closed when the context passed to Watch is done.
+func (w Watch[K, V]) Events() <-chan Event[K, V] {
+	return w.w.events
+}
+
+// Dropped returns the number of events dropped because the channel's buffer was full.
+func (w Watch[K, V]) Dropped() int64 {
+	return w.w.dropped.Load()
+}
+
+// emit reports a change to the SyncMap's watchers. m.mux must be held for writing.
+func (m *SyncMap[K, V]) emit(op Op, key K, value V) {
+	for _, w := range m.watchers {
+		w.send(Event[K, V]{Op: op, Key: key, Value: value})
+	}
+}
+
+func (m *SyncMap[K, V]) addWatcher(w *watcher[K, V]) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.watchers = append(m.watchers, w)
+}
+
+func (m *SyncMap[K, V]) removeWatcher(w *watcher[K, V]) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i, other := range m.watchers {
+		if other == w {
+			m.watchers = append(m.watchers[:i:i], m.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Watch subscribes to the changes made to the SyncMap until ctx is done, delivering them on a channel with the given
+// buffer size. Changes are delivered without blocking the map: if the buffer is full, the event is dropped and counted
+// by Watch.Dropped, so a watcher which cannot keep up should use a larger buffer.
+func (m *SyncMap[K, V]) Watch(ctx context.Context, buffer int) Watch[K, V] {
+	w := &watcher[K, V]{events: make(chan Event[K, V], buffer)}
+	m.addWatcher(w)
+	go func() {
+		<-ctx.Done()
+		m.removeWatcher(w)
+		close(w.events)
+	}()
+	return Watch[K, V]{w: w}
+}
+
+// Watch subscribes to the changes made to every shard of the map until ctx is done, as SyncMap.Watch does. Changes to
+// keys in different shards may be delivered in a different order than they were made.
+func (m *ShardedMap[K, V]) Watch(ctx context.Context, buffer int) Watch[K, V] {
+	w := &watcher[K, V]{events: make(chan Event[K, V], buffer)}
+	for _, shard := range m.shards {
+		shard.addWatcher(w)
+	}
+	go func() {
+		<-ctx.Done()
+		for _, shard := range m.shards {
+			shard.removeWatcher(w)
+		}
+		close(w.events)
+	}()
+	return Watch[K, V]{w: w}
+}
@@ -0,0 +1,54 @@
+package syncmap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSyncMap_Watch(t *testing.T) {
+	for _, m := range []interface {
+		Map[string, int]
+		Watch(context.Context, int) Watch[string, int]
+	}{New[string, int](), NewSharded[string, int](1)} {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := m.Watch(ctx, 4)
+		m.Store("a", 1)
+		m.LoadOrStore("a", 2)
+		m.Update(
+			"a", func(old int, loaded bool) (int, bool) {
+				return old + 1, true
+			},
+		)
+		m.Delete("b")
+		m.Delete("a")
+		m.Store("c", 3)
+		cancel()
+		var events []Event[string, int]
+		for e := range w.Events() {
+			events = append(events, e)
+		}
+		want := []Event[string, int]{{OpStore, "a", 1}, {OpStore, "a", 2}, {OpDelete, "a", 2}, {OpStore, "c", 3}}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("%T: events = %v, want %v", m, events, want)
+		}
+		if w.Dropped() != 0 {
+			t.Errorf("%T: Dropped() = %d, want 0", m, w.Dropped())
+		}
+	}
+}
+
+func TestSyncMap_WatchDropped(t *testing.T) {
+	m := New[string, int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := m.Watch(ctx, 1)
+	m.Store("a", 1)
+	m.Store("b", 2)
+	if w.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", w.Dropped())
+	}
+	if e := <-w.Events(); e.Key != "a" {
+		t.Errorf("first event = %v, want store of a", e)
+	}
+}
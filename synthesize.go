@@ -0,0 +1,88 @@
+package ratelim
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// tooManyRequests returns the 429 response synthesized for req, which was rejected with err, if err is a rejection
+// by one of the transport's limits: ErrRejected, a *PausedError, a *TooManyWaitersError, a *TooManyKeysError, a
+// *SheddingError, a *SpikeArrestError, a *QuotaExceededError or a *ByteBudgetExceededError. Its Retry-After header is
+// set to when the request could be retried, if known: the end of the pause, spike arrest sub-interval, quota or byte
+// budget period, or the wait estimated from the reservation the key's limiter would make.
+func (t *PerKeyRoundTripper[K]) tooManyRequests(req *http.Request, err error) (*http.Response, bool) {
+	var (
+		retryAfter time.Duration
+		paused     *PausedError
+		waiters    *TooManyWaitersError
+		keys       *TooManyKeysError
+		shed       *SheddingError
+		spike      *SpikeArrestError
+		quota      *QuotaExceededError
+		bytes      *ByteBudgetExceededError
+	)
+	switch {
+	case errors.Is(err, ErrRejected), errors.As(err, &shed), errors.As(err, &keys):
+	case errors.As(err, &paused):
+		if !paused.Until.IsZero() {
+			retryAfter = time.Until(paused.Until)
+		}
+	case errors.As(err, &waiters):
+		retryAfter = t.EstimateWait(req)
+	case errors.As(err, &spike):
+		retryAfter = time.Until(spike.Until)
+	case errors.As(err, &quota):
+		retryAfter = time.Until(quota.Reset)
+	case errors.As(err, &bytes):
+		retryAfter = time.Until(bytes.Reset)
+	default:
+		return nil, false
+	}
+	body := err.Error()
+	resp := &http.Response{
+		Status:        "429 " + http.StatusText(http.StatusTooManyRequests),
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          &rejectionBody{Reader: strings.NewReader(body), err: err},
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if retryAfter > 0 && retryAfter != rate.InfDuration {
+		resp.Header.Set("Retry-After", strconv.FormatFloat(math.Ceil(retryAfter.Seconds()), 'f', 0, 64))
+	}
+	return resp, true
+}
+
+// A rejectionBody is the body of a response synthesized for a rejected request, which keeps the error it was rejected
+// with.
+type rejectionBody struct {
+	io.Reader
+	err error
+}
+
+func (b *rejectionBody) Close() error {
+	return nil
+}
+
+// RejectionError returns the error a request was rejected with by a PerKeyRoundTripper, if resp is the 429 response
+// synthesized for it under Synthesize429, and nil otherwise, so that such responses can be told apart from those of
+// the upstream, e.g. to not retry requests beyond a *QuotaExceededError.
+func RejectionError(resp *http.Response) error {
+	if resp == nil {
+		return nil
+	}
+	if body, ok := resp.Body.(*rejectionBody); ok {
+		return body.err
+	}
+	return nil
+}
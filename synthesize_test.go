@@ -0,0 +1,113 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_Synthesize429(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          func(rt *PerKeyRoundTripper[string])
+		wantRetryAfter string
+		// anyRetryAfter, if true, accepts any Retry-After
+		anyRetryAfter bool
+	}{
+		{
+			name: "rejected",
+			setup: func(rt *PerKeyRoundTripper[string]) {
+				rt.SetMode(ModeReject)
+			},
+		},
+		{
+			name: "paused",
+			setup: func(rt *PerKeyRoundTripper[string]) {
+				rt.RejectAll(90 * time.Second)
+			},
+			wantRetryAfter: "90",
+		},
+		{
+			name: "too many waiters",
+			setup: func(rt *PerKeyRoundTripper[string]) {
+				rt.SetLimiterConfig("https://example.com", LimiterConfig{Limit: 0.5, Burst: 1, MaxWaiters: 1})
+				rt.Limiter(mustNewRequest(t)).Allow()
+				ctx, cancel := context.WithCancel(context.Background())
+				t.Cleanup(cancel)
+				go func() {
+					_, _ = rt.RoundTrip(mustNewRequest(t).WithContext(ctx))
+				}()
+				for rt.Waiting("https://example.com") == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			},
+			// the waiting request holds the next token, so another would wait for the one after it
+			wantRetryAfter: "4",
+		},
+		{
+			name: "quota exceeded",
+			setup: func(rt *PerKeyRoundTripper[string]) {
+				rt.Quota = NewQuota(0, QuotaDaily, nil)
+			},
+			anyRetryAfter: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				transport := &flakyTransport{}
+				rt := PerOriginRoundTripper(100, 1, transport)
+				rt.Synthesize429 = true
+				tt.setup(rt)
+				resp, err := rt.RoundTrip(mustNewRequest(t))
+				if err != nil {
+					t.Fatalf("RoundTrip() error = %v, want a synthesized response", err)
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusTooManyRequests || resp.Status != "429 Too Many Requests" {
+					t.Errorf("response status = %q", resp.Status)
+				}
+				retryAfter := resp.Header.Get("Retry-After")
+				if tt.anyRetryAfter && retryAfter == "" {
+					t.Error("response has no Retry-After")
+				} else if !tt.anyRetryAfter && retryAfter != tt.wantRetryAfter {
+					t.Errorf("Retry-After = %q, want %q", retryAfter, tt.wantRetryAfter)
+				}
+				if body, _ := io.ReadAll(resp.Body); len(body) == 0 {
+					t.Error("response has no body")
+				}
+				if RejectionError(resp) == nil {
+					t.Error("RejectionError() = nil for a synthesized response")
+				}
+				if transport.requests != 0 {
+					t.Errorf("transport sent %d requests, want 0", transport.requests)
+				}
+			},
+		)
+	}
+}
+
+func TestPerKeyRoundTripper_Synthesize429_otherErrors(t *testing.T) {
+	rt := PerOriginRoundTripper(100, 1, &flakyTransport{failures: 1})
+	rt.Synthesize429 = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rt.RoundTrip(mustNewRequest(t).WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("RoundTrip() with canceled context error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := rt.RoundTrip(mustNewRequest(t)); err == nil {
+		t.Error("RoundTrip() did not return the transport's error")
+	}
+}
+
+func mustNewRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
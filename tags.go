@@ -0,0 +1,74 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+type tagKey struct{}
+
+// WithTag returns a copy of ctx which tags the requests using it, so that they are also limited by the limit set for
+// the tag with SetTagLimit, e.g. to limit "backfill" jobs to 1 request per second whatever their key.
+func WithTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag)
+}
+
+// TagFromContext returns the tag set on ctx by WithTag, if any.
+func TagFromContext(ctx context.Context) (tag string, ok bool) {
+	tag, ok = ctx.Value(tagKey{}).(string)
+	return tag, ok
+}
+
+// SetTagLimit sets the limit of the requests tagged with tag, which they must wait for in addition to the limit of
+// their key. The limit is shared by all keys.
+func (t *PerKeyRoundTripper[K]) SetTagLimit(tag string, cfg LimiterConfig) {
+	t.tagConfigs.Store(tag, cfg)
+	if limiter, ok := t.tagLimiters.Load(tag); ok {
+		cfg.apply(limiter)
+	}
+}
+
+// DeleteTagLimit removes the limit of the requests tagged with tag.
+func (t *PerKeyRoundTripper[K]) DeleteTagLimit(tag string) {
+	t.tagConfigs.Delete(tag)
+	t.tagLimiters.Delete(tag)
+}
+
+// TagLimiter returns the rate.Limiter of the requests tagged with tag, if it has a limit.
+func (t *PerKeyRoundTripper[K]) TagLimiter(tag string) (*rate.Limiter, bool) {
+	cfg, ok := t.tagConfigs.Load(tag)
+	if !ok {
+		return nil, false
+	}
+	return loadOrCompute[string](t.tagLimiters, tag, cfg.NewLimiter), true
+}
+
+// tagRequest returns req with the tag of its TagHeader set on its context, unless its context already has a tag.
+func (t *PerKeyRoundTripper[K]) tagRequest(req *http.Request) *http.Request {
+	if t.TagHeader == "" {
+		return req
+	}
+	tag := req.Header.Get(t.TagHeader)
+	if tag == "" {
+		return req
+	}
+	if _, ok := TagFromContext(req.Context()); ok {
+		return req
+	}
+	return req.WithContext(WithTag(req.Context(), tag))
+}
+
+// waitTag blocks until the limiter of the tag of ctx, if any, permits a request, or ctx is done.
+func (t *PerKeyRoundTripper[K]) waitTag(ctx context.Context) error {
+	tag, ok := TagFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	limiter, ok := t.TagLimiter(tag)
+	if !ok {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,58 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_TagLimit(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	rt := NewPerKeyRoundTripper(
+		1000, 10, func(r *http.Request) string {
+			return r.URL.Path
+		}, nil,
+	)
+	rt.TagHeader = "X-Job"
+	rt.SetTagLimit("backfill", LimiterConfig{Limit: 20, Burst: 1})
+	send := func(ctx context.Context, path, header string) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if header != "" {
+			req.Header.Set("X-Job", header)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		send(context.Background(), "/untagged", "")
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("untagged requests took %v", elapsed)
+	}
+	// tagged requests for different keys share the tag's limit, whether tagged by context or header
+	start = time.Now()
+	send(WithTag(context.Background(), "backfill"), "/a", "")
+	send(context.Background(), "/b", "backfill")
+	send(WithTag(context.Background(), "backfill"), "/c", "other")
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("3 tagged requests took %v, want ~100ms", elapsed)
+	}
+	rt.DeleteTagLimit("backfill")
+	if _, ok := rt.TagLimiter("backfill"); ok {
+		t.Error("TagLimiter() found a deleted tag limit")
+	}
+}
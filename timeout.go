@@ -0,0 +1,23 @@
+package ratelim
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// withTimeout returns a copy of req whose context is canceled after timeout, and the function canceling it.
+func withTimeout(req *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return req.WithContext(ctx), cancel
+}
+
+// cancelWithBody defers cancel until the body of resp is closed, so that the body can still be read, or calls it at
+// once if there is no response.
+func cancelWithBody(resp *http.Response, cancel context.CancelFunc) {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+}
@@ -0,0 +1,52 @@
+package ratelim
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPerKeyRoundTripper_Timeout(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Second):
+					}
+				}
+				_, _ = io.WriteString(w, "ok")
+			},
+		),
+	)
+	defer server.Close()
+	rt := NewPerKeyRoundTripper(
+		1000, 10, func(r *http.Request) string {
+			return r.URL.Path
+		}, nil,
+	)
+	rt.SetLimiterConfig("/slow", LimiterConfig{Limit: 1000, Burst: 10, Timeout: 50 * time.Millisecond})
+	rt.SetLimiterConfig("/fast", LimiterConfig{Limit: 1000, Burst: 10, Timeout: time.Second})
+	client := &http.Client{Transport: rt}
+
+	start := time.Now()
+	if _, err := client.Get(server.URL + "/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get(/slow) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Get(/slow) took %v, want about 50ms", elapsed)
+	}
+	resp, err := client.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "ok" {
+		t.Errorf("reading body of /fast = %q, %v", body, err)
+	}
+}
@@ -0,0 +1,21 @@
+package ratelim
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+)
+
+// traceWaitRegion is the type of the runtime/trace regions around limiter waits.
+const traceWaitRegion = "ratelim.wait"
+
+// traceWait, if Trace is set and a trace is being recorded, logs the key of a request to the execution trace and
+// starts a region around its wait for the limiter, so that go tool trace shows the time goroutines spend blocked by
+// rate limiting. It returns a func which ends the region.
+func (t *PerKeyRoundTripper[K]) traceWait(ctx context.Context, key K) func() {
+	if !t.Trace || !trace.IsEnabled() {
+		return func() {}
+	}
+	trace.Log(ctx, "ratelim.key", fmt.Sprint(key))
+	return trace.StartRegion(ctx, traceWaitRegion).End
+}
@@ -0,0 +1,39 @@
+package ratelim
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime/trace"
+	"testing"
+)
+
+func TestPerKeyRoundTripper_Trace(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	rt := NewPerKeyRoundTripper(100, 1, TargetOrigin, nil)
+	rt.Trace = true
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skipf("tracing unavailable: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			trace.Stop()
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	trace.Stop()
+	if !bytes.Contains(buf.Bytes(), []byte(traceWaitRegion)) {
+		t.Errorf("trace does not contain a %q region", traceWaitRegion)
+	}
+}
@@ -0,0 +1,52 @@
+package ratelim
+
+import (
+	"net/http"
+)
+
+// transport returns the http.RoundTripper used to send the requests for key: the one created for it by TransportFunc,
+// if any, or the underlying http.RoundTripper.
+func (t *PerKeyRoundTripper[K]) transport(key K) http.RoundTripper {
+	if t.TransportFunc == nil {
+		return t.RoundTripper
+	}
+	transport := loadOrCompute(
+		t.transports, key, func() http.RoundTripper {
+			return t.TransportFunc(key)
+		},
+	)
+	if transport == nil {
+		return t.RoundTripper
+	}
+	return transport
+}
+
+// closeTransport removes the transport created for key by TransportFunc, if any, closing its idle connections.
+func (t *PerKeyRoundTripper[K]) closeTransport(key K) {
+	if transport, ok := t.transports.LoadAndDelete(key); ok {
+		if c, ok := transport.(interface{ CloseIdleConnections() }); ok {
+			c.CloseIdleConnections()
+		}
+	}
+}
+
+// Transport returns the http.RoundTripper used to send the requests for key.
+func (t *PerKeyRoundTripper[K]) Transport(key K) http.RoundTripper {
+	return t.transport(key)
+}
+
+// CloseIdleConnections closes the idle connections of the underlying http.RoundTripper and of each transport created
+// by TransportFunc, if they support it, as http.Client.CloseIdleConnections does for its transport.
+func (t *PerKeyRoundTripper[K]) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.RoundTripper.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+	for _, transport := range t.transports.Values() {
+		if c, ok := transport.(closeIdler); ok {
+			c.CloseIdleConnections()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTransport struct {
+	http.RoundTripper
+	requests atomic.Int64
+	closed   atomic.Int64
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return c.RoundTripper.RoundTrip(req)
+}
+
+func (c *countingTransport) CloseIdleConnections() {
+	c.closed.Add(1)
+}
+
+func TestPerKeyRoundTripper_TransportFunc(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+
+	slow := &countingTransport{RoundTripper: http.DefaultTransport}
+	created := 0
+	rt := NewPerKeyRoundTripper(
+		1000, 10, func(r *http.Request) string {
+			return r.URL.Path
+		}, http.DefaultTransport,
+	)
+	rt.TransportFunc = func(key string) http.RoundTripper {
+		created++
+		if key == "/slow" {
+			return slow
+		}
+		return nil
+	}
+	client := &http.Client{Transport: rt}
+	for _, path := range []string{"/slow", "/fast", "/slow"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if n := slow.requests.Load(); n != 2 {
+		t.Errorf("per-key transport sent %d requests, want 2", n)
+	}
+	if created != 2 {
+		t.Errorf("TransportFunc called %d times, want 2", created)
+	}
+	if rt.Transport("/fast") != http.DefaultTransport {
+		t.Error("Transport() for key without a transport of its own is not the underlying transport")
+	}
+	client.CloseIdleConnections()
+	if n := slow.closed.Load(); n != 1 {
+		t.Errorf("CloseIdleConnections() reached per-key transport %d times, want 1", n)
+	}
+}
@@ -0,0 +1,66 @@
+package ratelim
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// A TrustedProfile selects a named LimiterConfig for an inbound request by a header set by a trusted upstream, such
+// as an internal gateway, so that per-partner limits can be decided upstream without redeploying the service.
+//
+// A request's header is only trusted if it is signed with Secret, when Secret is set, and if Trusted reports it to be,
+// when Trusted is set; if neither is set, no request is trusted.
+type TrustedProfile struct {
+	// Header is the name of the header holding the profile name, e.g. "X-RateLimit-Profile".
+	Header string
+	// Profiles maps profile names to their LimiterConfig. Unknown names are ignored.
+	Profiles map[string]LimiterConfig
+	// Secret, if non-empty, is the key with which header values must be signed, as by SignProfile.
+	Secret []byte
+	// Trusted, if non-nil, reports whether the header of a request may be trusted, e.g. by the address it was
+	// received from.
+	Trusted func(r *http.Request) bool
+}
+
+const profileSignatureParam = ";sig="
+
+// SignProfile returns the value of a TrustedProfile header selecting the named profile, signed with secret by an
+// HMAC-SHA256 of the name. The signature does not bind the value to a request, so the header must only be set by an
+// upstream which strips it from the requests it receives.
+func SignProfile(secret []byte, name string) string {
+	return name + profileSignatureParam + hex.EncodeToString(profileSignature(secret, name))
+}
+
+func profileSignature(secret []byte, name string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(name))
+	return mac.Sum(nil)
+}
+
+// Select returns the profile selected by the header of r, and its config, if the header is present, trusted and
+// names a known profile.
+func (p *TrustedProfile) Select(r *http.Request) (name string, cfg LimiterConfig, ok bool) {
+	value := r.Header.Get(p.Header)
+	if value == "" || (len(p.Secret) == 0 && p.Trusted == nil) {
+		return "", LimiterConfig{}, false
+	}
+	name = value
+	if len(p.Secret) > 0 {
+		var sig string
+		if name, sig, ok = strings.Cut(value, profileSignatureParam); !ok {
+			return "", LimiterConfig{}, false
+		}
+		decoded, err := hex.DecodeString(sig)
+		if err != nil || !hmac.Equal(decoded, profileSignature(p.Secret, name)) {
+			return "", LimiterConfig{}, false
+		}
+	}
+	if p.Trusted != nil && !p.Trusted(r) {
+		return "", LimiterConfig{}, false
+	}
+	cfg, ok = p.Profiles[name]
+	return name, cfg, ok
+}
@@ -0,0 +1,81 @@
+package ratelim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProfile_Select(t *testing.T) {
+	secret := []byte("gateway-secret")
+	partner := LimiterConfig{Limit: 100, Burst: 100}
+	profiles := map[string]LimiterConfig{"partner": partner}
+	fromGateway := func(r *http.Request) bool {
+		return r.RemoteAddr == "10.0.0.1:1234"
+	}
+	tests := []struct {
+		name    string
+		profile TrustedProfile
+		value   string
+		remote  string
+		want    bool
+	}{
+		{"signed", TrustedProfile{Secret: secret}, SignProfile(secret, "partner"), "", true},
+		{"unsigned", TrustedProfile{Secret: secret}, "partner", "", false},
+		{"wrong secret", TrustedProfile{Secret: secret}, SignProfile([]byte("other"), "partner"), "", false},
+		{"tampered", TrustedProfile{Secret: secret}, "other" + SignProfile(secret, "partner")[7:], "", false},
+		{"trusted address", TrustedProfile{Trusted: fromGateway}, "partner", "10.0.0.1:1234", true},
+		{"untrusted address", TrustedProfile{Trusted: fromGateway}, "partner", "192.0.2.1:1234", false},
+		{
+			"signed from untrusted address", TrustedProfile{Secret: secret, Trusted: fromGateway},
+			SignProfile(secret, "partner"), "192.0.2.1:1234", false,
+		},
+		{"no trust configured", TrustedProfile{}, "partner", "", false},
+		{"unknown profile", TrustedProfile{Secret: secret}, SignProfile(secret, "unknown"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				p := tt.profile
+				p.Header, p.Profiles = "X-RateLimit-Profile", profiles
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Set(p.Header, tt.value)
+				if tt.remote != "" {
+					r.RemoteAddr = tt.remote
+				}
+				name, cfg, ok := p.Select(r)
+				if ok != tt.want || (ok && (name != "partner" || cfg != partner)) {
+					t.Errorf("Select() = %q, %+v, %t, want ok = %t", name, cfg, ok, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestMiddleware_Profile(t *testing.T) {
+	secret := []byte("gateway-secret")
+	m := NewMiddleware(
+		1, 1, func(r *http.Request) string {
+			return "everyone"
+		},
+	)
+	m.Profile = &TrustedProfile{
+		Header:   "X-RateLimit-Profile",
+		Secret:   secret,
+		Profiles: map[string]LimiterConfig{"partner": {Limit: 1, Burst: 5}},
+	}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-RateLimit-Profile", SignProfile(secret, "partner"))
+		if ok, _ := m.Allow(r); ok {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("%d requests with partner profile allowed, want 5", allowed)
+	}
+	if ok, _ := m.Allow(httptest.NewRequest(http.MethodGet, "/", nil)); !ok {
+		t.Error("request without profile rejected by exhausted partner limiter")
+	}
+}